@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	ozzolog "github.com/go-ozzo/ozzo-log"
+	"os"
+	logPackage "qBox/services/log"
+	"qBox/services/simulator"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+qbox-sim - симулятор теплосчётчиков для проверки qBox без приборов.
+Принимает TCP соединения, как шлюз RS-485/Ethernet, и отвечает на запросы приборов, подключённых к одной шине,
+см. пакет services/simulator.
+
+	qbox-sim -device=tem104 -listen=127.0.0.1:4001
+	qbox -type=tem104 127.0.0.1:4001
+*/
+func main() {
+	var listen, configPath, device, fault string
+	var number uint
+	var serial uint64
+	var clock, faultDelay time.Duration
+	var faultLength int
+
+	flag.Usage = func() {
+		_, _ = fmt.Fprintln(os.Stdout, "Симулятор теплосчётчиков для проверки qBox без приборов.")
+		_, _ = fmt.Fprintf(os.Stdout, "Использование: %s [-listen=адрес:порт] -device=тип [-number=N] [-fault=вид[:номер ответа]]\n", os.Args[0])
+		_, _ = fmt.Fprintf(os.Stdout, "или: %s [-listen=адрес:порт] -config=simulator.json\n", os.Args[0])
+		_, _ = fmt.Fprintf(os.Stdout, "Например: %s -device=tm3 -fault=checksum:2\n", os.Args[0])
+		_, _ = fmt.Fprintln(os.Stdout, "")
+		_, _ = fmt.Fprintln(os.Stdout, "Список доступных настроек:")
+		_, _ = fmt.Fprintln(os.Stdout, "")
+		flag.PrintDefaults()
+	}
+	flag.StringVar(&listen, "listen", "127.0.0.1:4001", "Адрес и порт, на которых симулятор принимает соединения")
+	flag.StringVar(&configPath, "config", "",
+		"Файл настройки симулятора (JSON): несколько приборов на шине, содержимое памяти и регистров, неисправности.\n\t"+
+			"Формат см. simulator.Config. Флаги device, number, serial, clock и fault при этом не используются")
+	flag.StringVar(&device, "device", "", "Тип прибора: "+strings.Join(simulator.Types, ", "))
+	flag.UintVar(&number, "number", 0, "Номер прибора, первичный адрес M-Bus или адрес modbus")
+	flag.Uint64Var(&serial, "serial", 0, "Заводской номер прибора. 0 - номер по умолчанию для типа прибора")
+	flag.DurationVar(&clock, "clock", 0, "Расхождение часов прибора с часами компьютера, например -5m")
+	flag.StringVar(&fault, "fault", "",
+		"Неисправность: truncate, checksum, echo, delay, eof. Через двоеточие - номер ответа с запуска (с 1),\n\t"+
+			"без номера неисправность вносится во все ответы. Например: checksum:2")
+	flag.DurationVar(&faultDelay, "fault-delay", 4*time.Second, "Задержка ответа для неисправности delay")
+	flag.IntVar(&faultLength, "fault-length", 0, "Длина оборванного ответа для неисправности truncate, 0 - половина ответа")
+	flag.Parse()
+
+	config, err := configure(configPath, device, number, serial, clock)
+	if err == nil && fault != "" && configPath == "" {
+		var parsed simulator.Fault
+		parsed, err = parseFault(fault, faultDelay, faultLength)
+		config.Faults = append(config.Faults, parsed)
+	}
+	var server *simulator.Server
+	if err == nil {
+		server, err = config.Server()
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(2)
+	}
+
+	logger := ozzolog.NewLogger()
+	target := ozzolog.NewConsoleTarget()
+	target.MaxLevel = ozzolog.LevelInfo
+	logger.Targets = append(logger.Targets, target)
+	if err = logger.Open(); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	defer logger.Close()
+	loggerService := logPackage.NewLoggerService(logger)
+	loggerService.Check("simulator")
+	server.Logger = &loggerService
+
+	for _, deviceConfig := range config.Devices {
+		loggerService.Info("Прибор %s", describe(deviceConfig))
+	}
+	if err = server.ListenAndServe(listen); err != nil {
+		loggerService.Error(err.Error())
+		logger.Close()
+		os.Exit(1)
+	}
+}
+
+func configure(configPath string, device string, number uint, serial uint64, clock time.Duration) (simulator.Config, error) {
+	if configPath != "" {
+		return simulator.LoadConfig(configPath)
+	}
+	if device == "" {
+		return simulator.Config{}, fmt.Errorf("не задан тип прибора (флаг device) или файл настройки (флаг config)")
+	}
+	if number > 0xFF || serial > 0xFFFFFFFF {
+		return simulator.Config{}, fmt.Errorf("номер прибора не больше 255, заводской номер не больше %d", uint32(0xFFFFFFFF))
+	}
+	deviceNumber := byte(number)
+	return simulator.Config{Devices: []simulator.DeviceConfig{{
+		Type:   device,
+		Number: &deviceNumber,
+		Serial: uint32(serial),
+		Clock:  simulator.Duration(clock),
+	}}}, nil
+}
+
+// Неисправность "вид[:номер ответа]"
+func parseFault(value string, delay time.Duration, length int) (simulator.Fault, error) {
+	fault := simulator.Fault{Delay: simulator.Duration(delay), Length: length}
+	parts := strings.SplitN(value, ":", 2)
+	fault.Kind = simulator.FaultKind(parts[0])
+	if len(parts) == 2 {
+		var err error
+		if fault.Response, err = strconv.Atoi(parts[1]); err != nil {
+			return fault, fmt.Errorf("некорректный номер ответа неисправности: %s", parts[1])
+		}
+	}
+	return fault, nil
+}
+
+func describe(config simulator.DeviceConfig) string {
+	description := config.Type
+	if config.Number != nil {
+		description += " № " + strconv.Itoa(int(*config.Number))
+	}
+	if config.Serial != 0 {
+		description += ", заводской номер " + strconv.FormatUint(uint64(config.Serial), 10)
+	}
+	if config.Clock != 0 {
+		description += ", часы " + time.Duration(config.Clock).String()
+	}
+	return description
+}
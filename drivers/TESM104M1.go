@@ -1,6 +1,7 @@
 package drivers
 
 import (
+	"context"
 	"errors"
 	"qBox/models"
 	"qBox/services/log"
@@ -17,20 +18,29 @@ type TESMART01 struct {
 	systemCount   int // количество активных систем
 }
 
+func init() {
+	Register("tesmart01", func() models.IDeviceDriver { return new(TESMART01) }, Metadata{
+		Title:        "ТЭСМАРТ.01 (ТЭМ-104-1, РФ)",
+		Protocol:     "ТЭМ",
+		Manufacturer: "ТЭМ-прибор",
+	})
+}
+
 // функция чтения 2К памяти
 
-func (tem *TESMART01) read2K(hi byte, lo byte, theSize byte) ([]byte, error) {
+func (tem *TESMART01) read2K(ctx context.Context, hi byte, lo byte, theSize byte) ([]byte, error) {
 	var command []byte
 	command = []byte{0x55, tem.counterNumber, ToNotByte(tem.counterNumber), 0x0F, 0x01, 0x03, hi, lo, theSize}
 	request := net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
 	request.ControlFunction = tem.checkFrame
 	request.SecondsReadTimeout = 5
-	return tem.network.RunIO(request)
+	return tem.network.RunIO(ctx, request)
 }
 
 // Реализация интерфейса IDeviceDriver::Init
 // инициализация прибора
-func (tem *TESMART01) Init(counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+func (tem *TESMART01) Init(ctx context.Context, counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+	*tem = TESMART01{} // см. models.IDeviceDriver::Init
 
 	var command []byte
 	var response []byte
@@ -50,7 +60,7 @@ func (tem *TESMART01) Init(counterNumber byte, network *net.Network, logger *log
 	request := net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
 	request.ControlFunction = tem.checkFrame
 	request.SecondsReadTimeout = 5
-	response, err = tem.network.RunIO(request)
+	response, err = tem.network.RunIO(ctx, request)
 	for err != nil {
 		return err
 	}
@@ -61,7 +71,7 @@ func (tem *TESMART01) Init(counterNumber byte, network *net.Network, logger *log
 	logger.Debug("Получено: %s", string(response[6:13])) // наименование прибора
 
 	// запрос на получение к-ва систем и конфигурации
-	response, err = tem.read2K(0x00, 0x00, 0x07)
+	response, err = tem.read2K(ctx, 0x00, 0x00, 0x07)
 	for err != nil {
 		return err
 	}
@@ -81,7 +91,7 @@ func (tem *TESMART01) Init(counterNumber byte, network *net.Network, logger *log
 	}
 
 	// запрос на чтение заводского номера прибора 4 байта и типа флэш памяти 28 байт
-	response, err = tem.read2K(0x01, 0x52, 0x20)
+	response, err = tem.read2K(ctx, 0x01, 0x52, 0x20)
 	for err != nil {
 		return err
 	}
@@ -94,7 +104,7 @@ func (tem *TESMART01) Init(counterNumber byte, network *net.Network, logger *log
 }
 
 // Реализация интерфейса IDeviceDriver::Read
-func (tem *TESMART01) Read() (*models.DataDevice, error) {
+func (tem *TESMART01) Read(ctx context.Context) (*models.DataDevice, error) {
 	tem.logger.Info("Чтение текущих данных")
 
 	var response []byte
@@ -103,7 +113,7 @@ func (tem *TESMART01) Read() (*models.DataDevice, error) {
 	tem.data.AddNewSystem(1)
 	tem.data.Systems[0].Status = true
 
-	response, err = tem.read2K(0x02, 0x00, 0x68)
+	response, err = tem.read2K(ctx, 0x02, 0x00, 0x68)
 	for err != nil {
 		return &tem.data, err
 	}
@@ -119,7 +129,7 @@ func (tem *TESMART01) Read() (*models.DataDevice, error) {
 	// разбито на два запроса	от 0x02 0x88 L-0x48 (72)
 	// запрос на чтение G 79 байт
 
-	response, _ = tem.read2K(0x02, 0x88, 0x48)
+	response, err = tem.read2K(ctx, 0x02, 0x88, 0x48)
 	for err != nil {
 		return &tem.data, err
 	}
@@ -131,7 +141,7 @@ func (tem *TESMART01) Read() (*models.DataDevice, error) {
 
 	// запрос на чтение V и M, 96 байт (Float по 6 шт.)
 
-	response, _ = tem.read2K(0x03, 0x00, 0x60)
+	response, err = tem.read2K(ctx, 0x03, 0x00, 0x60)
 	for err != nil {
 		return &tem.data, err
 	}
@@ -142,7 +152,7 @@ func (tem *TESMART01) Read() (*models.DataDevice, error) {
 	tem.data.Systems[0].M2 = float64(float32(tem.readLongFrom(response, 0x06+0x4C)) + tem.readFloatFrom(response, 0x06+0x34))
 
 	// запрос на чтение Q, 56 байт
-	response, _ = tem.read2K(0x03, 0x60, 0x38)
+	response, err = tem.read2K(ctx, 0x03, 0x60, 0x38)
 	for err != nil {
 		return &tem.data, err
 	}
@@ -157,7 +167,7 @@ func (tem *TESMART01) Read() (*models.DataDevice, error) {
 	// 0x404-0x41B время ошибки dT системы 1, 2, 3, 4, 5, 6
 	// 0x404-0x41B время ошибки Тех.неиспр. системы 1, 2, 3, 4, 5, 6
 
-	response, _ = tem.read2K(0x04, 0x00, 0x1C)
+	response, err = tem.read2K(ctx, 0x04, 0x00, 0x1C)
 	for err != nil {
 		return &tem.data, err
 	}
@@ -169,7 +179,7 @@ func (tem *TESMART01) Read() (*models.DataDevice, error) {
 	tem.data.TimeRequest = time.Now()
 
 	// читаем время на приборе
-	response, _ = tem.read2K(0x04, 0x82, 0x0C)
+	response, err = tem.read2K(ctx, 0x04, 0x82, 0x0C)
 	for err != nil {
 		return &tem.data, err
 	}
@@ -0,0 +1,247 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/npat-efault/crc16"
+	"qBox/drivers/mbus"
+	"qBox/models"
+	"qBox/services/log"
+	"qBox/services/net"
+	"strings"
+	"time"
+)
+
+// Значение флага type, при котором драйвер определяется по ответам прибора
+const Auto = "auto"
+
+/**
+Ответы на команду идентификации 0000h протокола ТЭМ, описанные в протоколах, и драйверы, которые им соответствуют.
+TEM-104M - протокол ТЭМ-104М, п. 3.1.
+*/
+var temIdentities = map[string]string{
+	"TEM-104M": "tem104m",
+}
+
+/**
+Драйверы, которые проверяются при наименовании, не описанном в протоколах. Init этих драйверов сам сверяет
+ответ на идентификацию: TSM-104 у tesmart01, ТЕМ-101 (cp1251, так отвечает ТЭМ-104К) у tem104k.
+Инициализация остальных драйверов ТЭМ удаётся на любом приборе ТЭМ, поэтому они не проверяются.
+*/
+var temCandidates = []string{"tesmart01", "tem104k"}
+
+/**
+Приборы M-Bus, для которых есть собственные драйверы, по коду производителя, версии и среде из заголовка ответа.
+SKU-02-K - ответ прибора, приведённый в драйвере sku02k (AXI, версия 06h, среда 0Dh).
+Остальные приборы M-Bus опрашиваются универсальным драйвером mbus.
+*/
+var mbusDrivers = []struct {
+	manufacturer string
+	version      byte
+	medium       byte
+	driver       string
+}{
+	{"AXI", 0x06, 0x0D, "sku02k"},
+}
+
+// Тип прибора в заголовке ответа SKU-02, см. createRequest
+const sku02Type = 0x0002
+
+/**
+Определение драйвера по ответам прибора (-type=auto).
+Прибору по очереди отправляются запросы, которые только читают данные и не изменяют настроек:
+идентификация протокола ТЭМ (0000h), SND_NKE M-Bus, чтение регистров modbus EF04h (ИСТОК-ТМ3)
+и запрос текущих данных SKU-02 (68h, команда 20h). Отсутствие ответа в течение Timeout означает,
+что прибор этот протокол не поддерживает, и проверяется следующий протокол.
+Прибор ТЭМ с наименованием, не описанным в протоколах, проверяется инициализацией драйверов temCandidates.
+Прибор, ответивший по протоколу, но не опознанный, - ошибка: запросы других протоколов ему не отправляются.
+*/
+type Detector struct {
+	Network *net.Network
+	Logger  *log.LoggerService
+	Timeout time.Duration // Время ожидания ответа на каждый запрос
+}
+
+func (detector Detector) Detect(ctx context.Context, number byte) (*models.Detection, error) {
+	probes := []func(ctx context.Context, number byte) (*models.Detection, error){
+		detector.probeTem,
+		detector.probeMBus,
+		detector.probeModbus,
+		detector.probeSKU02,
+	}
+	for _, probe := range probes {
+		detection, err := probe(ctx, number)
+		if err != nil || detection != nil {
+			return detection, err
+		}
+	}
+	return nil, fmt.Errorf("прибор № %d не ответил ни по одному из протоколов: ТЭМ, M-Bus, modbus, SKU-02", number)
+}
+
+/**
+Определение прибора M-Bus, выбранного по вторичному адресу. Другие протоколы вторичную адресацию не поддерживают.
+*/
+func (detector Detector) DetectSecondary(ctx context.Context, address mbus.SecondaryAddress) (*models.Detection, error) {
+	err := mbus.Select(ctx, detector.Network, address, detector.Logger)
+	if err != nil {
+		return nil, err
+	}
+	detection, err := detector.readMBusHeader(ctx, mbus.AddressNetwork)
+	if err == nil && detection == nil {
+		err = fmt.Errorf("прибор с вторичным адресом %s не ответил на запрос данных", address.String())
+	}
+	return detection, err
+}
+
+// Протокол ТЭМ: команда идентификации 0000h, затем версия ПО командой 0001h
+func (detector Detector) probeTem(ctx context.Context, number byte) (*models.Detection, error) {
+	detector.Logger.Info("Проверка протокола ТЭМ")
+	tem := Tem104{logger: detector.Logger}
+	command := []byte{0x55, number, ToNotByte(number), 0x00, 0x00, 0x00}
+	response, err := detector.Network.Exchange(ctx, append(command, tem.calculateCheckSum(command)), detector.Timeout, tem.checkFrame)
+	if err != nil || !tem.checkFrame(response) {
+		return nil, err
+	}
+	identity := strings.TrimRight(string(response[6:6+int(response[5])]), "\x00 ")
+	detector.Logger.Info("Прибор ответил на идентификацию ТЭМ: %X", identity)
+
+	name, ok := temIdentities[identity]
+	if !ok {
+		name, err = detector.tryTemCandidates(ctx, number)
+		if err != nil {
+			return nil, err
+		}
+		if name == "" {
+			return nil, fmt.Errorf("прибор ответил по протоколу ТЭМ наименованием %q (%X), драйверы %s его не опознали",
+				identity, identity, strings.Join(temCandidates, ", "))
+		}
+	}
+	detection := detector.detection(name)
+
+	command = []byte{0x55, number, ToNotByte(number), 0x00, 0x01, 0x00}
+	response, err = detector.Network.Exchange(ctx, append(command, tem.calculateCheckSum(command)), detector.Timeout, tem.checkFrame)
+	if err != nil {
+		return detection, err
+	}
+	if tem.checkFrame(response) {
+		detection.Firmware = strings.TrimRight(string(response[6:6+int(response[5])]), "\x00 ")
+	} else {
+		detector.Logger.Info("Прибор не сообщил версию ПО")
+	}
+	return detection, nil
+}
+
+// Проверка прибора ТЭМ инициализацией драйверов temCandidates. Возвращает первый драйвер, опознавший прибор, или ""
+func (detector Detector) tryTemCandidates(ctx context.Context, number byte) (string, error) {
+	for _, name := range temCandidates {
+		driver, err := New(name)
+		if err != nil {
+			continue // драйвер не входит в сборку
+		}
+		detector.Logger.Info("Проверка драйвера %s", name)
+		err = driver.Init(ctx, number, detector.Network, detector.Logger)
+		if err == nil {
+			return name, nil
+		}
+		if ctx.Err() != nil {
+			return "", err
+		}
+		detector.Logger.Info("Драйвер %s не опознал прибор: %s", name, err.Error())
+	}
+	return "", nil
+}
+
+// M-Bus: SND_NKE, затем заголовок ответа REQ_UD2. Драйвер выбирается по заголовку, см. mbusDrivers
+func (detector Detector) probeMBus(ctx context.Context, number byte) (*models.Detection, error) {
+	detector.Logger.Info("Проверка протокола M-Bus")
+	response, err := detector.Network.Exchange(ctx, mbus.ShortFrame(mbus.ControlSndNke, number), detector.Timeout, isMBusAck)
+	if err != nil || !isMBusAck(response) {
+		return nil, err
+	}
+	detection, err := detector.readMBusHeader(ctx, number)
+	if err == nil && detection == nil {
+		err = fmt.Errorf("прибор M-Bus № %d подтвердил SND_NKE, но не ответил на запрос данных", number)
+	}
+	return detection, err
+}
+
+func (detector Detector) readMBusHeader(ctx context.Context, address byte) (*models.Detection, error) {
+	response, err := detector.Network.Exchange(ctx, mbus.ShortFrame(mbus.ControlReqUD2, address), detector.Timeout, func(response []byte) bool {
+		_, err := mbus.ParseFrame(response)
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	frame, err := mbus.ParseFrame(response)
+	if err != nil {
+		detector.Logger.Info("Ответ M-Bus не разобран: %s", err.Error())
+		return nil, nil
+	}
+
+	header := mbus.HeaderAddress(frame.Header)
+	detector.Logger.Info("Прибор M-Bus %s", header.String())
+	for _, known := range mbusDrivers {
+		if known.manufacturer == frame.Header.Manufacturer && known.version == frame.Header.Version && known.medium == frame.Header.Medium {
+			detection := detector.detection(known.driver)
+			detection.Firmware = fmt.Sprint(header.Version)
+			return detection, nil
+		}
+	}
+	detection := detector.detection("mbus")
+	detection.Model = fmt.Sprintf("%s, производитель %s, среда %02Xh", detection.Model, header.Manufacturer, header.Medium)
+	detection.Firmware = fmt.Sprint(header.Version)
+	return detection, nil
+}
+
+// Modbus RTU: чтение регистров заводского номера ИСТОК-ТМ3 (EF04h - EF07h)
+func (detector Detector) probeModbus(ctx context.Context, number byte) (*models.Detection, error) {
+	detector.Logger.Info("Проверка протокола modbus")
+	tm3 := TM3{logger: detector.Logger, number: number}
+	request := []byte{number, 0x03, 0xEF, 0x04, 0x00, 0x04}
+	request = append(request, intToLittleEndian(crc16.Checksum(crc16.Modbus, request))...)
+	complete := func(response []byte) bool { return len(response) >= 5 && tm3.checkResponse(0x03, response) }
+	response, err := detector.Network.Exchange(ctx, request, detector.Timeout, complete)
+	if err != nil || len(response) < 3 {
+		return nil, err
+	}
+	if response[0] == number && response[1] == 0x83 {
+		return nil, fmt.Errorf("прибор ответил по протоколу modbus исключением %d на чтение регистров ИСТОК-ТМ3, драйвер для него не известен", response[2])
+	}
+	if !complete(response) {
+		return nil, nil
+	}
+	return detector.detection("tm3"), nil
+}
+
+// SKU-02: запрос текущих данных, в заголовке ответа тип прибора и версия ПО. Драйвер sku02 - только для типа 0002h
+func (detector Detector) probeSKU02(ctx context.Context, number byte) (*models.Detection, error) {
+	detector.Logger.Info("Проверка протокола SKU-02")
+	sku := SKU02{logger: detector.Logger}
+	complete := func(response []byte) bool { return len(response) >= 10 && sku.checkFrame(response) }
+	response, err := detector.Network.Exchange(ctx, createRequest(0x20), detector.Timeout, complete)
+	if err != nil || !complete(response) {
+		return nil, err
+	}
+	detector.Logger.Info("Тип прибора SKU %X", response[4:6])
+	if deviceType := int(response[4])<<8 | int(response[5]); deviceType != sku02Type {
+		return nil, fmt.Errorf("прибор ответил по протоколу SKU-02 типом %04Xh, драйвер для него не известен", deviceType)
+	}
+	detection := detector.detection("sku02")
+	detection.Firmware = fmt.Sprint(ToFloat([4]byte{response[6], response[7], response[8], response[9]}))
+	return detection, nil
+}
+
+func (detector Detector) detection(name string) *models.Detection {
+	driver, err := Lookup(name)
+	if err != nil {
+		panic("drivers: драйвер для автоматического определения не зарегистрирован: " + name)
+	}
+	detector.Logger.Info("Определён драйвер %s (%s)", driver.Name, driver.Title)
+	return &models.Detection{Driver: driver.Name, Protocol: driver.Protocol, Model: driver.Title}
+}
+
+func isMBusAck(response []byte) bool {
+	return bytes.Equal(response, []byte{mbus.Ack})
+}
@@ -0,0 +1,105 @@
+package drivers_test
+
+import (
+	"context"
+	stdnet "net"
+	"qBox/drivers"
+	"qBox/drivers/drivertest"
+	"qBox/models"
+	"qBox/services/log"
+	"qBox/services/net"
+	"qBox/services/simulator"
+	"strings"
+	"testing"
+	"time"
+
+	ozzolog "github.com/go-ozzo/ozzo-log"
+)
+
+// Определение драйвера прибора, подключённого к симулятору шлюза
+func detect(t *testing.T, device simulator.Device) (*models.Detection, error) {
+	listener, err := stdnet.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &simulator.Server{Devices: []simulator.Device{device}}
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(func() { _ = listener.Close() })
+
+	transport, err := net.NewTransport(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := log.NewLoggerService(ozzolog.NewLogger())
+	network := net.NewNetwork(transport, logger)
+	defer network.Close()
+	detector := drivers.Detector{Network: network, Logger: &logger, Timeout: 200 * time.Millisecond}
+	return detector.Detect(context.Background(), 1)
+}
+
+func TestDetect(t *testing.T) {
+	mbusDevice := func(manufacturer string, version byte, medium byte) *simulator.MBusDevice {
+		return &simulator.MBusDevice{Address: 1, ID: 12345678, Manufacturer: manufacturer, Version: version, Medium: medium,
+			Telegrams: [][]byte{{0x04, 0x07, 0x40, 0xE2, 0x01, 0x00}}}
+	}
+	for _, test := range []struct {
+		name   string
+		device simulator.Device
+		driver string
+	}{
+		{"TEM-104M", simulator.NewTem104M(1, 0), "tem104m"},
+		// Наименование, не описанное в протоколах, опознаётся инициализацией драйверов: ТЕМ-101 в cp1251 - tem104k
+		{"ТЕМ-101", &simulator.TemDevice{Number: 1, Identity: []byte{0xD2, 0xC5, 0xCC, 0x2D, 0x31, 0x30, 0x31},
+			Version: []byte("v2.000"), Memory: map[uint16]simulator.Memory{simulator.TemMemory2K: {}}}, "tem104k"},
+		{"SKU-02-K", mbusDevice("AXI", 0x06, 0x0D), "sku02k"},
+		{"M-Bus", mbusDevice("SKM", 0x01, 0x04), "mbus"},
+		{"SKU-02", simulator.NewSKU02(0), "sku02"},
+	} {
+		detection, err := detect(t, test.device)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if detection.Driver != test.driver {
+			t.Errorf("%s: определён драйвер %s, ожидался %s", test.name, detection.Driver, test.driver)
+		}
+	}
+}
+
+// Прибор, ответивший по протоколу, но не опознанный, - ошибка, а не универсальный драйвер
+func TestDetectUnknown(t *testing.T) {
+	sku := simulator.NewSKU02(0)
+	sku.Type = 0x0005
+	for name, device := range map[string]simulator.Device{
+		"ТЭМ":    &simulator.TemDevice{Number: 1, Identity: []byte("TEM-104"), Version: []byte("1.00")},
+		"SKU-02": sku,
+	} {
+		if detection, err := detect(t, device); err == nil {
+			t.Errorf("%s: определён драйвер %s, ожидалась ошибка", name, detection.Driver)
+		}
+	}
+}
+
+// Заголовок ответа SKU-02-K, полученного от прибора (см. drivers.SKU02K), выбирает драйвер sku02k
+func TestDetectCapturedSKU02K(t *testing.T) {
+	transcript, err := drivertest.ParseTranscript(strings.NewReader(`
+# SKU-02-K, ответ прибора на REQ_UD2
+driver: sku02k
+number: 1
+> 5501FE000000AB
+> 1040014116
+< E5
+> 105B015C16
+< 68131368080272697100000907060DBA00000001FF0C024116
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := log.NewLoggerService(ozzolog.NewLogger())
+	network := net.NewNetwork(drivertest.NewTransport(transcript.Frames), logger)
+	detector := drivers.Detector{Network: network, Logger: &logger, Timeout: time.Second}
+	detection, err := detector.Detect(context.Background(), 1)
+	if err != nil || detection.Driver != "sku02k" || detection.Firmware != "6" {
+		t.Errorf("определение %+v (%v)", detection, err)
+	}
+}
@@ -0,0 +1,48 @@
+package drivertest
+
+import (
+	"context"
+	"fmt"
+	ozzolog "github.com/go-ozzo/ozzo-log"
+	"qBox/drivers"
+	"qBox/models"
+	"qBox/services/log"
+	"qBox/services/net"
+)
+
+/**
+Опрос записи обмена без линии связи: Init и Read драйвера transcript.Driver через Transport.
+Ошибкой также считается запрос, которого нет в записи, и запросы записи, которые драйвер не передал.
+Лог драйвера не ведётся. Драйверы должны быть зарегистрированы - пакеты драйверов импортирует вызывающий код.
+*/
+func Poll(ctx context.Context, transcript *Transcript) (*models.DataDevice, error) {
+	transport := NewTransport(transcript.Frames)
+	data, err := PollTransport(ctx, transcript.Driver, transcript.Number, transport)
+	if transportErr := transport.Err(); transportErr != nil {
+		return data, transportErr
+	}
+	if err != nil {
+		return data, err
+	}
+	if remaining := transport.Remaining(); remaining > 0 {
+		return data, fmt.Errorf("драйвер не передал %d запросов записи обмена", remaining)
+	}
+	return data, nil
+}
+
+// Опрос прибора драйвером driver через transport, например net.ReplayTransport. Лог драйвера не ведётся
+func PollTransport(ctx context.Context, driver string, number byte, transport net.Transport) (*models.DataDevice, error) {
+	deviceDriver, err := drivers.New(driver)
+	if err != nil {
+		return nil, err
+	}
+	logger := log.NewLoggerService(ozzolog.NewLogger())
+	network := net.NewNetwork(transport, logger)
+	defer network.Close()
+
+	err = deviceDriver.Init(ctx, number, network, &logger)
+	if err != nil {
+		return nil, err
+	}
+	return deviceDriver.Read(ctx)
+}
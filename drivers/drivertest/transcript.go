@@ -0,0 +1,130 @@
+package drivertest
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Направление данных в записи обмена
+type Direction byte
+
+const (
+	Sent     Direction = '>' // Запрос, переданный драйвером прибору
+	Received Direction = '<' // Ответ прибора, полученный одним чтением
+)
+
+// Данные, переданные одной записью или полученные одним чтением
+type Frame struct {
+	Direction Direction
+	Bytes     []byte
+}
+
+/**
+Запись обмена драйвера с прибором. Текстовый файл:
+
+	# ТЭМ-104, транскрипт симулятора qbox-sim -device=tem104
+	driver: tem104
+	number: 0
+	> 5500FF0F010300F817
+	< AA00FF0F0103...
+
+Строки "#" - комментарии, "имя: значение" - параметры опроса, ">" - запрос драйвера, "<" - данные, полученные
+одним чтением. Если после запроса нет ответа, чтение завершается по таймауту. Байты записываются
+в шестнадцатеричном виде, пробелы допускаются.
+*/
+type Transcript struct {
+	Driver string // Имя драйвера, см. drivers.Register
+	Number byte   // Номер прибора
+	Frames []Frame
+}
+
+func LoadTranscript(path string) (*Transcript, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	transcript, err := ParseTranscript(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return transcript, nil
+}
+
+func ParseTranscript(reader io.Reader) (*Transcript, error) {
+	transcript := &Transcript{}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if err := transcript.parseLine(text); err != nil {
+			return nil, fmt.Errorf("строка %d: %w", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if transcript.Driver == "" {
+		return nil, fmt.Errorf("не задан драйвер (driver:)")
+	}
+	return transcript, nil
+}
+
+func (transcript *Transcript) parseLine(text string) error {
+	direction := Direction(text[0])
+	if direction == Sent || direction == Received {
+		bytes, err := hex.DecodeString(strings.ReplaceAll(text[1:], " ", ""))
+		if err != nil {
+			return err
+		}
+		if len(bytes) == 0 {
+			return fmt.Errorf("нет данных")
+		}
+		transcript.Frames = append(transcript.Frames, Frame{Direction: direction, Bytes: bytes})
+		return nil
+	}
+
+	parts := strings.SplitN(text, ":", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("ожидается \">\", \"<\" или \"имя: значение\": %q", text)
+	}
+	value := strings.TrimSpace(parts[1])
+	switch strings.TrimSpace(parts[0]) {
+	case "driver":
+		transcript.Driver = value
+	case "number":
+		number, err := strconv.ParseUint(value, 0, 8)
+		if err != nil {
+			return fmt.Errorf("некорректный номер прибора %q", value)
+		}
+		transcript.Number = byte(number)
+	default:
+		return fmt.Errorf("неизвестный параметр %q", parts[0])
+	}
+	return nil
+}
+
+// Запись обмена в текстовом виде, см. Transcript. comment выводится в начале файла строками "#"
+func (transcript *Transcript) Write(writer io.Writer, comment string) error {
+	var builder strings.Builder
+	if comment != "" {
+		for _, line := range strings.Split(comment, "\n") {
+			builder.WriteString(strings.TrimSpace("# "+line) + "\n")
+		}
+	}
+	builder.WriteString("driver: " + transcript.Driver + "\n")
+	builder.WriteString("number: " + strconv.Itoa(int(transcript.Number)) + "\n")
+	for _, frame := range transcript.Frames {
+		builder.WriteString(string(frame.Direction) + " " + strings.ToUpper(hex.EncodeToString(frame.Bytes)) + "\n")
+	}
+	_, err := io.WriteString(writer, builder.String())
+	return err
+}
@@ -0,0 +1,134 @@
+package drivertest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+/**
+Транспорт, воспроизводящий запись обмена (net.Transport). Каждый запрос драйвера сверяется со следующим
+запросом записи, в ответ на чтение передаются следующие полученные данные. Если до следующего запроса
+данных нет, чтение сразу завершается по таймауту - ожидания, как на линии, нет.
+Первое расхождение с записью сохраняется и возвращается Err, после него все операции завершаются ошибкой.
+*/
+type Transport struct {
+	frames []Frame
+	next   int
+	opened bool
+	err    error
+	mutex  sync.Mutex
+}
+
+func NewTransport(frames []Frame) *Transport {
+	return &Transport{frames: append([]Frame(nil), frames...)}
+}
+
+func (transport *Transport) Open(ctx context.Context) error {
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	transport.opened = true
+	return transport.err
+}
+
+func (transport *Transport) Close() error {
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+	transport.opened = false
+	return nil
+}
+
+func (transport *Transport) Read(b []byte) (int, error) {
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+	if transport.err != nil {
+		return 0, transport.err
+	}
+	if transport.next >= len(transport.frames) || transport.frames[transport.next].Direction != Received {
+		return 0, os.ErrDeadlineExceeded
+	}
+	frame := &transport.frames[transport.next]
+	n := copy(b, frame.Bytes)
+	if n < len(frame.Bytes) {
+		// Остаток передаётся следующим чтением
+		frame.Bytes = frame.Bytes[n:]
+	} else {
+		transport.next++
+	}
+	return n, nil
+}
+
+func (transport *Transport) Write(b []byte) (int, error) {
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+	if transport.err != nil {
+		return 0, transport.err
+	}
+	if !transport.opened {
+		transport.err = fmt.Errorf("запрос %X до открытия соединения", b)
+		return 0, transport.err
+	}
+	// Непрочитанный драйвером ответ пропускается: на линии он также был бы потерян
+	for transport.next < len(transport.frames) && transport.frames[transport.next].Direction == Received {
+		transport.next++
+	}
+	if transport.next >= len(transport.frames) {
+		transport.err = fmt.Errorf("запрос %X после окончания записи обмена", b)
+		return 0, transport.err
+	}
+	if expected := transport.frames[transport.next].Bytes; !bytes.Equal(expected, b) {
+		transport.err = fmt.Errorf("запрос %d: %X, в записи обмена %X", transport.sent()+1, b, expected)
+		return 0, transport.err
+	}
+	transport.next++
+	return len(b), nil
+}
+
+func (transport *Transport) SetReadDeadline(time.Time) error {
+	return nil
+}
+
+func (transport *Transport) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+func (transport *Transport) String() string {
+	return "Запись обмена"
+}
+
+// Первое расхождение обмена с записью
+func (transport *Transport) Err() error {
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+	return transport.err
+}
+
+// Запросы записи, которые драйвер не передал
+func (transport *Transport) Remaining() int {
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+	remaining := 0
+	for _, frame := range transport.frames[transport.next:] {
+		if frame.Direction == Sent {
+			remaining++
+		}
+	}
+	return remaining
+}
+
+// Количество запросов, уже сверенных с записью
+func (transport *Transport) sent() int {
+	count := 0
+	for _, frame := range transport.frames[:transport.next] {
+		if frame.Direction == Sent {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,86 @@
+package drivertest
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+const transcriptText = `# пример
+driver: tem104
+number: 0x01
+> 5501FE
+< AA01
+< FE00
+> 5502FD
+`
+
+func TestParseTranscript(t *testing.T) {
+	transcript, err := ParseTranscript(strings.NewReader(transcriptText))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transcript.Driver != "tem104" || transcript.Number != 1 || len(transcript.Frames) != 4 {
+		t.Fatalf("разбор записи: %+v", transcript)
+	}
+
+	var builder strings.Builder
+	if err = transcript.Write(&builder, "пример"); err != nil {
+		t.Fatal(err)
+	}
+	if builder.String() != strings.Replace(transcriptText, "0x01", "1", 1) {
+		t.Errorf("запись отличается от исходной:\n%s", builder.String())
+	}
+
+	for _, text := range []string{"> 55", "driver: tem104\n> 5G", "driver: tem104\nspeed: 9600"} {
+		if _, err = ParseTranscript(strings.NewReader(text)); err == nil {
+			t.Errorf("%q: ожидалась ошибка разбора", text)
+		}
+	}
+}
+
+func TestTransport(t *testing.T) {
+	transcript, err := ParseTranscript(strings.NewReader(transcriptText))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := NewTransport(transcript.Frames)
+	if err = transport.Open(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	buffer := make([]byte, 1)
+	if _, err = transport.Write([]byte{0x55, 0x01, 0xFE}); err != nil {
+		t.Fatal(err)
+	}
+	// Ответ передаётся частями по размеру буфера, затем - таймаут до следующего запроса
+	var response []byte
+	for i := 0; i < 4; i++ {
+		n, err := transport.Read(buffer)
+		if err != nil {
+			t.Fatalf("чтение %d: %v", i+1, err)
+		}
+		response = append(response, buffer[:n]...)
+	}
+	if string(response) != "\xAA\x01\xFE\x00" {
+		t.Errorf("получено %X", response)
+	}
+	if _, err = transport.Read(buffer); !os.IsTimeout(err) {
+		t.Errorf("ожидался таймаут, получено %v", err)
+	}
+	if transport.Remaining() != 1 {
+		t.Errorf("осталось запросов %d, ожидался 1", transport.Remaining())
+	}
+
+	// Запрос, которого нет в записи, - ошибка всех последующих операций
+	if _, err = transport.Write([]byte{0x55, 0x03, 0xFC}); err == nil {
+		t.Fatal("ожидалась ошибка запроса")
+	}
+	if _, err = transport.Write([]byte{0x55, 0x02, 0xFD}); err == nil || transport.Err() != err {
+		t.Errorf("ожидалась первая ошибка, получено %v", err)
+	}
+	// Исходная запись не изменяется
+	if len(transcript.Frames[1].Bytes) != 2 {
+		t.Errorf("запись обмена изменена транспортом: %X", transcript.Frames[1].Bytes)
+	}
+}
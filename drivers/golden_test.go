@@ -0,0 +1,164 @@
+package drivers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"qBox/drivers"
+	"qBox/drivers/drivertest"
+	_ "qBox/drivers/mbusgeneric"
+	_ "qBox/drivers/skm2"
+	_ "qBox/drivers/skm2m"
+	_ "qBox/drivers/tem104k"
+	_ "qBox/drivers/tem104m"
+	"qBox/services/net"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+/**
+Записи обмена testdata/<драйвер>.txt и ожидаемые результаты опроса testdata/<драйвер>.json.
+Записи - транскрипты симулятора qbox-sim, а не обмен с приборами: тест защищает разбор ответов от случайных
+изменений, но не подтверждает его на приборе. Ответы, полученные от приборов, проверяются в captured_test.go.
+Дробные значения в результатах записываются с точностью float32, см. roundFloats.
+После намеренного изменения разбора ответов ожидаемые результаты перезаписываются:
+
+	go test ./drivers -run TestGolden -update
+*/
+var update = flag.Bool("update", false, "перезаписать ожидаемые результаты testdata/*.json")
+
+func TestMain(m *testing.M) {
+	// Драйверы разбирают время прибора в местном часовом поясе
+	time.Local = time.UTC
+	os.Exit(m.Run())
+}
+
+func TestGolden(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("нет записей обмена в testdata")
+	}
+	for _, path := range paths {
+		path := path
+		t.Run(strings.TrimSuffix(filepath.Base(path), ".txt"), func(t *testing.T) {
+			transcript, err := drivertest.LoadTranscript(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := drivertest.Poll(context.Background(), transcript)
+			if err != nil {
+				t.Fatalf("опрос %s: %v", transcript.Driver, err)
+			}
+			data.TimeRequest = time.Time{}
+			actual, err := json.MarshalIndent(data, "", "\t")
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual = append(roundFloats(actual), '\n')
+
+			golden := strings.TrimSuffix(path, ".txt") + ".json"
+			if *update {
+				if err = ioutil.WriteFile(golden, actual, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			expected, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (ожидаемый результат создаётся флагом -update)", err)
+			}
+			if !bytes.Equal(actual, expected) {
+				t.Errorf("результат опроса отличается от %s:\n%s", golden, actual)
+			}
+		})
+	}
+}
+
+// Запись обмена в файл (флаг capture) и её воспроизведение (флаг replay) дают тот же результат опроса
+func TestCaptureReplay(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		path := path
+		t.Run(strings.TrimSuffix(filepath.Base(path), ".txt"), func(t *testing.T) {
+			transcript, err := drivertest.LoadTranscript(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var file bytes.Buffer
+			capture, err := net.NewCaptureTransport(drivertest.NewTransport(transcript.Frames), &file, net.CaptureHeader{
+				Driver: transcript.Driver,
+				Number: transcript.Number,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			captured, err := drivertest.PollTransport(context.Background(), transcript.Driver, transcript.Number, capture)
+			if err != nil {
+				t.Fatalf("опрос с записью обмена: %v", err)
+			}
+
+			header, events, err := net.ReadCapture(&file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if header.Driver != transcript.Driver || header.Number != transcript.Number {
+				t.Errorf("заголовок записи обмена: %+v", header)
+			}
+			replay := net.NewReplayTransport(events, nil)
+			replayed, err := drivertest.PollTransport(context.Background(), header.Driver, header.Number, replay)
+			if err != nil {
+				t.Fatalf("воспроизведение записи обмена: %v", err)
+			}
+			if replay.Remaining() != 0 {
+				t.Errorf("не воспроизведено событий записи обмена: %d", replay.Remaining())
+			}
+
+			captured.TimeRequest, replayed.TimeRequest = time.Time{}, time.Time{}
+			expected, _ := json.Marshal(captured)
+			actual, _ := json.Marshal(replayed)
+			if !bytes.Equal(expected, actual) {
+				t.Errorf("результат воспроизведения отличается от результата опроса:\n%s\n%s", actual, expected)
+			}
+		})
+	}
+}
+
+// Для каждого зарегистрированного драйвера есть запись обмена
+func TestGoldenCoverage(t *testing.T) {
+	for _, driver := range drivers.List() {
+		if _, err := os.Stat(filepath.Join("testdata", driver.Name+".txt")); err != nil {
+			t.Errorf("драйвер %s: нет записи обмена testdata/%s.txt", driver.Name, driver.Name)
+		}
+	}
+}
+
+var jsonFloat = regexp.MustCompile(`(?m)(^\s*|: |\[)(-?\d+\.\d+(?:[eE][-+]?\d+)?)`)
+
+/**
+Запись дробных чисел JSON, которые точно представимы во float32, кратчайшей записью, дающей то же значение float32.
+Драйверы складывают и хранят значения во float32, и цифры сверх его точности (1234.5670166015625 вместо 1234.567) -
+погрешность представления, а не результат разбора.
+*/
+func roundFloats(data []byte) []byte {
+	return jsonFloat.ReplaceAllFunc(data, func(match []byte) []byte {
+		parts := jsonFloat.FindSubmatch(match)
+		value, err := strconv.ParseFloat(string(parts[2]), 64)
+		if err != nil || float64(float32(value)) != value {
+			return match // значение float64, точнее float32
+		}
+		return append(append([]byte(nil), parts[1]...), strconv.FormatFloat(value, 'f', -1, 32)...)
+	})
+}
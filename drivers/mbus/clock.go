@@ -0,0 +1,50 @@
+package mbus
+
+import (
+	"context"
+	"qBox/services/log"
+	"qBox/services/net"
+	"time"
+)
+
+const CIDataSend byte = 0x51 // Передача данных ведомому (SND_UD) с записями переменной структуры
+
+/**
+Дата и время в формате F (EN 13757-3, приложение A), младший байт первым:
+минуты, часы, день и младшие биты года, месяц и старшие биты года. Секунды в формате F не передаются.
+*/
+func EncodeTimeF(moment time.Time) []byte {
+	year := moment.Year() - 2000
+	return []byte{
+		byte(moment.Minute()),
+		byte(moment.Hour()),
+		byte(moment.Day()) | byte(year&0x07)<<5,
+		byte(moment.Month()) | byte(year&0x78)<<1,
+	}
+}
+
+// Кадр установки времени: SND_UD с записью DIF 04h VIF 6Dh (дата и время, тип F)
+func SetTimeFrame(address byte, moment time.Time) []byte {
+	return LongFrame(ControlSndUD, address, CIDataSend, append([]byte{0x04, 0x6D}, EncodeTimeF(moment)...))
+}
+
+/**
+Установка времени прибора командой SND_UD. moment - время по часам компьютера на момент вызова.
+Формат F не содержит секунд, поэтому записывается начало следующей за moment минуты, и команда отправляется
+в эту минуту, иначе часы прибора отстанут на прошедшие секунды. Ожидание прерывается вместе с ctx.
+*/
+func SetTime(ctx context.Context, network *net.Network, address byte, moment time.Time, logger *log.LoggerService) error {
+	minute := moment.Truncate(time.Minute).Add(time.Minute)
+	logger.Info("Ожидание начала минуты %s для установки времени", minute.Format("15:04"))
+	select {
+	case <-ctx.Done():
+		return &net.InterruptedError{Err: ctx.Err()}
+	case <-time.After(time.Until(minute)):
+	}
+
+	logger.Info("Установка времени на приборе %s", minute.Format("02.01.2006 15:04"))
+	request := net.PrepareRequest(SetTimeFrame(address, minute))
+	request.ControlFunction = Checks{Logger: logger}.CheckAck
+	_, err := network.RunIO(ctx, request)
+	return err
+}
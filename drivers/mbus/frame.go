@@ -0,0 +1,181 @@
+package mbus
+
+import (
+	"errors"
+	"fmt"
+	"qBox/services/log"
+)
+
+// Поле управления (C) канального уровня EN 60870-5-2
+const (
+	ControlSndNke byte = 0x40 // Инициализация ведомого
+	ControlReqUD2 byte = 0x5B // Запрос данных класса 2, FCB = 0
+	ControlRspUD  byte = 0x08 // Ответ с данными
+	ControlFCB    byte = 0x20 // Бит счёта кадров (FCB)
+
+	AddressBroadcast byte = 0xFE // Широковещательный адрес, на который отвечают все приборы
+	AddressNetwork   byte = 0xFD // Адрес для обращения к прибору, выбранному по вторичному адресу
+
+	Ack byte = 0xE5 // Подтверждение приёма (Single Character)
+)
+
+// Поле CI прикладного уровня
+const (
+	CIResponseLong  byte = 0x72 // Ответ с данными переменной структуры и полным заголовком
+	CIResponseShort byte = 0x7A // Ответ с данными переменной структуры и коротким заголовком
+	CIResponseNone  byte = 0x78 // Ответ с данными переменной структуры без заголовка
+)
+
+/**
+Контрольная сумма M-Bus - сумма байтов, урезанная до одного байта
+*/
+func Checksum(bytes []byte) byte {
+	var sum byte
+	for _, b := range bytes {
+		sum += b
+	}
+	return sum
+}
+
+// Короткий кадр 10h C A CS 16h
+func ShortFrame(control byte, address byte) []byte {
+	return []byte{0x10, control, address, Checksum([]byte{control, address}), 0x16}
+}
+
+// Длинный кадр 68h L L 68h C A CI data CS 16h
+func LongFrame(control byte, address byte, ci byte, data []byte) []byte {
+	body := append([]byte{control, address, ci}, data...)
+	frame := []byte{0x68, byte(len(body)), byte(len(body)), 0x68}
+	frame = append(frame, body...)
+	return append(frame, Checksum(body), 0x16)
+}
+
+/**
+Заголовок прикладного уровня ответа RSP_UD
+*/
+type Header struct {
+	ID           uint32 // Идентификационный (серийный) номер, 8 цифр BCD
+	Manufacturer string // Код производителя из трёх латинских букв, например KAM
+	Version      byte   // Версия (поколение) прибора
+	Medium       byte   // Измеряемая среда: 0x04 - тепло (обратный трубопровод), 0x0C - тепло (подающий), 0x07 - вода...
+	AccessNumber byte   // Номер обращения
+	Status       byte   // Байт состояния
+	Signature    uint16 // Сигнатура (шифрование), 0 - без шифрования
+}
+
+/**
+Ответ прибора RSP_UD в длинном кадре
+*/
+type Frame struct {
+	Control byte
+	Address byte
+	CI      byte
+	Header  Header
+	Data    []byte // Записи данных переменной структуры, см. Parse
+}
+
+/**
+Разбор длинного кадра с ответом прибора: проверяются старт, длина, контрольная сумма и стоп,
+разбирается заголовок прикладного уровня по полю CI.
+*/
+func ParseFrame(frame []byte) (Frame, error) {
+	var result Frame
+	if len(frame) < 9 {
+		return result, errors.New("длинный кадр содержит меньше 9 байт")
+	}
+	if frame[0] != 0x68 || frame[3] != 0x68 || frame[1] != frame[2] {
+		return result, fmt.Errorf("неверный заголовок длинного кадра %X", frame[0:4])
+	}
+	length := int(frame[1])
+	if len(frame) < length+6 {
+		return result, errors.New("длина кадра меньше указанной в заголовке")
+	}
+	body := frame[4 : 4+length]
+	if Checksum(body) != frame[4+length] {
+		return result, fmt.Errorf("контрольная сумма не совпадает: ожидалась %X, получена %X", Checksum(body), frame[4+length])
+	}
+	if frame[5+length] != 0x16 {
+		return result, errors.New("неверный стоп-байт кадра")
+	}
+	if length < 3 {
+		return result, errors.New("кадр не содержит поле CI")
+	}
+
+	result.Control, result.Address, result.CI = body[0], body[1], body[2]
+	data := body[3:]
+	switch result.CI {
+	case CIResponseLong:
+		if len(data) < 12 {
+			return result, errors.New("кадр короче полного заголовка прикладного уровня")
+		}
+		id, err := decodeBCD(data[0:4])
+		if err != nil {
+			return result, err
+		}
+		result.Header = Header{
+			ID:           uint32(id),
+			Manufacturer: DecodeManufacturer(data[4], data[5]),
+			Version:      data[6],
+			Medium:       data[7],
+			AccessNumber: data[8],
+			Status:       data[9],
+			Signature:    uint16(data[10]) | uint16(data[11])<<8,
+		}
+		result.Data = data[12:]
+	case CIResponseShort:
+		if len(data) < 4 {
+			return result, errors.New("кадр короче короткого заголовка прикладного уровня")
+		}
+		result.Header = Header{
+			AccessNumber: data[0],
+			Status:       data[1],
+			Signature:    uint16(data[2]) | uint16(data[3])<<8,
+		}
+		result.Data = data[4:]
+	case CIResponseNone:
+		result.Data = data
+	default:
+		return result, fmt.Errorf("неподдерживаемое поле CI %X", result.CI)
+	}
+	return result, nil
+}
+
+// Код производителя: три буквы по 5 бит, младший байт первым
+func DecodeManufacturer(low byte, high byte) string {
+	code := uint16(low) | uint16(high)<<8
+	return string([]byte{
+		byte(code>>10&0x1F) + 64,
+		byte(code>>5&0x1F) + 64,
+		byte(code&0x1F) + 64,
+	})
+}
+
+/**
+Контрольные функции ответа для net.Request
+*/
+type Checks struct {
+	Logger *log.LoggerService
+}
+
+// Ответ - подтверждение E5h
+func (checks Checks) CheckAck(response []byte) bool {
+	if len(response) == 0 {
+		checks.Logger.Info("Получен пустой ответ.")
+		return false
+	}
+	if response[0] != Ack {
+		checks.Logger.Info("Получен некорректный ответ. Ожидалось подтверждение E5h - %X", response)
+		return false
+	}
+	return true
+}
+
+// Ответ - длинный кадр с корректной длиной и контрольной суммой
+func (checks Checks) CheckLongFrame(response []byte) bool {
+	_, err := ParseFrame(response)
+	if err != nil {
+		checks.Logger.Info("Получен некорректный ответ. %s", err.Error())
+		return false
+	}
+	return true
+}
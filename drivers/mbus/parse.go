@@ -0,0 +1,242 @@
+package mbus
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	maxDIFE = 10 // Максимальное количество расширений DIF и VIF по EN 13757-3
+	maxVIFE = 10
+)
+
+/**
+Результат разбора блока записей переменной структуры
+*/
+type VariableData struct {
+	Records          []Record
+	ManufacturerData []byte // Данные производителя после DIF 0x0F/0x1F
+	MoreRecords      bool   // DIF 0x1F: продолжение записей в следующей посылке
+}
+
+/**
+Ошибка разбора записи. Записи до смещения Offset разобраны и возвращаются вместе с ошибкой.
+*/
+type ParseError struct {
+	Offset int
+	Err    error
+}
+
+func (err *ParseError) Error() string {
+	return fmt.Sprintf("ошибка разбора записи M-Bus по смещению %d: %s", err.Offset, err.Err.Error())
+}
+
+func (err *ParseError) Unwrap() error {
+	return err.Err
+}
+
+/**
+Разбор записей данных переменной структуры (EN 13757-3) - данных после заголовка прикладного уровня.
+Байты наполнителя (DIF 0x2F) пропускаются. При ошибке возвращаются уже разобранные записи и *ParseError.
+*/
+func Parse(data []byte) (VariableData, error) {
+	var result VariableData
+	offset := 0
+	for offset < len(data) {
+		dif := data[offset]
+		switch dif {
+		case 0x2F: // наполнитель
+			offset++
+			continue
+		case 0x0F, 0x1F: // далее данные производителя
+			result.ManufacturerData = data[offset+1:]
+			result.MoreRecords = dif == 0x1F
+			return result, nil
+		}
+
+		record, length, err := parseRecord(data[offset:])
+		if err != nil {
+			return result, &ParseError{Offset: offset, Err: err}
+		}
+		record.Offset = offset
+		result.Records = append(result.Records, record)
+		offset += length
+	}
+	return result, nil
+}
+
+var errShort = errors.New("запись обрывается раньше конца данных")
+
+func parseRecord(data []byte) (Record, int, error) {
+	record := Record{DIF: data[0]}
+	if record.DIF&0x0F == 0x0F {
+		return record, 0, fmt.Errorf("неподдерживаемая специальная функция DIF 0x%02X", record.DIF)
+	}
+	record.Function = FunctionEnum((record.DIF >> 4) & 0x03)
+	record.Storage = uint64(record.DIF>>6) & 0x01
+
+	pos := 1
+	ext := record.DIF&0x80 != 0
+	for i := 0; ext; i++ {
+		if i >= maxDIFE {
+			return record, 0, errors.New("слишком много расширений DIF")
+		}
+		if pos >= len(data) {
+			return record, 0, errShort
+		}
+		dife := data[pos]
+		pos++
+		record.DIFE = append(record.DIFE, dife)
+		record.Storage |= uint64(dife&0x0F) << (1 + 4*uint(i))
+		record.Tariff |= uint32(dife>>4&0x03) << (2 * uint(i))
+		record.Subunit |= uint32(dife>>6&0x01) << uint(i)
+		ext = dife&0x80 != 0
+	}
+
+	if pos >= len(data) {
+		return record, 0, errShort
+	}
+	record.VIF = data[pos]
+	pos++
+	vif := record.VIF & 0x7F
+
+	var (
+		plainLength int
+		vifeCount   int
+	)
+	if vif == 0x7C {
+		if pos >= len(data) {
+			return record, 0, errShort
+		}
+		plainLength = int(data[pos])
+		pos++
+		if pos+plainLength > len(data) {
+			return record, 0, errShort
+		}
+	}
+	plainStart := pos
+	pos += plainLength
+
+	ext = record.VIF&0x80 != 0
+	for ext {
+		if vifeCount >= maxVIFE {
+			return record, 0, errors.New("слишком много расширений VIF")
+		}
+		if pos >= len(data) {
+			return record, 0, errShort
+		}
+		record.VIFE = append(record.VIFE, data[pos])
+		ext = data[pos]&0x80 != 0
+		pos++
+		vifeCount++
+	}
+
+	switch record.VIF {
+	case 0xFB, 0xFD:
+		if len(record.VIFE) == 0 {
+			return record, 0, errShort
+		}
+		if record.VIF == 0xFB {
+			record.Value = extensionFBValue(record.VIFE[0] & 0x7F)
+		} else {
+			record.Value = extensionFDValue(record.VIFE[0] & 0x7F)
+		}
+		applyVIFE(&record.Value, record.VIFE[1:])
+	case 0xFF, 0x7F:
+		// VIFE после VIF производителя также определяются производителем
+		record.Value = primaryValue(vif)
+	default:
+		record.Value = primaryValue(vif)
+		applyVIFE(&record.Value, record.VIFE)
+	}
+	if vif == 0x7C {
+		record.Value.PlainUnit = string(reverse(data[plainStart : plainStart+plainLength]))
+		record.Value.Unit = record.Value.PlainUnit
+	}
+
+	length, recordType, err := dataLength(record, data[pos:])
+	if err != nil {
+		return record, 0, err
+	}
+	if record.DIF&0x0F == 0x0D {
+		pos++ // байт LVAR
+	}
+	if pos+length > len(data) {
+		return record, 0, errShort
+	}
+	record.Data = data[pos : pos+length]
+	record.Type = recordType
+	return record, pos + length, nil
+}
+
+func applyVIFE(value *Value, vifes []byte) {
+	for _, vife := range vifes {
+		value.applyVIFE(vife)
+	}
+}
+
+/**
+Длина и тип значения по полю данных DIF (таблица 4 EN 13757-3) и VIF.
+Для данных переменной длины tail начинается с байта LVAR.
+*/
+func dataLength(record Record, tail []byte) (int, DataTypeEnum, error) {
+	vif := record.VIF & 0x7F
+	switch record.DIF & 0x0F {
+	case 0x00, 0x08:
+		return 0, DataNone, nil
+	case 0x01:
+		return 1, DataInt, nil
+	case 0x02:
+		if vif == 0x6C {
+			return 2, DataDate, nil
+		}
+		return 2, DataInt, nil
+	case 0x03:
+		if vif == 0x6D {
+			return 3, DataTime, nil
+		}
+		return 3, DataInt, nil
+	case 0x04:
+		if vif == 0x6D {
+			return 4, DataDateTime, nil
+		}
+		return 4, DataInt, nil
+	case 0x05:
+		return 4, DataReal, nil
+	case 0x06:
+		if vif == 0x6D {
+			return 6, DataDateTime, nil
+		}
+		return 6, DataInt, nil
+	case 0x07:
+		return 8, DataInt, nil
+	case 0x09:
+		return 1, DataBCD, nil
+	case 0x0A:
+		return 2, DataBCD, nil
+	case 0x0B:
+		return 3, DataBCD, nil
+	case 0x0C:
+		return 4, DataBCD, nil
+	case 0x0E:
+		return 6, DataBCD, nil
+	case 0x0D:
+		if len(tail) == 0 {
+			return 0, DataNone, errShort
+		}
+		lvar := tail[0]
+		switch {
+		case lvar <= 0xBF:
+			return int(lvar), DataString, nil
+		case lvar >= 0xC0 && lvar <= 0xC9, lvar >= 0xD0 && lvar <= 0xD9:
+			// BCD, отрицательные числа (0xD0-0xD9) передаются без знаковой тетрады и здесь не различаются
+			return int(lvar & 0x0F), DataBCD, nil
+		case lvar >= 0xE0 && lvar <= 0xEF:
+			return int(lvar - 0xE0), DataBytes, nil
+		case lvar >= 0xF0 && lvar <= 0xFA:
+			return 4 * int(lvar-0xEC), DataBytes, nil
+		}
+		return 0, DataNone, fmt.Errorf("неподдерживаемое значение LVAR 0x%02X", lvar)
+	}
+	return 0, DataNone, fmt.Errorf("неподдерживаемое поле данных DIF 0x%02X", record.DIF)
+}
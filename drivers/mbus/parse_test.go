@@ -0,0 +1,227 @@
+package mbus
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Ожидаемое значение записи: число (Float), время (Time) или строка (String) - по типу данных
+type recordCase struct {
+	name   string
+	data   []byte
+	typ    DataTypeEnum
+	value  Value
+	number float64
+	moment time.Time
+	text   string
+}
+
+func checkRecord(t *testing.T, test recordCase, record Record) {
+	t.Helper()
+	if record.Type != test.typ {
+		t.Errorf("%s: тип %d, ожидался %d", test.name, record.Type, test.typ)
+	}
+	if !reflect.DeepEqual(record.Value, test.value) {
+		t.Errorf("%s: значение %+v, ожидалось %+v", test.name, record.Value, test.value)
+	}
+	switch test.typ {
+	case DataInt, DataBCD, DataReal:
+		number, err := record.Float()
+		if err != nil || math.Abs(number-test.number) > 1e-9 {
+			t.Errorf("%s: число %v (%v), ожидалось %v", test.name, number, err, test.number)
+		}
+	case DataDate, DataDateTime, DataTime:
+		moment, err := record.Time()
+		if err != nil || !moment.Equal(test.moment) {
+			t.Errorf("%s: время %v (%v), ожидалось %v", test.name, moment, err, test.moment)
+		}
+	case DataString, DataBytes:
+		if text := record.String(); text != test.text {
+			t.Errorf("%s: строка %q, ожидалось %q", test.name, text, test.text)
+		}
+	}
+	if header := record.Header(); !bytes.Equal(header, test.data[:len(header)]) {
+		t.Errorf("%s: заголовок % X, ожидался % X", test.name, header, test.data[:len(header)])
+	}
+}
+
+// Поле данных DIF (таблица 4 EN 13757-3): длина и тип значения
+func TestParseDataTypes(t *testing.T) {
+	volume := Value{Quantity: QuantityVolume, Unit: "m3", Exponent: -3}
+	for _, test := range []recordCase{
+		{name: "нет данных", data: []byte{0x00, 0x13}, typ: DataNone, value: volume},
+		{name: "8 бит", data: []byte{0x01, 0x13, 0x2A}, typ: DataInt, value: volume, number: 0.042},
+		{name: "16 бит", data: []byte{0x02, 0x5A, 0x34, 0x12}, typ: DataInt,
+			value: Value{Quantity: QuantityFlowTemperature, Unit: "C", Exponent: -1}, number: 466},
+		{name: "24 бит со знаком", data: []byte{0x03, 0x13, 0xFF, 0xFF, 0xFF}, typ: DataInt, value: volume, number: -0.001},
+		{name: "32 бит", data: []byte{0x04, 0x06, 0x78, 0x56, 0x34, 0x12}, typ: DataInt,
+			value: Value{Quantity: QuantityEnergy, Unit: "Wh", Exponent: 3}, number: 305419896e3},
+		{name: "real", data: []byte{0x05, 0x3E, 0x00, 0x00, 0xA0, 0x3F}, typ: DataReal,
+			value: Value{Quantity: QuantityVolumeFlow, Unit: "m3/h"}, number: 1.25},
+		{name: "48 бит", data: []byte{0x06, 0x13, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00}, typ: DataInt, value: volume,
+			number: 1 << 32 / 1000.0},
+		{name: "64 бит", data: []byte{0x07, 0x13, 0x01, 0, 0, 0, 0, 0, 0, 0}, typ: DataInt, value: volume, number: 0.001},
+		{name: "BCD 2", data: []byte{0x09, 0x5B, 0x42}, typ: DataBCD,
+			value: Value{Quantity: QuantityFlowTemperature, Unit: "C"}, number: 42},
+		{name: "BCD 4", data: []byte{0x0A, 0x5F, 0x34, 0x12}, typ: DataBCD,
+			value: Value{Quantity: QuantityReturnTemperature, Unit: "C"}, number: 1234},
+		{name: "BCD 4 отрицательное", data: []byte{0x0A, 0x13, 0x01, 0xF0}, typ: DataBCD, value: volume, number: -0.001},
+		{name: "BCD 6", data: []byte{0x0B, 0x3B, 0x56, 0x34, 0x12}, typ: DataBCD,
+			value: Value{Quantity: QuantityVolumeFlow, Unit: "m3/h", Exponent: -3}, number: 123.456},
+		{name: "BCD 8", data: []byte{0x0C, 0x78, 0x78, 0x56, 0x34, 0x12}, typ: DataBCD,
+			value: Value{Quantity: QuantityFabricationNumber}, number: 12345678},
+		{name: "BCD 12", data: []byte{0x0E, 0x06, 0x01, 0, 0, 0, 0, 0}, typ: DataBCD,
+			value: Value{Quantity: QuantityEnergy, Unit: "Wh", Exponent: 3}, number: 1000},
+		{name: "дата G", data: []byte{0x02, 0x6C, 0x52, 0x3A}, typ: DataDate, value: Value{Quantity: QuantityDate},
+			moment: time.Date(2026, 10, 18, 0, 0, 0, 0, time.Local)},
+		{name: "время J", data: []byte{0x03, 0x6D, 0x05, 0x1E, 0x0A}, typ: DataTime,
+			value: Value{Quantity: QuantityDateTime}, moment: time.Date(0, 1, 1, 10, 30, 5, 0, time.Local)},
+		{name: "дата и время F", data: []byte{0x04, 0x6D, 0x1E, 0x0A, 0x52, 0x3A}, typ: DataDateTime,
+			value: Value{Quantity: QuantityDateTime}, moment: time.Date(2026, 10, 18, 10, 30, 0, 0, time.Local)},
+		{name: "дата и время I", data: []byte{0x06, 0x6D, 0x05, 0x1E, 0x0A, 0x52, 0x3A, 0x00}, typ: DataDateTime,
+			value: Value{Quantity: QuantityDateTime}, moment: time.Date(2026, 10, 18, 10, 30, 5, 0, time.Local)},
+		{name: "LVAR строка", data: []byte{0x0D, 0x79, 0x03, 'C', 'B', 'A'}, typ: DataString,
+			value: Value{Quantity: QuantityIdentification}, text: "ABC"},
+		{name: "LVAR BCD", data: []byte{0x0D, 0x13, 0xC3, 0x45, 0x23, 0x01}, typ: DataBCD, value: volume, number: 12.345},
+		{name: "LVAR двоичные", data: []byte{0x0D, 0x7E, 0xE2, 0xAA, 0xBB}, typ: DataBytes,
+			value: Value{Quantity: QuantityAny}, text: "AABB"},
+		{name: "LVAR двоичные 4*n", data: []byte{0x0D, 0x7E, 0xF1, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20},
+			typ: DataBytes, value: Value{Quantity: QuantityAny}, text: "0102030405060708090A0B0C0D0E0F1011121314"},
+	} {
+		result, err := Parse(test.data)
+		if err != nil || len(result.Records) != 1 {
+			t.Errorf("%s: %v, записей %d", test.name, err, len(result.Records))
+			continue
+		}
+		checkRecord(t, test, result.Records[0])
+	}
+}
+
+// Расширения DIFE: номер хранения, тариф и подустройство собираются из всех расширений
+func TestParseDIFE(t *testing.T) {
+	// DIF: максимум, хранение 1; DIFE 1: тариф 1, хранение 3; DIFE 2: подустройство 1, хранение 1
+	result, err := Parse([]byte{0xD4, 0x93, 0x41, 0x06, 0x10, 0x00, 0x00, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := result.Records[0]
+	if record.Function != FunctionMaximum || record.Storage != 1|3<<1|1<<5 || record.Tariff != 1 || record.Subunit != 2 {
+		t.Errorf("функция %s, хранение %d, тариф %d, подустройство %d", record.Function, record.Storage, record.Tariff,
+			record.Subunit)
+	}
+	if !bytes.Equal(record.DIFE, []byte{0x93, 0x41}) || !bytes.Equal(record.Data, []byte{0x10, 0, 0, 0}) {
+		t.Errorf("DIFE % X, данные % X", record.DIFE, record.Data)
+	}
+
+	tooMany := append([]byte{0x84}, bytes.Repeat([]byte{0x80}, maxDIFE+1)...)
+	if _, err = Parse(append(tooMany, 0x00, 0x06, 0, 0, 0, 0)); err == nil {
+		t.Error("более 10 DIFE должны завершаться ошибкой")
+	}
+}
+
+// Расширения VIFE основного VIF и таблиц расширения FB, FD; единицы измерения текстом; значения производителя
+func TestParseVIFE(t *testing.T) {
+	for _, test := range []recordCase{
+		{name: "накопление и множитель", data: []byte{0x04, 0x86, 0xBB, 0x73, 0x10, 0, 0, 0}, typ: DataInt,
+			value: Value{Quantity: QuantityEnergy, Unit: "Wh", Accumulation: "positive"}, number: 16},
+		{name: "аддитивная поправка", data: []byte{0x01, 0xDB, 0x79, 0x14}, typ: DataInt,
+			value: Value{Quantity: QuantityFlowTemperature, Unit: "C", Additive: 0.01}, number: 20.01},
+		{name: "на единицу", data: []byte{0x02, 0x93, 0x22, 0x10, 0x00}, typ: DataInt,
+			value: Value{Quantity: QuantityVolume, Unit: "m3", Exponent: -3, Per: "h"}, number: 0.016},
+		{name: "множитель 1000", data: []byte{0x01, 0x83, 0x7D, 0x02}, typ: DataInt,
+			value: Value{Quantity: QuantityEnergy, Unit: "Wh", Exponent: 3}, number: 2000},
+		{name: "FB МВт*ч", data: []byte{0x04, 0xFB, 0x01, 0x39, 0x30, 0, 0}, typ: DataInt,
+			value: Value{Quantity: QuantityEnergy, Unit: "MWh"}, number: 12345},
+		{name: "FB ГДж с VIFE", data: []byte{0x04, 0xFB, 0x88, 0x3C, 0x0A, 0, 0, 0}, typ: DataInt,
+			value: Value{Quantity: QuantityEnergy, Unit: "GJ", Exponent: -1, Accumulation: "negative"}, number: 1},
+		{name: "FB температура F", data: []byte{0x02, 0xFB, 0x5A, 0x64, 0x00}, typ: DataInt,
+			value: Value{Quantity: QuantityFlowTemperature, Unit: "F", Exponent: -1}, number: 10},
+		{name: "FD напряжение", data: []byte{0x02, 0xFD, 0x48, 0x10, 0x09}, typ: DataInt,
+			value: Value{Quantity: QuantityVoltage, Unit: "V", Exponent: -1}, number: 232},
+		{name: "FD версия ПО", data: []byte{0x0C, 0xFD, 0x0E, 0x21, 0x03, 0x00, 0x00}, typ: DataBCD,
+			value: Value{Quantity: QuantityFirmwareVersion}, number: 321},
+		{name: "FD флаги ошибок", data: []byte{0x02, 0xFD, 0x17, 0x00, 0x00}, typ: DataInt,
+			value: Value{Quantity: QuantityErrorFlags}},
+		{name: "текст единиц", data: []byte{0x02, 0xFC, 0x03, 'h', '/', 'l', 0x74, 0x64, 0x00}, typ: DataInt,
+			value: Value{Quantity: QuantityPlainText, Unit: "l/h", PlainUnit: "l/h", Exponent: -2}, number: 1},
+		{name: "текст единиц без VIFE", data: []byte{0x01, 0x7C, 0x01, 'L', 0x05}, typ: DataInt,
+			value: Value{Quantity: QuantityPlainText, Unit: "L", PlainUnit: "L"}, number: 5},
+		{name: "VIF производителя", data: []byte{0x01, 0xFF, 0x01, 0x05}, typ: DataInt,
+			value: Value{Quantity: QuantityManufacturerRecord, Manufacturer: true}, number: 5},
+		{name: "VIF производителя без VIFE", data: []byte{0x02, 0x7F, 0x34, 0x12}, typ: DataInt,
+			value: Value{Quantity: QuantityManufacturerRecord, Manufacturer: true}, number: 0x1234},
+		{name: "VIFE производителя", data: []byte{0x01, 0x93, 0x7F, 0x05}, typ: DataInt,
+			value: Value{Quantity: QuantityVolume, Unit: "m3", Exponent: -3, Manufacturer: true}, number: 0.005},
+	} {
+		result, err := Parse(test.data)
+		if err != nil || len(result.Records) != 1 {
+			t.Errorf("%s: %v, записей %d", test.name, err, len(result.Records))
+			continue
+		}
+		checkRecord(t, test, result.Records[0])
+	}
+
+	tooMany := append([]byte{0x01, 0x93}, bytes.Repeat([]byte{0xFF}, maxVIFE)...)
+	if _, err := Parse(append(tooMany, 0x7F, 0x05)); err == nil {
+		t.Error("более 10 VIFE должны завершаться ошибкой")
+	}
+}
+
+// Наполнитель и данные производителя после DIF 0x0F/0x1F
+func TestParseManufacturerData(t *testing.T) {
+	data := []byte{0x2F, 0x01, 0x13, 0x2A, 0x2F, 0x2F, 0x02, 0x5A, 0x34, 0x12, 0x1F, 0xAA, 0xBB}
+	result, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Records) != 2 || result.Records[0].Offset != 1 || result.Records[1].Offset != 6 {
+		t.Fatalf("записи %+v", result.Records)
+	}
+	if !bytes.Equal(result.ManufacturerData, []byte{0xAA, 0xBB}) || !result.MoreRecords {
+		t.Errorf("данные производителя % X, продолжение %v", result.ManufacturerData, result.MoreRecords)
+	}
+
+	data[10] = 0x0F
+	if result, err = Parse(data); err != nil || result.MoreRecords || len(result.ManufacturerData) != 2 {
+		t.Errorf("DIF 0x0F: %v, продолжение %v", err, result.MoreRecords)
+	}
+}
+
+// Запись, обрывающаяся на любом байте, возвращает ошибку и предыдущие записи
+func TestParseTruncated(t *testing.T) {
+	first := []byte{0x04, 0x06, 0x78, 0x56, 0x34, 0x12}
+	for _, record := range [][]byte{
+		{0xD4, 0x93, 0x41, 0x06, 0x10, 0x00, 0x00, 0x00},
+		{0x04, 0x86, 0xBB, 0x73, 0x10, 0, 0, 0},
+		{0x04, 0xFB, 0x88, 0x3C, 0x0A, 0, 0, 0},
+		{0x02, 0xFC, 0x03, 'h', '/', 'l', 0x74, 0x64, 0x00},
+		{0x0D, 0x79, 0x03, 'C', 'B', 'A'},
+		{0x0D, 0x13, 0xC3, 0x45, 0x23, 0x01},
+		{0x06, 0x6D, 0x05, 0x1E, 0x0A, 0x52, 0x3A, 0x00},
+	} {
+		for length := 1; length < len(record); length++ {
+			data := append(append([]byte{}, first...), record[:length]...)
+			result, err := Parse(data)
+			var parseError *ParseError
+			if !errors.As(err, &parseError) || parseError.Offset != len(first) || !errors.Is(err, errShort) {
+				t.Errorf("% X: ошибка %v", data, err)
+			}
+			if len(result.Records) != 1 || !bytes.Equal(result.Records[0].Data, first[2:]) {
+				t.Errorf("% X: разобранные записи %+v", data, result.Records)
+			}
+		}
+	}
+
+	for _, data := range [][]byte{
+		{0x3F, 0x13},             // специальная функция DIF
+		{0x0D, 0x13, 0xFB, 0x00}, // LVAR
+	} {
+		if _, err := Parse(data); err == nil || errors.Is(err, errShort) {
+			t.Errorf("% X: ожидалась ошибка формата, получено %v", data, err)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package mbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+type FunctionEnum byte // Функция значения, биты 4-5 DIF
+const (
+	FunctionInstantaneous FunctionEnum = 0 // Текущее значение
+	FunctionMaximum       FunctionEnum = 1 // Максимальное значение
+	FunctionMinimum       FunctionEnum = 2 // Минимальное значение
+	FunctionError         FunctionEnum = 3 // Значение в состоянии ошибки
+)
+
+func (function FunctionEnum) String() string {
+	switch function {
+	case FunctionMaximum:
+		return "max"
+	case FunctionMinimum:
+		return "min"
+	case FunctionError:
+		return "error"
+	}
+	return "instantaneous"
+}
+
+type DataTypeEnum byte // Тип данных записи, определяется полем данных DIF (биты 0-3) и VIF
+const (
+	DataNone     DataTypeEnum = iota // Нет данных
+	DataInt                          // Целое со знаком (тип B), 8-64 бит
+	DataReal                         // Число с плавающей точкой (тип H), 32 бит
+	DataBCD                          // Двоично-десятичное число (тип A)
+	DataDate                         // Дата (тип G)
+	DataDateTime                     // Дата и время (тип F - до минут, тип I - до секунд)
+	DataTime                         // Время суток (тип J)
+	DataString                       // Строка переменной длины
+	DataBytes                        // Двоичные данные без интерпретации
+)
+
+/**
+Запись данных (data record) прикладного уровня M-Bus согласно EN 13757-3.
+Запись состоит из блока DIB (DIF + DIFE), блока VIB (VIF + VIFE) и значения.
+*/
+type Record struct {
+	Offset int    // Смещение записи в разобранных данных
+	DIF    byte   // Data Information Field
+	DIFE   []byte // Расширения DIF
+	VIF    byte   // Value Information Field
+	VIFE   []byte // Расширения VIF. Для VIF 0xFB и 0xFD первый байт - код из таблицы расширения
+	Data   []byte // Значение в том виде, в котором передано (младший байт первым)
+
+	Storage  uint64       // Номер хранения: 0 - текущее значение, 1 и далее - архивные значения
+	Tariff   uint32       // Тариф
+	Subunit  uint32       // Номер подустройства (например, номер системы теплосчётчика)
+	Function FunctionEnum // Функция значения
+	Type     DataTypeEnum // Тип данных
+
+	Value
+}
+
+// Байты DIB и VIB записи
+func (record Record) Header() []byte {
+	header := append([]byte{record.DIF}, record.DIFE...)
+	if record.DIF&0x0F == 0x0F {
+		return header // специальные функции не содержат VIB
+	}
+	header = append(header, record.VIF)
+	if record.VIF&0x7F == 0x7C {
+		// plain text VIF: за VIF следует длина и текст единиц измерения, затем VIFE
+		header = append(header, byte(len(record.Value.PlainUnit)))
+		header = append(header, reverse([]byte(record.Value.PlainUnit))...)
+	}
+	return append(header, record.VIFE...)
+}
+
+// Значение записи в виде целого числа без учёта множителя. Для BCD и целых типов.
+func (record Record) Int() (int64, error) {
+	switch record.Type {
+	case DataInt:
+		return decodeInt(record.Data), nil
+	case DataBCD:
+		return decodeBCD(record.Data)
+	case DataString:
+		return 0, errors.New("значение записи - строка")
+	}
+	return 0, fmt.Errorf("значение записи типа %d не является целым числом", record.Type)
+}
+
+// Значение записи с учётом множителя, в единицах измерения Unit
+func (record Record) Float() (float64, error) {
+	var value float64
+	switch record.Type {
+	case DataReal:
+		value = float64(math.Float32frombits(binary.LittleEndian.Uint32(record.Data)))
+	default:
+		intValue, err := record.Int()
+		if err != nil {
+			return 0, err
+		}
+		value = float64(intValue)
+	}
+	// Деление на 10^n даёт ближайшее к десятичному значению число, умножение на 10^-n - нет: 3 * 0.1 != 0.3
+	if record.Exponent < 0 {
+		return value/math.Pow10(-record.Exponent) + record.Additive, nil
+	}
+	return value*math.Pow10(record.Exponent) + record.Additive, nil
+}
+
+// Значение записи типа дата (G), дата и время (F, I) или время суток (J).
+// Время возвращается в часовом поясе компьютера, как и время на приборе у остальных драйверов.
+func (record Record) Time() (time.Time, error) {
+	b := record.Data
+	switch record.Type {
+	case DataDate: // тип G
+		return makeDate(b[0], b[1], 0, 0, 0)
+	case DataDateTime:
+		if len(b) == 4 { // тип F
+			if b[0]&0x80 != 0 {
+				return time.Time{}, errors.New("значение времени недостоверно")
+			}
+			return makeDate(b[2], b[3], int(b[1]&0x1F), int(b[0]&0x3F), 0)
+		}
+		// тип I
+		if b[1]&0x80 != 0 {
+			return time.Time{}, errors.New("значение времени недостоверно")
+		}
+		return makeDate(b[3], b[4], int(b[2]&0x1F), int(b[1]&0x3F), int(b[0]&0x3F))
+	case DataTime: // тип J
+		return time.Date(0, 1, 1, int(b[2]&0x1F), int(b[1]&0x3F), int(b[0]&0x3F), 0, time.Local), nil
+	}
+	return time.Time{}, fmt.Errorf("значение записи типа %d не является датой или временем", record.Type)
+}
+
+// Строковое значение записи переменной длины
+func (record Record) String() string {
+	if record.Type == DataString {
+		return string(reverse(record.Data))
+	}
+	return fmt.Sprintf("%X", record.Data)
+}
+
+/**
+Дата в формате M-Bus: день в битах 0-4 первого байта, месяц в битах 0-3 второго байта,
+год - биты 5-7 первого байта (младшие) и биты 4-7 второго байта (старшие).
+*/
+func makeDate(first byte, second byte, hour int, min int, sec int) (time.Time, error) {
+	day := int(first & 0x1F)
+	month := int(second & 0x0F)
+	year := 2000 + int(first>>5) + int(second&0xF0)>>1
+	if day < 1 || day > 31 || month < 1 || month > 12 || hour > 23 || min > 59 || sec > 59 {
+		return time.Time{}, errors.New("некорректное значение даты")
+	}
+	return time.Date(year, time.Month(month), day, hour, min, sec, 0, time.Local), nil
+}
+
+// Целое со знаком, младший байт первым
+func decodeInt(b []byte) int64 {
+	var value uint64
+	for i := len(b) - 1; i >= 0; i-- {
+		value = value<<8 | uint64(b[i])
+	}
+	shift := 64 - 8*uint(len(b))
+	return int64(value<<shift) >> shift
+}
+
+// Двоично-десятичное число, младший байт первым.
+// Старшая тетрада 0xF последнего байта означает отрицательное число.
+func decodeBCD(b []byte) (int64, error) {
+	var value int64
+	negative := false
+	for i := len(b) - 1; i >= 0; i-- {
+		hi, lo := b[i]>>4, b[i]&0x0F
+		if i == len(b)-1 && hi == 0x0F {
+			negative = true
+			hi = 0
+		}
+		if hi > 9 || lo > 9 {
+			return 0, fmt.Errorf("некорректное BCD значение %X", reverse(b))
+		}
+		value = value*100 + int64(hi)*10 + int64(lo)
+	}
+	if negative {
+		value = -value
+	}
+	return value, nil
+}
+
+func reverse(b []byte) []byte {
+	reversed := make([]byte, len(b))
+	for i := range b {
+		reversed[len(b)-1-i] = b[i]
+	}
+	return reversed
+}
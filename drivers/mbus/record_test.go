@@ -0,0 +1,50 @@
+package mbus
+
+import "testing"
+
+// Значения, которые не могут быть преобразованы: ошибка вместо произвольного числа или даты
+func TestRecordInvalidValues(t *testing.T) {
+	for name, record := range map[string]Record{
+		"BCD с тетрадой A":      {Type: DataBCD, Data: []byte{0x1A, 0x00}},
+		"строка":                {Type: DataString, Data: []byte{'1'}},
+		"нет данных":            {Type: DataNone},
+		"недостоверное время F": {Type: DataDateTime, Data: []byte{0x9E, 0x0A, 0x52, 0x3A}},
+		"недостоверное время I": {Type: DataDateTime, Data: []byte{0x05, 0x9E, 0x0A, 0x52, 0x3A, 0x00}},
+		"месяц 13":              {Type: DataDate, Data: []byte{0x52, 0x3D}},
+		"день 0":                {Type: DataDateTime, Data: []byte{0x1E, 0x0A, 0x40, 0x3A}},
+	} {
+		var err error
+		switch record.Type {
+		case DataDate, DataDateTime:
+			_, err = record.Time()
+		default:
+			_, err = record.Float()
+		}
+		if err == nil {
+			t.Errorf("%s: ожидалась ошибка", name)
+		}
+	}
+	if _, err := (Record{Type: DataInt, Data: []byte{1}}).Time(); err == nil {
+		t.Error("целое значение не является временем")
+	}
+}
+
+// Значение с отрицательным порядком совпадает с десятичной записью числа, а не с произведением на 10^-n
+func TestRecordFloatDecimal(t *testing.T) {
+	for _, test := range []struct {
+		data     []byte
+		exponent int
+		want     float64
+	}{
+		{[]byte{0x03}, -1, 0.3},
+		{[]byte{0x40, 0xE2, 0x01, 0x00}, -2, 1234.56},
+		{[]byte{0xD9, 0x12}, -2, 48.25},
+		{[]byte{0x07}, -3, 0.007},
+		{[]byte{0x07}, 3, 7000},
+	} {
+		record := Record{Type: DataInt, Data: test.data, Value: Value{Exponent: test.exponent}}
+		if value, err := record.Float(); err != nil || value != test.want {
+			t.Errorf("% X * 10^%d: получено %v (%v), ожидалось %v", test.data, test.exponent, value, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package mbus
+
+import (
+	"bytes"
+	"context"
+	"qBox/services/log"
+	"qBox/services/net"
+	"time"
+)
+
+/**
+Прибор, найденный при поиске на шине
+*/
+type Meter struct {
+	Primary   int              // Первичный адрес, -1 - прибор найден по вторичному адресу
+	Address   SecondaryAddress // Вторичный адрес из заголовка ответа
+	Collision bool             // Ответили одновременно несколько приборов, адрес прибора определить не удалось
+}
+
+/**
+Поиск приборов на одной шине M-Bus (одном шлюзе или последовательном порту).
+Запросы отправляются без повторных попыток, отсутствие ответа в течение ReplyTimeout означает,
+что прибора нет.
+*/
+type Scanner struct {
+	Network      *net.Network
+	Logger       *log.LoggerService
+	ReplyTimeout time.Duration
+}
+
+/**
+Первичный поиск: SND_NKE на каждый адрес от first до last. Ответившие приборы опрашиваются запросом REQ_UD2,
+чтобы получить вторичный адрес. Повреждённый ответ на SND_NKE означает несколько приборов с одним адресом.
+*/
+func (scanner Scanner) ScanPrimary(ctx context.Context, first byte, last byte) ([]Meter, error) {
+	var meters []Meter
+	for address := int(first); address <= int(last); address++ {
+		scanner.Logger.Info("Поиск по первичному адресу %d", address)
+		response, err := scanner.Network.Exchange(ctx, ShortFrame(ControlSndNke, byte(address)), scanner.ReplyTimeout, isAck)
+		if err != nil {
+			return meters, err
+		}
+		if len(response) == 0 {
+			continue
+		}
+		meter := Meter{Primary: address, Address: WildcardAddress()}
+		if !isAck(response) {
+			meter.Collision = true
+			meters = append(meters, meter)
+			continue
+		}
+
+		frame, err := scanner.readData(ctx, byte(address))
+		if err != nil {
+			return meters, err
+		}
+		if frame == nil {
+			meter.Collision = true
+		} else {
+			meter.Address = HeaderAddress(frame.Header)
+		}
+		meters = append(meters, meter)
+	}
+	return meters, nil
+}
+
+/**
+Вторичный поиск: перебор цифр номера, начиная со старшей. На каждую маску прибор выбирается
+командой выбора и опрашивается по адресу 0xFD. Неповреждённый ответ означает, что маске соответствует
+один прибор, повреждённый - что приборов несколько, и поиск продолжается по следующей цифре.
+*/
+func (scanner Scanner) ScanSecondary(ctx context.Context) ([]Meter, error) {
+	var meters []Meter
+	err := scanner.searchSecondary(ctx, []byte("FFFFFFFF"), 0, &meters)
+	// Снятие выбора с последнего выбранного прибора
+	_, _ = scanner.Network.Exchange(ctx, ShortFrame(ControlSndNke, AddressNetwork), scanner.ReplyTimeout, isAck)
+	return meters, err
+}
+
+func (scanner Scanner) searchSecondary(ctx context.Context, mask []byte, position int, meters *[]Meter) error {
+	for digit := byte('0'); digit <= '9'; digit++ {
+		mask[position] = digit
+		address := WildcardAddress()
+		address.ID = string(mask)
+		scanner.Logger.Info("Поиск по вторичному адресу %s", address.ID)
+
+		response, err := scanner.Network.Exchange(ctx, SelectFrame(address), scanner.ReplyTimeout, isAck)
+		if err != nil {
+			return err
+		}
+		if len(response) == 0 {
+			continue // маске не соответствует ни один прибор
+		}
+
+		frame, err := scanner.readData(ctx, AddressNetwork)
+		if err != nil {
+			return err
+		}
+		switch {
+		case frame != nil && isAck(response):
+			*meters = append(*meters, Meter{Primary: -1, Address: HeaderAddress(frame.Header)})
+		case position < len(mask)-1:
+			err = scanner.searchSecondary(ctx, mask, position+1, meters)
+			if err != nil {
+				return err
+			}
+		default:
+			*meters = append(*meters, Meter{Primary: -1, Address: address, Collision: true})
+		}
+	}
+	mask[position] = 'F'
+	return nil
+}
+
+// Запрос REQ_UD2. nil - ответ повреждён или отсутствует
+func (scanner Scanner) readData(ctx context.Context, address byte) (*Frame, error) {
+	response, err := scanner.Network.Exchange(ctx, ShortFrame(ControlReqUD2, address), scanner.ReplyTimeout, func(response []byte) bool {
+		_, err := ParseFrame(response)
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	frame, err := ParseFrame(response)
+	if err != nil {
+		if len(response) > 0 {
+			scanner.Logger.Debug("Повреждённый ответ: %s", err.Error())
+		}
+		return nil, nil
+	}
+	return &frame, nil
+}
+
+func isAck(response []byte) bool {
+	return bytes.Equal(response, []byte{Ack})
+}
@@ -0,0 +1,219 @@
+package mbus_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"qBox/drivers/mbus"
+	"qBox/services/log"
+	"qBox/services/net"
+	"reflect"
+	"testing"
+	"time"
+
+	ozzolog "github.com/go-ozzo/ozzo-log"
+)
+
+/**
+Прибор M-Bus на шине: отвечает E5h на SND_NKE, заголовком и одной записью на REQ_UD2,
+выбирается по вторичному адресу и отвечает по адресу FDh до снятия выбора.
+*/
+type meter struct {
+	primary  byte
+	address  mbus.SecondaryAddress
+	selected bool
+}
+
+func newMeter(primary byte, id string, manufacturer string) *meter {
+	return &meter{primary: primary, address: meterAddress(id, manufacturer)}
+}
+
+func (device *meter) handle(frame []byte) []byte {
+	// Номер, производитель, версия и среда в том же виде, что и в заголовке ответа
+	identity := mbus.SelectFrame(device.address)[7:15]
+	switch {
+	case len(frame) == 5:
+		control, address := frame[1], frame[2]
+		if address != device.primary && !(address == mbus.AddressNetwork && device.selected) {
+			return nil
+		}
+		switch control &^ mbus.ControlFCB {
+		case mbus.ControlSndNke:
+			if address == mbus.AddressNetwork {
+				device.selected = false
+			}
+			return []byte{mbus.Ack}
+		case mbus.ControlReqUD2 &^ mbus.ControlFCB:
+			data := append(append([]byte(nil), identity...), 0x01, 0x00, 0x00, 0x00)
+			data = append(data, 0x04, 0x13, 0x01, 0x00, 0x00, 0x00)
+			return mbus.LongFrame(mbus.ControlRspUD, address, mbus.CIResponseLong, data)
+		}
+	case len(frame) > 15 && frame[6] == mbus.CISelectAddress:
+		device.selected = matches(identity, frame[7:15])
+		if device.selected {
+			return []byte{mbus.Ack}
+		}
+	}
+	return nil
+}
+
+// Совпадение с маской выбора: F в цифрах номера, FFFFh и FFh - любое значение
+func matches(identity []byte, mask []byte) bool {
+	for i := 0; i < 4; i++ {
+		for _, shift := range []uint{0, 4} {
+			digit := mask[i] >> shift & 0x0F
+			if digit != 0x0F && digit != identity[i]>>shift&0x0F {
+				return false
+			}
+		}
+	}
+	if !(mask[4] == 0xFF && mask[5] == 0xFF) && (mask[4] != identity[4] || mask[5] != identity[5]) {
+		return false
+	}
+	return (mask[6] == 0xFF || mask[6] == identity[6]) && (mask[7] == 0xFF || mask[7] == identity[7])
+}
+
+/**
+Шина M-Bus с приборами без TCP. Одинаковые ответы нескольких приборов (подтверждения E5h)
+сливаются в один, различающиеся накладываются с искажением контрольной суммы, как при коллизии на линии.
+Без ответа чтение сразу завершается таймаутом.
+*/
+type busTransport struct {
+	devices  []*meter
+	response []byte
+	requests [][]byte
+}
+
+func (bus *busTransport) Open(context.Context) error { return nil }
+func (bus *busTransport) Close() error               { return nil }
+func (bus *busTransport) Write(b []byte) (int, error) {
+	bus.requests = append(bus.requests, append([]byte(nil), b...))
+	bus.response = nil
+	collision := false
+	for _, device := range bus.devices {
+		response := device.handle(b)
+		if response == nil {
+			continue
+		}
+		collision = collision || bus.response != nil && !bytes.Equal(bus.response, response)
+		for i, value := range response {
+			if i < len(bus.response) {
+				bus.response[i] |= value
+			} else {
+				bus.response = append(bus.response, value)
+			}
+		}
+	}
+	if collision && len(bus.response) > 1 {
+		bus.response[len(bus.response)-2] ^= 0xFF
+	}
+	return len(b), nil
+}
+func (bus *busTransport) Read(b []byte) (int, error) {
+	if len(bus.response) == 0 {
+		return 0, os.ErrDeadlineExceeded
+	}
+	n := copy(b, bus.response)
+	bus.response = bus.response[n:]
+	return n, nil
+}
+func (bus *busTransport) SetReadDeadline(time.Time) error  { return nil }
+func (bus *busTransport) SetWriteDeadline(time.Time) error { return nil }
+func (bus *busTransport) String() string                   { return "bus" }
+
+func newScanner(bus *busTransport) mbus.Scanner {
+	logger := log.NewLoggerService(ozzolog.NewLogger())
+	return mbus.Scanner{Network: net.NewNetwork(bus, logger), Logger: &logger, ReplyTimeout: time.Second}
+}
+
+func meterAddress(id string, manufacturer string) mbus.SecondaryAddress {
+	return mbus.SecondaryAddress{ID: id, Manufacturer: manufacturer, Version: 1, Medium: 0x04}
+}
+
+func TestScanPrimary(t *testing.T) {
+	bus := &busTransport{devices: []*meter{
+		newMeter(1, "20001234", "SKM"),
+		newMeter(3, "20001235", "SKM"),
+		newMeter(3, "20020001", "SKU"), // одинаковый первичный адрес
+	}}
+	meters, err := newScanner(bus).ScanPrimary(context.Background(), 0, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []mbus.Meter{
+		{Primary: 1, Address: meterAddress("20001234", "SKM")},
+		{Primary: 3, Address: mbus.WildcardAddress(), Collision: true},
+	}
+	if !reflect.DeepEqual(meters, expected) {
+		t.Errorf("найдены %+v, ожидалось %+v", meters, expected)
+	}
+}
+
+func TestScanSecondary(t *testing.T) {
+	bus := &busTransport{devices: []*meter{
+		newMeter(0, "55555555", "SKU"),
+		newMeter(0, "12345679", "SKM"),
+		newMeter(0, "20001234", "SKM"),
+		newMeter(0, "12345678", "SKM"),
+		newMeter(0, "55555555", "SKM"), // одинаковый вторичный номер, различаются только производителем
+	}}
+	meters, err := newScanner(bus).ScanSecondary(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	collision := mbus.WildcardAddress()
+	collision.ID = "55555555"
+	expected := []mbus.Meter{
+		{Primary: -1, Address: meterAddress("12345678", "SKM")},
+		{Primary: -1, Address: meterAddress("12345679", "SKM")},
+		{Primary: -1, Address: meterAddress("20001234", "SKM")},
+		{Primary: -1, Address: collision, Collision: true},
+	}
+	if !reflect.DeepEqual(meters, expected) {
+		t.Errorf("найдены %+v, ожидалось %+v", meters, expected)
+	}
+
+	// После возврата из перебора следующей цифры маска восстанавливается: 2FFFFFFF, а не 2234567F
+	found := false
+	for _, request := range bus.requests {
+		if len(request) > 10 && request[6] == mbus.CISelectAddress {
+			if bytes.Equal(request[7:11], []byte{0xFF, 0xFF, 0xFF, 0x2F}) {
+				found = true
+			}
+		}
+	}
+	if !found {
+		t.Error("нет выбора по маске 2FFFFFFF")
+	}
+	// Выбор снимается по окончании поиска
+	last := bus.requests[len(bus.requests)-1]
+	if !bytes.Equal(last, mbus.ShortFrame(mbus.ControlSndNke, mbus.AddressNetwork)) {
+		t.Errorf("последний запрос %X", last)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	bus := &busTransport{devices: []*meter{newMeter(0, "20001234", "SKM"), newMeter(0, "20001235", "SKM")}}
+	logger := log.NewLoggerService(ozzolog.NewLogger())
+	network := net.NewNetwork(bus, logger)
+
+	address, err := mbus.ParseSecondaryAddress("20001234.skm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mbus.Select(context.Background(), network, address, &logger); err != nil {
+		t.Fatal(err)
+	}
+	request := mbus.ShortFrame(mbus.ControlReqUD2, mbus.AddressNetwork)
+	if response := bus.devices[0].handle(request); response == nil {
+		t.Error("выбранный прибор не отвечает по адресу FDh")
+	}
+	if response := bus.devices[1].handle(request); response != nil {
+		t.Error("отвечает прибор, не выбранный по вторичному адресу")
+	}
+
+	address.Manufacturer = "KAM"
+	if err = mbus.Select(context.Background(), network, address, &logger); err == nil {
+		t.Error("выбран прибор другого производителя")
+	}
+}
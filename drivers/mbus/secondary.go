@@ -0,0 +1,144 @@
+package mbus
+
+import (
+	"context"
+	"fmt"
+	"qBox/services/log"
+	"qBox/services/net"
+	"strconv"
+	"strings"
+)
+
+const (
+	ControlSndUD    byte = 0x53 // Передача данных ведомому, FCB = 0
+	CISelectAddress byte = 0x52 // Выбор прибора по вторичному адресу
+)
+
+/**
+Вторичный адрес прибора M-Bus (EN 13757-2): идентификационный номер, производитель, версия и среда.
+Записывается как ID[.MAN[.VER[.MED]]], например 12345678, 12345678.KAM или 12345678.KAM.1B.04,
+где VER и MED - две шестнадцатеричные цифры. В номере F обозначает любую цифру,
+символ * вместо производителя, версии или среды - любое значение.
+*/
+type SecondaryAddress struct {
+	ID           string // 8 цифр, старшая первой. F - любая цифра
+	Manufacturer string // Код производителя из трёх букв. Пустая строка - любой
+	Version      int    // Версия, -1 - любая
+	Medium       int    // Среда, -1 - любая
+}
+
+// Адрес, под который подходит любой прибор
+func WildcardAddress() SecondaryAddress {
+	return SecondaryAddress{ID: "FFFFFFFF", Version: -1, Medium: -1}
+}
+
+// Вторичный адрес прибора по заголовку его ответа
+func HeaderAddress(header Header) SecondaryAddress {
+	return SecondaryAddress{
+		ID:           fmt.Sprintf("%08d", header.ID),
+		Manufacturer: header.Manufacturer,
+		Version:      int(header.Version),
+		Medium:       int(header.Medium),
+	}
+}
+
+// Разбор вторичного адреса, см. SecondaryAddress
+func ParseSecondaryAddress(value string) (SecondaryAddress, error) {
+	address := WildcardAddress()
+	parts := strings.Split(strings.TrimSpace(value), ".")
+	if len(parts) > 4 {
+		return address, fmt.Errorf("неверный вторичный адрес %q: ожидается ID[.MAN[.VER[.MED]]]", value)
+	}
+
+	id := strings.ToUpper(parts[0])
+	if len(id) != 8 || strings.Trim(id, "0123456789F") != "" {
+		return address, fmt.Errorf("неверный вторичный адрес %q: номер должен состоять из 8 цифр, F - любая цифра", value)
+	}
+	address.ID = id
+
+	if len(parts) > 1 && parts[1] != "*" {
+		manufacturer := strings.ToUpper(parts[1])
+		if len(manufacturer) != 3 || strings.Trim(manufacturer, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+			return address, fmt.Errorf("неверный вторичный адрес %q: код производителя должен состоять из трёх латинских букв", value)
+		}
+		address.Manufacturer = manufacturer
+	}
+	for i, target := range []*int{&address.Version, &address.Medium} {
+		if len(parts) <= i+2 || parts[i+2] == "*" {
+			continue
+		}
+		number, err := strconv.ParseUint(parts[i+2], 16, 8)
+		if err != nil {
+			return address, fmt.Errorf("неверный вторичный адрес %q: версия и среда задаются двумя шестнадцатеричными цифрами", value)
+		}
+		*target = int(number)
+	}
+	return address, nil
+}
+
+func (address SecondaryAddress) String() string {
+	manufacturer, version, medium := "*", "*", "*"
+	if address.Manufacturer != "" {
+		manufacturer = address.Manufacturer
+	}
+	if address.Version >= 0 {
+		version = fmt.Sprintf("%02X", address.Version)
+	}
+	if address.Medium >= 0 {
+		medium = fmt.Sprintf("%02X", address.Medium)
+	}
+	return address.ID + "." + manufacturer + "." + version + "." + medium
+}
+
+// Данные команды выбора: номер BCD младшим байтом первым, производитель, версия, среда. FFh - любое значение
+func (address SecondaryAddress) selectData() []byte {
+	data := make([]byte, 8)
+	for i := 0; i < 4; i++ {
+		digits := address.ID[6-2*i : 8-2*i]
+		data[i] = hexDigit(digits[0])<<4 | hexDigit(digits[1])
+	}
+	data[4], data[5] = 0xFF, 0xFF
+	if address.Manufacturer != "" {
+		data[4], data[5] = encodeManufacturer(address.Manufacturer)
+	}
+	data[6], data[7] = 0xFF, 0xFF
+	if address.Version >= 0 {
+		data[6] = byte(address.Version)
+	}
+	if address.Medium >= 0 {
+		data[7] = byte(address.Medium)
+	}
+	return data
+}
+
+func hexDigit(digit byte) byte {
+	if digit >= '0' && digit <= '9' {
+		return digit - '0'
+	}
+	return 0x0F
+}
+
+func encodeManufacturer(code string) (byte, byte) {
+	value := uint16(code[0]-64)<<10 | uint16(code[1]-64)<<5 | uint16(code[2]-64)
+	return byte(value), byte(value >> 8)
+}
+
+// Кадр выбора прибора по вторичному адресу
+func SelectFrame(address SecondaryAddress) []byte {
+	return LongFrame(ControlSndUD, AddressNetwork, CISelectAddress, address.selectData())
+}
+
+/**
+Выбор прибора по вторичному адресу. После выбора к прибору обращаются по адресу AddressNetwork (0xFD)
+до выбора другого прибора или команды SND_NKE по адресу 0xFD.
+*/
+func Select(ctx context.Context, network *net.Network, address SecondaryAddress, logger *log.LoggerService) error {
+	logger.Info("Выбор прибора по вторичному адресу %s", address.String())
+	request := net.PrepareRequest(SelectFrame(address))
+	request.ControlFunction = Checks{Logger: logger}.CheckAck
+	_, err := network.RunIO(ctx, request)
+	if err != nil {
+		return fmt.Errorf("прибор с вторичным адресом %s не выбран: %w", address.String(), err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package mbus
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseSecondaryAddress(t *testing.T) {
+	for value, expected := range map[string]string{
+		"12345678":           "12345678.*.*.*",
+		"1234567f.kam.1b.04": "1234567F.KAM.1B.04",
+		"12345678.*.*.0C":    "12345678.*.*.0C",
+		" FFFFFFFF.LUG ":     "FFFFFFFF.LUG.*.*",
+	} {
+		address, err := ParseSecondaryAddress(value)
+		if err != nil || address.String() != expected {
+			t.Errorf("%q: %s (%v), ожидалось %s", value, address.String(), err, expected)
+		}
+	}
+	for _, value := range []string{"", "1234567", "123456789", "1234567A", "12345678.KA", "12345678.K1M",
+		"12345678.KAM.GG", "12345678.KAM.1B.100", "1.2.3.4.5"} {
+		if _, err := ParseSecondaryAddress(value); err == nil {
+			t.Errorf("%q: ожидалась ошибка", value)
+		}
+	}
+}
+
+// Номер BCD младшим байтом первым, F - любая цифра, FFh - любые производитель, версия, среда
+func TestSelectFrame(t *testing.T) {
+	address, err := ParseSecondaryAddress("1234567F.KAM.1B.04")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := LongFrame(ControlSndUD, AddressNetwork, CISelectAddress, []byte{0x7F, 0x56, 0x34, 0x12, 0x2D, 0x2C, 0x1B, 0x04})
+	if frame := SelectFrame(address); !bytes.Equal(frame, expected) {
+		t.Errorf("кадр %X, ожидался %X", frame, expected)
+	}
+	data := WildcardAddress().selectData()
+	if !bytes.Equal(data, bytes.Repeat([]byte{0xFF}, 8)) {
+		t.Errorf("любой адрес: %X", data)
+	}
+	header := Header{ID: 20001234, Manufacturer: "SKM", Version: 1, Medium: 4}
+	if address := HeaderAddress(header).String(); address != "20001234.SKM.01.04" {
+		t.Errorf("адрес по заголовку %s", address)
+	}
+}
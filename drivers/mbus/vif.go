@@ -0,0 +1,266 @@
+package mbus
+
+import "math"
+
+type Quantity string // Физическая величина записи, определяется по VIF
+
+const (
+	QuantityUnknown            Quantity = ""
+	QuantityEnergy             Quantity = "energy"
+	QuantityVolume             Quantity = "volume"
+	QuantityMass               Quantity = "mass"
+	QuantityOnTime             Quantity = "on time"
+	QuantityOperatingTime      Quantity = "operating time"
+	QuantityPower              Quantity = "power"
+	QuantityVolumeFlow         Quantity = "volume flow"
+	QuantityMassFlow           Quantity = "mass flow"
+	QuantityFlowTemperature    Quantity = "flow temperature"
+	QuantityReturnTemperature  Quantity = "return temperature"
+	QuantityTemperatureDiff    Quantity = "temperature difference"
+	QuantityExternalTemp       Quantity = "external temperature"
+	QuantityPressure           Quantity = "pressure"
+	QuantityDate               Quantity = "date"
+	QuantityDateTime           Quantity = "date time"
+	QuantityHCA                Quantity = "heat cost allocation"
+	QuantityAveragingDuration  Quantity = "averaging duration"
+	QuantityActualityDuration  Quantity = "actuality duration"
+	QuantityFabricationNumber  Quantity = "fabrication number"
+	QuantityIdentification     Quantity = "identification"
+	QuantityBusAddress         Quantity = "bus address"
+	QuantityCredit             Quantity = "credit"
+	QuantityDebit              Quantity = "debit"
+	QuantityAccessNumber       Quantity = "access number"
+	QuantityMedium             Quantity = "medium"
+	QuantityManufacturer       Quantity = "manufacturer"
+	QuantityParameterSet       Quantity = "parameter set"
+	QuantityModelVersion       Quantity = "model version"
+	QuantityHardwareVersion    Quantity = "hardware version"
+	QuantityFirmwareVersion    Quantity = "firmware version"
+	QuantitySoftwareVersion    Quantity = "software version"
+	QuantityErrorFlags         Quantity = "error flags"
+	QuantityDigitalOutput      Quantity = "digital output"
+	QuantityDigitalInput       Quantity = "digital input"
+	QuantityStorageInterval    Quantity = "storage interval"
+	QuantitySinceLastReadout   Quantity = "duration since last readout"
+	QuantityVoltage            Quantity = "voltage"
+	QuantityCurrent            Quantity = "current"
+	QuantityResetCounter       Quantity = "reset counter"
+	QuantityCumulationCounter  Quantity = "cumulation counter"
+	QuantityBatteryTime        Quantity = "battery operating time"
+	QuantityBatteryChange      Quantity = "battery change date"
+	QuantityTemperatureLimit   Quantity = "temperature limit"
+	QuantityMaxPowerCount      Quantity = "cumulative max power count"
+	QuantityPlainText          Quantity = "plain text"
+	QuantityAny                Quantity = "any"
+	QuantityManufacturerRecord Quantity = "manufacturer specific"
+)
+
+/**
+Описание значения записи по VIF и VIFE: величина, единица измерения и десятичный порядок.
+Значение в единицах Unit равно числу из поля данных, умноженному на 10^Exponent, плюс Additive.
+*/
+type Value struct {
+	Quantity     Quantity
+	Unit         string  // Единица измерения: Wh, J, m3, kg, W, J/h, m3/h, kg/h, C, K, bar, s, V, A...
+	Exponent     int     // Десятичный порядок множителя
+	Additive     float64 // Аддитивная поправка из VIFE 0x78-0x7B, в единицах Unit
+	PlainUnit    string  // Единица измерения, переданная текстом (VIF 0x7C)
+	Manufacturer bool    // Значение в формате производителя (VIF 0x7F или VIFE 0x7F)
+	Accumulation string  // Уточнение накопления из VIFE: "positive" (0x3B) или "negative" (0x3C)
+	Per          string  // Уточнение "на единицу" из VIFE 0x20-0x35, например "h" или "m3"
+}
+
+var timeUnits = [4]string{"s", "min", "h", "d"}
+
+// Расшифровка основного VIF (без бита расширения), таблица 10 EN 13757-3
+func primaryValue(vif byte) Value {
+	n := int(vif & 0x07)
+	nn := int(vif & 0x03)
+	switch {
+	case vif <= 0x07:
+		return Value{Quantity: QuantityEnergy, Unit: "Wh", Exponent: n - 3}
+	case vif <= 0x0F:
+		return Value{Quantity: QuantityEnergy, Unit: "J", Exponent: n}
+	case vif <= 0x17:
+		return Value{Quantity: QuantityVolume, Unit: "m3", Exponent: n - 6}
+	case vif <= 0x1F:
+		return Value{Quantity: QuantityMass, Unit: "kg", Exponent: n - 3}
+	case vif <= 0x23:
+		return Value{Quantity: QuantityOnTime, Unit: timeUnits[nn]}
+	case vif <= 0x27:
+		return Value{Quantity: QuantityOperatingTime, Unit: timeUnits[nn]}
+	case vif <= 0x2F:
+		return Value{Quantity: QuantityPower, Unit: "W", Exponent: n - 3}
+	case vif <= 0x37:
+		return Value{Quantity: QuantityPower, Unit: "J/h", Exponent: n}
+	case vif <= 0x3F:
+		return Value{Quantity: QuantityVolumeFlow, Unit: "m3/h", Exponent: n - 6}
+	case vif <= 0x47:
+		return Value{Quantity: QuantityVolumeFlow, Unit: "m3/min", Exponent: n - 7}
+	case vif <= 0x4F:
+		return Value{Quantity: QuantityVolumeFlow, Unit: "m3/s", Exponent: n - 9}
+	case vif <= 0x57:
+		return Value{Quantity: QuantityMassFlow, Unit: "kg/h", Exponent: n - 3}
+	case vif <= 0x5B:
+		return Value{Quantity: QuantityFlowTemperature, Unit: "C", Exponent: nn - 3}
+	case vif <= 0x5F:
+		return Value{Quantity: QuantityReturnTemperature, Unit: "C", Exponent: nn - 3}
+	case vif <= 0x63:
+		return Value{Quantity: QuantityTemperatureDiff, Unit: "K", Exponent: nn - 3}
+	case vif <= 0x67:
+		return Value{Quantity: QuantityExternalTemp, Unit: "C", Exponent: nn - 3}
+	case vif <= 0x6B:
+		return Value{Quantity: QuantityPressure, Unit: "bar", Exponent: nn - 3}
+	case vif == 0x6C:
+		return Value{Quantity: QuantityDate}
+	case vif == 0x6D:
+		return Value{Quantity: QuantityDateTime}
+	case vif == 0x6E:
+		return Value{Quantity: QuantityHCA}
+	case vif >= 0x70 && vif <= 0x73:
+		return Value{Quantity: QuantityAveragingDuration, Unit: timeUnits[nn]}
+	case vif >= 0x74 && vif <= 0x77:
+		return Value{Quantity: QuantityActualityDuration, Unit: timeUnits[nn]}
+	case vif == 0x78:
+		return Value{Quantity: QuantityFabricationNumber}
+	case vif == 0x79:
+		return Value{Quantity: QuantityIdentification}
+	case vif == 0x7A:
+		return Value{Quantity: QuantityBusAddress}
+	case vif == 0x7C:
+		return Value{Quantity: QuantityPlainText}
+	case vif == 0x7E:
+		return Value{Quantity: QuantityAny}
+	case vif == 0x7F:
+		return Value{Quantity: QuantityManufacturerRecord, Manufacturer: true}
+	}
+	return Value{}
+}
+
+// Расшифровка первой таблицы расширения (VIF 0xFB), таблица 14 EN 13757-3
+func extensionFBValue(code byte) Value {
+	n := int(code & 0x01)
+	nn := int(code & 0x03)
+	switch {
+	case code <= 0x01:
+		return Value{Quantity: QuantityEnergy, Unit: "MWh", Exponent: n - 1}
+	case code >= 0x08 && code <= 0x09:
+		return Value{Quantity: QuantityEnergy, Unit: "GJ", Exponent: n - 1}
+	case code >= 0x10 && code <= 0x11:
+		return Value{Quantity: QuantityVolume, Unit: "m3", Exponent: n + 2}
+	case code >= 0x18 && code <= 0x19:
+		return Value{Quantity: QuantityMass, Unit: "t", Exponent: n + 2}
+	case code == 0x21:
+		return Value{Quantity: QuantityVolume, Unit: "ft3", Exponent: -1}
+	case code >= 0x22 && code <= 0x23:
+		return Value{Quantity: QuantityVolume, Unit: "gal", Exponent: n - 1}
+	case code == 0x24:
+		return Value{Quantity: QuantityVolumeFlow, Unit: "gal/min", Exponent: -3}
+	case code == 0x25:
+		return Value{Quantity: QuantityVolumeFlow, Unit: "gal/min"}
+	case code == 0x26:
+		return Value{Quantity: QuantityVolumeFlow, Unit: "gal/h"}
+	case code >= 0x28 && code <= 0x29:
+		return Value{Quantity: QuantityPower, Unit: "MW", Exponent: n - 1}
+	case code >= 0x30 && code <= 0x31:
+		return Value{Quantity: QuantityPower, Unit: "GJ/h", Exponent: n - 1}
+	case code >= 0x58 && code <= 0x5B:
+		return Value{Quantity: QuantityFlowTemperature, Unit: "F", Exponent: nn - 3}
+	case code >= 0x5C && code <= 0x5F:
+		return Value{Quantity: QuantityReturnTemperature, Unit: "F", Exponent: nn - 3}
+	case code >= 0x60 && code <= 0x63:
+		return Value{Quantity: QuantityTemperatureDiff, Unit: "F", Exponent: nn - 3}
+	case code >= 0x64 && code <= 0x67:
+		return Value{Quantity: QuantityExternalTemp, Unit: "F", Exponent: nn - 3}
+	case code >= 0x70 && code <= 0x73:
+		return Value{Quantity: QuantityTemperatureLimit, Unit: "F", Exponent: nn - 3}
+	case code >= 0x74 && code <= 0x77:
+		return Value{Quantity: QuantityTemperatureLimit, Unit: "C", Exponent: nn - 3}
+	case code >= 0x78:
+		return Value{Quantity: QuantityMaxPowerCount, Unit: "W", Exponent: int(code&0x07) - 3}
+	}
+	return Value{}
+}
+
+// Расшифровка второй таблицы расширения (VIF 0xFD), таблица 12 EN 13757-3
+func extensionFDValue(code byte) Value {
+	nn := int(code & 0x03)
+	switch {
+	case code <= 0x03:
+		return Value{Quantity: QuantityCredit, Exponent: nn - 3}
+	case code <= 0x07:
+		return Value{Quantity: QuantityDebit, Exponent: nn - 3}
+	case code == 0x08:
+		return Value{Quantity: QuantityAccessNumber}
+	case code == 0x09:
+		return Value{Quantity: QuantityMedium}
+	case code == 0x0A:
+		return Value{Quantity: QuantityManufacturer}
+	case code == 0x0B:
+		return Value{Quantity: QuantityParameterSet}
+	case code == 0x0C:
+		return Value{Quantity: QuantityModelVersion}
+	case code == 0x0D:
+		return Value{Quantity: QuantityHardwareVersion}
+	case code == 0x0E:
+		return Value{Quantity: QuantityFirmwareVersion}
+	case code == 0x0F:
+		return Value{Quantity: QuantitySoftwareVersion}
+	case code == 0x17:
+		return Value{Quantity: QuantityErrorFlags}
+	case code == 0x1A:
+		return Value{Quantity: QuantityDigitalOutput}
+	case code == 0x1B:
+		return Value{Quantity: QuantityDigitalInput}
+	case code >= 0x24 && code <= 0x27:
+		return Value{Quantity: QuantityStorageInterval, Unit: timeUnits[nn]}
+	case code == 0x28:
+		return Value{Quantity: QuantityStorageInterval, Unit: "month"}
+	case code == 0x29:
+		return Value{Quantity: QuantityStorageInterval, Unit: "year"}
+	case code >= 0x2C && code <= 0x2F:
+		return Value{Quantity: QuantitySinceLastReadout, Unit: timeUnits[nn]}
+	case code >= 0x40 && code <= 0x4F:
+		return Value{Quantity: QuantityVoltage, Unit: "V", Exponent: int(code&0x0F) - 9}
+	case code >= 0x50 && code <= 0x5F:
+		return Value{Quantity: QuantityCurrent, Unit: "A", Exponent: int(code&0x0F) - 12}
+	case code == 0x60:
+		return Value{Quantity: QuantityResetCounter}
+	case code == 0x61:
+		return Value{Quantity: QuantityCumulationCounter}
+	case code >= 0x6C && code <= 0x6F:
+		return Value{Quantity: QuantityBatteryTime, Unit: [4]string{"h", "d", "month", "year"}[nn]}
+	case code == 0x70:
+		return Value{Quantity: QuantityBatteryChange}
+	}
+	return Value{}
+}
+
+var perUnits = map[byte]string{
+	0x20: "s", 0x21: "min", 0x22: "h", 0x23: "d", 0x24: "week", 0x25: "month", 0x26: "year",
+	0x27: "revolution", 0x2C: "l", 0x2D: "m3", 0x2E: "kg", 0x2F: "K",
+	0x30: "kWh", 0x31: "GJ", 0x32: "kW", 0x33: "K*l", 0x34: "V", 0x35: "A",
+}
+
+// Применение комбинируемых расширений VIFE (таблица 15 EN 13757-3) к описанию значения
+func (value *Value) applyVIFE(vife byte) {
+	code := vife & 0x7F
+	switch {
+	case code == 0x3B:
+		value.Accumulation = "positive"
+	case code == 0x3C:
+		value.Accumulation = "negative"
+	case code >= 0x70 && code <= 0x77:
+		value.Exponent += int(code&0x07) - 6
+	case code >= 0x78 && code <= 0x7B:
+		value.Additive = math.Pow10(int(code&0x03) - 3)
+	case code == 0x7D:
+		value.Exponent += 3
+	case code == 0x7F:
+		value.Manufacturer = true
+	default:
+		if per, ok := perUnits[code]; ok {
+			value.Per = per
+		}
+	}
+}
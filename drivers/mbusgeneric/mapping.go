@@ -0,0 +1,265 @@
+package mbusgeneric
+
+import (
+	"bytes"
+	"fmt"
+	"qBox/drivers/mbus"
+	"qBox/models"
+)
+
+type fieldKind byte // Вид величины поля данных, определяет единицы, к которым приводится значение
+const (
+	kindEnergy      fieldKind = iota // Энергия в единицах DataDevice.UnitQ
+	kindVolume                       // м3
+	kindMass                         // т
+	kindVolumeFlow                   // м3/ч
+	kindMassFlow                     // т/ч
+	kindTemperature                  // градусы Цельсия
+	kindPressure                     // МПа
+	kindDuration                     // секунды
+	kindTime                         // время на приборе
+	kindSerial                       // серийный номер
+)
+
+type field struct {
+	kind   fieldKind
+	system func(system *models.SystemDevice, value float64)    // Поле системы
+	device func(dataDevice *models.DataDevice, value float64) // Поле теплосчётчика
+}
+
+// Поля данных теплосчётчика, доступные для сопоставления
+var fields = map[string]field{
+	"SigmaQ":        {kind: kindEnergy, system: func(s *models.SystemDevice, v float64) { s.SigmaQ = v }},
+	"Q1":            {kind: kindEnergy, system: func(s *models.SystemDevice, v float64) { s.Q1 = v }},
+	"Q2":            {kind: kindEnergy, system: func(s *models.SystemDevice, v float64) { s.Q2 = v }},
+	"Q3":            {kind: kindEnergy, system: func(s *models.SystemDevice, v float64) { s.Q3 = v }},
+	"V1":            {kind: kindVolume, system: func(s *models.SystemDevice, v float64) { s.V1 = v }},
+	"V2":            {kind: kindVolume, system: func(s *models.SystemDevice, v float64) { s.V2 = v }},
+	"M1":            {kind: kindMass, system: func(s *models.SystemDevice, v float64) { s.M1 = v }},
+	"M2":            {kind: kindMass, system: func(s *models.SystemDevice, v float64) { s.M2 = v }},
+	"GV1":           {kind: kindVolumeFlow, system: func(s *models.SystemDevice, v float64) { s.GV1 = float32(v) }},
+	"GV2":           {kind: kindVolumeFlow, system: func(s *models.SystemDevice, v float64) { s.GV2 = float32(v) }},
+	"GM1":           {kind: kindMassFlow, system: func(s *models.SystemDevice, v float64) { s.GM1 = float32(v) }},
+	"GM2":           {kind: kindMassFlow, system: func(s *models.SystemDevice, v float64) { s.GM2 = float32(v) }},
+	"T1":            {kind: kindTemperature, system: func(s *models.SystemDevice, v float64) { s.T1 = float32(v) }},
+	"T2":            {kind: kindTemperature, system: func(s *models.SystemDevice, v float64) { s.T2 = float32(v) }},
+	"T3":            {kind: kindTemperature, system: func(s *models.SystemDevice, v float64) { s.T3 = float32(v) }},
+	"P1":            {kind: kindPressure, system: func(s *models.SystemDevice, v float64) { s.P1 = float32(v) }},
+	"P2":            {kind: kindPressure, system: func(s *models.SystemDevice, v float64) { s.P2 = float32(v) }},
+	"P3":            {kind: kindPressure, system: func(s *models.SystemDevice, v float64) { s.P3 = float32(v) }},
+	"M3":            {kind: kindMass, system: func(s *models.SystemDevice, v float64) { s.M3 = v }},
+	"GM3":           {kind: kindMassFlow, system: func(s *models.SystemDevice, v float64) { s.GM3 = float32(v) }},
+	"GV3":           {kind: kindVolumeFlow, system: func(s *models.SystemDevice, v float64) { s.GV3 = float32(v) }},
+	"TMakeup":       {kind: kindTemperature, system: func(s *models.SystemDevice, v float64) { s.TMakeup = float32(v) }},
+	"PMakeup":       {kind: kindPressure, system: func(s *models.SystemDevice, v float64) { s.PMakeup = float32(v) }},
+	"TimeRunSys":    {kind: kindDuration, system: func(s *models.SystemDevice, v float64) { s.TimeRunSys = uint32(v) }},
+	"TimeOn":        {kind: kindDuration, device: func(d *models.DataDevice, v float64) { d.TimeOn = uint32(v) }},
+	"TimeRunCommon": {kind: kindDuration, device: func(d *models.DataDevice, v float64) { d.TimeRunCommon = uint32(v) }},
+	"Time":          {kind: kindTime},
+	"Serial":        {kind: kindSerial},
+}
+
+// Стандартное сопоставление: величина VIF -> поля по порядку появления записей в подустройстве
+var defaultFields = map[mbus.Quantity][]string{
+	mbus.QuantityEnergy:            {"SigmaQ", "Q1", "Q2", "Q3"},
+	mbus.QuantityVolume:            {"V1", "V2"},
+	mbus.QuantityMass:              {"M1", "M2"},
+	mbus.QuantityVolumeFlow:        {"GV1", "GV2"},
+	mbus.QuantityMassFlow:          {"GM1", "GM2"},
+	mbus.QuantityFlowTemperature:   {"T1"},
+	mbus.QuantityReturnTemperature: {"T2"},
+	mbus.QuantityExternalTemp:      {"T3"},
+	mbus.QuantityPressure:          {"P1", "P2", "P3"},
+	mbus.QuantityOnTime:            {"TimeOn"},
+	mbus.QuantityOperatingTime:     {"TimeRunSys"},
+	mbus.QuantityDateTime:          {"Time"},
+	mbus.QuantityDate:              {"Time"},
+	mbus.QuantityFabricationNumber: {"Serial"},
+}
+
+/**
+Наибольшее количество систем теплосчётчика: у приборов, которые опрашивают драйверы, не больше 4 систем (ТЭМ-104).
+Номер подустройства записи без настройки модели используется как номер системы только в этих пределах,
+иначе ошибочный DIFE привёл бы к созданию сотен пустых систем.
+*/
+const maxSystems = 4
+
+type quantityKey struct {
+	subunit  uint32
+	quantity mbus.Quantity
+}
+
+/**
+Заполнение данных теплосчётчика записями M-Bus
+*/
+type mapper struct {
+	data      *models.DataDevice
+	model     *Model
+	unitQSet  bool
+	positions map[quantityKey]int
+}
+
+func newMapper(data *models.DataDevice, model *Model) *mapper {
+	return &mapper{data: data, model: model, positions: map[quantityKey]int{}}
+}
+
+// Сопоставление записи полю данных. Возвращает имя поля или пустую строку, если запись не используется
+func (m *mapper) apply(record mbus.Record) (string, error) {
+	name, system, scale := m.resolve(record)
+	if name == "" {
+		return "", nil
+	}
+	target := fields[name]
+
+	switch target.kind {
+	case kindTime:
+		if record.Type == mbus.DataDate && !m.data.Time.IsZero() {
+			return "", nil // дата без времени не заменяет дату и время
+		}
+		value, err := record.Time()
+		if err != nil {
+			return name, err
+		}
+		m.data.Time = value
+		return name, nil
+	case kindSerial:
+		if m.data.Serial == "" {
+			m.data.Serial = record.String()
+			if record.Type == mbus.DataBCD {
+				number, err := record.Int()
+				if err != nil {
+					return name, err
+				}
+				m.data.Serial = fmt.Sprintf("%08d", number)
+			}
+		}
+		return name, nil
+	}
+
+	value, err := record.Float()
+	if err != nil {
+		return name, err
+	}
+	value = m.convert(target.kind, record, value) * scale
+
+	if target.device != nil {
+		target.device(m.data, value)
+		return name, nil
+	}
+	m.data.AddNewSystem(system)
+	m.data.Systems[system].Status = true
+	target.system(&m.data.Systems[system], value)
+	return name, nil
+}
+
+// Поле, номер системы и множитель для записи: по настройкам модели, иначе по стандартному VIF
+func (m *mapper) resolve(record mbus.Record) (string, int, float64) {
+	if m.model != nil {
+		header := record.Header()
+		for _, recordField := range m.model.Records {
+			if !bytes.Equal(recordField.header, header) {
+				continue
+			}
+			if recordField.Field == "-" {
+				return "", 0, 0
+			}
+			scale := 1.0
+			if recordField.Scale != nil {
+				scale = *recordField.Scale
+			}
+			return recordField.Field, recordField.System, scale
+		}
+		if m.model.Defaults != nil && !*m.model.Defaults {
+			return "", 0, 0
+		}
+	}
+
+	// Стандартно используются только текущие значения: архивные, тарифные, минимальные и максимальные пропускаются
+	if record.Storage != 0 || record.Tariff != 0 || record.Function != mbus.FunctionInstantaneous || record.Manufacturer {
+		return "", 0, 0
+	}
+	names, ok := defaultFields[record.Quantity]
+	if !ok || record.Subunit >= maxSystems {
+		return "", 0, 0
+	}
+	key := quantityKey{subunit: record.Subunit, quantity: record.Quantity}
+	position := m.positions[key]
+	m.positions[key]++
+	if position >= len(names) {
+		return "", 0, 0
+	}
+	return names[position], int(record.Subunit), 1
+}
+
+// Приведение значения в единицах записи к единицам поля данных
+func (m *mapper) convert(kind fieldKind, record mbus.Record, value float64) float64 {
+	switch kind {
+	case kindEnergy:
+		return m.energy(record.Unit, value)
+	case kindMass:
+		if record.Unit == "kg" {
+			return value / 1000
+		}
+	case kindVolumeFlow:
+		switch record.Unit {
+		case "m3/min":
+			return value * 60
+		case "m3/s":
+			return value * 3600
+		}
+	case kindMassFlow:
+		if record.Unit == "kg/h" {
+			return value / 1000
+		}
+	case kindTemperature:
+		if record.Unit == "F" && record.Quantity != mbus.QuantityTemperatureDiff {
+			return (value - 32) * 5 / 9
+		}
+	case kindPressure:
+		if record.Unit == "bar" {
+			return value / 10
+		}
+	case kindDuration:
+		switch record.Unit {
+		case "min":
+			return value * 60
+		case "h":
+			return value * 3600
+		case "d":
+			return value * 86400
+		}
+	}
+	return value
+}
+
+/**
+Энергия приводится к единицам первой записи энергии: Wh и MWh - к МВт*ч, J и GJ - к ГДж.
+Единицы записываются в DataDevice.UnitQ.
+*/
+func (m *mapper) energy(unit string, value float64) float64 {
+	var unitQ models.UnitQEnum
+	switch unit {
+	case "Wh":
+		unitQ, value = models.MWh, value/1e6
+	case "MWh":
+		unitQ = models.MWh
+	case "J":
+		unitQ, value = models.GJ, value/1e9
+	case "GJ":
+		unitQ = models.GJ
+	default:
+		return value
+	}
+
+	if !m.unitQSet {
+		m.data.UnitQ = unitQ
+		m.unitQSet = true
+	}
+	switch {
+	case unitQ == models.MWh && m.data.UnitQ == models.GJ:
+		value *= 3.6
+	case unitQ == models.GJ && m.data.UnitQ == models.MWh:
+		value /= 3.6
+	}
+	return value
+}
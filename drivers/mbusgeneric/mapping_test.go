@@ -0,0 +1,169 @@
+package mbusgeneric
+
+import (
+	"math"
+	"qBox/drivers/mbus"
+	"qBox/models"
+	"testing"
+	"time"
+)
+
+// Записи M-Bus из байтов DIB, VIB и значения
+func parseRecords(t *testing.T, data []byte) []mbus.Record {
+	t.Helper()
+	result, err := mbus.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result.Records
+}
+
+func mapRecords(t *testing.T, model *Model, data []byte) (*models.DataDevice, []string) {
+	t.Helper()
+	device := &models.DataDevice{}
+	mapper := newMapper(device, model)
+	var names []string
+	for _, record := range parseRecords(t, data) {
+		name, err := mapper.apply(record)
+		if err != nil {
+			t.Fatalf("запись %X: %v", record.Header(), err)
+		}
+		names = append(names, name)
+	}
+	return device, names
+}
+
+func near(value float64, expected float64) bool {
+	return math.Abs(value-expected) < 1e-6
+}
+
+// Стандартное сопоставление по VIF с приведением единиц
+func TestDefaultMapping(t *testing.T) {
+	data := []byte{
+		0x04, 0x06, 0x80, 0xD6, 0x12, 0x00, // энергия 1234560 кВт*ч -> SigmaQ, МВт*ч
+		0x04, 0x0E, 0x10, 0x0E, 0x00, 0x00, // энергия 3600 МДж -> Q1, 1 МВт*ч
+		0x84, 0x40, 0x06, 0xE8, 0x03, 0x00, 0x00, // подустройство 1: энергия 1000 кВт*ч
+		0x44, 0x06, 0x01, 0x00, 0x00, 0x00, // архивное значение
+		0x84, 0x10, 0x06, 0x01, 0x00, 0x00, 0x00, // тарифное значение
+		0x14, 0x06, 0x01, 0x00, 0x00, 0x00, // максимальное значение
+		0x04, 0x1B, 0xC6, 0x0C, 0xA8, 0x02, // масса 44567750 кг
+		0x02, 0x5A, 0xD5, 0x02, // подача 72.5 °C
+		0x02, 0xFB, 0x5E, 0xC4, 0x04, // обратка 122.0 °F
+		0x02, 0x69, 0x6C, 0x02, // давление 6.2 бар
+		0x02, 0x22, 0x0A, 0x00, // время включения 10 ч
+		0x04, 0x6D, 0x1E, 0x0A, 0x52, 0x3A, // 18.10.2026 10:30
+		0x02, 0x6C, 0x51, 0x3A, // дата без времени не заменяет дату и время
+		0x0C, 0x78, 0x78, 0x56, 0x34, 0x12, // заводской номер
+		0x01, 0xFF, 0x01, 0x05, // запись производителя
+		0x05, 0x3E, 0x00, 0x00, 0xA0, 0x3F, // расход 1.25 м3/ч
+		0x02, 0x53, 0xCA, 0x04, // массовый расход 1226 кг/ч
+		0x02, 0x5B, 0x01, 0x00, // вторая температура подачи не сопоставляется
+	}
+	device, names := mapRecords(t, nil, data)
+	expectedNames := []string{"SigmaQ", "Q1", "SigmaQ", "", "", "", "M1", "T1", "T2", "P1", "TimeOn", "Time", "",
+		"Serial", "", "GV1", "GM1", ""}
+	if len(names) != len(expectedNames) {
+		t.Fatalf("поля %v, ожидалось %v", names, expectedNames)
+	}
+	for i := range names {
+		if names[i] != expectedNames[i] {
+			t.Errorf("запись %d: поле %q, ожидалось %q", i+1, names[i], expectedNames[i])
+		}
+	}
+
+	if device.UnitQ != models.MWh || len(device.Systems) < 2 {
+		t.Fatalf("единицы %d, систем %d", device.UnitQ, len(device.Systems))
+	}
+	system := device.Systems[0]
+	if !near(system.SigmaQ, 1234.56) || !near(system.Q1, 1) || !near(device.Systems[1].SigmaQ, 1) {
+		t.Errorf("энергия %v, %v, система 2: %v", system.SigmaQ, system.Q1, device.Systems[1].SigmaQ)
+	}
+	if !near(system.M1, 44567.75) || !near(float64(system.GV1), 1.25) || !near(float64(system.GM1), 1.226) {
+		t.Errorf("масса %v, расходы %v, %v", system.M1, system.GV1, system.GM1)
+	}
+	if !near(float64(system.T1), 72.5) || !near(float64(system.T2), 50) || !near(float64(system.P1), 0.62) {
+		t.Errorf("температуры %v, %v, давление %v", system.T1, system.T2, system.P1)
+	}
+	if device.TimeOn != 36000 || device.Serial != "12345678" ||
+		!device.Time.Equal(time.Date(2026, 10, 18, 10, 30, 0, 0, time.Local)) {
+		t.Errorf("время включения %d, номер %s, время %v", device.TimeOn, device.Serial, device.Time)
+	}
+	if !system.Status || !device.Systems[1].Status {
+		t.Error("системы с записями должны быть активны")
+	}
+}
+
+// Энергия приводится к единицам первой записи энергии
+func TestEnergyUnits(t *testing.T) {
+	device, _ := mapRecords(t, nil, []byte{
+		0x04, 0xFB, 0x09, 0x24, 0x00, 0x00, 0x00, // 36 ГДж -> SigmaQ
+		0x04, 0xFB, 0x01, 0x05, 0x00, 0x00, 0x00, // 5 МВт*ч -> Q1
+	})
+	if device.UnitQ != models.GJ || !near(device.Systems[0].SigmaQ, 36) || !near(device.Systems[0].Q1, 18) {
+		t.Errorf("единицы %d, энергия %v, %v", device.UnitQ, device.Systems[0].SigmaQ, device.Systems[0].Q1)
+	}
+}
+
+// Сопоставление по настройкам модели: поле, система и множитель по заголовку записи
+func TestModelMapping(t *testing.T) {
+	defaults := false
+	scale := 0.5
+	err := SetModels([]Model{{
+		Manufacturer: "KAM",
+		Defaults:     &defaults,
+		Records: []RecordField{
+			{Header: "04 06", Field: "Q2", System: 1, Scale: &scale},
+			{Header: "025A", Field: "-"},
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = SetModels(nil) })
+
+	model := findModel(mbus.Header{Manufacturer: "kam"})
+	if model == nil || findModel(mbus.Header{Manufacturer: "LUG"}) != nil {
+		t.Fatal("модель должна выбираться по коду производителя без учёта регистра")
+	}
+	device, names := mapRecords(t, model, []byte{
+		0x04, 0x06, 0x80, 0xD6, 0x12, 0x00,
+		0x02, 0x5A, 0xD5, 0x02,
+		0x02, 0x69, 0x6C, 0x02,
+	})
+	if names[0] != "Q2" || names[1] != "" || names[2] != "" {
+		t.Errorf("поля %v", names)
+	}
+	if len(device.Systems) != 2 || !near(device.Systems[1].Q2, 617.28) || device.Systems[0].Status {
+		t.Errorf("системы %+v", device.Systems)
+	}
+
+	for _, invalid := range []Model{
+		{Manufacturer: "KAMS"},
+		{Manufacturer: "KAM", Records: []RecordField{{Header: "0X06", Field: "Q1"}}},
+		{Manufacturer: "KAM", Records: []RecordField{{Header: "0406", Field: "Q4"}}},
+		{Manufacturer: "KAM", Records: []RecordField{{Header: "0406", Field: "Q1", System: -1}}},
+	} {
+		if SetModels([]Model{invalid}) == nil {
+			t.Errorf("модель %+v должна отклоняться", invalid)
+		}
+	}
+}
+
+// Номер подустройства вне пределов количества систем не создаёт систем без настройки модели
+func TestSubunitLimit(t *testing.T) {
+	device, names := mapRecords(t, nil, []byte{
+		0x04, 0x06, 0x80, 0xD6, 0x12, 0x00, // подустройство 0 -> система 0
+		0x84, 0xC0, 0x40, 0x06, 0xE8, 0x03, 0x00, 0x00, // подустройство 3 -> система 3
+		0x84, 0x80, 0x80, 0x80, 0x80, 0x40, 0x06, 0xE8, 0x03, 0x00, 0x00, // подустройство 16 пропускается
+	})
+	if names[0] != "SigmaQ" || names[1] != "SigmaQ" || names[2] != "" {
+		t.Errorf("поля %v", names)
+	}
+	if len(device.Systems) != 4 || !device.Systems[3].Status {
+		t.Errorf("системы %+v", device.Systems)
+	}
+
+	if SetModels([]Model{{Manufacturer: "KAM", Records: []RecordField{{Header: "0406", Field: "Q1", System: maxSystems}}}}) == nil {
+		t.Error("номер системы модели вне пределов должен отклоняться")
+	}
+}
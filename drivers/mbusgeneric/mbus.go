@@ -0,0 +1,142 @@
+package mbusgeneric
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"qBox/drivers"
+	"qBox/drivers/mbus"
+	"qBox/models"
+	"qBox/services/log"
+	"qBox/services/net"
+	"time"
+)
+
+const maxTelegrams = 16 // Ограничение количества посылок с продолжением записей (DIF 1Fh)
+
+/**
+Универсальный драйвер теплосчётчиков с протоколом M-Bus (EN 13757-2, EN 13757-3).
+Данные читаются запросом REQ_UD2 и заполняются по стандартным VIF: энергия, объём, масса, расходы,
+температуры, давления, время работы и время на приборе. Номер подустройства записи - номер системы.
+Сопоставление для конкретных моделей приборов настраивается, см. Model.
+*/
+type MBus struct {
+	data          models.DataDevice
+	network       *net.Network
+	logger        *log.LoggerService
+	counterNumber byte
+	checks        mbus.Checks
+}
+
+func init() {
+	drivers.Register("mbus", func() models.IDeviceDriver { return new(MBus) }, drivers.Metadata{
+		Title:    "Теплосчётчик M-Bus (универсальный)",
+		Protocol: "M-Bus (EN 13757-3)",
+	})
+}
+
+/**
+counterNumber - первичный адрес прибора:
+0 - несконфигурированный прибор, 1-250 - адреса приборов, 254 (0xFE) - широковещательный адрес,
+на который отвечает любой прибор (только при одном приборе на линии).
+*/
+func (driver *MBus) Init(ctx context.Context, counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+	*driver = MBus{} // см. models.IDeviceDriver::Init
+	driver.logger = logger
+	driver.network = network
+	driver.counterNumber = counterNumber
+	driver.checks = mbus.Checks{Logger: logger}
+
+	if driver.counterNumber == mbus.AddressNetwork {
+		// Прибор выбран по вторичному адресу, SND_NKE снимет выбор, см. models.IMBusDriver
+		return nil
+	}
+
+	driver.logger.Info("Запрос на инициализацию прибора (SND_NKE), № %d", driver.counterNumber)
+	request := net.PrepareRequest(mbus.ShortFrame(mbus.ControlSndNke, driver.counterNumber))
+	request.ControlFunction = driver.checks.CheckAck
+	_, err := driver.network.RunIO(ctx, request)
+	return err
+}
+
+// Прибор M-Bus, см. models.IMBusDriver
+func (driver *MBus) MBus() {}
+
+// Реализация интерфейса IClockDriver::SetTime. Время записывается с точностью до минуты, см. mbus.SetTime
+func (driver *MBus) SetTime(ctx context.Context, moment time.Time) error {
+	return mbus.SetTime(ctx, driver.network, driver.counterNumber, moment, driver.logger)
+}
+
+/**
+Чтение текущих данных запросом REQ_UD2. Если прибор сообщает о продолжении записей (DIF 1Fh),
+запрос повторяется с переключением бита FCB.
+*/
+func (driver *MBus) Read(ctx context.Context) (*models.DataDevice, error) {
+	driver.data.TimeRequest = time.Now()
+
+	var (
+		header  mbus.Header
+		records []mbus.Record
+	)
+	fcb := byte(0)
+	for telegram := 0; ; telegram++ {
+		if telegram >= maxTelegrams {
+			return &driver.data, errors.New("прибор передаёт слишком много посылок с продолжением записей")
+		}
+
+		driver.logger.Info("Запрос на чтение текущих данных (REQ_UD2), посылка %d", telegram+1)
+		request := net.PrepareRequest(mbus.ShortFrame(mbus.ControlReqUD2|fcb, driver.counterNumber))
+		request.ControlFunction = driver.checks.CheckLongFrame
+		response, err := driver.network.RunIO(ctx, request)
+		if err != nil {
+			return &driver.data, err
+		}
+		fcb ^= mbus.ControlFCB
+
+		frame, err := mbus.ParseFrame(response)
+		if err != nil {
+			return &driver.data, err
+		}
+		if telegram == 0 {
+			header = frame.Header
+		}
+
+		variableData, err := mbus.Parse(frame.Data)
+		records = append(records, variableData.Records...)
+		if err != nil {
+			// Записи до ошибки разобраны, их достаточно для заполнения большей части данных
+			driver.logger.Error("%s", err.Error())
+			break
+		}
+		if !variableData.MoreRecords {
+			break
+		}
+	}
+
+	driver.logger.Info("Прибор %s, версия %d, среда %02Xh, записей %d",
+		header.Manufacturer, header.Version, header.Medium, len(records))
+	if header.ID != 0 {
+		driver.data.Serial = fmt.Sprintf("%08d", header.ID)
+	}
+
+	model := findModel(header)
+	if model != nil {
+		driver.logger.Info("Используются настройки модели %s", model.Manufacturer)
+	}
+	mapper := newMapper(&driver.data, model)
+	for _, record := range records {
+		name, err := mapper.apply(record)
+		if err != nil {
+			driver.logger.Error("Запись %X: %s", record.Header(), err.Error())
+			continue
+		}
+		if name != "" {
+			driver.logger.Debug("Запись %X (%s %s) -> %s", record.Header(), record.Quantity, record.Unit, name)
+		}
+	}
+
+	if len(driver.data.Systems) == 0 {
+		return &driver.data, errors.New("ответ прибора не содержит записей, которые можно сопоставить данным теплосчётчика")
+	}
+	return &driver.data, nil
+}
@@ -0,0 +1,111 @@
+package mbusgeneric
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"qBox/drivers/mbus"
+	"strings"
+)
+
+/**
+Настройка сопоставления записей M-Bus полям данных теплосчётчика для модели прибора.
+Модель определяется по заголовку ответа: коду производителя и, если заданы, версии и среде.
+Файл настроек - JSON массив моделей, например:
+
+	[{
+		"manufacturer": "KAM", "version": 27,
+		"records": [
+			{"header": "0C06", "field": "Q1", "system": 0},
+			{"header": "8C1006", "field": "Q3", "system": 0},
+			{"header": "01FF07", "field": "-"}
+		]
+	}]
+
+header - байты DIB и VIB записи в шестнадцатеричном виде, как они передаются прибором.
+field - поле данных (см. fields), "-" - запись не используется.
+scale - множитель значения после приведения к единицам поля, по умолчанию 1.
+Записи, не перечисленные в records, сопоставляются по стандартным VIF, если не задано "defaults": false.
+*/
+type Model struct {
+	Manufacturer string        `json:"manufacturer"`
+	Version      *byte         `json:"version,omitempty"`
+	Medium       *byte         `json:"medium,omitempty"`
+	Defaults     *bool         `json:"defaults,omitempty"`
+	Records      []RecordField `json:"records"`
+}
+
+type RecordField struct {
+	Header string   `json:"header"`
+	Field  string   `json:"field"`
+	System int      `json:"system"`
+	Scale  *float64 `json:"scale,omitempty"`
+
+	header []byte
+}
+
+var meterModels []Model
+
+/**
+Загрузка настроек моделей из файла. Вызывается до начала опроса, настройки действуют на все
+последующие опросы драйвером mbus.
+*/
+func LoadModels(path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать файл моделей M-Bus: %w", err)
+	}
+	var loaded []Model
+	err = json.Unmarshal(content, &loaded)
+	if err != nil {
+		return fmt.Errorf("не удалось разобрать файл моделей M-Bus %s: %w", path, err)
+	}
+	return SetModels(loaded)
+}
+
+// Установка настроек моделей. Проверяет коды производителей, заголовки записей и имена полей.
+func SetModels(list []Model) error {
+	prepared := make([]Model, len(list))
+	for i, model := range list {
+		if len(model.Manufacturer) != 3 {
+			return fmt.Errorf("модель %d: код производителя должен состоять из трёх букв", i+1)
+		}
+		model.Records = append([]RecordField(nil), model.Records...)
+		for j := range model.Records {
+			record := &model.Records[j]
+			header, err := hex.DecodeString(strings.ReplaceAll(record.Header, " ", ""))
+			if err != nil || len(header) == 0 {
+				return fmt.Errorf("модель %s, запись %d: неверный заголовок записи %q", model.Manufacturer, j+1, record.Header)
+			}
+			record.header = header
+			if _, ok := fields[record.Field]; !ok && record.Field != "-" {
+				return fmt.Errorf("модель %s, запись %d: неизвестное поле %q", model.Manufacturer, j+1, record.Field)
+			}
+			if record.System < 0 || record.System >= maxSystems {
+				return fmt.Errorf("модель %s, запись %d: номер системы должен быть от 0 до %d", model.Manufacturer, j+1, maxSystems-1)
+			}
+		}
+		prepared[i] = model
+	}
+	meterModels = prepared
+	return nil
+}
+
+// Настройки модели прибора по заголовку ответа. nil - используется стандартное сопоставление
+func findModel(header mbus.Header) *Model {
+	for i := range meterModels {
+		model := &meterModels[i]
+		if !strings.EqualFold(model.Manufacturer, header.Manufacturer) {
+			continue
+		}
+		if model.Version != nil && *model.Version != header.Version {
+			continue
+		}
+		if model.Medium != nil && *model.Medium != header.Medium {
+			continue
+		}
+		return model
+	}
+	return nil
+}
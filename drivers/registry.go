@@ -0,0 +1,158 @@
+package drivers
+
+import (
+	"errors"
+	"fmt"
+	"qBox/models"
+	"sort"
+	"strconv"
+)
+
+// Создаёт новый экземпляр драйвера. Драйверы хранят состояние опроса, поэтому экземпляр нельзя
+// использовать для нескольких теплосчётчиков одновременно.
+type Factory func() models.IDeviceDriver
+
+/**
+Описание драйвера для пользователя (см. флаг list-drivers)
+*/
+type Metadata struct {
+	Title        string // Наименование теплосчётчика, например ТЭМ-104
+	Protocol     string // Протокол обмена
+	Manufacturer string // Производитель теплосчётчика
+}
+
+// Возможность драйвера. Определяется по интерфейсам, которые реализует драйвер.
+type Capability string
+
+const (
+	CapabilityCurrent   Capability = "current"   // Чтение текущих данных, models.IDeviceDriver
+	CapabilitySecondary Capability = "secondary" // Выбор прибора M-Bus по вторичному адресу, models.IMBusDriver
+	CapabilityArchive   Capability = "archive"   // Чтение архивов, models.IArchiveDriver
+	CapabilityClock     Capability = "clock"     // Установка времени на приборе, models.IClockDriver
+)
+
+/**
+Зарегистрированный драйвер
+*/
+type Driver struct {
+	Name string // Имя драйвера, используется во флаге type и в файле заданий
+	Metadata
+	factory Factory
+}
+
+// Номер драйвера, который использовался во флаге type до появления имён. -1, если номера нет.
+func (driver Driver) Number() int {
+	for i, name := range legacyNumbers {
+		if name == driver.Name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Создаёт новый экземпляр драйвера
+func (driver Driver) New() models.IDeviceDriver {
+	return driver.factory()
+}
+
+// Возможности драйвера
+func (driver Driver) Capabilities() []Capability {
+	capabilities := []Capability{CapabilityCurrent}
+	if _, ok := driver.New().(models.IMBusDriver); ok {
+		capabilities = append(capabilities, CapabilitySecondary)
+	}
+	if _, ok := driver.New().(models.IArchiveDriver); ok {
+		capabilities = append(capabilities, CapabilityArchive)
+	}
+	if _, ok := driver.New().(models.IClockDriver); ok {
+		capabilities = append(capabilities, CapabilityClock)
+	}
+	return capabilities
+}
+
+var registry = make(map[string]Driver)
+
+/**
+Номера драйверов, которые использовались во флаге type до появления имён.
+Номера сохранены для совместимости с уже настроенными планировщиками опроса. Новым драйверам номера не назначаются.
+*/
+var legacyNumbers = [...]string{
+	"skm2",
+	"sku02b",
+	"tem104",
+	"tem05",
+	"sku02",
+	"tm3",
+	"tem104m1",
+	"tem104-1",
+	"tesmart01",
+	"sku02k",
+	"sku02b7b",
+	"tem104m",
+	"tem104k",
+	"tem104m2",
+	"skm2m",
+}
+
+// Регистрация драйвера. Вызывается из init() пакета драйвера.
+// Повторная регистрация имени - ошибка программиста, поэтому вызывает panic.
+func Register(name string, factory Factory, metadata Metadata) {
+	if name == "" || factory == nil {
+		panic("drivers: не задано имя или фабрика драйвера")
+	}
+	if _, err := strconv.Atoi(name); err == nil {
+		panic("drivers: имя драйвера не может быть числом: " + name)
+	}
+	if _, ok := registry[name]; ok {
+		panic("drivers: драйвер уже зарегистрирован: " + name)
+	}
+	registry[name] = Driver{Name: name, Metadata: metadata, factory: factory}
+}
+
+// Поиск драйвера по имени либо по номеру (см. legacyNumbers)
+func Lookup(nameOrNumber string) (Driver, error) {
+	name := nameOrNumber
+	if number, err := strconv.Atoi(nameOrNumber); err == nil {
+		if number < 0 || number >= len(legacyNumbers) {
+			return Driver{}, fmt.Errorf("драйвер с номером %d не найден", number)
+		}
+		name = legacyNumbers[number]
+	}
+
+	driver, ok := registry[name]
+	if !ok {
+		if name == "" {
+			return Driver{}, errors.New("драйвер не задан")
+		}
+		return Driver{}, fmt.Errorf("драйвер \"%s\" не найден", name)
+	}
+	return driver, nil
+}
+
+// Создаёт новый экземпляр драйвера по имени либо по номеру
+func New(nameOrNumber string) (models.IDeviceDriver, error) {
+	driver, err := Lookup(nameOrNumber)
+	if err != nil {
+		return nil, err
+	}
+	return driver.New(), nil
+}
+
+// Список зарегистрированных драйверов: сначала драйверы с номерами по порядку номеров, затем остальные по имени
+func List() []Driver {
+	list := make([]Driver, 0, len(registry))
+	for _, driver := range registry {
+		list = append(list, driver)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		numberI, numberJ := list[i].Number(), list[j].Number()
+		if numberI >= 0 && numberJ >= 0 {
+			return numberI < numberJ
+		}
+		if numberI >= 0 || numberJ >= 0 {
+			return numberI >= 0
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
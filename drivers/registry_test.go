@@ -0,0 +1,150 @@
+package drivers
+
+import (
+	"context"
+	"qBox/models"
+	"qBox/services/log"
+	"qBox/services/net"
+	"reflect"
+	"testing"
+)
+
+// Драйвер для проверки регистрации: не реализует ни одного дополнительного интерфейса
+type stubDriver struct{}
+
+func (stubDriver) Init(context.Context, byte, *net.Network, *log.LoggerService) error { return nil }
+func (stubDriver) Read(context.Context) (*models.DataDevice, error)                   { return nil, nil }
+
+// Регистрирует драйвер на время теста
+func registerStub(t *testing.T, name string) {
+	Register(name, func() models.IDeviceDriver { return stubDriver{} }, Metadata{Title: "Заглушка"})
+	t.Cleanup(func() { delete(registry, name) })
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		input  string
+		name   string
+		number int
+	}{
+		{"tem104", "tem104", 2},
+		{"2", "tem104", 2},
+		{"02", "tem104", 2},
+		{"sku02k", "sku02k", 9},
+		{"9", "sku02k", 9},
+		{"tem104m2", "tem104m2", 13},
+		{"13", "tem104m2", 13},
+	}
+	for _, test := range tests {
+		driver, err := Lookup(test.input)
+		if err != nil {
+			t.Errorf("%s: %v", test.input, err)
+			continue
+		}
+		if driver.Name != test.name || driver.Number() != test.number {
+			t.Errorf("%s: получен драйвер %s с номером %d", test.input, driver.Name, driver.Number())
+		}
+		if _, err := New(test.input); err != nil {
+			t.Errorf("%s: %v", test.input, err)
+		}
+	}
+
+	for _, input := range []string{"", "-1", "15", "100", "tem-104", "TEM104"} {
+		if driver, err := Lookup(input); err == nil {
+			t.Errorf("%s: ожидалась ошибка, получен драйвер %s", input, driver.Name)
+		}
+	}
+}
+
+// Номер закреплён за каждым драйвером, существовавшим до появления имён, и не меняется
+func TestLegacyNumbers(t *testing.T) {
+	want := []string{"skm2", "sku02b", "tem104", "tem05", "sku02", "tm3", "tem104m1", "tem104-1", "tesmart01",
+		"sku02k", "sku02b7b", "tem104m", "tem104k", "tem104m2", "skm2m"}
+	if !reflect.DeepEqual(legacyNumbers[:], want) {
+		t.Errorf("номера драйверов изменились: %v", legacyNumbers)
+	}
+
+	registerStub(t, "stub")
+	driver, err := Lookup("stub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if driver.Number() != -1 {
+		t.Errorf("новому драйверу назначен номер %d", driver.Number())
+	}
+	// Сначала драйверы с номерами по порядку номеров, затем остальные
+	list := List()
+	for i := 1; i < len(list); i++ {
+		previous, current := list[i-1].Number(), list[i].Number()
+		if current >= 0 && (previous == -1 || previous >= current) {
+			t.Errorf("драйверы %s и %s расположены не по порядку", list[i-1].Name, list[i].Name)
+		}
+	}
+}
+
+func TestRegisterPanics(t *testing.T) {
+	registerStub(t, "stub")
+	factory := func() models.IDeviceDriver { return stubDriver{} }
+	tests := []struct {
+		name    string
+		factory Factory
+	}{
+		{"", factory},
+		{"nofactory", nil},
+		{"16", factory},
+		{"stub", factory},
+		{"tem104", factory},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%q: ожидалась panic", test.name)
+				}
+			}()
+			Register(test.name, test.factory, Metadata{})
+		}()
+	}
+	if driver, _ := Lookup("tem104"); driver.Title == "" {
+		t.Error("повторная регистрация заменила драйвер tem104")
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	registerStub(t, "stub")
+	tests := []struct {
+		name string
+		want []Capability
+	}{
+		{"stub", []Capability{CapabilityCurrent}},
+		{"tem104", []Capability{CapabilityCurrent}},
+		{"sku02b", []Capability{CapabilityCurrent, CapabilitySecondary}},
+		{"tm3", []Capability{CapabilityCurrent, CapabilityClock}},
+		{"tem104m2", []Capability{CapabilityCurrent, CapabilityArchive, CapabilityClock}},
+		{"sku02k", []Capability{CapabilityCurrent, CapabilitySecondary, CapabilityArchive, CapabilityClock}},
+	}
+	for _, test := range tests {
+		driver, err := Lookup(test.name)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if capabilities := driver.Capabilities(); !reflect.DeepEqual(capabilities, test.want) {
+			t.Errorf("%s: возможности %v, ожидалось %v", test.name, capabilities, test.want)
+		}
+	}
+}
+
+// Драйвер хранит состояние опроса, поэтому каждый вызов New создаёт отдельный экземпляр
+func TestNewFreshInstance(t *testing.T) {
+	for _, driver := range List() {
+		first, second := driver.New(), driver.New()
+		if reflect.ValueOf(first).Kind() != reflect.Ptr {
+			t.Errorf("%s: драйвер %T должен создаваться как указатель", driver.Name, first)
+			continue
+		}
+		if first == second {
+			t.Errorf("%s: New вернул тот же экземпляр", driver.Name)
+		}
+	}
+}
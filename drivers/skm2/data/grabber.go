@@ -2,6 +2,8 @@ package data
 
 import (
 	"bytes"
+	"errors"
+	"qBox/drivers/mbus"
 )
 
 type Grabber struct {
@@ -18,19 +20,75 @@ type Grabber struct {
 /**
 * block - байты DIB и VIB
 * lengthValueBlock - количество байт, которое занимает value
+*
+* Поиск выполняется по записям, разобранным mbus.Parse: block сравнивается только с началом записи,
+* поэтому совпадение байтов внутри значений других записей не даёт ложного результата.
+* Сначала ищется запись с точно совпадающими DIB и VIB, затем - запись, которая начинается с block
+* (например, DIF и VIF производителя, за которыми следует VIFE).
+* Если записи удалось разобрать не полностью, в неразобранной части используется поиск по байтам.
  */
 func (grabber *Grabber) GrabValueBytes(block []byte, lengthValueBlock int) []byte {
-	index := bytes.Index(grabber.Datum, block)
+	start, end := grabber.records()
+	variableData, err := mbus.Parse(grabber.Datum[start:end])
 
+	for _, record := range variableData.Records {
+		if bytes.Equal(record.Header(), block) {
+			return cut(grabber.Datum, start+record.Offset+len(block), lengthValueBlock)
+		}
+	}
+	for _, record := range variableData.Records {
+		if bytes.HasPrefix(grabber.Datum[start+record.Offset:], block) {
+			return cut(grabber.Datum, start+record.Offset+len(block), lengthValueBlock)
+		}
+	}
+
+	var parseError *mbus.ParseError
+	if !errors.As(err, &parseError) {
+		return []byte{}
+	}
+	rest := grabber.Datum[start+parseError.Offset:]
+	index := bytes.Index(rest, block)
 	if index == -1 {
 		return []byte{}
 	}
+	return cut(rest, index+len(block), lengthValueBlock)
+}
 
-	startIndex := index + len(block)
-	finishIndex := startIndex + lengthValueBlock
-	if len(grabber.Datum) < finishIndex {
-		return []byte{}
+/**
+* Длина заголовка прикладного уровня ответа RSP_UD по полю CI (EN 13757-3):
+* 72h - полный заголовок, 7Ah - короткий заголовок, 78h - без заголовка
+ */
+var applicationHeaders = map[byte]int{0x72: 12, 0x7A: 4, 0x78: 0}
+
+/**
+* Границы записей данных в Datum. Драйверы передают кадр RSP_UD целиком: записи начинаются после
+* заголовка кадра (68h L L 68h C A CI) и заголовка прикладного уровня, длина которого зависит от CI,
+* и заканчиваются перед контрольной суммой. Иначе Datum считается записями данных.
+ */
+func (grabber *Grabber) records() (int, int) {
+	datum := grabber.Datum
+	if len(datum) < 7 || datum[0] != 0x68 || datum[3] != 0x68 || datum[1] != datum[2] {
+		return 0, len(datum)
+	}
+	header, ok := applicationHeaders[datum[6]]
+	if !ok {
+		return 0, len(datum)
+	}
+	start := 7 + header
+	end := 4 + int(datum[1])
+	if end > len(datum) {
+		end = len(datum)
+	}
+	if start > end {
+		start = end
 	}
+	return start, end
+}
 
-	return grabber.Datum[startIndex:finishIndex]
-}
\ No newline at end of file
+func cut(datum []byte, startIndex int, length int) []byte {
+	finishIndex := startIndex + length
+	if len(datum) < finishIndex {
+		return []byte{}
+	}
+	return datum[startIndex:finishIndex]
+}
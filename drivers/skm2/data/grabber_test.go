@@ -0,0 +1,36 @@
+package data
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Длинный кадр RSP_UD с заданным CI: заголовок прикладного уровня содержит байты 04h 06h,
+// совпадающие с DIF и VIF записи энергии, а записи - энергию 1234 и объём 5678
+func responseFrame(ci byte, header []byte) []byte {
+	body := append([]byte{0x08, 0x01, ci}, header...)
+	body = append(body, 0x04, 0x06, 0xD2, 0x04, 0x00, 0x00, 0x04, 0x13, 0x2E, 0x16, 0x00, 0x00)
+	frame := append([]byte{0x68, byte(len(body)), byte(len(body)), 0x68}, body...)
+	var sum byte
+	for _, b := range body {
+		sum += b
+	}
+	return append(frame, sum, 0x16)
+}
+
+func TestGrabValueBytes(t *testing.T) {
+	for name, datum := range map[string][]byte{
+		"полный заголовок":   responseFrame(0x72, []byte{0x04, 0x06, 0x00, 0x00, 0x2D, 0x2C, 0x01, 0x04, 0x01, 0x00, 0x00, 0x00}),
+		"короткий заголовок": responseFrame(0x7A, []byte{0x04, 0x06, 0x00, 0x00}),
+		"без заголовка":      responseFrame(0x78, nil),
+		"только записи":      {0x04, 0x06, 0xD2, 0x04, 0x00, 0x00, 0x04, 0x13, 0x2E, 0x16, 0x00, 0x00},
+	} {
+		grabber := Grabber{Datum: datum}
+		if value := grabber.GrabValueBytes([]byte{0x04, 0x06}, 4); !bytes.Equal(value, []byte{0xD2, 0x04, 0x00, 0x00}) {
+			t.Errorf("%s: энергия %X", name, value)
+		}
+		if value := grabber.GrabValueBytes([]byte{0x04, 0x13}, 4); !bytes.Equal(value, []byte{0x2E, 0x16, 0x00, 0x00}) {
+			t.Errorf("%s: объём %X", name, value)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package skm2
 
 import (
+	"context"
 	"encoding/hex"
+	"qBox/drivers"
+	"qBox/drivers/mbus"
 	"qBox/drivers/skm2/data"
 	"qBox/drivers/skm2/systems"
 	"qBox/models"
@@ -18,13 +21,21 @@ type SKM struct {
 	checks        data.Checks
 }
 
+func init() {
+	drivers.Register("skm2", func() models.IDeviceDriver { return new(SKM) }, drivers.Metadata{
+		Title:    "СКМ-2",
+		Protocol: "M-Bus (EN 60870-5)",
+	})
+}
+
 /**
 counterNumber для СКМ:
 254 (0xFE) - воспринимается всеми теплосчетчиками, вне зависимости от их адресов.
 0 адрес принадлежит несконфигурированным теплосчётчикам
 1-250 - принадлежат ведомым теплосчётчикам.
 */
-func (skm *SKM) Init(counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+func (skm *SKM) Init(ctx context.Context, counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+	*skm = SKM{} // см. models.IDeviceDriver::Init
 	skm.logger = logger
 	skm.network = network
 	skm.counterNumber = counterNumber
@@ -37,6 +48,11 @@ func (skm *SKM) Init(counterNumber byte, network *net.Network, logger *log.Logge
 	skm.data.CoefficientKWh = 1 / 1.163 / 1000
 	skm.data.CoefficientGJ = 1 / (3.6 / skm.data.CoefficientMWh) //0,238845896625
 
+	if skm.counterNumber == mbus.AddressNetwork {
+		// Прибор выбран по вторичному адресу, SND_NKE снимет выбор, см. models.IMBusDriver
+		return nil
+	}
+
 	skm.logger.Info("Запрос на инициализацию прибора, № %d", skm.counterNumber)
 	request := net.PrepareRequest([]byte{
 		0x10,
@@ -44,14 +60,17 @@ func (skm *SKM) Init(counterNumber byte, network *net.Network, logger *log.Logge
 		skm.checks.CalculateCheckSum([]byte{0x40, skm.counterNumber}),
 		0x16})
 	request.ControlFunction = skm.checks.CheckSimpleFrame
-	_, err := skm.network.RunIO(request)
+	_, err := skm.network.RunIO(ctx, request)
 	return err
 }
 
+// Прибор M-Bus, см. models.IMBusDriver
+func (skm *SKM) MBus() {}
+
 /**
 Чтение текущих данных для СКМ-2 согласно протоколу M-bus EN 60870-5
 */
-func (skm *SKM) Read() (*models.DataDevice, error) {
+func (skm *SKM) Read(ctx context.Context) (*models.DataDevice, error) {
 
 	skm.logger.Info("Запрос на чтение текущих данных")
 	request := net.PrepareRequest([]byte{
@@ -60,7 +79,7 @@ func (skm *SKM) Read() (*models.DataDevice, error) {
 		0x50, 0x10,
 		skm.checks.CalculateCheckSum([]byte{0x53, skm.counterNumber, 0x50, 0x10}), 0x16})
 	request.ControlFunction = skm.checks.CheckSimpleFrame
-	_, err := skm.network.RunIO(request)
+	_, err := skm.network.RunIO(ctx, request)
 	if err != nil {
 		return &skm.data, err
 	}
@@ -71,7 +90,7 @@ func (skm *SKM) Read() (*models.DataDevice, error) {
 	request = net.PrepareRequest([]byte{0x10, 0x5B, skm.counterNumber,
 		skm.checks.CalculateCheckSum([]byte{0x5B, skm.counterNumber}), 0x16})
 	request.ControlFunction = skm.checks.CheckLongFrame
-	response, err := skm.network.RunIO(request)
+	response, err := skm.network.RunIO(ctx, request)
 	for err != nil {
 		return &skm.data, err
 	}
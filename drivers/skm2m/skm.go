@@ -1,7 +1,10 @@
 package skm2m
 
 import (
-	"encoding/hex"
+	"context"
+	"fmt"
+	"qBox/drivers"
+	"qBox/drivers/mbus"
 	"qBox/drivers/skm2/data"
 	"qBox/models"
 	"qBox/services/convert"
@@ -18,6 +21,13 @@ type SKM struct {
 	checks        data.Checks
 }
 
+func init() {
+	drivers.Register("skm2m", func() models.IDeviceDriver { return new(SKM) }, drivers.Metadata{
+		Title:    "СКМ-2М",
+		Protocol: "M-Bus (EN 60870-5)",
+	})
+}
+
 /*
 *
 counterNumber для СКМ:
@@ -25,7 +35,8 @@ counterNumber для СКМ:
 0 адрес принадлежит несконфигурированным теплосчётчикам
 1-250 - принадлежат ведомым теплосчётчикам.
 */
-func (skm *SKM) Init(counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+func (skm *SKM) Init(ctx context.Context, counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+	*skm = SKM{} // см. models.IDeviceDriver::Init
 	skm.logger = logger
 	skm.network = network
 	skm.counterNumber = counterNumber
@@ -38,6 +49,11 @@ func (skm *SKM) Init(counterNumber byte, network *net.Network, logger *log.Logge
 	skm.data.CoefficientKWh = 1 / 1.163 / 1000
 	skm.data.CoefficientGJ = 1 / (3.6 / skm.data.CoefficientMWh) //0,238845896625
 
+	if skm.counterNumber == mbus.AddressNetwork {
+		// Прибор выбран по вторичному адресу, SND_NKE снимет выбор, см. models.IMBusDriver
+		return nil
+	}
+
 	skm.logger.Info("Запрос на инициализацию прибора, № %d", skm.counterNumber)
 	request := net.PrepareRequest([]byte{
 		0x10,
@@ -45,15 +61,18 @@ func (skm *SKM) Init(counterNumber byte, network *net.Network, logger *log.Logge
 		skm.checks.CalculateCheckSum([]byte{0x40, skm.counterNumber}),
 		0x16})
 	request.ControlFunction = skm.checks.CheckSimpleFrame
-	_, err := skm.network.RunIO(request)
+	_, err := skm.network.RunIO(ctx, request)
 	return err
 }
 
+// Прибор M-Bus, см. models.IMBusDriver
+func (skm *SKM) MBus() {}
+
 /*
 *
 Чтение текущих данных для СКМ-2 согласно протоколу M-bus EN 60870-5
 */
-func (skm *SKM) Read() (*models.DataDevice, error) {
+func (skm *SKM) Read(ctx context.Context) (*models.DataDevice, error) {
 
 	skm.logger.Info("Запрос на чтение текущих данных")
 	request := net.PrepareRequest([]byte{
@@ -62,7 +81,7 @@ func (skm *SKM) Read() (*models.DataDevice, error) {
 		0x50, 0x10,
 		skm.checks.CalculateCheckSum([]byte{0x53, skm.counterNumber, 0x50, 0x10}), 0x16})
 	request.ControlFunction = skm.checks.CheckSimpleFrame
-	_, err := skm.network.RunIO(request)
+	_, err := skm.network.RunIO(ctx, request)
 	if err != nil {
 		return &skm.data, err
 	}
@@ -73,7 +92,7 @@ func (skm *SKM) Read() (*models.DataDevice, error) {
 	request = net.PrepareRequest([]byte{0x10, 0x5B, skm.counterNumber,
 		skm.checks.CalculateCheckSum([]byte{0x5B, skm.counterNumber}), 0x16})
 	request.ControlFunction = skm.checks.CheckLongFrame
-	response1, err := skm.network.RunIO(request)
+	response1, err := skm.network.RunIO(ctx, request)
 	for err != nil {
 		return &skm.data, err
 	}
@@ -82,56 +101,71 @@ func (skm *SKM) Read() (*models.DataDevice, error) {
 	request = net.PrepareRequest([]byte{0x10, 0x7B, skm.counterNumber,
 		skm.checks.CalculateCheckSum([]byte{0x7B, skm.counterNumber}), 0x16})
 	request.ControlFunction = skm.checks.CheckLongFrame
-	response2, err := skm.network.RunIO(request)
+	response2, err := skm.network.RunIO(ctx, request)
 	for err != nil {
 		return &skm.data, err
 	}
 
-	skm.data.Serial = hex.EncodeToString([]byte{response1[10], response1[9], response1[8], response1[7]})
+	frame1, err := mbus.ParseFrame(response1)
+	if err != nil {
+		return &skm.data, err
+	}
+	frame2, err := mbus.ParseFrame(response2)
+	if err != nil {
+		return &skm.data, err
+	}
+	skm.data.Serial = fmt.Sprintf("%08d", frame1.Header.ID)
 
-	skm.PopulateFromBytes(response1, response2)
+	skm.PopulateFromBytes(frame1.Data, frame2.Data)
 
 	return &skm.data, nil
 }
 
+/*
+*
+Данные ответов СКМ-2М после заголовка прикладного уровня (CI = 72h) - не записи DIF/VIF EN 13757-3, а блок
+производителя фиксированной структуры: время в BCD без DIF и VIF, интеграторы по 8 байт, расходы и температуры.
+Поэтому mbus.Parse к ним неприменим, и значения читаются по смещениям от начала блока, как в исходном драйвере.
+b1 - данные ответа на REQ_UD2 5Bh, b2 - на REQ_UD2 7Bh.
+*/
 func (skm *SKM) PopulateFromBytes(b1 []byte, b2 []byte) {
-	skm.data.Time = time.Date(2000+int(convert.ByteFromBDC(b1[24])), time.Month(int(convert.ByteFromBDC(b1[23]))), int(convert.ByteFromBDC(b1[22])), int(convert.ByteFromBDC(b1[21])), int(convert.ByteFromBDC(b1[20])), int(convert.ByteFromBDC(b1[19])), 0, time.Local)
-	skm.data.TimeOn = convert.LongLittleEndianByPointer(b2, 177)
+	skm.data.Time = time.Date(2000+int(convert.ByteFromBDC(b1[5])), time.Month(int(convert.ByteFromBDC(b1[4]))), int(convert.ByteFromBDC(b1[3])), int(convert.ByteFromBDC(b1[2])), int(convert.ByteFromBDC(b1[1])), int(convert.ByteFromBDC(b1[0])), 0, time.Local)
+	skm.data.TimeOn = convert.LongLittleEndianByPointer(b2, 158)
 
 	skm.data.AddNewSystem(0)
 	skm.data.AddNewSystem(1)
 
-	if convert.LongLittleEndianByPointer(b2, 181) > 0 {
+	if convert.LongLittleEndianByPointer(b2, 162) > 0 {
 		skm.data.Systems[0].Status = true
-		skm.data.Systems[0].TimeRunSys = convert.LongLittleEndianByPointer(b2, 181)
-		skm.data.Systems[0].Q1 = float64(convert.LongLongLittleEndianByPointer(b1, 25)&0x0001FFFFFFFFFFFF) / 4.1868 * 1.163 / 1000000
-		skm.data.Systems[0].T1 = convert.FloatLittleEndianByPointer(b2, 19)
-		skm.data.Systems[0].T2 = convert.FloatLittleEndianByPointer(b2, 23)
-		skm.data.Systems[0].T3 = convert.FloatLittleEndianByPointer(b2, 43)
-		skm.data.Systems[0].P1 = convert.FloatLittleEndianByPointer(b2, 47)
-		skm.data.Systems[0].P2 = convert.FloatLittleEndianByPointer(b2, 51)
-		skm.data.Systems[0].P3 = convert.FloatLittleEndianByPointer(b2, 71)
-		skm.data.Systems[0].V1 = float64(convert.LongLongLittleEndianByPointer(b1, 65)&0x00000000FFFFFFFF) / 100000
-		skm.data.Systems[0].V2 = float64(convert.LongLongLittleEndianByPointer(b1, 73)&0x00000000FFFFFFFF) / 100000
-		skm.data.Systems[0].M1 = float64(convert.LongLongLittleEndianByPointer(b1, 129)&0x00000000FFFFFFFF) / 100000
-		skm.data.Systems[0].M2 = float64(convert.LongLongLittleEndianByPointer(b1, 137)&0x00000000FFFFFFFF) / 100000
-		skm.data.Systems[0].GM1 = float32(convert.LongWordLittleEndianByPointer(b1, 197)) / 10000
-		skm.data.Systems[0].GM2 = float32(convert.LongWordLittleEndianByPointer(b1, 205)) / 10000
-		skm.data.Systems[0].GV1 = float32(convert.LongWordLittleEndianByPointer(b1, 193)) / 10000
-		skm.data.Systems[0].GV2 = float32(convert.LongWordLittleEndianByPointer(b1, 201)) / 10000
+		skm.data.Systems[0].TimeRunSys = convert.LongLittleEndianByPointer(b2, 162)
+		skm.data.Systems[0].Q1 = float64(convert.LongLongLittleEndianByPointer(b1, 6)&0x0001FFFFFFFFFFFF) / 4.1868 * 1.163 / 1000000
+		skm.data.Systems[0].T1 = convert.FloatLittleEndianByPointer(b2, 0)
+		skm.data.Systems[0].T2 = convert.FloatLittleEndianByPointer(b2, 4)
+		skm.data.Systems[0].T3 = convert.FloatLittleEndianByPointer(b2, 24)
+		skm.data.Systems[0].P1 = convert.FloatLittleEndianByPointer(b2, 28)
+		skm.data.Systems[0].P2 = convert.FloatLittleEndianByPointer(b2, 32)
+		skm.data.Systems[0].P3 = convert.FloatLittleEndianByPointer(b2, 52)
+		skm.data.Systems[0].V1 = float64(convert.LongLongLittleEndianByPointer(b1, 46)&0x00000000FFFFFFFF) / 100000
+		skm.data.Systems[0].V2 = float64(convert.LongLongLittleEndianByPointer(b1, 54)&0x00000000FFFFFFFF) / 100000
+		skm.data.Systems[0].M1 = float64(convert.LongLongLittleEndianByPointer(b1, 110)&0x00000000FFFFFFFF) / 100000
+		skm.data.Systems[0].M2 = float64(convert.LongLongLittleEndianByPointer(b1, 118)&0x00000000FFFFFFFF) / 100000
+		skm.data.Systems[0].GM1 = float32(convert.LongWordLittleEndianByPointer(b1, 178)) / 10000
+		skm.data.Systems[0].GM2 = float32(convert.LongWordLittleEndianByPointer(b1, 186)) / 10000
+		skm.data.Systems[0].GV1 = float32(convert.LongWordLittleEndianByPointer(b1, 174)) / 10000
+		skm.data.Systems[0].GV2 = float32(convert.LongWordLittleEndianByPointer(b1, 182)) / 10000
 		//skm.data.Systems[0].
 	}
 
-	if convert.LongLittleEndianByPointer(b2, 185) > 0 {
+	if convert.LongLittleEndianByPointer(b2, 166) > 0 {
 		skm.data.Systems[1].Status = true
-		skm.data.Systems[1].TimeRunSys = convert.LongLittleEndianByPointer(b2, 185)
-		skm.data.Systems[1].T1 = convert.FloatLittleEndianByPointer(b2, 27)
-		skm.data.Systems[1].T2 = convert.FloatLittleEndianByPointer(b2, 31)
-		skm.data.Systems[1].T3 = convert.FloatLittleEndianByPointer(b2, 43)
-		skm.data.Systems[1].P1 = convert.FloatLittleEndianByPointer(b2, 55)
-		skm.data.Systems[1].P2 = convert.FloatLittleEndianByPointer(b2, 59)
-		skm.data.Systems[1].P3 = convert.FloatLittleEndianByPointer(b2, 71)
-		skm.data.Systems[1].M1 = float64(convert.LongLongLittleEndianByPointer(b1, 145)&0x00000000FFFFFFFF) / 100000
-		skm.data.Systems[1].Q1 = float64(convert.LongLongLittleEndianByPointer(b1, 33)&0x0001FFFFFFFFFFFF) / 4.1868 * 1.163 / 1000000
+		skm.data.Systems[1].TimeRunSys = convert.LongLittleEndianByPointer(b2, 166)
+		skm.data.Systems[1].T1 = convert.FloatLittleEndianByPointer(b2, 8)
+		skm.data.Systems[1].T2 = convert.FloatLittleEndianByPointer(b2, 12)
+		skm.data.Systems[1].T3 = convert.FloatLittleEndianByPointer(b2, 24)
+		skm.data.Systems[1].P1 = convert.FloatLittleEndianByPointer(b2, 36)
+		skm.data.Systems[1].P2 = convert.FloatLittleEndianByPointer(b2, 40)
+		skm.data.Systems[1].P3 = convert.FloatLittleEndianByPointer(b2, 52)
+		skm.data.Systems[1].M1 = float64(convert.LongLongLittleEndianByPointer(b1, 126)&0x00000000FFFFFFFF) / 100000
+		skm.data.Systems[1].Q1 = float64(convert.LongLongLittleEndianByPointer(b1, 14)&0x0001FFFFFFFFFFFF) / 4.1868 * 1.163 / 1000000
 	}
 }
@@ -1,6 +1,7 @@
 package drivers
 
 import (
+	"context"
 	"errors"
 	"qBox/models"
 	"qBox/services/log"
@@ -19,12 +20,20 @@ type SKU02 struct {
 	logger  *log.LoggerService
 }
 
+func init() {
+	Register("sku02", func() models.IDeviceDriver { return new(SKU02) }, Metadata{
+		Title:    "SKU-02",
+		Protocol: "M-Bus (EN 60870-5)",
+	})
+}
+
 /**
 При запросе номер счётчика не передаётся
 Инициализации как такой нет, т.к. в Read() совместно с опросом текущих удаётся получить всю техническую информацию.
 Возможно в будущем, при оптимизации стоит сюда что-то перенести.
 */
-func (sku *SKU02) Init(counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+func (sku *SKU02) Init(ctx context.Context, counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+	*sku = SKU02{} // см. models.IDeviceDriver::Init
 	sku.logger = logger
 	sku.network = network
 	//Система всегда одна
@@ -34,12 +43,12 @@ func (sku *SKU02) Init(counterNumber byte, network *net.Network, logger *log.Log
 }
 
 // Реализация интерфейса IDeviceDriver::Read
-func (sku *SKU02) Read() (*models.DataDevice, error) {
+func (sku *SKU02) Read(ctx context.Context) (*models.DataDevice, error) {
 
 	sku.logger.Info("Запрос текущих данных")
 	request := net.PrepareRequest(createRequest(0x20))
 	request.ControlFunction = sku.checkFrame
-	response, err := sku.network.RunIO(request)
+	response, err := sku.network.RunIO(ctx, request)
 	for err != nil {
 		return &sku.data, err
 	}
@@ -54,7 +63,7 @@ func (sku *SKU02) Read() (*models.DataDevice, error) {
 	*/
 	request = net.PrepareRequest(createRequest(0x28))
 	request.ControlFunction = sku.checkFrame
-	response, err = sku.network.RunIO(request)
+	response, err = sku.network.RunIO(ctx, request)
 	for err != nil {
 		return &sku.data, err
 	}
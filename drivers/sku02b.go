@@ -1,7 +1,9 @@
 package drivers
 
 import (
-	"encoding/hex"
+	"context"
+	"fmt"
+	"qBox/drivers/mbus"
 	"qBox/models"
 	"qBox/services/log"
 	"qBox/services/net"
@@ -21,42 +23,57 @@ type SKU02B struct {
 	counterNumber byte
 }
 
+func init() {
+	Register("sku02b", func() models.IDeviceDriver { return new(SKU02B) }, Metadata{
+		Title:    "SKU-02-B (5b)",
+		Protocol: "M-Bus (EN 60870-5)",
+	})
+}
+
 /**
 counterNumber для SKU-02-B:
 254 (0xFE) - вопринимается всеми теплосчетчиками, внезависимости от их адресов.
 0 адрес принадлежит несконфигурированным теплосчётчикам
 1-250 - принадлежат ведомым теплосчётчикам.
 */
-func (sku *SKU02B) Init(counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+func (sku *SKU02B) Init(ctx context.Context, counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+	*sku = SKU02B{} // см. models.IDeviceDriver::Init
 	sku.logger = logger
 	sku.network = network
 	sku.counterNumber = counterNumber
 	return nil
 }
 
-// Реализация интерфейса IDeviceDriver::Read
-func (sku *SKU02B) Read() (*models.DataDevice, error) {
+// Прибор M-Bus, см. models.IMBusDriver
+func (sku *SKU02B) MBus() {}
 
-	sku.logger.Info("Запрос на инициализацию прибора, № %d", sku.counterNumber)
-	request := net.PrepareRequest([]byte{
-		0x10,
-		0x40, sku.counterNumber,
-		sku.calculateCheckSum([]byte{0x40, sku.counterNumber}),
-		0x16})
-	request.ControlFunction = sku.checkSimpleFrame
-	_, err := sku.network.RunIO(request)
-	for err != nil {
-		return &sku.data, err
+// Реализация интерфейса IDeviceDriver::Read
+func (sku *SKU02B) Read(ctx context.Context) (*models.DataDevice, error) {
+
+	// Прибору, выбранному по вторичному адресу, SND_NKE не отправляется, см. models.IMBusDriver
+	var err error
+	if sku.counterNumber != mbus.AddressNetwork {
+		sku.logger.Info("Запрос на инициализацию прибора, № %d", sku.counterNumber)
+		request := net.PrepareRequest([]byte{
+			0x10,
+			0x40, sku.counterNumber,
+			sku.calculateCheckSum([]byte{0x40, sku.counterNumber}),
+			0x16})
+		request.ControlFunction = sku.checkSimpleFrame
+		_, err = sku.network.RunIO(ctx, request)
+		for err != nil {
+			return &sku.data, err
+		}
 	}
 
 	sku.logger.Info("Запрос на чтение текущих данных")
-	request = net.PrepareRequest([]byte{
+	request := net.PrepareRequest([]byte{
 		0x68, 0x04, 0x04, 0x68,
 		0x53, sku.counterNumber,
 		0x50, 0x00,
 		sku.calculateCheckSum([]byte{0x53, sku.counterNumber, 0x50, 0x00}), 0x16})
 	request.ControlFunction = sku.checkSimpleFrame
-	_, err = sku.network.RunIO(request)
+	_, err = sku.network.RunIO(ctx, request)
 	for err != nil {
 		return &sku.data, err
 	}
@@ -66,15 +83,24 @@ func (sku *SKU02B) Read() (*models.DataDevice, error) {
 	sku.logger.Info("Запрос на просмотр ответа текущих данных")
 	request = net.PrepareRequest([]byte{0x10, 0x5B, sku.counterNumber,
 		sku.calculateCheckSum([]byte{0x5B, sku.counterNumber}), 0x16})
-	request.ControlFunction = sku.checkLongFrame
-	response, err := sku.network.RunIO(request)
+	request.ControlFunction = mbus.Checks{Logger: sku.logger}.CheckLongFrame
+	response, err := sku.network.RunIO(ctx, request)
 	for err != nil {
 		return &sku.data, err
 	}
 
-	sku.data.Serial = hex.EncodeToString([]byte{response[10], response[9], response[8], response[7]})
-	sku.populate(response[19:])
-	return &sku.data, nil
+	return &sku.data, sku.applyResponse(response)
+}
+
+// Заводской номер из заголовка прикладного уровня и записи данных ответа RSP_UD
+func (sku *SKU02B) applyResponse(response []byte) error {
+	frame, err := mbus.ParseFrame(response)
+	if err != nil {
+		return err
+	}
+	sku.data.Serial = fmt.Sprintf("%08d", frame.Header.ID)
+	sku.populate(frame.Data)
+	return nil
 }
 
 func (sku *SKU02B) checkSimpleFrame(response []byte) bool {
@@ -91,39 +117,6 @@ func (sku *SKU02B) checkSimpleFrame(response []byte) bool {
 	}
 }
 
-func (sku *SKU02B) checkLongFrame(response []byte) bool {
-	if len(response) < 19 {
-		// Структура ответа на запрос текущих данных занимает от 19 байт и больше
-		sku.logger.Info("Получен некорректный ответ. Ответ содержит меньше 19 байт.")
-		return false
-	}
-
-	if response[0] != response[3] || response[0] != 0x68 || response[1] != response[2] {
-		sku.logger.Info(
-			"Получен некорректный ответ. Заголовок(68h L L 68h) ответа не верный - %X",
-			response[0:3])
-		return false
-	}
-
-	L := response[1] // задаём L из заголовка
-
-	if len(response) < int(L)+4+1+1 { //  Заголовок(68h L L 68h) + L + CheckSum + Stop(16h)
-		sku.logger.Info("Получен некорректный ответ. Некорректная длинна ответа, согласно заголовку (68h L L 68h).")
-		return false
-	}
-
-	checkSum := response[len(response)-2]
-	calculatedCheckSum := sku.calculateCheckSum(response[4 : len(response)-2])
-	if calculatedCheckSum != checkSum {
-		sku.logger.Info("Получен некорректный ответ. Контрольная сумма не совпадает.")
-		sku.logger.Debug("Ожидалась контрольная сумма- %X", calculatedCheckSum)
-		sku.logger.Debug("Получена контрольная сумма- %X", checkSum)
-		return false
-	}
-
-	return true
-}
-
 /**
 Проверка контрольной суммы для SKU-02-B
 Представляет собой сумму значений из bytes, урезенную до одного байта
@@ -137,604 +130,121 @@ func (sku *SKU02B) calculateCheckSum(bytes []byte) byte {
 }
 
 /**
- * datum - Data records (sometimes called variable data blocks)
- * contain the measured data. Each data record is made up of a data
- * information block (DIB), a value information block (VIB) and a value.
- * Similar to OBIS codes DIBs and VIBs code information such as
- * the meaning of a value.
- */
+Разбор записей данных ответа RSP_UD (EN 13757-3), см. mbus.Parse. Используются только текущие значения.
+Номер подустройства записи различает трубопроводы: 0 - подающий, 1 - обратный. Энергия подустройства 0 -
+суммарная, подустройств 1 и 2 - энергия Q1 и Q2.
+*/
 func (sku *SKU02B) populate(datum []byte) {
-	i := 0
 	sku.data.AddNewSystem(1)
 	sku.data.Systems[0].Status = true
-	for {
-		cursor := i
-		switch dib := datum[cursor]; dib {
-		case 0x84:
-			if datum[cursor+1] == 0x40 { // Ox84 0x40 - DIF
-				cursor += 2
-				switch vib := datum[cursor]; vib {
-				case 0xFB: // 0.1 MWh Тепловая энергия
-					if datum[cursor+1] == 0x00 { //0xFB 0x00 - VIF
-						cursor++
-						factor := float32(0.1)
-						cursor += 4
-						sku.data.UnitQ = models.MWh
-						sku.data.Systems[0].Q1 = float64(float32(ToLong([4]byte{
-							datum[cursor],
-							datum[cursor-1],
-							datum[cursor-2],
-							datum[cursor-3]})) * factor)
-						break
-					}
-					if datum[cursor+1] == 0x08 { //0xFB 0x08 - VIF
-						cursor++
-						factor := float32(0.1)
-						cursor += 4
-						sku.data.UnitQ = models.GJ
-						sku.data.Systems[0].Q1 = float64(float32(ToLong([4]byte{
-							datum[cursor],
-							datum[cursor-1],
-							datum[cursor-2],
-							datum[cursor-3]})) * factor)
-						break
-					}
-				case 0x0F:
-					factor := float32(0.01)
-					cursor += 4
-					sku.data.UnitQ = models.GJ
-					sku.data.Systems[0].Q1 = float64(float32(ToLong([4]byte{
-						datum[cursor],
-						datum[cursor-1],
-						datum[cursor-2],
-						datum[cursor-3]})) * factor)
-					break
-				case 0x07:
-					factor := float32(0.01)
-					cursor += 4
-					sku.data.UnitQ = models.MWh
-					sku.data.Systems[0].Q1 = float64(float32(ToLong([4]byte{
-						datum[cursor],
-						datum[cursor-1],
-						datum[cursor-2],
-						datum[cursor-3]})) * factor)
-					break
-				case 0x0E:
-					factor := float32(0.001)
-					cursor += 4
-					sku.data.UnitQ = models.GJ
-					sku.data.Systems[0].Q1 = float64(float32(ToLong([4]byte{
-						datum[cursor],
-						datum[cursor-1],
-						datum[cursor-2],
-						datum[cursor-3]})) * factor)
-					break
-				case 0x06:
-					factor := float32(0.001)
-					cursor += 4
-					sku.data.UnitQ = models.MWh
-					sku.data.Systems[0].Q1 = float64(float32(ToLong([4]byte{
-						datum[cursor],
-						datum[cursor-1],
-						datum[cursor-2],
-						datum[cursor-3]})) * factor)
-					break
-				case 0x05:
-					factor := float32(0.0001)
-					cursor += 4
-					sku.data.UnitQ = models.MWh
-					sku.data.Systems[0].Q1 = float64(float32(ToLong([4]byte{
-						datum[cursor],
-						datum[cursor-1],
-						datum[cursor-2],
-						datum[cursor-3]})) * factor)
-					break
-				case 0x0D:
-					factor := float32(0.0001)
-					cursor += 4
-					sku.data.UnitQ = models.GJ
-					sku.data.Systems[0].Q1 = float64(float32(ToLong([4]byte{
-						datum[cursor],
-						datum[cursor-1],
-						datum[cursor-2],
-						datum[cursor-3]})) * factor)
-					break
-				case 0x16:
-					factor := float32(1)
-					cursor += 4
-					sku.data.Systems[0].V2 = float64(float32(ToLong([4]byte{
-						datum[cursor],
-						datum[cursor-1],
-						datum[cursor-2],
-						datum[cursor-3]})) * factor)
-					break
-				case 0x96:
-				case 0x1E: // встретилось в одном из приборов
-					factor := float32(1)
-					cursor += 4
-					sku.data.Systems[0].M2 = float64(float32(ToLong([4]byte{
-						datum[cursor],
-						datum[cursor-1],
-						datum[cursor-2],
-						datum[cursor-3]})) * factor)
-					break
-				case 0x15:
-					factor := float32(0.1)
-					cursor += 4
-					sku.data.Systems[0].V2 = float64(float32(ToLong([4]byte{
-						datum[cursor],
-						datum[cursor-1],
-						datum[cursor-2],
-						datum[cursor-3]})) * factor)
-					break
-				case 0x95:
-					factor := float32(0.1)
-					cursor += 4
-					sku.data.Systems[0].M2 = float64(float32(ToLong([4]byte{
-						datum[cursor],
-						datum[cursor-1],
-						datum[cursor-2],
-						datum[cursor-3]})) * factor)
-					break
-				case 0x14:
-					factor := float32(0.01)
-					cursor += 4
-					sku.data.Systems[0].V2 = float64(float32(ToLong([4]byte{
-						datum[cursor],
-						datum[cursor-1],
-						datum[cursor-2],
-						datum[cursor-3]})) * factor)
-					break
-				case 0x94:
-					factor := float32(0.01)
-					cursor += 4
-					sku.data.Systems[0].M2 = float64(float32(ToLong([4]byte{
-						datum[cursor],
-						datum[cursor-1],
-						datum[cursor-2],
-						datum[cursor-3]})) * factor)
-					break
-				case 0x13:
-					factor := float32(0.001)
-					cursor += 4
-					sku.data.Systems[0].V2 = float64(float32(ToLong([4]byte{
-						datum[cursor],
-						datum[cursor-1],
-						datum[cursor-2],
-						datum[cursor-3]})) * factor)
-					break
-				case 0x93:
-					factor := float32(0.001)
-					cursor += 4
-					sku.data.Systems[0].M2 = float64(float32(ToLong([4]byte{
-						datum[cursor],
-						datum[cursor-1],
-						datum[cursor-2],
-						datum[cursor-3]})) * factor)
-					break
-				default:
-					cursor -= 2 // VIB не найден, возврат курсора
-				}
-			}
-			if datum[cursor+1] == 0x80 && datum[cursor+2] == 0x40 { // 0x84 0x80 0x40 - DIF
-				cursor += 3
-				switch vib := datum[cursor]; vib {
-				case 0xFB:
-					if datum[cursor+1] == 0x00 { //0xFB 0x00 - VIF
-						cursor += 1
-						factor := float32(0.1)
-						cursor += 4
-						sku.data.UnitQ = models.MWh
-						sku.data.Systems[0].Q2 = float64(float32(ToLong([4]byte{
-							datum[cursor],
-							datum[cursor-1],
-							datum[cursor-2],
-							datum[cursor-3]})) * factor)
-						break
-					}
-					if datum[cursor+1] == 0x08 { //0xFB 0x08 - VIF
-						cursor += 1
-						factor := float32(0.1)
-						cursor += 4
-						sku.data.UnitQ = models.GJ
-						sku.data.Systems[0].Q2 = float64(float32(ToLong([4]byte{
-							datum[cursor],
-							datum[cursor-1],
-							datum[cursor-2],
-							datum[cursor-3]})) * factor)
-						break
-					}
-				case 0x0F:
-					factor := float32(0.01)
-					cursor += 4
-					sku.data.UnitQ = models.GJ
-					sku.data.Systems[0].Q2 = float64(float32(ToLong([4]byte{
-						datum[cursor],
-						datum[cursor-1],
-						datum[cursor-2],
-						datum[cursor-3]})) * factor)
-					break
-				case 0x07:
-					factor := float32(0.01)
-					cursor += 4
-					sku.data.UnitQ = models.MWh
-					sku.data.Systems[0].Q2 = float64(float32(ToLong([4]byte{
-						datum[cursor],
-						datum[cursor-1],
-						datum[cursor-2],
-						datum[cursor-3]})) * factor)
-					break
-				case 0x0E:
-					factor := float32(0.001)
-					cursor += 4
-					sku.data.UnitQ = models.GJ
-					sku.data.Systems[0].Q2 = float64(float32(ToLong([4]byte{
-						datum[cursor],
-						datum[cursor-1],
-						datum[cursor-2],
-						datum[cursor-3]})) * factor)
-					break
-				case 0x06:
-					factor := float32(0.001)
-					cursor += 4
-					sku.data.UnitQ = models.MWh
-					sku.data.Systems[0].Q2 = float64(float32(ToLong([4]byte{
-						datum[cursor],
-						datum[cursor-1],
-						datum[cursor-2],
-						datum[cursor-3]})) * factor)
-					break
-				case 0x05:
-					factor := float32(0.0001)
-					cursor += 4
-					sku.data.UnitQ = models.MWh
-					sku.data.Systems[0].Q2 = float64(float32(ToLong([4]byte{
-						datum[cursor],
-						datum[cursor-1],
-						datum[cursor-2],
-						datum[cursor-3]})) * factor)
-					break
-				case 0x0D:
-					factor := float32(0.0001)
-					cursor += 4
-					sku.data.UnitQ = models.GJ
-					sku.data.Systems[0].Q2 = float64(float32(ToLong([4]byte{
-						datum[cursor],
-						datum[cursor-1],
-						datum[cursor-2],
-						datum[cursor-3]})) * factor)
-					break
-				default:
-					cursor -= 3 // VIB не найден, возврат курсора
-				}
-			}
-
-		case 0x04:
-			cursor++
-			switch vib := datum[cursor]; vib {
-			case 0xFB:
-				if datum[cursor+1] == 0x00 { //0xFB 0x00 - VIF
-					cursor += 1
-					factor := float32(0.1)
-					cursor += 4
-					sku.data.UnitQ = models.MWh
-					sku.data.Systems[0].SigmaQ = float64(float32(ToLong([4]byte{
-						datum[cursor],
-						datum[cursor-1],
-						datum[cursor-2],
-						datum[cursor-3]})) * factor)
-					break
-				}
-				if datum[cursor+1] == 0x08 { //0xFB 0x08 - VIF
-					cursor += 1
-					factor := float32(0.1)
-					cursor += 4
-					sku.data.UnitQ = models.GJ
-					sku.data.Systems[0].SigmaQ = float64(float32(ToLong([4]byte{
-						datum[cursor],
-						datum[cursor-1],
-						datum[cursor-2],
-						datum[cursor-3]})) * factor)
-					break
-				}
-			case 0x0F:
-				factor := float32(0.01)
-				cursor += 4
-				sku.data.UnitQ = models.GJ
-				sku.data.Systems[0].SigmaQ = float64(float32(ToLong([4]byte{
-					datum[cursor],
-					datum[cursor-1],
-					datum[cursor-2],
-					datum[cursor-3]})) * factor)
-				break
-			case 0x07:
-				factor := float32(0.01)
-				cursor += 4
-				sku.data.UnitQ = models.MWh
-				sku.data.Systems[0].SigmaQ = float64(float32(ToLong([4]byte{
-					datum[cursor],
-					datum[cursor-1],
-					datum[cursor-2],
-					datum[cursor-3]})) * factor)
-				break
-			case 0x0E:
-				factor := float32(0.001)
-				cursor += 4
-				sku.data.UnitQ = models.GJ
-				sku.data.Systems[0].SigmaQ = float64(float32(ToLong([4]byte{
-					datum[cursor],
-					datum[cursor-1],
-					datum[cursor-2],
-					datum[cursor-3]})) * factor)
-				break
-			case 0x06:
-				factor := float32(0.001)
-				cursor += 4
-				sku.data.UnitQ = models.MWh
-				sku.data.Systems[0].SigmaQ = float64(float32(ToLong([4]byte{
-					datum[cursor],
-					datum[cursor-1],
-					datum[cursor-2],
-					datum[cursor-3]})) * factor)
-				break
-			case 0x05:
-				factor := float32(0.0001)
-				cursor += 4
-				sku.data.UnitQ = models.MWh
-				sku.data.Systems[0].SigmaQ = float64(float32(ToLong([4]byte{
-					datum[cursor],
-					datum[cursor-1],
-					datum[cursor-2],
-					datum[cursor-3]})) * factor)
-				break
-			case 0x0D:
-				factor := float32(0.0001)
-				cursor += 4
-				sku.data.UnitQ = models.GJ
-				sku.data.Systems[0].SigmaQ = float64(float32(ToLong([4]byte{
-					datum[cursor],
-					datum[cursor-1],
-					datum[cursor-2],
-					datum[cursor-3]})) * factor)
-				break
-			case 0x16:
-				factor := float32(1)
-				cursor += 4
-				sku.data.Systems[0].V1 = float64(float32(ToLong([4]byte{
-					datum[cursor],
-					datum[cursor-1],
-					datum[cursor-2],
-					datum[cursor-3]})) * factor)
-				break
-			case 0x96:
-			case 0x1E: // встретилось в одном из приборов
-				factor := float32(1)
-				cursor += 4
-				sku.data.Systems[0].M1 = float64(float32(ToLong([4]byte{
-					datum[cursor],
-					datum[cursor-1],
-					datum[cursor-2],
-					datum[cursor-3]})) * factor)
-				break
-			case 0x15:
-				factor := float32(0.1)
-				cursor += 4
-				sku.data.Systems[0].V1 = float64(float32(ToLong([4]byte{
-					datum[cursor],
-					datum[cursor-1],
-					datum[cursor-2],
-					datum[cursor-3]})) * factor)
-				break
-			case 0x95:
-				factor := float32(0.1)
-				cursor += 4
-				sku.data.Systems[0].M1 = float64(float32(ToLong([4]byte{
-					datum[cursor],
-					datum[cursor-1],
-					datum[cursor-2],
-					datum[cursor-3]})) * factor)
-				break
-			case 0x14:
-				factor := float32(0.01)
-				cursor += 4
-				sku.data.Systems[0].V1 = float64(float32(ToLong([4]byte{
-					datum[cursor],
-					datum[cursor-1],
-					datum[cursor-2],
-					datum[cursor-3]})) * factor)
-				break
-			case 0x94:
-				factor := float32(0.01)
-				cursor += 4
-				sku.data.Systems[0].M1 = float64(float32(ToLong([4]byte{
-					datum[cursor],
-					datum[cursor-1],
-					datum[cursor-2],
-					datum[cursor-3]})) * factor)
-				break
-			case 0x13:
-				factor := float32(0.001)
-				cursor += 4
-				sku.data.Systems[0].V1 = float64(float32(ToLong([4]byte{
-					datum[cursor],
-					datum[cursor-1],
-					datum[cursor-2],
-					datum[cursor-3]})) * factor)
-				break
-			case 0x93:
-				factor := float32(0.001)
-				cursor += 4
-				sku.data.Systems[0].M1 = float64(float32(ToLong([4]byte{
-					datum[cursor],
-					datum[cursor-1],
-					datum[cursor-2],
-					datum[cursor-3]})) * factor)
-				break
-			case 0x6D:
-				cursor += 4
-				year := 2000 + int(datum[cursor-1]>>5) + (int(datum[cursor]&0xF0) >> 1)
-				month := time.Month(int(datum[cursor] & 0x0F))
-				day := int(datum[cursor-1] & 0x1F)
-				hour := int(datum[cursor-2])
-				min := int(datum[cursor-3])
-				sku.data.Time = time.Date(year, month, day, hour, min, 0, 0, time.Local)
-				break
-			case 0x20:
-				cursor += 4
-				sku.data.TimeOn = ToLong([4]byte{
-					datum[cursor],
-					datum[cursor-1],
-					datum[cursor-2],
-					datum[cursor-3]})
-				break
-			case 0x24:
-				cursor += 4
-				sku.data.TimeRunCommon = ToLong([4]byte{
-					datum[cursor],
-					datum[cursor-1],
-					datum[cursor-2],
-					datum[cursor-3]})
-				sku.data.Systems[0].TimeRunSys = sku.data.TimeRunCommon
-				break
-			default:
-				cursor-- // VIB не найден, возврат курсора
-			}
-
-		case 0x05:
-			cursor++
-			switch vib := datum[cursor]; vib {
-			case 0x3E:
-				factor := float32(1.0)
-				cursor += 4
-				sku.data.Systems[0].GV1 = ToFloat([4]byte{
-					datum[cursor],
-					datum[cursor-1],
-					datum[cursor-2],
-					datum[cursor-3]}) * factor
-				break
-			case 0x56:
-				factor := float32(1.0)
-				cursor += 4
-				sku.data.Systems[0].GM1 = ToFloat([4]byte{
-					datum[cursor],
-					datum[cursor-1],
-					datum[cursor-2],
-					datum[cursor-3]}) * factor
-				break
-			default:
-				cursor-- // VIB не найден, возврат курсора
-			}
-
-		case 0x85:
-			if datum[cursor+1] == 0x40 { //0x85 0x40 - DIB
-				cursor += 2
-				switch vib := datum[cursor]; vib {
-				case 0x3E:
-					factor := float32(1.0)
-					cursor += 4
-					sku.data.Systems[0].GV2 = ToFloat([4]byte{
-						datum[cursor],
-						datum[cursor-1],
-						datum[cursor-2],
-						datum[cursor-3]}) * factor
-					break
-				case 0x56:
-					factor := float32(1.0)
-					cursor += 4
-					sku.data.Systems[0].GM2 = ToFloat([4]byte{
-						datum[cursor],
-						datum[cursor-1],
-						datum[cursor-2],
-						datum[cursor-3]}) * factor
-					break
-				default:
-					cursor -= 2 // VIB не найден, возврат курсора
-				}
-			}
-
-		case 0x02:
-			cursor++
-			switch vib := datum[cursor]; vib {
-			case 0x59:
-				factor := float32(0.01)
-				cursor += 2
-				sku.data.Systems[0].T1 = float32(toWord([2]byte{datum[cursor], datum[cursor-1]})) * factor
-				break
-			case 0x5D:
-				factor := float32(0.01)
-				cursor += 2
-				sku.data.Systems[0].T2 = float32(toWord([2]byte{datum[cursor], datum[cursor-1]})) * factor
-				break
-			default:
-				cursor-- // VIB не найден, возврат курсора
-			}
 
-		case 0x82:
-			if datum[cursor+1] == 0x40 { //0x82 0x40 - DIB
-				cursor += 2
-				switch vib := datum[cursor]; vib {
-				case 0x65:
-					factor := float32(0.01)
-					cursor += 2
-					sku.data.Systems[0].T3 = float32(toWord([2]byte{datum[cursor], datum[cursor-1]})) * factor
-					break
-				case 0x59: // температура 3
-					break
-				default:
-					cursor -= 2 // VIB не найден, возврат курсора
-				}
-			}
-
-		case 0x03:
-			cursor++
-			switch vib := datum[cursor]; vib {
-			case 0x68:
-				factor := float32(0.0001)
-				cursor += 3
-				sku.data.Systems[0].P1 = float32(ToLong([4]byte{
-					0x00,
-					datum[cursor],
-					datum[cursor-1],
-					datum[cursor-2]})) * factor
-				break
-			default:
-				cursor-- // VIB не найден, возврат курсора
-			}
+	variableData, err := mbus.Parse(datum)
+	if err != nil {
+		sku.logger.Info("Записи ответа разобраны не полностью: %s", err.Error())
+	}
+	for _, record := range variableData.Records {
+		if record.Storage != 0 || record.Tariff != 0 || record.Function != mbus.FunctionInstantaneous || record.Manufacturer {
+			continue
+		}
+		if err = sku.applyRecord(record); err != nil {
+			sku.logger.Info("Не расшифрована запись %X: %s", record.Header(), err.Error())
+		}
+	}
+}
 
-		case 0x83:
-			if datum[cursor+1] == 0x40 { //0x83 0x40 - DIB
-				cursor += 2
-				switch vib := datum[cursor]; vib {
-				case 0x68:
-					factor := float32(0.0001)
-					cursor += 3
-					sku.data.Systems[0].P2 = float32(ToLong([4]byte{
-						0x00,
-						datum[cursor],
-						datum[cursor-1],
-						datum[cursor-2]})) * factor
-					break
-				default:
-					cursor -= 2 // VIB не найден, возврат курсора
-				}
-			}
+func (sku *SKU02B) applyRecord(record mbus.Record) error {
+	system := &sku.data.Systems[0]
+	switch record.Quantity {
+	case mbus.QuantityDateTime:
+		moment, err := record.Time()
+		if err != nil {
+			return err
 		}
+		sku.data.Time = moment
+		return nil
+	case mbus.QuantityEnergy, mbus.QuantityVolume, mbus.QuantityMass, mbus.QuantityVolumeFlow, mbus.QuantityMassFlow,
+		mbus.QuantityFlowTemperature, mbus.QuantityReturnTemperature, mbus.QuantityExternalTemp, mbus.QuantityPressure,
+		mbus.QuantityOnTime, mbus.QuantityOperatingTime:
+	default:
+		return nil
+	}
 
-		if i == cursor {
-			cursor++
+	value, err := record.Float()
+	if err != nil {
+		return err
+	}
+	switch record.Quantity {
+	case mbus.QuantityEnergy:
+		switch record.Unit {
+		case "Wh":
+			sku.data.UnitQ, value = models.MWh, value/1e6
+		case "MWh":
+			sku.data.UnitQ = models.MWh
+		case "J":
+			sku.data.UnitQ, value = models.GJ, value/1e9
+		case "GJ":
+			sku.data.UnitQ = models.GJ
+		default:
+			return nil
+		}
+		switch record.Subunit {
+		case 0:
+			system.SigmaQ = value
+		case 1:
+			system.Q1 = value
+		case 2:
+			system.Q2 = value
+		}
+	case mbus.QuantityVolume:
+		setPair(record, &system.V1, &system.V2, value)
+	case mbus.QuantityMass:
+		setPair(record, &system.M1, &system.M2, value/1000) // кг -> т
+	case mbus.QuantityVolumeFlow:
+		if record.Unit == "m3/h" {
+			setPair32(record, &system.GV1, &system.GV2, value)
 		}
+	case mbus.QuantityMassFlow:
+		setPair32(record, &system.GM1, &system.GM2, value/1000) // кг/ч -> т/ч
+	case mbus.QuantityFlowTemperature:
+		// Температура подающего трубопровода подустройства 1 (температура 3 по протоколу) не используется
+		if record.Subunit == 0 {
+			system.T1 = float32(value)
+		}
+	case mbus.QuantityReturnTemperature:
+		if record.Subunit == 0 {
+			system.T2 = float32(value)
+		}
+	case mbus.QuantityExternalTemp:
+		system.T3 = float32(value)
+	case mbus.QuantityPressure:
+		setPair32(record, &system.P1, &system.P2, value/10) // бар -> МПа
+	case mbus.QuantityOnTime:
+		sku.data.TimeOn = uint32(value * durationSeconds[record.Unit])
+	case mbus.QuantityOperatingTime:
+		sku.data.TimeRunCommon = uint32(value * durationSeconds[record.Unit])
+		system.TimeRunSys = sku.data.TimeRunCommon
+	}
+	return nil
+}
 
-		i = cursor
+// Длительность в секундах для единиц VIF времени работы
+var durationSeconds = map[string]float64{"s": 1, "min": 60, "h": 3600, "d": 86400}
 
-		if i >= len(datum) {
-			//fmt.Println("Выполенно: 100%")
-			break
-		}
+// Значение подающего (подустройство 0) или обратного (подустройство 1) трубопровода
+func setPair(record mbus.Record, first *float64, second *float64, value float64) {
+	switch record.Subunit {
+	case 0:
+		*first = value
+	case 1:
+		*second = value
+	}
+}
 
-		if len(datum) != 0 {
-			//fmt.Println("Выполенно: ", i*100/len(datum), "%")
-		} else {
-			break
-		}
+func setPair32(record mbus.Record, first *float32, second *float32, value float64) {
+	switch record.Subunit {
+	case 0:
+		*first = float32(value)
+	case 1:
+		*second = float32(value)
 	}
 }
@@ -1,7 +1,8 @@
 package drivers
 
 import (
-	"encoding/hex"
+	"context"
+	"qBox/drivers/mbus"
 	"qBox/models"
 	"qBox/services/log"
 	"qBox/services/net"
@@ -17,31 +18,46 @@ type SKU02B7B struct {
 	sku    SKU02B
 }
 
-func (sku *SKU02B7B) Init(counterNumber byte, network *net.Network, logger *log.LoggerService) error {
-	return sku.sku.Init(counterNumber, network, logger)
+func init() {
+	Register("sku02b7b", func() models.IDeviceDriver { return new(SKU02B7B) }, Metadata{
+		Title:    "SKU-02-B (7b)",
+		Protocol: "M-Bus (EN 60870-5)",
+	})
 }
 
-func (sku *SKU02B7B) Read() (*models.DataDevice, error) {
-	sku.sku.logger.Info("Запрос на инициализацию прибора, № %d", sku.sku.counterNumber)
-	request := net.PrepareRequest([]byte{
-		0x10,
-		0x40, sku.sku.counterNumber,
-		sku.sku.calculateCheckSum([]byte{0x40, sku.sku.counterNumber}),
-		0x16})
-	request.ControlFunction = sku.sku.checkSimpleFrame
-	_, err := sku.sku.network.RunIO(request)
-	for err != nil {
-		return &sku.sku.data, err
+func (sku *SKU02B7B) Init(ctx context.Context, counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+	*sku = SKU02B7B{} // см. models.IDeviceDriver::Init
+	return sku.sku.Init(ctx, counterNumber, network, logger)
+}
+
+// Прибор M-Bus, см. models.IMBusDriver
+func (sku *SKU02B7B) MBus() {}
+
+func (sku *SKU02B7B) Read(ctx context.Context) (*models.DataDevice, error) {
+	// Прибору, выбранному по вторичному адресу, SND_NKE не отправляется, см. models.IMBusDriver
+	var err error
+	if sku.sku.counterNumber != mbus.AddressNetwork {
+		sku.sku.logger.Info("Запрос на инициализацию прибора, № %d", sku.sku.counterNumber)
+		request := net.PrepareRequest([]byte{
+			0x10,
+			0x40, sku.sku.counterNumber,
+			sku.sku.calculateCheckSum([]byte{0x40, sku.sku.counterNumber}),
+			0x16})
+		request.ControlFunction = sku.sku.checkSimpleFrame
+		_, err = sku.sku.network.RunIO(ctx, request)
+		for err != nil {
+			return &sku.sku.data, err
+		}
 	}
 
 	sku.sku.logger.Info("Запрос на чтение текущих данных")
-	request = net.PrepareRequest([]byte{
+	request := net.PrepareRequest([]byte{
 		0x68, 0x04, 0x04, 0x68,
 		0x53, sku.sku.counterNumber,
 		0x50, 0x00,
 		sku.sku.calculateCheckSum([]byte{0x53, sku.sku.counterNumber, 0x50, 0x00}), 0x16})
 	request.ControlFunction = sku.sku.checkSimpleFrame
-	_, err = sku.sku.network.RunIO(request)
+	_, err = sku.sku.network.RunIO(ctx, request)
 	for err != nil {
 		return &sku.sku.data, err
 	}
@@ -51,13 +67,11 @@ func (sku *SKU02B7B) Read() (*models.DataDevice, error) {
 	sku.sku.logger.Info("Запрос на просмотр ответа текущих данных")
 	request = net.PrepareRequest([]byte{0x10, 0x7B, sku.sku.counterNumber,
 		sku.sku.calculateCheckSum([]byte{0x7B, sku.sku.counterNumber}), 0x16})
-	request.ControlFunction = sku.sku.checkLongFrame
-	response, err := sku.sku.network.RunIO(request)
+	request.ControlFunction = mbus.Checks{Logger: sku.sku.logger}.CheckLongFrame
+	response, err := sku.sku.network.RunIO(ctx, request)
 	for err != nil {
 		return &sku.sku.data, err
 	}
 
-	sku.sku.data.Serial = hex.EncodeToString([]byte{response[10], response[9], response[8], response[7]})
-	sku.sku.populate(response[19:])
-	return &sku.sku.data, nil
-}
\ No newline at end of file
+	return &sku.sku.data, sku.sku.applyResponse(response)
+}
@@ -2,7 +2,9 @@ package drivers
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
+	"qBox/drivers/mbus"
 	"qBox/drivers/skm2/data"
 	"qBox/models"
 	"qBox/services/log"
@@ -19,6 +21,14 @@ type SKU02K struct {
 	network       *net.Network
 	logger        *log.LoggerService
 	counterNumber byte
+	factorQ       float32 // Множитель интеграторов энергии по индексу единиц измерения, см. applyResponse
+}
+
+func init() {
+	Register("sku02k", func() models.IDeviceDriver { return new(SKU02K) }, Metadata{
+		Title:    "SKU-02-K",
+		Protocol: "M-Bus (EN 60870-5)",
+	})
 }
 
 /**
@@ -26,35 +36,48 @@ counterNumber для SKU-02K:
 254 (0xFE) - воспринимается всеми теплосчетчиками, вне зависимости от их адресов.
 1-250 - принадлежат ведомым теплосчётчикам.
 */
-func (sku *SKU02K) Init(counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+func (sku *SKU02K) Init(ctx context.Context, counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+	*sku = SKU02K{} // см. models.IDeviceDriver::Init
 	sku.logger = logger
 	sku.network = network
 	sku.counterNumber = counterNumber
 	return nil
 }
 
-// Реализация интерфейса IDeviceDriver::Read
-func (sku *SKU02K) Read() (*models.DataDevice, error) {
+// Прибор M-Bus, см. models.IMBusDriver
+func (sku *SKU02K) MBus() {}
 
-	sku.logger.Info("Запрос на инициализацию прибора, № %d", sku.counterNumber)
-	request := net.PrepareRequest([]byte{
-		0x10,
-		0x40, sku.counterNumber,
-		sku.calculateCheckSum([]byte{0x40, sku.counterNumber}),
-		0x16})
-	request.ControlFunction = sku.checkSimpleFrame
-	_, err := sku.network.RunIO(request)
-	for err != nil {
-		return &sku.data, err
+// Реализация интерфейса IClockDriver::SetTime. Время записывается с точностью до минуты, см. mbus.SetTime
+func (sku *SKU02K) SetTime(ctx context.Context, moment time.Time) error {
+	return mbus.SetTime(ctx, sku.network, sku.counterNumber, moment, sku.logger)
+}
+
+// Реализация интерфейса IDeviceDriver::Read
+func (sku *SKU02K) Read(ctx context.Context) (*models.DataDevice, error) {
+
+	// Прибору, выбранному по вторичному адресу, SND_NKE не отправляется, см. models.IMBusDriver
+	var err error
+	if sku.counterNumber != mbus.AddressNetwork {
+		sku.logger.Info("Запрос на инициализацию прибора, № %d", sku.counterNumber)
+		request := net.PrepareRequest([]byte{
+			0x10,
+			0x40, sku.counterNumber,
+			sku.calculateCheckSum([]byte{0x40, sku.counterNumber}),
+			0x16})
+		request.ControlFunction = sku.checkSimpleFrame
+		_, err = sku.network.RunIO(ctx, request)
+		for err != nil {
+			return &sku.data, err
+		}
 	}
 
 	sku.logger.Info("Запрос на инициализацию программного уровня протокола M-Bus")
-	request = net.PrepareRequest([]byte{
+	request := net.PrepareRequest([]byte{
 		0x68, 0x03, 0x03, 0x68,
 		0x73, sku.counterNumber, 0x50,
 		sku.calculateCheckSum([]byte{0x73, sku.counterNumber, 0x50}), 0x16})
 	request.ControlFunction = sku.checkSimpleFrame
-	_, err = sku.network.RunIO(request)
+	_, err = sku.network.RunIO(ctx, request)
 	for err != nil {
 		return &sku.data, err
 	}
@@ -66,7 +89,7 @@ func (sku *SKU02K) Read() (*models.DataDevice, error) {
 		0x51, 0x08, 0xFF, 0x0C,
 		sku.calculateCheckSum([]byte{0x73, sku.counterNumber, 0x51, 0x08, 0xFF, 0x0C}), 0x16})
 	request.ControlFunction = sku.checkSimpleFrame
-	_, err = sku.network.RunIO(request)
+	_, err = sku.network.RunIO(ctx, request)
 	for err != nil {
 		return &sku.data, err
 	}
@@ -78,7 +101,7 @@ func (sku *SKU02K) Read() (*models.DataDevice, error) {
 		sku.calculateCheckSum([]byte{0x7B, sku.counterNumber}), 0x16})
 	request.ControlFunction = sku.checkConfigDeviceResponse
 	request.SecondsReadTimeout = 7
-	response, err = sku.network.RunIO(request)
+	response, err = sku.network.RunIO(ctx, request)
 	for err != nil {
 		return &sku.data, err
 	}
@@ -105,7 +128,7 @@ func (sku *SKU02K) Read() (*models.DataDevice, error) {
 		0x73, sku.counterNumber, 0x50,
 		sku.calculateCheckSum([]byte{0x73, sku.counterNumber, 0x50}), 0x16})
 	request.ControlFunction = sku.checkSimpleFrame
-	_, err = sku.network.RunIO(request)
+	_, err = sku.network.RunIO(ctx, request)
 	for err != nil {
 		return &sku.data, err
 	}
@@ -134,7 +157,7 @@ func (sku *SKU02K) Read() (*models.DataDevice, error) {
 
 	request = net.PrepareRequest(bytes.Join([][]byte{headerBytes, userData, checksum, []byte{0x16}}, []byte("")))
 	request.ControlFunction = sku.checkSimpleFrame
-	_, err = sku.network.RunIO(request)
+	_, err = sku.network.RunIO(ctx, request)
 	for err != nil {
 		return &sku.data, err
 	}
@@ -145,7 +168,7 @@ func (sku *SKU02K) Read() (*models.DataDevice, error) {
 		sku.calculateCheckSum([]byte{0x7B, sku.counterNumber}), 0x16})
 	request.ControlFunction = sku.checkLongFrame
 	request.SecondsReadTimeout = 7
-	response, err = sku.network.RunIO(request)
+	response, err = sku.network.RunIO(ctx, request)
 	for err != nil {
 		return &sku.data, err
 	}
@@ -160,7 +183,7 @@ func (sku *SKU02K) Read() (*models.DataDevice, error) {
 	checksum = []byte{sku.calculateCheckSum(userData)}
 	request = net.PrepareRequest(bytes.Join([][]byte{headerBytes, userData, checksum, []byte{0x16}}, []byte("")))
 	request.ControlFunction = sku.checkSimpleFrame
-	_, err = sku.network.RunIO(request)
+	_, err = sku.network.RunIO(ctx, request)
 	for err != nil {
 		return &sku.data, err
 	}
@@ -176,7 +199,7 @@ func (sku *SKU02K) Read() (*models.DataDevice, error) {
 	checksum = []byte{sku.calculateCheckSum(userData)}
 	request = net.PrepareRequest(bytes.Join([][]byte{headerBytes, userData, checksum, []byte{0x16}}, []byte("")))
 	request.ControlFunction = sku.checkSimpleFrame
-	_, err = sku.network.RunIO(request)
+	_, err = sku.network.RunIO(ctx, request)
 	for err != nil {
 		return &sku.data, err
 	}
@@ -192,7 +215,7 @@ func (sku *SKU02K) Read() (*models.DataDevice, error) {
 		sku.calculateCheckSum([]byte{0x7B, sku.counterNumber}), 0x16})
 	request.ControlFunction = sku.checkLongFrame
 	request.SecondsReadTimeout = 7
-	responseForDay, err := sku.network.RunIO(request)
+	responseForDay, err := sku.network.RunIO(ctx, request)
 	for err != nil {
 		return &sku.data, err
 	}
@@ -268,6 +291,7 @@ func (sku *SKU02K) applyResponse(datum []byte, datumForDay []byte) {
 		factor = float32(0.001)
 		break
 	}
+	sku.factorQ = factor
 
 	result = grabber.GrabValueBytes([]byte{0x04, 0x86, 0x3B}, 4)
 	if 4 == len(result) {
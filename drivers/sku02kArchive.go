@@ -0,0 +1,288 @@
+package drivers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"qBox/drivers/mbus"
+	"qBox/models"
+	"qBox/services/net"
+	"sort"
+	"time"
+)
+
+/**
+Подкод сброса прикладного уровня (68h 04 04 68h 73h A 50h подкод), после которого прибор отдаёт суточный архив
+(см. Read). Подкоды месячного и часового архивов в протоколе не описаны: месячный архив строится из суточного,
+см. readMonthly, часовой по M-Bus не читается.
+*/
+const sku02KArchiveSubcode = 0x30
+
+/**
+Выбор величин архива (SND_UD, CI = 51h) - байты таблицы 11 протокола, что в запросе текущих данных Read,
+без среднего расхода и индекса единиц. По EN 13757-3: DIF C8h - выбор для чтения с битом номера хранения,
+DIFE FFh 7Fh - все номера хранения и все тарифы, VIF FEh - любая величина, VIFE 3Bh - накопление
+положительных значений. В Read для энергии DIFE 0Fh - только тариф 0, в архиве энергия выбирается
+по всем тарифам, чтобы вернуть и тарифные значения.
+*/
+var sku02KArchiveSelection = []byte{
+	0xC8, 0xFF, 0x7F, 0x6D, // Date and time stamp, (F)
+	0xC8, 0xFF, 0x7F, 0x24, // Working time without error (sec)
+	0xC8, 0xFF, 0x7F, 0xFE, 0x3B, // Energy for heating
+	0xC8, 0xFF, 0x7F, 0x13, // Volume (m3)
+	0xC8, 0xFF, 0x7F, 0x5B, // Average Temperature 1 (ºC)
+	0xC8, 0xFF, 0x7F, 0x5F, // Average Temperature 2 (ºC)
+}
+
+// Реализация интерфейса IArchiveDriver::ReadArchive
+func (sku *SKU02K) ReadArchive(ctx context.Context, archiveType models.ArchiveType, from time.Time, to time.Time) (*models.Archive, error) {
+	switch archiveType {
+	case models.ArchiveDaily:
+		return sku.readDaily(ctx, from, to)
+	case models.ArchiveMonthly:
+		return sku.readMonthly(ctx, from, to)
+	}
+	return nil, fmt.Errorf("тип архива %s не поддерживается прибором", archiveType)
+}
+
+/**
+Чтение суточного архива. Серийный номер и единицы энергии берутся из текущих данных, поэтому сначала выполняется Read.
+Затем прибор переводится в режим архива подкодом сброса, выбираются величины по всем номерам хранения,
+и ответы запрашиваются с чередованием 7Bh - 5Bh: каждый следующий ответ содержит более старые записи.
+Записи одного номера хранения (storage) в ответе образуют одну запись архива, её период - по дате записи.
+Чтение заканчивается, когда встречается запись раньше from, ответ без архивных записей или ответ,
+который не старше предыдущего (архив закончился и прибор начал его сначала).
+*/
+func (sku *SKU02K) readDaily(ctx context.Context, from time.Time, to time.Time) (*models.Archive, error) {
+	archiveType := models.ArchiveDaily
+	current, err := sku.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	archive := &models.Archive{
+		Serial:      current.Serial,
+		Type:        archiveType,
+		UnitQ:       current.UnitQ,
+		TimeRequest: time.Now(),
+	}
+
+	sku.logger.Info("Запрос на просмотр архива, подкод %X", sku02KArchiveSubcode)
+	request := net.PrepareRequest(mbus.LongFrame(0x73, sku.counterNumber, 0x50, []byte{sku02KArchiveSubcode}))
+	request.ControlFunction = sku.checkSimpleFrame
+	if _, err = sku.network.RunIO(ctx, request); err != nil {
+		return archive, err
+	}
+
+	sku.logger.Info("Запрос на получение архивных данных")
+	request = net.PrepareRequest(mbus.LongFrame(0x73, sku.counterNumber, 0x51, sku02KArchiveSelection))
+	request.ControlFunction = sku.checkSimpleFrame
+	if _, err = sku.network.RunIO(ctx, request); err != nil {
+		return archive, err
+	}
+
+	// Ответов не больше, чем периодов от from до времени запроса, с запасом на расхождение часов прибора
+	pages := 2
+	for period := archiveType.First(from); period.Before(archive.TimeRequest); period = archiveType.Next(period) {
+		pages++
+	}
+
+	var records []models.ArchiveRecord // в порядке убывания времени
+	var oldest time.Time
+	control := mbus.ControlReqUD2 | mbus.ControlFCB
+	for page := 0; page < pages; page++ {
+		sku.logger.Info("Запрос на просмотр ответа")
+		request = net.PrepareRequest(mbus.ShortFrame(control, sku.counterNumber))
+		request.ControlFunction = sku.checkLongFrame
+		request.SecondsReadTimeout = 7
+		response, err := sku.network.RunIO(ctx, request)
+		if err != nil {
+			reverseArchiveRecords(records)
+			archive.Records = records
+			return archive, err
+		}
+		control ^= mbus.ControlFCB
+
+		pageRecords := sku.decodeArchive(archiveType, response)
+		if len(pageRecords) == 0 {
+			sku.logger.Info("Ответ не содержит архивных записей")
+			break
+		}
+		if !oldest.IsZero() && !pageRecords[0].Start.Before(oldest) {
+			sku.logger.Info("Записи ответа не старше предыдущих, архив закончился")
+			break
+		}
+		for _, record := range pageRecords {
+			if !record.Start.Before(from) && record.Start.Before(to) {
+				records = append(records, record)
+			}
+		}
+		oldest = pageRecords[len(pageRecords)-1].Start
+		if oldest.Before(from) {
+			break
+		}
+	}
+	reverseArchiveRecords(records)
+	archive.Records = records
+	return archive, nil
+}
+
+/**
+Месячный архив из суточного. Накопленные значения на конец месяца - значения суточной записи за последний день
+месяца, средние температуры - среднее суточных записей месяца. Месяц без записи за последний день
+(текущий месяц или пропуск в суточном архиве) не выводится: накопленных значений на его конец нет.
+Суточный архив читается за все дни запрошенных месяцев, поэтому чтение дольше, чем у суточного архива.
+*/
+func (sku *SKU02K) readMonthly(ctx context.Context, from time.Time, to time.Time) (*models.Archive, error) {
+	first := models.ArchiveMonthly.First(from)
+	last := first
+	for period := first; period.Before(to); period = models.ArchiveMonthly.Next(period) {
+		last = models.ArchiveMonthly.Next(period)
+	}
+	daily, err := sku.readDaily(ctx, first, last)
+	if daily == nil {
+		return nil, err
+	}
+	archive := *daily
+	archive.Type = models.ArchiveMonthly
+	archive.Records = monthlyArchiveRecords(daily.Records)
+	return &archive, err
+}
+
+// Записи месячного архива из суточных записей в порядке возрастания времени, см. readMonthly
+func monthlyArchiveRecords(daily []models.ArchiveRecord) []models.ArchiveRecord {
+	var records []models.ArchiveRecord
+	var days []models.ArchiveRecord
+	for _, day := range daily {
+		if len(days) > 0 && !models.ArchiveMonthly.Truncate(days[0].Start).Equal(models.ArchiveMonthly.Truncate(day.Start)) {
+			days = nil
+		}
+		days = append(days, day)
+		end := models.ArchiveMonthly.Next(day.Start)
+		if !day.End.Equal(end) {
+			continue
+		}
+
+		month := day
+		month.Start = models.ArchiveMonthly.Truncate(day.Start)
+		month.End = end
+		month.Systems = append([]models.ArchiveSystem(nil), day.Systems...)
+		for i := range month.Systems {
+			var t1, t2 float64
+			count := 0
+			for _, d := range days {
+				if i < len(d.Systems) && d.Systems[i].Status {
+					t1 += float64(d.Systems[i].T1)
+					t2 += float64(d.Systems[i].T2)
+					count++
+				}
+			}
+			if count > 0 {
+				month.Systems[i].T1 = float32(t1 / float64(count))
+				month.Systems[i].T2 = float32(t2 / float64(count))
+			}
+		}
+		records = append(records, month)
+		days = nil
+	}
+	return records
+}
+
+/**
+Расшифровка ответа с архивными значениями. Записи M-Bus группируются по номеру хранения,
+текущие значения (номер хранения 0) и записи без даты пропускаются.
+Энергия пересчитывается по индексу единиц измерения, как в applyResponse. Возвращаются записи по убыванию времени.
+*/
+func (sku *SKU02K) decodeArchive(archiveType models.ArchiveType, response []byte) []models.ArchiveRecord {
+	frame, err := mbus.ParseFrame(response)
+	if err != nil {
+		sku.logger.Info("Ответ не разобран: %s", err.Error())
+		return nil
+	}
+	variableData, err := mbus.Parse(frame.Data)
+	if err != nil {
+		sku.logger.Info("Записи ответа разобраны не полностью: %s", err.Error())
+	}
+
+	var storages []uint64
+	byStorage := map[uint64]*models.ArchiveRecord{}
+	for _, record := range variableData.Records {
+		if record.Storage == 0 || record.Function != mbus.FunctionInstantaneous {
+			continue
+		}
+		data, ok := byStorage[record.Storage]
+		if !ok {
+			data = new(models.ArchiveRecord)
+			data.AddNewSystem(0)
+			data.Systems[0].Status = true
+			byStorage[record.Storage] = data
+			storages = append(storages, record.Storage)
+		}
+		sku.applyArchiveRecord(archiveType, data, record)
+	}
+
+	var records []models.ArchiveRecord
+	for _, storage := range storages {
+		data := byStorage[storage]
+		if data.Start.IsZero() {
+			sku.logger.Info("Запись с номером хранения %d не содержит даты и пропущена", storage)
+			continue
+		}
+		records = append(records, *data)
+	}
+	// Номера хранения растут от новых записей к старым, но порядок определяется датой
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Start.After(records[j].Start) })
+	return records
+}
+
+func (sku *SKU02K) applyArchiveRecord(archiveType models.ArchiveType, data *models.ArchiveRecord, record mbus.Record) {
+	system := &data.Systems[0]
+	switch record.Quantity {
+	case mbus.QuantityDateTime, mbus.QuantityDate:
+		moment, err := record.Time()
+		if err != nil {
+			sku.logger.Info("Не расшифрована дата записи %X: %s", record.Data, err.Error())
+			return
+		}
+		data.Start = archiveType.Truncate(moment)
+		data.End = archiveType.Next(data.Start)
+	case mbus.QuantityEnergy:
+		// Множитель VIF входит в sku.factorQ, поэтому значение берётся без него. Float читает и записи Real
+		value, err := record.Float()
+		if err != nil {
+			return
+		}
+		q := float64(float32(value*math.Pow10(-record.Exponent)) * sku.factorQ)
+		if record.Tariff != 0 {
+			if len(system.Tariffs) < int(record.Tariff) {
+				system.Tariffs = append(system.Tariffs, make([]float64, int(record.Tariff)-len(system.Tariffs))...)
+			}
+			system.Tariffs[record.Tariff-1] = q
+			return
+		}
+		system.Q1 = q
+		system.SigmaQ = system.Q1
+	case mbus.QuantityVolume:
+		if value, err := record.Float(); err == nil {
+			system.V1 = value
+		}
+	case mbus.QuantityOperatingTime:
+		if value, err := record.Float(); err == nil {
+			data.TimeOn = uint32(value)
+			system.TimeRunSys = data.TimeOn
+		}
+	case mbus.QuantityFlowTemperature:
+		if value, err := record.Float(); err == nil {
+			system.T1 = float32(value)
+		}
+	case mbus.QuantityReturnTemperature:
+		if value, err := record.Float(); err == nil {
+			system.T2 = float32(value)
+		}
+	}
+}
+
+func reverseArchiveRecords(records []models.ArchiveRecord) {
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+}
@@ -0,0 +1,106 @@
+package drivers
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"qBox/drivers/mbus"
+	"qBox/models"
+	"qBox/services/log"
+	"testing"
+	"time"
+
+	ozzolog "github.com/go-ozzo/ozzo-log"
+)
+
+// Ответ SKU-02-K с двумя суточными записями (номера хранения 1 и 2), текущим значением и тарифной энергией
+func sku02KArchiveFrame() []byte {
+	real32 := func(value float32) []byte {
+		b := make([]byte, 4)
+		binary.LittleEndian.PutUint32(b, math.Float32bits(value))
+		return b
+	}
+	var records []byte
+	add := func(header []byte, value []byte) {
+		records = append(append(records, header...), value...)
+	}
+	add([]byte{0x04, 0x13}, []byte{0x01, 0x00, 0x00, 0x00})             // текущий объём
+	add([]byte{0x44, 0x6D}, []byte{0x00, 0x00, 0x51, 0x3A})             // хранение 1: 17.10.2026
+	add([]byte{0x44, 0x86, 0x3B}, []byte{0x40, 0xE2, 0x01, 0x00})       // 123456 кВт*ч
+	add([]byte{0xC4, 0x10, 0x86, 0x3B}, []byte{0x01, 0x00, 0x00, 0x00}) // тариф 1
+	add([]byte{0x44, 0x13}, []byte{0xAA, 0xFE, 0xB8, 0x02})             // 45678.25 м3
+	add([]byte{0x44, 0x24}, []byte{0x80, 0x51, 0x01, 0x00})             // 86400 с
+	add([]byte{0x45, 0x5B}, real32(72.5))
+	add([]byte{0x45, 0x5F}, real32(48.25))
+	add([]byte{0x84, 0x01, 0x6D}, []byte{0x00, 0x00, 0x50, 0x3A}) // хранение 2: 16.10.2026
+	add([]byte{0x85, 0x01, 0x86, 0x3B}, real32(123000.5))         // энергия Real
+	add([]byte{0xC4, 0x01, 0x13}, []byte{0x01, 0x00, 0x00, 0x00}) // хранение 3 без даты
+	header := []byte{0x01, 0x00, 0x03, 0x20, 0x09, 0x07, 0x01, 0x04, 0x01, 0x00, 0x00, 0x00}
+	return mbus.LongFrame(mbus.ControlRspUD, 0x01, mbus.CIResponseLong, append(header, records...))
+}
+
+func TestSKU02KDecodeArchive(t *testing.T) {
+	logger := log.NewLoggerService(ozzolog.NewLogger())
+	sku := &SKU02K{logger: &logger, factorQ: 0.001}
+
+	records := sku.decodeArchive(models.ArchiveDaily, sku02KArchiveFrame())
+	if len(records) != 2 {
+		t.Fatalf("записей %d, ожидалось 2", len(records))
+	}
+	newest, oldest := records[0], records[1]
+	if !newest.Start.Equal(time.Date(2026, 10, 17, 0, 0, 0, 0, time.Local)) ||
+		!newest.End.Equal(time.Date(2026, 10, 18, 0, 0, 0, 0, time.Local)) ||
+		!oldest.Start.Equal(time.Date(2026, 10, 16, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("периоды %v - %v, %v", newest.Start, newest.End, oldest.Start)
+	}
+	system := newest.Systems[0]
+	if math.Abs(system.Q1-123.456) > 1e-4 || system.SigmaQ != system.Q1 || system.V1 != 45678.25 ||
+		system.T1 != 72.5 || system.T2 != 48.25 || newest.TimeOn != 86400 || system.TimeRunSys != 86400 {
+		t.Errorf("запись %+v, система %+v", newest, system)
+	}
+	if len(system.Tariffs) != 1 || math.Abs(system.Tariffs[0]-0.001) > 1e-9 {
+		t.Errorf("энергия по тарифам %v, ожидалось [0.001]", system.Tariffs)
+	}
+	if q := oldest.Systems[0].Q1; math.Abs(q-123.0005) > 1e-4 {
+		t.Errorf("энергия записи Real %v, ожидалось 123.0005", q)
+	}
+
+	if records = sku.decodeArchive(models.ArchiveDaily, []byte{0xE5}); records != nil {
+		t.Errorf("записи из неразобранного ответа: %+v", records)
+	}
+}
+
+// Месячная запись - накопленные значения за последний день месяца и средние температуры суток месяца
+func TestSKU02KMonthlyArchiveRecords(t *testing.T) {
+	day := func(year int, month time.Month, d int, q float64, t1 float32) models.ArchiveRecord {
+		start := time.Date(year, month, d, 0, 0, 0, 0, time.Local)
+		return models.ArchiveRecord{Start: start, End: start.AddDate(0, 0, 1), TimeOn: uint32(d),
+			Systems: []models.ArchiveSystem{{Status: true, SigmaQ: q, T1: t1, Tariffs: []float64{q / 2}}}}
+	}
+	records := monthlyArchiveRecords([]models.ArchiveRecord{
+		day(2026, 8, 31, 5, 60), // последний день месяца без остальных дней
+		day(2026, 9, 29, 9, 70),
+		day(2026, 9, 30, 10, 80),
+		day(2026, 10, 1, 11, 90), // текущий месяц не закончился
+	})
+	if len(records) != 2 {
+		t.Fatalf("записей %d, ожидалось 2: %+v", len(records), records)
+	}
+	september := records[1]
+	system := september.Systems[0]
+	if !september.Start.Equal(time.Date(2026, 9, 1, 0, 0, 0, 0, time.Local)) ||
+		!september.End.Equal(time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local)) ||
+		september.TimeOn != 30 || system.SigmaQ != 10 || system.T1 != 75 || system.Tariffs[0] != 5 {
+		t.Errorf("запись %+v, система %+v", september, system)
+	}
+	if records[0].Systems[0].T1 != 60 {
+		t.Errorf("средняя температура августа %v", records[0].Systems[0].T1)
+	}
+}
+
+func TestSKU02KArchiveTypes(t *testing.T) {
+	sku := &SKU02K{}
+	if _, err := sku.ReadArchive(context.Background(), models.ArchiveHourly, time.Now(), time.Now()); err == nil {
+		t.Error("часовой архив по M-Bus не читается")
+	}
+}
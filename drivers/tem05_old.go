@@ -1,6 +1,7 @@
 package drivers
 
 import (
+	"context"
 	"qBox/models"
 	"qBox/services/log"
 	"qBox/services/net"
@@ -9,15 +10,24 @@ import (
 )
 
 // Шаблонный код для драйверов
-// После создания нового драйвера, нужно добавить его в карту драйверов services/config/config.go
+// После создания нового драйвера, нужно зарегистрировать его в init(), см. drivers.Register
 type TEM05OLD struct {
 	data    models.DataDevice
 	network *net.Network
 	logger  *log.LoggerService
 }
 
+func init() {
+	Register("tem05", func() models.IDeviceDriver { return new(TEM05OLD) }, Metadata{
+		Title:        "ТЭМ-05",
+		Protocol:     "ТЭМ-05",
+		Manufacturer: "АРВАС",
+	})
+}
+
 // Реализация интерфейса IDeviceDriver::Init
-func (tem05 *TEM05OLD) Init(counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+func (tem05 *TEM05OLD) Init(ctx context.Context, counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+	*tem05 = TEM05OLD{} // см. models.IDeviceDriver::Init
 	tem05.logger = logger
 	tem05.network = network
 	tem05.data.UnitQ = models.MWh // в других не измеряет
@@ -27,7 +37,7 @@ func (tem05 *TEM05OLD) Init(counterNumber byte, network *net.Network, logger *lo
 }
 
 // Реализация интерфейса IDeviceDriver::Read
-func (tem05 *TEM05OLD) Read() (*models.DataDevice, error) {
+func (tem05 *TEM05OLD) Read(ctx context.Context) (*models.DataDevice, error) {
 
 	/**
 	Иногда для инициализации ТЭМ-05М в официального ПО надо нажать кнопку "Интерф. адаптер", эта кнопка отправляет 4 байта.
@@ -36,7 +46,7 @@ func (tem05 *TEM05OLD) Read() (*models.DataDevice, error) {
 	tem05.logger.Info("Чтение текущих")
 	request := net.PrepareRequest([]byte{0x33, 0x81, 0x7e, 0x32})
 	request.SecondsReadTimeout = 8
-	response, err := tem05.network.RunIO(request)
+	response, err := tem05.network.RunIO(ctx, request)
 	for err != nil {
 		return &tem05.data, err
 	}
@@ -53,8 +63,9 @@ func (tem05 *TEM05OLD) populate(response []byte) {
 	year := 2000 + int(ByteFromBDC(response[9]))
 	month := time.Month(int(ByteFromBDC(response[8])))
 	day := int(ByteFromBDC(response[7]))
-	hour := int(ByteFromBDC(response[5])<<8 + ByteFromBDC(response[4]))
-	min := int(ByteFromBDC(response[3])<<8 + ByteFromBDC(response[2]))
+	// Старшие байты (response[5], response[3]) при сдвиге byte на 8 бит всегда давали 0, значимы только младшие
+	hour := int(ByteFromBDC(response[4]))
+	min := int(ByteFromBDC(response[2]))
 	tem05.data.Time = time.Date(year, month, day, hour, min, 0, 0, time.Local)
 	//=====================================НОМЕР ПРИБОРА================================================================
 	tem05.data.Serial = strconv.Itoa(int(toWord([2]byte{response[15], response[14]})))
@@ -1,6 +1,7 @@
 package drivers
 
 import (
+	"context"
 	"qBox/models"
 	"qBox/services/log"
 	"qBox/services/net"
@@ -30,8 +31,17 @@ type Tem104 struct {
 	systemCount   int // количество активных систем
 }
 
+func init() {
+	Register("tem104", func() models.IDeviceDriver { return new(Tem104) }, Metadata{
+		Title:        "ТЭМ-104",
+		Protocol:     "ТЭМ",
+		Manufacturer: "АРВАС",
+	})
+}
+
 // Реализация интерфейса IDeviceDriver::Init
-func (tem *Tem104) Init(counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+func (tem *Tem104) Init(ctx context.Context, counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+	*tem = Tem104{} // см. models.IDeviceDriver::Init
 
 	tem.logger = logger
 	tem.network = network
@@ -57,7 +67,7 @@ func (tem *Tem104) Init(counterNumber byte, network *net.Network, logger *log.Lo
 		}
 		return true
 	}
-	response, err = tem.network.RunIO(request)
+	response, err = tem.network.RunIO(ctx, request)
 	for err != nil {
 		return err
 	}
@@ -80,7 +90,7 @@ func (tem *Tem104) Init(counterNumber byte, network *net.Network, logger *log.Lo
 }
 
 // Реализация интерфейса IDeviceDriver::Read
-func (tem *Tem104) Read() (*models.DataDevice, error) {
+func (tem *Tem104) Read(ctx context.Context) (*models.DataDevice, error) {
 
 	tem.data.TimeRequest = time.Now()
 
@@ -98,7 +108,7 @@ func (tem *Tem104) Read() (*models.DataDevice, error) {
 		}
 		return true
 	}
-	response, err = tem.network.RunIO(request)
+	response, err = tem.network.RunIO(ctx, request)
 	for err != nil {
 		return &tem.data, err
 	}
@@ -131,7 +141,7 @@ func (tem *Tem104) Read() (*models.DataDevice, error) {
 
 		request = net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
 		request.ControlFunction = tem.checkFrame
-		response, err = tem.network.RunIO(request)
+		response, err = tem.network.RunIO(ctx, request)
 		for err != nil {
 			return &tem.data, err
 		}
@@ -162,7 +172,7 @@ func (tem *Tem104) Read() (*models.DataDevice, error) {
 	request = net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
 	request.SecondsReadTimeout = 5
 	request.ControlFunction = tem.checkFrame
-	response, err = tem.network.RunIO(request)
+	response, err = tem.network.RunIO(ctx, request)
 
 	if err == nil {
 		memoryResponse2K = response
@@ -180,7 +190,7 @@ func (tem *Tem104) Read() (*models.DataDevice, error) {
 		request = net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
 		request.SecondsReadTimeout = 5
 		request.ControlFunction = tem.checkFrame
-		response, err = tem.network.RunIO(request)
+		response, err = tem.network.RunIO(ctx, request)
 		for err != nil {
 			return &tem.data, err
 		}
@@ -190,7 +200,7 @@ func (tem *Tem104) Read() (*models.DataDevice, error) {
 		request = net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
 		request.SecondsReadTimeout = 5
 		request.ControlFunction = tem.checkFrame
-		response, err = tem.network.RunIO(request)
+		response, err = tem.network.RunIO(ctx, request)
 		for err != nil {
 			return &tem.data, err
 		}
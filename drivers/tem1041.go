@@ -1,6 +1,7 @@
 package drivers
 
 import (
+	"context"
 	"qBox/models"
 	"qBox/services/log"
 	"qBox/services/net"
@@ -17,8 +18,17 @@ type Tem104s1 struct {
 	counterNumber byte
 }
 
+func init() {
+	Register("tem104-1", func() models.IDeviceDriver { return new(Tem104s1) }, Metadata{
+		Title:        "ТЭМ-104-1",
+		Protocol:     "ТЭМ",
+		Manufacturer: "АРВАС",
+	})
+}
+
 // Реализация интерфейса IDeviceDriver::Init
-func (tem *Tem104s1) Init(counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+func (tem *Tem104s1) Init(ctx context.Context, counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+	*tem = Tem104s1{} // см. models.IDeviceDriver::Init
 
 	tem.logger = logger
 	tem.network = network
@@ -42,7 +52,7 @@ func (tem *Tem104s1) Init(counterNumber byte, network *net.Network, logger *log.
 		}
 		return true
 	}
-	response, err = tem.network.RunIO(request)
+	response, err = tem.network.RunIO(ctx, request)
 	for err != nil {
 		return err
 	}
@@ -57,7 +67,7 @@ func (tem *Tem104s1) Init(counterNumber byte, network *net.Network, logger *log.
 	return nil
 }
 
-func (tem *Tem104s1) Read() (*models.DataDevice, error) {
+func (tem *Tem104s1) Read(ctx context.Context) (*models.DataDevice, error) {
 
 	tem.data.TimeRequest = time.Now()
 
@@ -70,7 +80,7 @@ func (tem *Tem104s1) Read() (*models.DataDevice, error) {
 	request := net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
 	request.ControlFunction = tem.checkFrame
 	request.SecondsReadTimeout = 5
-	response, err = tem.network.RunIO(request)
+	response, err = tem.network.RunIO(ctx, request)
 	for err != nil {
 		return &tem.data, err
 	}
@@ -88,7 +98,7 @@ func (tem *Tem104s1) Read() (*models.DataDevice, error) {
 	request = net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
 	request.ControlFunction = tem.checkFrame
 	request.SecondsReadTimeout = 5
-	response, err = tem.network.RunIO(request)
+	response, err = tem.network.RunIO(ctx, request)
 	for err != nil {
 		return &tem.data, err
 	}
@@ -106,7 +116,7 @@ func (tem *Tem104s1) Read() (*models.DataDevice, error) {
 	request = net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
 	request.ControlFunction = tem.checkFrame
 	request.SecondsReadTimeout = 5
-	response, err = tem.network.RunIO(request)
+	response, err = tem.network.RunIO(ctx, request)
 	for err != nil {
 		return &tem.data, err
 	}
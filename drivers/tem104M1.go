@@ -1,6 +1,7 @@
 package drivers
 
 import (
+	"context"
 	"qBox/models"
 	"qBox/services/log"
 	"qBox/services/net"
@@ -18,8 +19,17 @@ type TEM104M1 struct {
 	counterNumber byte
 }
 
+func init() {
+	Register("tem104m1", func() models.IDeviceDriver { return new(TEM104M1) }, Metadata{
+		Title:        "ТЭМ-104М-1",
+		Protocol:     "ТЭМ",
+		Manufacturer: "АРВАС",
+	})
+}
+
 // Реализация интерфейса IDeviceDriver::Init
-func (tem *TEM104M1) Init(counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+func (tem *TEM104M1) Init(ctx context.Context, counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+	*tem = TEM104M1{} // см. models.IDeviceDriver::Init
 
 	tem.logger = logger
 	tem.network = network
@@ -35,7 +45,7 @@ func (tem *TEM104M1) Init(counterNumber byte, network *net.Network, logger *log.
 	request := net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
 	request.SecondsReadTimeout = 5
 	request.ControlFunction = tem.checkFrame
-	response, err = tem.network.RunIO(request)
+	response, err = tem.network.RunIO(ctx, request)
 	for err != nil {
 		return err
 	}
@@ -50,7 +60,7 @@ func (tem *TEM104M1) Init(counterNumber byte, network *net.Network, logger *log.
 }
 
 // Реализация интерфейса IDeviceDriver::Read
-func (tem *TEM104M1) Read() (*models.DataDevice, error) {
+func (tem *TEM104M1) Read(ctx context.Context) (*models.DataDevice, error) {
 
 	tem.data.TimeRequest = time.Now()
 
@@ -63,7 +73,7 @@ func (tem *TEM104M1) Read() (*models.DataDevice, error) {
 	request := net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
 	request.ControlFunction = tem.checkFrame
 	request.SecondsReadTimeout = 5
-	response, err = tem.network.RunIO(request)
+	response, err = tem.network.RunIO(ctx, request)
 	for err != nil {
 		return &tem.data, err
 	}
@@ -82,7 +92,7 @@ func (tem *TEM104M1) Read() (*models.DataDevice, error) {
 	request = net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
 	request.ControlFunction = tem.checkFrame
 	request.SecondsReadTimeout = 5
-	response, err = tem.network.RunIO(request)
+	response, err = tem.network.RunIO(ctx, request)
 	for err != nil {
 		return &tem.data, err
 	}
@@ -96,7 +106,7 @@ func (tem *TEM104M1) Read() (*models.DataDevice, error) {
 	request = net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
 	request.ControlFunction = tem.checkFrame
 	request.SecondsReadTimeout = 5
-	response, err = tem.network.RunIO(request)
+	response, err = tem.network.RunIO(ctx, request)
 	for err != nil {
 		return &tem.data, err
 	}
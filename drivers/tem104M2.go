@@ -1,6 +1,7 @@
 package drivers
 
 import (
+	"context"
 	"qBox/models"
 	"qBox/services/convert"
 	"qBox/services/log"
@@ -19,8 +20,17 @@ type TEM104M2 struct {
 	counterNumber byte
 }
 
+func init() {
+	Register("tem104m2", func() models.IDeviceDriver { return new(TEM104M2) }, Metadata{
+		Title:        "ТЭМ-104М2",
+		Protocol:     "ТЭМ",
+		Manufacturer: "АРВАС",
+	})
+}
+
 // Реализация интерфейса IDeviceDriver::Init
-func (tem *TEM104M2) Init(counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+func (tem *TEM104M2) Init(ctx context.Context, counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+	*tem = TEM104M2{} // см. models.IDeviceDriver::Init
 
 	tem.logger = logger
 	tem.network = network
@@ -36,7 +46,7 @@ func (tem *TEM104M2) Init(counterNumber byte, network *net.Network, logger *log.
 	request := net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
 	request.SecondsReadTimeout = 5
 	request.ControlFunction = tem.checkFrame
-	response, err = tem.network.RunIO(request)
+	response, err = tem.network.RunIO(ctx, request)
 	for err != nil {
 		return err
 	}
@@ -58,13 +68,13 @@ func (tem *TEM104M2) Init(counterNumber byte, network *net.Network, logger *log.
 }
 
 // Реализация интерфейса IDeviceDriver::Read
-func (tem *TEM104M2) Read() (*models.DataDevice, error) {
+func (tem *TEM104M2) Read(ctx context.Context) (*models.DataDevice, error) {
 	var command []byte
 	var response []byte
 	var err error
 
 	tem.data.TimeRequest = time.Now()
-	tem.populateDatetime()
+	tem.populateDatetime(ctx)
 
 	tem.logger.Info("Чтение оперативной памяти")
 
@@ -72,7 +82,7 @@ func (tem *TEM104M2) Read() (*models.DataDevice, error) {
 	request := net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
 	request.ControlFunction = tem.checkFrame
 	request.SecondsReadTimeout = 5
-	response, err = tem.network.RunIO(request)
+	response, err = tem.network.RunIO(ctx, request)
 	for err != nil {
 		return &tem.data, err
 	}
@@ -81,7 +91,10 @@ func (tem *TEM104M2) Read() (*models.DataDevice, error) {
 	tem.data.Systems[0].GV2 = convert.FloatLittleEndianByPointer(response, 0x06+0x44)
 	tem.data.Systems[0].GM2 = convert.FloatLittleEndianByPointer(response, 0x06+0x54)
 
-	integratorsData := tem.integratorsData()
+	integratorsData, err := tem.integratorsData(ctx)
+	for err != nil {
+		return &tem.data, err
+	}
 	tem.logger.Info("Расшифровка интеграторов %X", integratorsData)
 
 	for i, _ := range tem.data.Systems {
@@ -104,18 +117,36 @@ func (tem *TEM104M2) Read() (*models.DataDevice, error) {
 	return &tem.data, nil
 }
 
+// Реализация интерфейса IArchiveDriver::ReadArchive, см. Tem104MArchive
+func (tem *TEM104M2) ReadArchive(ctx context.Context, archive models.ArchiveType, from time.Time, to time.Time) (*models.Archive, error) {
+	reader := Tem104MArchive{
+		Network:       tem.network,
+		Logger:        tem.logger,
+		CounterNumber: tem.counterNumber,
+		Serial:        tem.data.Serial,
+		Systems:       len(tem.data.Systems),
+	}
+	return reader.Read(ctx, archive, from, to)
+}
+
+// Реализация интерфейса IClockDriver::SetTime, см. Tem104MClock
+func (tem *TEM104M2) SetTime(ctx context.Context, moment time.Time) error {
+	clock := Tem104MClock{Network: tem.network, Logger: tem.logger, CounterNumber: tem.counterNumber}
+	return clock.Write(ctx, moment)
+}
+
 func (tem *TEM104M2) prepareCommand(commandBytes []byte) []byte {
 	command := append([]byte{0x55, tem.counterNumber, convert.ToNotByte(tem.counterNumber)}, commandBytes...)
 	return append(command, tem.calculateCheckSum(command))
 }
 
-func (tem *TEM104M2) populateDatetime() {
+func (tem *TEM104M2) populateDatetime(ctx context.Context) {
 	tem.logger.Info("Получение даты времени на теплосчётчике")
 	command := []byte{0x55, tem.counterNumber, convert.ToNotByte(tem.counterNumber), 0x0F, 0x02, 0x02, 0x00, 0x06}
 	request := net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
 	request.ControlFunction = tem.checkFrame
 	request.SecondsReadTimeout = 5
-	response, err := tem.network.RunIO(request)
+	response, err := tem.network.RunIO(ctx, request)
 	for err != nil {
 		tem.logger.Info("Ошибка получения даты времени на теплосчётчике. " + err.Error())
 		return
@@ -130,7 +161,7 @@ func (tem *TEM104M2) populateDatetime() {
 	tem.data.Time = time.Date(year, month, day, hour, min, sek, 0, time.Local)
 }
 
-func (tem *TEM104M2) integratorsData() []byte {
+func (tem *TEM104M2) integratorsData(ctx context.Context) ([]byte, error) {
 	tem.logger.Info("Чтение карты накопленных значений параметров (интеграторы)")
 	step := 0
 	var integratorsData []byte
@@ -142,10 +173,10 @@ func (tem *TEM104M2) integratorsData() []byte {
 		request := net.PrepareRequest(tem.prepareCommand(append(append([]byte{0x0F, 0x01, 0x03}, startBytes...), 0x40)))
 		request.ControlFunction = tem.checkFrame
 		request.SecondsReadTimeout = 5
-		response, err := tem.network.RunIO(request)
+		response, err := tem.network.RunIO(ctx, request)
 		for err != nil {
 			tem.logger.Info("Ошибка чтения интеграторов. " + err.Error())
-			return integratorsData
+			return integratorsData, err
 		}
 		integratorsData = append(integratorsData, response[6:len(response)-1]...)
 		if step*0x40 < 351 { // 0x01 0x5F
@@ -154,7 +185,7 @@ func (tem *TEM104M2) integratorsData() []byte {
 			break
 		}
 	}
-	return integratorsData
+	return integratorsData, nil
 }
 
 /**
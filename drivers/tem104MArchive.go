@@ -0,0 +1,300 @@
+package drivers
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"qBox/models"
+	"qBox/services/log"
+	"qBox/services/net"
+	"time"
+)
+
+const (
+	tem104MRecordSize   = 0x160  // Размер записи архива, совпадает с картой интеграторов (протокол ТЭМ-104М, п. 5.1.5)
+	tem104MBlockSize    = 0x40   // Наибольший блок, который читается командой 0F03
+	tem104MNotFound     = 0xFFFF // Ответ команды поиска 0D11, если записи с заданной датой нет
+	tem104MRecordPeriod = 0x04   // Смещение даты и времени, за которые сделана запись (п. 5.7.2)
+	tem104MSysCon       = 0x0080 // Адрес настроек первой системы SysCon в памяти настроек (п. 5.1, 5.5.2)
+	tem104MSysConSize   = 0x4D   // Размер настроек одной системы: системы 2-4 начинаются с 00CDh, 011Ah, 0167h
+	tem104MGChan        = 0x05   // Смещение массива G_chan в настройках системы (п. 5.1.2)
+)
+
+// Количество каналов расхода системы по типу sys_type 0-Fh (п. 5.5.3)
+var tem104MGChanCount = [16]int{1, 1, 1, 1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 3, 3, 3}
+
+/**
+Область flash памяти ТЭМ-104М, занятая архивом одного типа (протокол ТЭМ-104М, п. 5.4)
+*/
+type tem104MArchiveArea struct {
+	statType byte   // Тип архива в команде поиска 0D11
+	address  uint32 // Адрес первой записи
+	count    int    // Количество записей, архив кольцевой
+}
+
+var tem104MArchiveAreas = map[models.ArchiveType]tem104MArchiveArea{
+	models.ArchiveHourly:  {statType: 0x00, address: 0x00000000, count: 1600},
+	models.ArchiveDaily:   {statType: 0x01, address: 0x00089800, count: 800},
+	models.ArchiveMonthly: {statType: 0x02, address: 0x000CE400, count: 60},
+}
+
+/**
+Чтение архивов теплосчётчиков с протоколом ТЭМ-104М (ТЭМ-104М, ТЭМ-104М2).
+Номер записи первого периода находится командой поиска по дате 0D11, следующие записи читаются подряд
+командой 0F03, пока дата записи растёт. Если дата записи не та, что ожидалась (архив переписан или прибор
+не записал период), поиск по дате повторяется для следующего периода.
+Номер записи в ответе на 0D11 - номер в области архива заданного типа, адрес записи - начало области + номер * 0160h.
+*/
+type Tem104MArchive struct {
+	Network       *net.Network
+	Logger        *log.LoggerService
+	CounterNumber byte
+	Serial        string // Заводской номер, переносится в записи
+	Systems       int    // Количество систем прибора
+	channels      [][]int
+}
+
+func (archive Tem104MArchive) Read(ctx context.Context, archiveType models.ArchiveType, from time.Time, to time.Time) (*models.Archive, error) {
+	area, ok := tem104MArchiveAreas[archiveType]
+	if !ok {
+		return nil, fmt.Errorf("тип архива %s не поддерживается прибором", archiveType)
+	}
+	if archive.Systems < 1 {
+		archive.Systems = 1
+	}
+
+	result := &models.Archive{
+		Serial:      archive.Serial,
+		Type:        archiveType,
+		UnitQ:       models.Gcal,
+		TimeRequest: time.Now(),
+	}
+	channels, err := archive.readChannels(ctx)
+	if err != nil {
+		return result, err
+	}
+	archive.channels = channels
+
+	period := archiveType.First(from)
+	for period.Before(to) {
+		number, err := archive.search(ctx, area, period)
+		if err != nil {
+			return result, err
+		}
+		if number == tem104MNotFound {
+			archive.Logger.Info("Запись за %s не найдена", period.Format("02.01.2006 15:04"))
+			period = archiveType.Next(period)
+			continue
+		}
+
+		// Записи после найденной читаются подряд, пока их дата растёт
+		for read := 0; read < area.count && period.Before(to); read++ {
+			record, err := archive.readRecord(ctx, area, number)
+			if err != nil {
+				return result, err
+			}
+			data, err := archive.decode(archiveType, record)
+			if err == nil && data.Start.Before(period) {
+				err = errors.New("запись старше ожидаемой, архив закончился")
+			}
+			if err != nil {
+				archive.Logger.Info("Запись № %d: %s", number, err.Error())
+				if read == 0 {
+					period = archiveType.Next(period)
+				}
+				break
+			}
+			if !data.Start.Before(to) {
+				return result, nil
+			}
+			result.Records = append(result.Records, data)
+			period = data.End
+			number = (number + 1) % area.count
+		}
+	}
+	return result, nil
+}
+
+/**
+Каналы расхода каждой системы (п. 5.5.3): тип системы sys_type и массив G_chan читаются из настроек системы
+командой 0F01. Номера каналов в G_chan начинаются с 0, используется столько первых элементов, сколько каналов
+расхода у системы этого типа.
+*/
+func (archive Tem104MArchive) readChannels(ctx context.Context) ([][]int, error) {
+	channels := make([][]int, archive.Systems)
+	for i := range channels {
+		archive.Logger.Info("Чтение каналов расхода системы %d", i+1)
+		address := make([]byte, 2)
+		binary.BigEndian.PutUint16(address, uint16(tem104MSysCon+tem104MSysConSize*i))
+		size := tem104MGChan + 4
+		request := net.PrepareRequest(archive.prepareCommand(append(append([]byte{0x0F, 0x01, 0x03}, address...), byte(size))))
+		request.ControlFunction = archive.checkFrame
+		request.SecondsReadTimeout = 5
+		response, err := archive.Network.RunIO(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		if int(response[5]) != size {
+			return nil, fmt.Errorf("прибор вернул %d байт настроек системы вместо %d", response[5], size)
+		}
+		settings := response[6 : 6+size]
+		for _, channel := range settings[tem104MGChan : tem104MGChan+tem104MGChanCount[settings[0]&0x0F]] {
+			if channel > 3 {
+				return nil, fmt.Errorf("неверный номер канала расхода %d в настройках системы %d", channel, i+1)
+			}
+			channels[i] = append(channels[i], int(channel))
+		}
+	}
+	return channels, nil
+}
+
+// Поиск номера записи архива по дате, команда 0D11. Дата передаётся по часам прибора
+func (archive Tem104MArchive) search(ctx context.Context, area tem104MArchiveArea, period time.Time) (int, error) {
+	archive.Logger.Info("Поиск записи архива за %s", period.Format("02.01.2006 15:04"))
+	request := net.PrepareRequest(archive.prepareCommand([]byte{0x0D, 0x11, 0x05, area.statType,
+		toBcd(period.Hour()), toBcd(period.Day()), toBcd(int(period.Month())), toBcd(period.Year() % 100)}))
+	request.ControlFunction = archive.checkFrame
+	request.SecondsReadTimeout = 5
+	response, err := archive.Network.RunIO(ctx, request)
+	if err != nil {
+		return 0, err
+	}
+	if response[5] != 0x02 {
+		return 0, fmt.Errorf("неверный ответ на поиск записи архива: %X", response)
+	}
+	return int(response[6])<<8 | int(response[7]), nil
+}
+
+// Чтение записи архива блоками командой 0F03
+func (archive Tem104MArchive) readRecord(ctx context.Context, area tem104MArchiveArea, number int) ([]byte, error) {
+	if number >= area.count {
+		return nil, fmt.Errorf("номер записи архива %d вне области архива (%d записей)", number, area.count)
+	}
+	archive.Logger.Info("Чтение записи архива № %d", number)
+	address := area.address + uint32(number)*tem104MRecordSize
+	var record []byte
+	for len(record) < tem104MRecordSize {
+		size := tem104MRecordSize - len(record)
+		if size > tem104MBlockSize {
+			size = tem104MBlockSize
+		}
+		blockAddress := make([]byte, 4)
+		binary.BigEndian.PutUint32(blockAddress, address+uint32(len(record)))
+		request := net.PrepareRequest(archive.prepareCommand(append([]byte{0x0F, 0x03, 0x05, byte(size)}, blockAddress...)))
+		request.ControlFunction = archive.checkFrame
+		request.SecondsReadTimeout = 5
+		response, err := archive.Network.RunIO(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		if int(response[5]) != size {
+			return nil, fmt.Errorf("прибор вернул %d байт вместо %d", response[5], size)
+		}
+		record = append(record, response[6:6+size]...)
+	}
+	return record, nil
+}
+
+/**
+Расшифровка записи архива (п. 5.1.5, 5.7). Числа хранятся младшим байтом вперёд.
+Интеграторы объёма и массы ведутся по каналам, поэтому в V1, M1 и V2, M2 системы заносятся первый и второй
+каналы расхода системы, а в M3 - третий (подпитка), см. readChannels.
+*/
+func (archive Tem104MArchive) decode(archiveType models.ArchiveType, record []byte) (models.ArchiveRecord, error) {
+	var data models.ArchiveRecord
+	var sum byte
+	for _, b := range record[:tem104MRecordSize-1] {
+		sum += b
+	}
+	if ^sum != record[tem104MRecordSize-1] {
+		return data, errors.New("контрольная сумма записи не совпадает")
+	}
+
+	long := func(offset int) uint32 {
+		return binary.LittleEndian.Uint32(record[offset:])
+	}
+	integrator := func(offset int) float64 {
+		// Целая часть L и дробная часть F хранятся в разных массивах, дробная на 40h дальше
+		return float64(float32(long(offset)) + math.Float32frombits(long(offset+0x40)))
+	}
+
+	data.Start = archiveType.Truncate(time.Unix(int64(long(tem104MRecordPeriod)), 0))
+	data.End = archiveType.Next(data.Start)
+	data.TimeOn = long(0x98)
+	data.TimeOff = long(0x9C)
+	data.AddNewSystem(archive.Systems - 1)
+	for i := range data.Systems {
+		system := &data.Systems[i]
+		system.Status = true
+		system.SigmaQ = integrator(0x28 + 4*i)
+		if i < len(archive.channels) {
+			channels := archive.channels[i]
+			if len(channels) > 0 {
+				system.V1 = integrator(0x08 + 4*channels[0])
+				system.M1 = integrator(0x18 + 4*channels[0])
+			}
+			if len(channels) > 1 {
+				system.V2 = integrator(0x08 + 4*channels[1])
+				system.M2 = integrator(0x18 + 4*channels[1])
+			}
+			if len(channels) > 2 {
+				system.M3 = integrator(0x18 + 4*channels[2])
+			}
+		}
+		// Температуры в сотых долях градуса, по три канала на систему. Давления в той же шкале, что в текущих
+		// данных (tem104m.Read: байт / 100), хотя в таблице п. 5.1.5 для 0134h указаны единицы МПа/10
+		system.T1 = float32(binary.LittleEndian.Uint16(record[0x11C+6*i:])) / 100
+		system.T2 = float32(binary.LittleEndian.Uint16(record[0x11C+6*i+2:])) / 100
+		system.P1 = float32(record[0x134+3*i]) / 100
+		system.P2 = float32(record[0x134+3*i+1]) / 100
+		system.TimeRunSys = long(0xA0 + 4*i)
+		system.TimeGMin = long(0xB0 + 4*i)
+		system.TimeGMax = long(0xC0 + 4*i)
+		system.TimeDT = long(0xD0 + 4*i)
+		system.TimeFault = long(0xE0 + 4*i)
+	}
+	return data, nil
+}
+
+func (archive Tem104MArchive) prepareCommand(commandBytes []byte) []byte {
+	command := append([]byte{0x55, archive.CounterNumber, ToNotByte(archive.CounterNumber)}, commandBytes...)
+	var sum byte
+	for _, b := range command {
+		sum += b
+	}
+	return append(command, ^sum)
+}
+
+func (archive Tem104MArchive) checkFrame(response []byte) bool {
+	if len(response) < 6 {
+		archive.Logger.Info("Получено меньше 6 байт")
+		return false
+	}
+
+	if response[0] != 0xAA || ((^response[1])&0xFF) != response[2] {
+		archive.Logger.Info("Заголовок ответа не верный: %X", response[0:5])
+		return false
+	}
+
+	if len(response) < 6+int(response[5])+1 {
+		archive.Logger.Info("Размер полученных данных меньше ожидаемого: %X", 6+int(response[5])+1)
+		return false
+	}
+
+	var sum byte
+	for _, b := range response[:len(response)-1] {
+		sum += b
+	}
+	if ^sum != response[len(response)-1] {
+		archive.Logger.Info("Получен некорректный ответ. Контрольная сумма не совпадает.")
+		return false
+	}
+	return true
+}
+
+// Число 0-99 в двоично-десятичном коде
+func toBcd(value int) byte {
+	return byte(value/10<<4 | value%10)
+}
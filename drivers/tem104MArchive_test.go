@@ -0,0 +1,46 @@
+package drivers
+
+import (
+	"encoding/binary"
+	"math"
+	"qBox/models"
+	"testing"
+	"time"
+)
+
+// Запись архива ТЭМ-104М: температуры в сотых градуса, давления в сотых МПа, объём и масса по каналам системы
+func TestTem104MArchiveDecode(t *testing.T) {
+	moment := time.Date(2026, 10, 18, 10, 0, 0, 0, time.Local)
+	record := make([]byte, tem104MRecordSize)
+	binary.LittleEndian.PutUint32(record[tem104MRecordPeriod:], uint32(moment.Unix()))
+	binary.LittleEndian.PutUint32(record[0x28:], 1234)
+	binary.LittleEndian.PutUint32(record[0x28+0x40:], math.Float32bits(0.5))
+	binary.LittleEndian.PutUint16(record[0x11C:], 7250)
+	binary.LittleEndian.PutUint16(record[0x11E:], 4825)
+	record[0x134], record[0x135] = 62, 45
+	binary.LittleEndian.PutUint32(record[0x08+4*2:], 10)
+	binary.LittleEndian.PutUint32(record[0x08+4*3:], 20)
+	binary.LittleEndian.PutUint32(record[0x18+4*2:], 30)
+	binary.LittleEndian.PutUint32(record[0x18+4*3:], 40)
+	var sum byte
+	for _, b := range record[:tem104MRecordSize-1] {
+		sum += b
+	}
+	record[tem104MRecordSize-1] = ^sum
+
+	data, err := Tem104MArchive{Systems: 1, channels: [][]int{{2, 3}}}.decode(models.ArchiveHourly, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	system := data.Systems[0]
+	if !data.Start.Equal(moment) || system.SigmaQ != 1234.5 || system.T1 != 72.5 || system.T2 != 48.25 ||
+		system.P1 != float32(0.62) || system.P2 != float32(0.45) ||
+		system.V1 != 10 || system.V2 != 20 || system.M1 != 30 || system.M2 != 40 {
+		t.Errorf("запись %v, система %+v", data.Start, system)
+	}
+
+	record[0x134]++
+	if _, err = (Tem104MArchive{Systems: 1}).decode(models.ArchiveHourly, record); err == nil {
+		t.Error("запись с неверной контрольной суммой должна отклоняться")
+	}
+}
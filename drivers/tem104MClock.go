@@ -0,0 +1,33 @@
+package drivers
+
+import (
+	"context"
+	"qBox/services/log"
+	"qBox/services/net"
+	"time"
+)
+
+/**
+Установка часов реального времени теплосчётчиков с протоколом ТЭМ-104М (ТЭМ-104М, ТЭМ-104М2), команда 0182h
+(протокол ТЭМ-104М, п. 4.5.2). Регистры часов с адреса 00h: секунды, минуты, часы, день, месяц, год - 2000
+и день недели (0 - воскресенье), значения двоичные, как при чтении командой 0F02h.
+*/
+type Tem104MClock struct {
+	Network       *net.Network
+	Logger        *log.LoggerService
+	CounterNumber byte
+}
+
+func (clock Tem104MClock) Write(ctx context.Context, moment time.Time) error {
+	// Кадры и проверка ответа такие же, как при чтении архива
+	frame := Tem104MArchive{Network: clock.Network, Logger: clock.Logger, CounterNumber: clock.CounterNumber}
+
+	clock.Logger.Info("Установка времени на приборе %s", moment.Format("02.01.2006 15:04:05"))
+	request := net.PrepareRequest(frame.prepareCommand([]byte{0x01, 0x82, 0x08, 0x00,
+		byte(moment.Second()), byte(moment.Minute()), byte(moment.Hour()),
+		byte(moment.Day()), byte(moment.Month()), byte(moment.Year() - 2000), byte(moment.Weekday())}))
+	request.ControlFunction = frame.checkFrame
+	request.SecondsReadTimeout = 5
+	_, err := clock.Network.RunIO(ctx, request)
+	return err
+}
@@ -0,0 +1,188 @@
+package tem104k
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"qBox/drivers"
+	"qBox/models"
+	"qBox/services/net"
+	"sort"
+	"time"
+)
+
+const recordSize = 0x30 // Размер записи часового, суточного и месячного архива
+
+/**
+Область памяти EEPROM 64К, занятая архивом одного типа (протокол ТЭМ-104К, п. 5.4).
+Для часового и суточного архива прибор хранит адреса первой и последней записи в памяти EEPROM 512 (п. 5.3),
+месячный архив из 12 записей читается целиком.
+*/
+type archiveArea struct {
+	address uint16 // Адрес первой записи области
+	count   int    // Количество записей, архив кольцевой
+	pointer byte   // Адрес указателей первой и последней записи в EEPROM 512, 0 - указателей нет
+}
+
+var archiveAreas = map[models.ArchiveType]archiveArea{
+	models.ArchiveHourly:  {address: 0x0000, count: 800, pointer: 0x9D},
+	models.ArchiveDaily:   {address: 0x9600, count: 400, pointer: 0x94},
+	models.ArchiveMonthly: {address: 0xE100, count: 12},
+}
+
+// Реализация интерфейса IArchiveDriver::ReadArchive
+func (tem *Tem104K) ReadArchive(ctx context.Context, archiveType models.ArchiveType, from time.Time, to time.Time) (*models.Archive, error) {
+	area, ok := archiveAreas[archiveType]
+	if !ok {
+		return nil, fmt.Errorf("тип архива %s не поддерживается прибором", archiveType)
+	}
+
+	archive := &models.Archive{
+		Serial:      tem.data.Serial,
+		Type:        archiveType,
+		UnitQ:       models.Gcal,
+		TimeRequest: time.Now(),
+	}
+	if area.pointer == 0 {
+		for i := 0; i < area.count; i++ {
+			record, err := tem.readRecord(ctx, archiveType, area.address+uint16(i*recordSize))
+			if err != nil {
+				return archive, err
+			}
+			if record != nil && !record.Start.Before(from) && record.Start.Before(to) {
+				archive.Records = append(archive.Records, *record)
+			}
+		}
+		sort.Slice(archive.Records, func(i, j int) bool { return archive.Records[i].Start.Before(archive.Records[j].Start) })
+		return archive, nil
+	}
+
+	first, last, err := tem.readPointers(ctx, area)
+	if err != nil {
+		return archive, err
+	}
+
+	// Записи читаются от последней к первой, пока не встретится запись раньше from
+	address := last
+	for i := 0; i < area.count; i++ {
+		record, err := tem.readRecord(ctx, archiveType, address)
+		if err != nil {
+			reverse(archive.Records)
+			return archive, err
+		}
+		if record != nil {
+			if record.Start.Before(from) {
+				break
+			}
+			if record.Start.Before(to) {
+				archive.Records = append(archive.Records, *record)
+			}
+		}
+		if address == first {
+			break
+		}
+		if address == area.address {
+			address += uint16((area.count - 1) * recordSize)
+		} else {
+			address -= recordSize
+		}
+	}
+	reverse(archive.Records)
+	return archive, nil
+}
+
+// Адреса первой и последней записи архива из памяти EEPROM 512
+func (tem *Tem104K) readPointers(ctx context.Context, area archiveArea) (uint16, uint16, error) {
+	tem.logger.Info("Чтение указателей архива")
+	command := []byte{0x55, tem.counterNumber, drivers.ToNotByte(tem.counterNumber), 0x0F, 0x01, 0x03, 0x00, area.pointer, 0x04}
+	request := net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
+	request.ControlFunction = tem.checkFrame
+	request.SecondsReadTimeout = 5
+	response, err := tem.network.RunIO(ctx, request)
+	if err != nil {
+		return 0, 0, err
+	}
+	if response[5] < 4 {
+		return 0, 0, fmt.Errorf("прибор вернул %d байт вместо 4", response[5])
+	}
+
+	first := uint16(response[6])<<8 | uint16(response[7])
+	last := uint16(response[8])<<8 | uint16(response[9])
+	tem.logger.Debug("Адрес первой записи %04X, последней %04X", first, last)
+	end := int(area.address) + area.count*recordSize
+	for _, pointer := range []uint16{first, last} {
+		if int(pointer) < int(area.address) || int(pointer) >= end || (pointer-area.address)%recordSize != 0 {
+			return 0, 0, fmt.Errorf("неверный указатель архива %04X", pointer)
+		}
+	}
+	return first, last, nil
+}
+
+/**
+Чтение записи архива из памяти EEPROM 64К (п. 4.3, 5.4). Числа хранятся старшим байтом вперёд.
+nil - запись пустая или повреждена.
+*/
+func (tem *Tem104K) readRecord(ctx context.Context, archiveType models.ArchiveType, address uint16) (*models.ArchiveRecord, error) {
+	tem.logger.Info("Чтение записи архива по адресу %04X", address)
+	command := []byte{0x55, tem.counterNumber, drivers.ToNotByte(tem.counterNumber), 0x0F, 0x03, 0x03, byte(address >> 8), byte(address), recordSize}
+	request := net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
+	request.ControlFunction = tem.checkFrame
+	request.SecondsReadTimeout = 5
+	response, err := tem.network.RunIO(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	if int(response[5]) != recordSize {
+		return nil, fmt.Errorf("прибор вернул %d байт вместо %d", response[5], recordSize)
+	}
+
+	record, err := tem.decodeRecord(archiveType, response[6:6+recordSize])
+	if err != nil {
+		tem.logger.Info("Запись по адресу %04X пропущена: %s", address, err.Error())
+		return nil, nil
+	}
+	return record, nil
+}
+
+func (tem *Tem104K) decodeRecord(archiveType models.ArchiveType, record []byte) (*models.ArchiveRecord, error) {
+	var sum byte
+	for _, b := range record[:recordSize-1] {
+		sum += b
+	}
+	if ^sum != record[recordSize-1] {
+		return nil, errors.New("контрольная сумма записи не совпадает")
+	}
+
+	day := drivers.DecodeBcd([]byte{record[0x00]})
+	month := drivers.DecodeBcd([]byte{record[0x01]})
+	year := drivers.DecodeBcd([]byte{record[0x02]})
+	hour := drivers.DecodeBcd([]byte{record[0x03]})
+	if day < 1 || day > 31 || month < 1 || month > 12 || hour > 23 {
+		return nil, fmt.Errorf("неверная дата записи %X", record[0:4])
+	}
+
+	data := new(models.ArchiveRecord)
+	data.Start = archiveType.Truncate(time.Date(2000+year, time.Month(month), day, hour, 0, 0, 0, time.Local))
+	data.End = archiveType.Next(data.Start)
+	data.AddNewSystem(0)
+
+	system := &data.Systems[0]
+	system.Status = true
+	system.SigmaQ = float64(float32(tem.readLongFrom(record, 0x04)) + tem.readFloatFrom(record, 0x08))
+	system.M1 = float64(float32(tem.readLongFrom(record, 0x0C)) + tem.readFloatFrom(record, 0x10))
+	system.T1 = tem.readFloatFrom(record, 0x14)
+	system.T2 = tem.readFloatFrom(record, 0x18)
+
+	// Времена в записи архива хранятся в сотых долях часа. Как и в Read, TimeOn - сумма времени работы без ошибок и в ошибках
+	system.TimeRunSys = tem.readLongFrom(record, 0x1C) * 36
+	system.TimeError = tem.readLongFrom(record, 0x20) * 36
+	data.TimeOn = system.TimeRunSys + system.TimeError
+	data.TimeOff = tem.readLongFrom(record, 0x24) * 36
+	return data, nil
+}
+
+func reverse(records []models.ArchiveRecord) {
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+}
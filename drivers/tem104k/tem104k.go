@@ -2,6 +2,7 @@ package tem104k
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"qBox/drivers"
 	"qBox/models"
@@ -20,8 +21,17 @@ type Tem104K struct {
 	counterNumber byte
 }
 
+func init() {
+	drivers.Register("tem104k", func() models.IDeviceDriver { return new(Tem104K) }, drivers.Metadata{
+		Title:        "ТЭМ-104К",
+		Protocol:     "ТЭМ",
+		Manufacturer: "АРВАС",
+	})
+}
+
 // Реализация интерфейса IDeviceDriver::Init
-func (tem *Tem104K) Init(counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+func (tem *Tem104K) Init(ctx context.Context, counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+	*tem = Tem104K{} // см. models.IDeviceDriver::Init
 
 	tem.logger = logger
 	tem.network = network
@@ -35,7 +45,7 @@ func (tem *Tem104K) Init(counterNumber byte, network *net.Network, logger *log.L
 	command = []byte{0x55, tem.counterNumber, drivers.ToNotByte(tem.counterNumber), 0x00, 0x00, 0x00}
 	request := net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
 	request.ControlFunction = tem.checkDevice
-	_, err = tem.network.RunIO(request)
+	_, err = tem.network.RunIO(ctx, request)
 	for err != nil {
 		return err
 	}
@@ -44,7 +54,7 @@ func (tem *Tem104K) Init(counterNumber byte, network *net.Network, logger *log.L
 	command = []byte{0x55, tem.counterNumber, drivers.ToNotByte(tem.counterNumber), 0x00, 0x01, 0x00}
 	request = net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
 	request.ControlFunction = tem.checkSoftVersion
-	_, err = tem.network.RunIO(request)
+	_, err = tem.network.RunIO(ctx, request)
 	for err != nil {
 		return err
 	}
@@ -53,7 +63,7 @@ func (tem *Tem104K) Init(counterNumber byte, network *net.Network, logger *log.L
 	command = []byte{0x55, tem.counterNumber, drivers.ToNotByte(tem.counterNumber), 0x0F, 0x01, 0x03, 0x00, 0x00, 0x08}
 	request = net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
 	request.ControlFunction = tem.checkFrame
-	response, err = tem.network.RunIO(request)
+	response, err = tem.network.RunIO(ctx, request)
 	for err != nil {
 		return err
 	}
@@ -72,7 +82,7 @@ func (tem *Tem104K) Init(counterNumber byte, network *net.Network, logger *log.L
 }
 
 // Реализация интерфейса IDeviceDriver::Read
-func (tem *Tem104K) Read() (*models.DataDevice, error) {
+func (tem *Tem104K) Read(ctx context.Context) (*models.DataDevice, error) {
 
 	tem.data.TimeRequest = time.Now()
 
@@ -84,7 +94,7 @@ func (tem *Tem104K) Read() (*models.DataDevice, error) {
 	command = []byte{0x55, tem.counterNumber, drivers.ToNotByte(tem.counterNumber), 0x0F, 0x02, 0x02, 0x00, 0x07}
 	request := net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
 	request.ControlFunction = tem.checkFrame
-	response, err = tem.network.RunIO(request)
+	response, err = tem.network.RunIO(ctx, request)
 	for err != nil {
 		return &tem.data, err
 	}
@@ -103,7 +113,7 @@ func (tem *Tem104K) Read() (*models.DataDevice, error) {
 	request = net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
 	request.ControlFunction = tem.checkFrame
 	request.SecondsReadTimeout = 5
-	response, err = tem.network.RunIO(request)
+	response, err = tem.network.RunIO(ctx, request)
 	for err != nil {
 		return &tem.data, err
 	}
@@ -123,7 +133,7 @@ func (tem *Tem104K) Read() (*models.DataDevice, error) {
 	request = net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
 	request.ControlFunction = tem.checkFrame
 	request.SecondsReadTimeout = 5
-	response, err = tem.network.RunIO(request)
+	response, err = tem.network.RunIO(ctx, request)
 	for err != nil {
 		return &tem.data, err
 	}
@@ -137,7 +147,7 @@ func (tem *Tem104K) Read() (*models.DataDevice, error) {
 	request = net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
 	request.ControlFunction = tem.checkFrame
 	request.SecondsReadTimeout = 5
-	response, err = tem.network.RunIO(request)
+	response, err = tem.network.RunIO(ctx, request)
 	for err != nil {
 		return &tem.data, err
 	}
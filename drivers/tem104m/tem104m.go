@@ -1,6 +1,8 @@
 package tem104m
 
 import (
+	"context"
+	"qBox/drivers"
 	"qBox/models"
 	"qBox/services/convert"
 	"qBox/services/log"
@@ -19,8 +21,17 @@ type TEM104M struct {
 	counterNumber byte
 }
 
+func init() {
+	drivers.Register("tem104m", func() models.IDeviceDriver { return new(TEM104M) }, drivers.Metadata{
+		Title:        "ТЭМ-104М",
+		Protocol:     "ТЭМ",
+		Manufacturer: "АРВАС",
+	})
+}
+
 // Реализация интерфейса IDeviceDriver::Init
-func (tem *TEM104M) Init(counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+func (tem *TEM104M) Init(ctx context.Context, counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+	*tem = TEM104M{} // см. models.IDeviceDriver::Init
 
 	tem.logger = logger
 	tem.network = network
@@ -36,7 +47,7 @@ func (tem *TEM104M) Init(counterNumber byte, network *net.Network, logger *log.L
 	request := net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
 	request.SecondsReadTimeout = 5
 	request.ControlFunction = tem.checkFrame
-	response, err = tem.network.RunIO(request)
+	response, err = tem.network.RunIO(ctx, request)
 	for err != nil {
 		return err
 	}
@@ -58,13 +69,13 @@ func (tem *TEM104M) Init(counterNumber byte, network *net.Network, logger *log.L
 }
 
 // Реализация интерфейса IDeviceDriver::Read
-func (tem *TEM104M) Read() (*models.DataDevice, error) {
+func (tem *TEM104M) Read(ctx context.Context) (*models.DataDevice, error) {
 	var command []byte
 	var response []byte
 	var err error
 
 	tem.data.TimeRequest = time.Now()
-	tem.populateDatetime()
+	tem.populateDatetime(ctx)
 
 	tem.logger.Info("Чтение оперативной памяти")
 
@@ -72,7 +83,7 @@ func (tem *TEM104M) Read() (*models.DataDevice, error) {
 	request := net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
 	request.ControlFunction = tem.checkFrame
 	request.SecondsReadTimeout = 5
-	response, err = tem.network.RunIO(request)
+	response, err = tem.network.RunIO(ctx, request)
 	for err != nil {
 		return &tem.data, err
 	}
@@ -81,7 +92,10 @@ func (tem *TEM104M) Read() (*models.DataDevice, error) {
 	tem.data.Systems[0].GV2 = convert.FloatLittleEndianByPointer(response, 0x06+0x44)
 	tem.data.Systems[0].GM2 = convert.FloatLittleEndianByPointer(response, 0x06+0x54)
 
-	integratorsData := tem.integratorsData()
+	integratorsData, err := tem.integratorsData(ctx)
+	for err != nil {
+		return &tem.data, err
+	}
 	tem.logger.Info("Расшифровка интеграторов %X", integratorsData)
 
 	for i, _ := range tem.data.Systems {
@@ -104,18 +118,36 @@ func (tem *TEM104M) Read() (*models.DataDevice, error) {
 	return &tem.data, nil
 }
 
+// Реализация интерфейса IArchiveDriver::ReadArchive, см. drivers.Tem104MArchive
+func (tem *TEM104M) ReadArchive(ctx context.Context, archive models.ArchiveType, from time.Time, to time.Time) (*models.Archive, error) {
+	reader := drivers.Tem104MArchive{
+		Network:       tem.network,
+		Logger:        tem.logger,
+		CounterNumber: tem.counterNumber,
+		Serial:        tem.data.Serial,
+		Systems:       len(tem.data.Systems),
+	}
+	return reader.Read(ctx, archive, from, to)
+}
+
+// Реализация интерфейса IClockDriver::SetTime, см. drivers.Tem104MClock
+func (tem *TEM104M) SetTime(ctx context.Context, moment time.Time) error {
+	clock := drivers.Tem104MClock{Network: tem.network, Logger: tem.logger, CounterNumber: tem.counterNumber}
+	return clock.Write(ctx, moment)
+}
+
 func (tem *TEM104M) prepareCommand(commandBytes []byte) []byte {
 	command := append([]byte{0x55, tem.counterNumber, convert.ToNotByte(tem.counterNumber)}, commandBytes...)
 	return append(command, tem.calculateCheckSum(command))
 }
 
-func (tem *TEM104M) populateDatetime() {
+func (tem *TEM104M) populateDatetime(ctx context.Context) {
 	tem.logger.Info("Получение даты времени на теплосчётчике")
 	command := []byte{0x55, tem.counterNumber, convert.ToNotByte(tem.counterNumber), 0x0F, 0x02, 0x02, 0x00, 0x06}
 	request := net.PrepareRequest(append(command, tem.calculateCheckSum(command)))
 	request.ControlFunction = tem.checkFrame
 	request.SecondsReadTimeout = 5
-	response, err := tem.network.RunIO(request)
+	response, err := tem.network.RunIO(ctx, request)
 	for err != nil {
 		tem.logger.Info("Ошибка получения даты времени на теплосчётчике. " + err.Error())
 		return
@@ -130,7 +162,7 @@ func (tem *TEM104M) populateDatetime() {
 	tem.data.Time = time.Date(year, month, day, hour, min, sek, 0, time.Local)
 }
 
-func (tem *TEM104M) integratorsData() []byte {
+func (tem *TEM104M) integratorsData(ctx context.Context) ([]byte, error) {
 	tem.logger.Info("Чтение карты накопленных значений параметров (интеграторы)")
 	step := 0
 	var integratorsData []byte
@@ -142,10 +174,10 @@ func (tem *TEM104M) integratorsData() []byte {
 		request := net.PrepareRequest(tem.prepareCommand(append(append([]byte{0x0F, 0x01, 0x03}, startBytes...), 0x40)))
 		request.ControlFunction = tem.checkFrame
 		request.SecondsReadTimeout = 5
-		response, err := tem.network.RunIO(request)
+		response, err := tem.network.RunIO(ctx, request)
 		for err != nil {
 			tem.logger.Info("Ошибка чтения интеграторов. " + err.Error())
-			return integratorsData
+			return integratorsData, err
 		}
 		integratorsData = append(integratorsData, response[6:len(response)-1]...)
 		if step*0x40 < 351 { // 0x01 0x5F
@@ -154,7 +186,7 @@ func (tem *TEM104M) integratorsData() []byte {
 			break
 		}
 	}
-	return integratorsData
+	return integratorsData, nil
 }
 
 /**
@@ -1,6 +1,7 @@
 package drivers
 
 import (
+	"context"
 	"errors"
 	"github.com/npat-efault/crc16"
 	"qBox/models"
@@ -30,9 +31,18 @@ type TM3 struct {
 	coefficientV float32
 }
 
+func init() {
+	Register("tm3", func() models.IDeviceDriver { return new(TM3) }, Metadata{
+		Title:        "ИСТОК-ТМ3",
+		Protocol:     "Modbus RTU",
+		Manufacturer: "НПЦ \"Спецсистема\"",
+	})
+}
+
 /**
  */
-func (tm3 *TM3) Init(counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+func (tm3 *TM3) Init(ctx context.Context, counterNumber byte, network *net.Network, logger *log.LoggerService) error {
+	*tm3 = TM3{} // см. models.IDeviceDriver::Init
 
 	var response []byte
 	var err error
@@ -56,7 +66,7 @@ func (tm3 *TM3) Init(counterNumber byte, network *net.Network, logger *log.Logge
 		const uint16_t year : 7;
 	};
 	*/
-	response, err = tm3.runIO([]byte{tm3.number, 0x03, 0xEF, 0x04, 0x00, 0x04})
+	response, err = tm3.runIO(ctx, []byte{tm3.number, 0x03, 0xEF, 0x04, 0x00, 0x04})
 	for err != nil {
 		return err
 	}
@@ -87,7 +97,7 @@ func (tm3 *TM3) Init(counterNumber byte, network *net.Network, logger *log.Logge
 	tm3.data.Serial = serial
 
 	tm3.logger.Info("Запрос количества систем")
-	response, err = tm3.runIO([]byte{tm3.number, 0x03, 0x01, 0x43, 0x00, 0x01})
+	response, err = tm3.runIO(ctx, []byte{tm3.number, 0x03, 0x01, 0x43, 0x00, 0x01})
 	for err != nil {
 		return err
 	}
@@ -104,7 +114,7 @@ func (tm3 *TM3) Init(counterNumber byte, network *net.Network, logger *log.Logge
 
 	tm3.data.UnitQ = models.Gcal
 
-	response, err = tm3.runIO([]byte{tm3.number, 0x03, 0xED, 0x01, 0x00, 0x01})
+	response, err = tm3.runIO(ctx, []byte{tm3.number, 0x03, 0xED, 0x01, 0x00, 0x01})
 	for err != nil {
 		return err
 	}
@@ -119,7 +129,7 @@ func (tm3 *TM3) Init(counterNumber byte, network *net.Network, logger *log.Logge
 
 	tm3.logger.Info("Запрос единиц измерения давления")
 
-	response, err = tm3.runIO([]byte{tm3.number, 0x03, 0xED, 0x00, 0x00, 0x01})
+	response, err = tm3.runIO(ctx, []byte{tm3.number, 0x03, 0xED, 0x00, 0x00, 0x01})
 	for err != nil {
 		return err
 	}
@@ -141,7 +151,7 @@ func (tm3 *TM3) Init(counterNumber byte, network *net.Network, logger *log.Logge
 
 	logger.Info("Запрос единиц измерения объёма, массы")
 
-	response, err = tm3.runIO([]byte{tm3.number, 0x03, 0xED, 0x02, 0x00, 0x01})
+	response, err = tm3.runIO(ctx, []byte{tm3.number, 0x03, 0xED, 0x02, 0x00, 0x01})
 	for err != nil {
 		return err
 	}
@@ -162,13 +172,13 @@ func (tm3 *TM3) Init(counterNumber byte, network *net.Network, logger *log.Logge
 
 /**
  */
-func (tm3 *TM3) Read() (*models.DataDevice, error) {
+func (tm3 *TM3) Read(ctx context.Context) (*models.DataDevice, error) {
 
 	var response []byte
 	var err error
 
 	tm3.logger.Info("Запрос времени на приборе")
-	response, err = tm3.runIO([]byte{tm3.number, 0x03, 0xEF, 0x50, 0x00, 0x02})
+	response, err = tm3.runIO(ctx, []byte{tm3.number, 0x03, 0xEF, 0x50, 0x00, 0x02})
 	for err != nil {
 		return &tm3.data, err
 	}
@@ -185,7 +195,7 @@ func (tm3 *TM3) Read() (*models.DataDevice, error) {
 		tm3.data.Systems[i].Status = true
 
 		tm3.logger.Info("Запрос данных для системы %d", i+1)
-		response, err = tm3.runIO([]byte{tm3.number, 0x03, 0x70, byte(i * 4), 0x00, 0x3A})
+		response, err = tm3.runIO(ctx, []byte{tm3.number, 0x03, 0x70, byte(i * 4), 0x00, 0x3A})
 		for err != nil {
 			return &tm3.data, err
 		}
@@ -206,7 +216,7 @@ func (tm3 *TM3) Read() (*models.DataDevice, error) {
 			// не кладёт в этот адрес значение Q2. Значение лежит для первой системы в регистре 0x0480 в типе DOUBLE.
 			// Решено, что если такая система установлена, то надо обращать внимание только на Q результирующее
 			tm3.logger.Info("Запрос Q2 для замкнутой системы 1")
-			responseQ2, err := tm3.runIO([]byte{tm3.number, 0x03, 0x04, 0x80, 0x00, 0x04})
+			responseQ2, err := tm3.runIO(ctx, []byte{tm3.number, 0x03, 0x04, 0x80, 0x00, 0x04})
 			for err != nil {
 				return &tm3.data, err
 			}
@@ -221,14 +231,13 @@ func (tm3 *TM3) Read() (*models.DataDevice, error) {
 
 		tm3.data.Systems[i].Q3 = float64(float32(toDouble(response[72:80]) / 1000000))
 
-		/**
-		Трубопровод подпитки. В ядре он не учтён.
-		*/
-		//M3 = float32(toDouble(response[80:88]) / 1000000)
-		//GM3 = toFloat([4]byte{response[91], response[58], response[57], response[88]})
-		//GV3 = toFloat([4]byte{response[95], response[62], response[61], response[92]})
-		//температура подпитки= toFloat([4]byte{response[99], response[66], response[65], response[96]})
-		//давление подпитки = toFloat([4]byte{response[103], response[70], response[69], response[100]})
+		// Трубопровод подпитки. Масштаб массы и расхода взят из исходных заметок драйвера: масса M3 делится на 10^6,
+		// как Q3, расход GM3 передаётся без множителя
+		tm3.data.Systems[i].M3 = float64(float32(toDouble(response[80:88]) / 1000000))
+		tm3.data.Systems[i].GM3 = calculateFloatByPointer(response, 88)
+		tm3.data.Systems[i].GV3 = calculateFloatByPointer(response, 92) * tm3.coefficientV
+		tm3.data.Systems[i].TMakeup = calculateFloatByPointer(response, 96)
+		tm3.data.Systems[i].PMakeup = calculateFloatByPointer(response, 100) * tm3.coefficientP
 
 		tm3.data.Systems[i].T3 = calculateFloatByPointer(response, 104)
 		tm3.data.Systems[i].P3 = calculateFloatByPointer(response, 108) * tm3.coefficientP
@@ -238,7 +247,7 @@ func (tm3 *TM3) Read() (*models.DataDevice, error) {
 	}
 
 	tm3.logger.Info("Запрос общего времени работы прибора")
-	response, err = tm3.runIO([]byte{tm3.number, 0x03, 0xEF, 0x57, 0x00, 0x02})
+	response, err = tm3.runIO(ctx, []byte{tm3.number, 0x03, 0xEF, 0x57, 0x00, 0x02})
 	for err != nil {
 		return &tm3.data, err
 	}
@@ -247,14 +256,33 @@ func (tm3 *TM3) Read() (*models.DataDevice, error) {
 
 }
 
-func (tm3 *TM3) checkResponse(response []byte) bool {
+/**
+Реализация интерфейса IClockDriver::SetTime. Время прибора - unixtime в регистрах 0xEF50-0xEF51, как при чтении в Read
+*/
+func (tm3 *TM3) SetTime(ctx context.Context, moment time.Time) error {
+	tm3.logger.Info("Установка времени на приборе %s", moment.Format("02.01.2006 15:04:05"))
+	value := uint32(moment.Unix())
+	_, err := tm3.runIO(ctx, []byte{tm3.number, 0x10, 0xEF, 0x50, 0x00, 0x02, 0x04,
+		byte(value >> 24), byte(value >> 16), byte(value >> 8), byte(value)})
+	return err
+}
+
+/**
+function - функциональный код запроса: 0x03 - чтение регистров, 0x10 - запись
+*/
+func (tm3 *TM3) checkResponse(function byte, response []byte) bool {
 
 	if len(response) < 3 {
 		tm3.logger.Info("Получен некорректный ответ. Ответ содержит меньше 3 байт.")
 		return false
 	}
 
-	if response[0] != tm3.number || response[1] != 0x03 {
+	if response[0] == tm3.number && response[1] == function|0x80 {
+		tm3.logger.Info("Прибор вернул ошибку modbus, код исключения %d", response[2])
+		return false
+	}
+
+	if response[0] != tm3.number || response[1] != function {
 		tm3.logger.Info("modbus адрес прибора и функциональный код не совпадают в ответе")
 		return false
 	}
@@ -271,13 +299,13 @@ func (tm3 *TM3) checkResponse(response []byte) bool {
 	return true
 }
 
-func (tm3 *TM3) runIO(request []byte) ([]byte, error) {
+func (tm3 *TM3) runIO(ctx context.Context, request []byte) ([]byte, error) {
 	checkSum := intToLittleEndian(crc16.Checksum(crc16.Modbus, request))
 	request = append(request, checkSum...)
 	requestComponent := net.PrepareRequest(request)
-	requestComponent.ControlFunction = tm3.checkResponse
+	requestComponent.ControlFunction = func(response []byte) bool { return tm3.checkResponse(request[1], response) }
 	requestComponent.SecondsReadTimeout = 7
-	response, err := tm3.network.RunIO(requestComponent)
+	response, err := tm3.network.RunIO(ctx, requestComponent)
 	for err != nil {
 		return nil, err
 	}
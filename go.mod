@@ -5,6 +5,7 @@ go 1.17
 require (
 	github.com/go-ozzo/ozzo-log v0.0.0-20160703175702-610cdd147d9a
 	github.com/npat-efault/crc16 v0.0.0-20161013170008-4128ccbe47c3
+	golang.org/x/sys v0.13.0
 )
 
 require (
@@ -1,9 +1,28 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"os/signal"
+	"qBox/drivers/mbus"
+	"qBox/drivers/mbusgeneric"
+	batchPackage "qBox/services/batch"
+	exporterPackage "qBox/services/exporter"
+	influxPackage "qBox/services/influx"
 	logPackage "qBox/services/log"
+	mqttPackage "qBox/services/mqtt"
+	pollPackage "qBox/services/poll"
+	rawPackage "qBox/services/raw"
+	"strings"
 	"syscall"
+	"text/tabwriter"
+	"time"
 )
 import netService "qBox/services/net"
 import configPackage "qBox/services/config"
@@ -38,7 +57,13 @@ go env -w GOARCH=386&&go env -w GOOS=linux&&go build
 go env -w GOARCH=amd64&&go env -w GOOS=linux&&go build
 */
 func main() {
+	os.Exit(run())
+}
+
+// Работа утилиты. Возвращает код завершения процесса, см. poll.ExitCode
+func run() int {
 	var err error
+	var archiveFrom, archiveTo time.Time
 
 	// ИНИЦИАЛИЗАЦИЯ КОМПОНЕНТОВ
 	configService := configPackage.InitConfig()
@@ -48,23 +73,118 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	if configService.IsStructuredFormat() {
+		// Сообщения об ошибках не должны смешиваться с документом результата на стандартном выводе
+		logger.SetConsole(os.Stderr)
+	}
+
+	if modelsFile := configService.GetMBusModelsFile(); modelsFile != "" {
+		err = mbusgeneric.LoadModels(modelsFile)
+		if err != nil {
+			logger.Check("app")
+			logger.Fatal(err.Error())
+			logger.Close()
+			return 1
+		}
+	}
 
-	driver, err := configService.GetDriver()
+	influxWriter, err := configService.GetInfluxWriter()
+	if err != nil {
+		logger.Check("app")
+		logger.Fatal(err.Error())
+		logger.Close()
+		return 1
+	}
+	output := io.Writer(os.Stdout)
+	if influxWriter != nil {
+		output = influxWriter
+	}
+	publisher, err := configService.GetMqttPublisher()
+	if err != nil {
+		logger.Check("app")
+		logger.Fatal(err.Error())
+		logger.Close()
+		return 1
+	}
+
+	if configService.GetBatchFile() != "" && (configService.GetCaptureFile() != "" || configService.GetReplayFile() != "") {
+		logger.Check("app")
+		logger.Fatal("флаги capture и replay в пакетном режиме не используются")
+		logger.Close()
+		return 1
+	}
+
+	var replay []netService.CaptureEvent
+	if replayFile := configService.GetReplayFile(); replayFile != "" {
+		var header netService.CaptureHeader
+		header, replay, err = netService.LoadCapture(replayFile)
+		if err != nil {
+			logger.Check("app")
+			logger.Fatal(err.Error())
+			logger.Close()
+			return 1
+		}
+		logger.Check("app")
+		logger.Info("Воспроизведение записи обмена %s от %s, подключение %s", replayFile,
+			header.Start.Format("2006-01-02 15:04:05"), header.Endpoint)
+		configService.ApplyReplayDefaults(header.Driver, header.Number, header.Address)
+	}
+
+	if configService.GetCommand() == configPackage.CommandScan {
+		code := runScan(configService, replay, logger)
+		logger.Close()
+		return code
+	}
+
+	if configService.GetCommand() == configPackage.CommandRaw {
+		code := runRaw(configService, replay, logger)
+		logger.Close()
+		return code
+	}
+
+	if configService.GetCommand() == configPackage.CommandExporter {
+		code := runExporter(configService, logger)
+		logger.Close()
+		return code
+	}
+
+	if configService.GetBatchFile() != "" {
+		code := flushOutput(influxWriter, runBatch(configService, output, publisher, logger), &logger)
+		logger.Close()
+		return code
+	}
+
+	driverName, err := configService.GetDriverName()
 	if err != nil {
 		logger.Check("driver")
 		logger.Fatal(err.Error())
 		logger.Close()
-		return
+		return 1
+	}
+
+	archive, err := configService.GetArchive()
+	if err == nil && archive != "" {
+		archiveFrom, archiveTo, err = configService.GetArchivePeriod()
+	}
+	if err != nil {
+		logger.Check("app")
+		logger.Fatal(err.Error())
+		logger.Close()
+		return 1
 	}
 
-	host, port, err := netService.SplitHostPort(configService.GetHostPort())
+	transport, closeCapture, err := openTransport(configService, replay, &logger)
 	if err != nil {
 		logger.Fatal(err.Error())
 		logger.Close()
-		return
+		return exitCode(err)
 	}
 
-	network := *netService.NewNetwork(host, port, logger)
+	network := *netService.NewNetwork(transport, logger)
+
+	// Контекст сеанса опроса. Отменяется по сигналу ОС либо по истечении -timeout
+	ctx, cancel := signalContext(configService.GetTimeout(), &logger)
+	defer cancel()
 
 	// ОБРАБОТКА ЗАВЕРШЕНИЯ ПРОГРАММЫ
 	defer func() {
@@ -75,29 +195,42 @@ func main() {
 				return
 			}
 		}
+		closeCapture()
 		logger.Close()
 	}()
 
-	signalChanel := make(chan os.Signal, 1)
-	signal.Notify(signalChanel, syscall.SIGINT, syscall.SIGTERM)
-	go terminate(signalChanel, &network, &logger)
-
 	// РАБОТА С ДРАЙВЕРОМ
-	logger.Check("driver")
-	logger.Info("Инициализация драйвера")
-	err = driver.Init(configService.GetCounterNumber(), &network, &logger) // TODO: Добавить таймаут, через conn::SetDeadline
-	if err != nil {
-		logger.Fatal(err.Error())
-		return
+	session := pollPackage.Session{
+		Driver:        driverName,
+		CounterNumber: configService.GetCounterNumber(),
+		Address:       configService.GetAddress(),
+		Network:       &network,
+		Logger:        logger,
+		ProbeTimeout:  configService.GetScanTimeout(),
 	}
-
-	logger.Info("Чтение текущих данных") // TODO: Добавить таймаут
-	deviceData, err := driver.Read()
+	if configService.IsSyncTime() {
+		return flushOutput(influxWriter, syncTime(ctx, session, configService, output, logger), &logger)
+	}
+	if archive != "" {
+		return flushOutput(influxWriter, readArchive(ctx, session, archive, archiveFrom, archiveTo, configService, output, logger), &logger)
+	}
+	// Подключение к брокеру до опроса: при ошибке опроса брокер опубликует Last-Will
+	if err = connectPublisher(ctx, publisher, &logger); err != nil {
+		return exitCode(err)
+	}
+	timeRequest := time.Now()
+	deviceData, err := session.Poll(ctx)
+	if deviceData == nil {
+		deviceData = &models.DataDevice{TimeRequest: timeRequest}
+	}
+	deviceData.Status = pollPackage.NewStatus(deviceData, err)
 	if err != nil {
+		// Состояние опроса выводится и при ошибке, прочитанная часть данных - без приведения единиц
+		logger.Check("driver")
 		logger.Fatal(err.Error())
-		formatter := configService.GetFormatter()
-		formatter.Render(os.Stdout, deviceData)
-		return
+		configService.GetFormatter().Render(output, deviceData)
+		code := flushOutput(influxWriter, pollPackage.ExitCode(deviceData.Status.Class), &logger)
+		return closePublisher(publisher, code, nil, &logger)
 	}
 
 	// TODO: Можно закрыть соединение.
@@ -125,23 +258,494 @@ func main() {
 	formatter := configService.GetFormatter()
 
 	logger.Info("Вывод данных")
-	formatter.Render(os.Stdout, deviceData)
+	formatter.Render(output, deviceData)
+	publishErr := publishData(ctx, publisher, deviceData, configService.GetJson2Format(), &logger)
+	return closePublisher(publisher, flushOutput(influxWriter, 0, &logger), publishErr, &logger)
 }
 
-// Функция будет вызываться, когда срабатывают ОС сигналы SIGINT или SIGTERM
-// См. https://en.wikipedia.org/wiki/Signal_(IPC)
-func terminate(signalChanel chan os.Signal, network *netService.Network, logger *logPackage.LoggerService) {
-	for {
-		sig := <-signalChanel
-		logger.Check("app")
-		logger.Notice("OS сигнал: " + sig.String())
-		logger.Close()
+// Подключение к брокеру MQTT при флаге mqtt-url
+func connectPublisher(ctx context.Context, publisher *mqttPackage.Publisher, logger *logPackage.LoggerService) error {
+	if publisher == nil {
+		return nil
+	}
+	logger.Check("mqtt")
+	err := publisher.Connect(ctx)
+	if err != nil {
+		logger.Fatal(err.Error())
+		return err
+	}
+	logger.Info("Подключение к брокеру MQTT %s", publisher.Options.URL)
+	return nil
+}
+
+// Публикация успешно прочитанных данных прибора в MQTT при флаге mqtt-url
+func publishData(ctx context.Context, publisher *mqttPackage.Publisher, data *models.DataDevice, source models.Json2Format,
+	logger *logPackage.LoggerService) error {
+	if publisher == nil {
+		return nil
+	}
+	count, err := publisher.Publish(ctx, data, source)
+	logger.Check("mqtt")
+	if err != nil {
+		logger.Error("Публикация данных прибора %s: %s", data.Serial, err.Error())
+		return err
+	}
+	logger.Info("Опубликовано сообщений MQTT: %d", count)
+	return nil
+}
+
+/**
+Отключение от брокера MQTT. Если code не 0, соединение разрывается и брокер публикует Last-Will.
+Возвращает code, а если он 0 и публикация не удалась - код завершения ошибки публикации.
+*/
+func closePublisher(publisher *mqttPackage.Publisher, code int, publishErr error, logger *logPackage.LoggerService) int {
+	if publisher == nil {
+		return code
+	}
+	err := publisher.Close(code != 0)
+	logger.Check("mqtt")
+	if code != 0 && publisher.Options.Will != nil {
+		logger.Notice("Опрос не удался, брокер MQTT опубликует Last-Will в %s", publisher.Options.Will.Topic)
+	}
+	if err != nil {
+		logger.Error(err.Error())
+	}
+	if code == 0 && publishErr != nil {
+		return exitCode(publishErr)
+	}
+	return code
+}
+
+/**
+Отправка результатов формата influx при флаге influx-url. Возвращает code, а если он 0 и запись не удалась -
+код завершения ошибки записи.
+*/
+func flushOutput(writer *influxPackage.Writer, code int, logger *logPackage.LoggerService) int {
+	if writer == nil {
+		return code
+	}
+	lines := writer.Lines()
+	err := writer.Flush(context.Background())
+	logger.Check("app")
+	if err != nil {
+		logger.Fatal(err.Error())
+		if code == 0 {
+			return exitCode(err)
+		}
+		return code
+	}
+	if lines > 0 {
+		logger.Info("Записано строк в InfluxDB: %d", lines)
+	}
+	return code
+}
+
+// Код завершения по ошибке, см. poll.Classify
+func exitCode(err error) int {
+	return pollPackage.ExitCode(pollPackage.Classify(err))
+}
+
+// Чтение архива теплосчётчика за период
+func readArchive(ctx context.Context, session pollPackage.Session, archiveType models.ArchiveType, from time.Time, to time.Time,
+	configService configPackage.Config, output io.Writer, logger logPackage.LoggerService) int {
+	archive, err := session.ReadArchive(ctx, archiveType, from, to)
+	formatter := configService.GetFormatter()
+	if err != nil {
+		logger.Check("driver")
+		logger.Fatal(err.Error())
+		if archive == nil {
+			return exitCode(err)
+		}
+		// Прочитанные до ошибки записи всё равно выводятся
+		if json2, ok := formatter.(*models.Json2Format); ok {
+			json2.Err = err
+		}
+	}
+
+	logger.Check("app")
+	logger.Info("Прочитано записей архива: %d", len(archive.Records))
+	unitQ, unitErr := configService.GetUnitQ()
+	if unitErr != nil {
+		logger.Notice(unitErr.Error())
+	}
+	archive.ChangeUnitQ(unitQ)
+	formatter.RenderArchive(output, archive)
+	return exitCode(err)
+}
+
+// Синхронизация часов прибора с часами компьютера
+func syncTime(ctx context.Context, session pollPackage.Session, configService configPackage.Config, output io.Writer,
+	logger logPackage.LoggerService) int {
+	clock, err := session.SyncTime(ctx, configService.GetSyncMax(), configService.IsDryRun())
+	formatter := configService.GetFormatter()
+	if err != nil {
+		logger.Check("driver")
+		logger.Fatal(err.Error())
+		if clock == nil {
+			return exitCode(err)
+		}
+		// Время до коррекции выводится и при ошибке
+		if json2, ok := formatter.(*models.Json2Format); ok {
+			json2.Err = err
+		}
+	}
+	formatter.RenderClock(output, clock)
+	return exitCode(err)
+}
+
+// Пакетный опрос теплосчётчиков по файлу заданий. Код завершения - по ошибке задания с наименьшим номером
+func runBatch(configService configPackage.Config, output io.Writer, publisher *mqttPackage.Publisher,
+	logger logPackage.LoggerService) int {
+	logger.Check("batch")
+	jobs, err := batchPackage.LoadJobs(configService.GetBatchFile(), batchPackage.Job{
+		Driver: batchPackage.DriverRef(configService.GetDeviceType()),
+		Number: uint(configService.GetCounterNumber()),
+		Unit:   configService.GetUnitQInt(),
+	})
+	if err != nil {
+		logger.Fatal(err.Error())
+		return 1
+	}
+	logger.Info("Заданий на опрос: %d", len(jobs))
+
+	ctx, cancel := signalContext(0, &logger)
+	defer cancel()
+
+	if err = connectPublisher(ctx, publisher, &logger); err != nil {
+		return exitCode(err)
+	}
+
+	formatter := configService.GetFormatter()
+	if csvFormat, ok := formatter.(*models.CsvFormat); ok {
+		csvFormat.RenderHeader(output)
+		formatter = csvFormat.WithoutHeader()
+	}
+	runner := batchPackage.Runner{
+		Workers: configService.GetWorkers(),
+		Timeout: configService.GetTimeout(),
+		Logger:  logger,
+	}
+	code, failedJob := 0, 0
+	var publishErr error
+	runner.Run(ctx, jobs, func(result batchPackage.Result) {
+		result.Render(output, formatter)
+		if result.Err != nil && (code == 0 || result.Job.Index < failedJob) {
+			code, failedJob = exitCode(result.Err), result.Job.Index
+		}
+		if result.Err == nil {
+			if err := publishData(ctx, publisher, result.Data, result.Json2Format(), &logger); err != nil && publishErr == nil {
+				publishErr = err
+			}
+		}
+	})
+	return closePublisher(publisher, code, publishErr, &logger)
+}
+
+/**
+Экспортёр метрик Prometheus: опрос теплосчётчиков из файла заданий по расписанию и HTTP сервер метрик.
+Работает до сигнала SIGINT или SIGTERM.
+*/
+func runExporter(configService configPackage.Config, logger logPackage.LoggerService) int {
+	logger.Check("exporter")
+	if configService.GetBatchFile() == "" {
+		logger.Fatal("не задан файл заданий экспортёра")
+		return 1
+	}
+	jobs, err := batchPackage.LoadJobs(configService.GetBatchFile(), batchPackage.Job{
+		Driver: batchPackage.DriverRef(configService.GetDeviceType()),
+		Number: uint(configService.GetCounterNumber()),
+		Unit:   configService.GetUnitQInt(),
+	})
+	if err != nil {
+		logger.Fatal(err.Error())
+		return 1
+	}
+
+	ctx, cancel := signalContext(0, &logger)
+	defer cancel()
+
+	exporter := &exporterPackage.Exporter{
+		Jobs: jobs,
+		Runner: batchPackage.Runner{
+			Workers: configService.GetWorkers(),
+			Timeout: configService.GetTimeout(),
+			Logger:  logger,
+		},
+		Interval: configService.GetInterval(),
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", exporter)
+	server := &http.Server{Addr: configService.GetListen(), Handler: mux}
+
+	listener, err := net.Listen("tcp", configService.GetListen())
+	if err != nil {
+		logger.Fatal(err.Error())
+		return 1
+	}
+	logger.Info("Метрики доступны по адресу http://%s/metrics, заданий на опрос: %d, период опроса %s",
+		listener.Addr().String(), len(jobs), configService.GetInterval().String())
+
+	polling := make(chan struct{})
+	go func() {
+		exporter.Run(ctx)
+		close(polling)
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelShutdown()
+		_ = server.Shutdown(shutdown)
+	}()
+
+	err = server.Serve(listener)
+	cancel()
+	<-polling
+	if err != nil && err != http.ErrServerClosed {
+		logger.Check("exporter")
+		logger.Fatal(err.Error())
+		return 1
+	}
+	return 0
+}
+
+// Поиск приборов M-Bus на шине одного шлюза или последовательного порта
+func runScan(configService configPackage.Config, replay []netService.CaptureEvent, logger logPackage.LoggerService) int {
+	logger.Check("scan")
+	mode, err := configService.GetScanMode()
+	if err != nil {
+		logger.Fatal(err.Error())
+		return 1
+	}
+	transport, closeCapture, err := openTransport(configService, replay, &logger)
+	if err != nil {
+		logger.Fatal(err.Error())
+		return exitCode(err)
+	}
+	network := netService.NewNetwork(transport, logger)
+	defer func() {
 		if network.IsConnected() {
-			err := network.Close()
-			if err != nil {
+			_ = network.Close()
+		}
+		closeCapture()
+	}()
+
+	ctx, cancel := signalContext(configService.GetTimeout(), &logger)
+	defer cancel()
+
+	scanner := mbus.Scanner{Network: network, Logger: &logger, ReplyTimeout: configService.GetScanTimeout()}
+	var meters []mbus.Meter
+	var scanErr error
+	if mode == "all" || mode == "primary" {
+		logger.Info("Поиск по первичным адресам")
+		found, err := scanner.ScanPrimary(ctx, 0, 250)
+		meters = append(meters, found...)
+		if err != nil {
+			logger.Fatal(err.Error())
+			scanErr = err
+		}
+	}
+	if (mode == "all" || mode == "secondary") && ctx.Err() == nil {
+		logger.Info("Поиск по вторичным адресам")
+		found, err := scanner.ScanSecondary(ctx)
+		meters = append(meters, found...)
+		if err != nil {
+			logger.Fatal(err.Error())
+			scanErr = err
+		}
+	}
 
+	switch configService.GetFormatter().(type) {
+	case *models.JsonFormat, *models.Json2Format:
+		type meterJson struct {
+			Primary   *int   `json:"primary,omitempty"`
+			Address   string `json:"address"`
+			Collision bool   `json:"collision,omitempty"`
+		}
+		list := make([]meterJson, 0, len(meters))
+		for i := range meters {
+			item := meterJson{Address: meters[i].Address.String(), Collision: meters[i].Collision}
+			if meters[i].Primary >= 0 {
+				item.Primary = &meters[i].Primary
 			}
+			list = append(list, item)
 		}
-		os.Exit(0)
+		bytesResponse, _ := json.Marshal(list)
+		fmt.Println(string(bytesResponse))
+		return exitCode(scanErr)
 	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "Первичный адрес\tВторичный адрес\tПримечание")
+	for _, meter := range meters {
+		primary, note := "-", ""
+		if meter.Primary >= 0 {
+			primary = fmt.Sprint(meter.Primary)
+		}
+		if meter.Collision {
+			note = "ответили несколько приборов"
+		}
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\n", primary, meter.Address.String(), note)
+	}
+	_ = writer.Flush()
+	fmt.Printf("Найдено приборов: %d\n", len(meters))
+	return exitCode(scanErr)
+}
+
+/**
+Отправка произвольных запросов прибору: запросы из командной строки либо построчно со стандартного ввода.
+Пустые строки и строки, начинающиеся с #, пропускаются. Ошибка в запросе со стандартного ввода выводится,
+и чтение запросов продолжается. Код завершения - по первому запросу с ошибкой.
+*/
+func runRaw(configService configPackage.Config, replay []netService.CaptureEvent, logger logPackage.LoggerService) int {
+	logger.Check("raw")
+	checksum, err := configService.GetChecksum()
+	if err != nil {
+		logger.Fatal(err.Error())
+		return 1
+	}
+	var frames [][]byte
+	for _, text := range configService.GetFrames() {
+		frame, err := rawPackage.ParseFrame(text)
+		if err != nil {
+			logger.Fatal(err.Error())
+			return 1
+		}
+		frames = append(frames, frame)
+	}
+
+	transport, closeCapture, err := openTransport(configService, replay, &logger)
+	if err != nil {
+		logger.Fatal(err.Error())
+		return exitCode(err)
+	}
+	network := netService.NewNetwork(transport, logger)
+	defer func() {
+		if network.IsConnected() {
+			_ = network.Close()
+		}
+		closeCapture()
+	}()
+
+	ctx, cancel := signalContext(configService.GetTimeout(), &logger)
+	defer cancel()
+
+	console := rawPackage.Console{Network: network, Checksum: checksum, Timeout: configService.GetRawTimeout()}
+	formatter := configService.GetFormatter()
+	code := 0
+	send := func(frame []byte) bool {
+		exchange := console.Send(ctx, frame)
+		exchange.Render(os.Stdout, formatter)
+		if exchange.Err != nil {
+			logger.Error(exchange.Err.Error())
+			if code == 0 {
+				code = exitCode(exchange.Err)
+			}
+		}
+		return ctx.Err() == nil
+	}
+
+	if len(frames) > 0 {
+		for _, frame := range frames {
+			if !send(frame) {
+				return code
+			}
+		}
+		return code
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		frame, err := rawPackage.ParseFrame(text)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stdout, "! %s\n", err.Error())
+			continue
+		}
+		if !send(frame) {
+			return code
+		}
+	}
+	return code
+}
+
+/**
+Транспорт сеанса: подключение по строке подключения, при флаге capture - с записью обмена в файл,
+при флаге replay - воспроизведение записи обмена replay. Возвращаемая функция закрывает файл записи обмена
+и вызывается после закрытия соединения.
+*/
+func openTransport(configService configPackage.Config, replay []netService.CaptureEvent,
+	logger *logPackage.LoggerService) (netService.Transport, func(), error) {
+	captureFile := configService.GetCaptureFile()
+	if configService.GetReplayFile() != "" {
+		if captureFile != "" {
+			return nil, nil, errors.New("флаги capture и replay не используются вместе")
+		}
+		return netService.NewReplayTransport(replay, logger), func() {}, nil
+	}
+
+	transport, err := netService.NewTransport(configService.GetEndpoint())
+	if err != nil || captureFile == "" {
+		return transport, func() {}, err
+	}
+	file, err := os.Create(captureFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	capture, err := netService.NewCaptureTransport(transport, file, netService.CaptureHeader{
+		Version:  configPackage.VersionCoreApp,
+		Endpoint: configService.GetEndpoint(),
+		Driver:   configService.GetDeviceType(),
+		Number:   configService.GetCounterNumber(),
+		Address:  configService.GetAddress(),
+		Args:     os.Args[1:],
+	})
+	if err != nil {
+		_ = file.Close()
+		return nil, nil, err
+	}
+	return capture, func() {
+		if err := capture.Err(); err != nil {
+			logger.Check("app")
+			logger.Error(err.Error())
+		}
+		_ = file.Close()
+	}, nil
+}
+
+// Контекст команды: отменяется первым сигналом SIGINT или SIGTERM либо по истечении timeout (0 - без ограничения).
+// Повторный сигнал завершает программу, см. terminate
+func signalContext(timeout time.Duration, logger *logPackage.LoggerService) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signalChanel := make(chan os.Signal, 1)
+	signal.Notify(signalChanel, syscall.SIGINT, syscall.SIGTERM)
+	go terminate(signalChanel, cancel, logger)
+
+	if timeout <= 0 {
+		return ctx, cancel
+	}
+	ctx, cancelTimeout := context.WithTimeout(ctx, timeout)
+	return ctx, func() {
+		cancelTimeout()
+		cancel()
+	}
+}
+
+// Функция будет вызываться, когда срабатывают ОС сигналы SIGINT или SIGTERM
+// См. https://en.wikipedia.org/wiki/Signal_(IPC)
+// Первый сигнал отменяет опрос: текущая операция чтения/записи завершается, драйвер возвращает ошибку,
+// соединение закрывается в main. Повторный сигнал завершает программу немедленно.
+func terminate(signalChanel chan os.Signal, cancel context.CancelFunc, logger *logPackage.LoggerService) {
+	sig := <-signalChanel
+	logger.Check("app")
+	logger.Notice("OS сигнал: " + sig.String() + ". Опрос отменяется.")
+	cancel()
+
+	sig = <-signalChanel
+	logger.Notice("OS сигнал: " + sig.String() + ". Принудительное завершение.")
+	logger.Close()
+	os.Exit(pollPackage.ExitCode(models.ErrorInterrupted))
 }
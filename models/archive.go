@@ -0,0 +1,156 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Тип архива теплосчётчика: период, за который прибор сохраняет запись
+type ArchiveType string
+
+const (
+	ArchiveHourly  ArchiveType = "hourly"  // Часовой архив
+	ArchiveDaily   ArchiveType = "daily"   // Суточный архив
+	ArchiveMonthly ArchiveType = "monthly" // Месячный архив
+)
+
+// Разбор значения флага archive
+func ParseArchiveType(value string) (ArchiveType, error) {
+	switch archive := ArchiveType(value); archive {
+	case ArchiveHourly, ArchiveDaily, ArchiveMonthly:
+		return archive, nil
+	}
+	return "", fmt.Errorf("неверный тип архива %q, возможно: hourly, daily, monthly", value)
+}
+
+// Начало периода архива, в который попадает moment
+func (archive ArchiveType) Truncate(moment time.Time) time.Time {
+	year, month, day := moment.Date()
+	switch archive {
+	case ArchiveHourly:
+		return time.Date(year, month, day, moment.Hour(), 0, 0, 0, moment.Location())
+	case ArchiveMonthly:
+		return time.Date(year, month, 1, 0, 0, 0, 0, moment.Location())
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, moment.Location())
+}
+
+// Начало периода архива, следующего за периодом, в который попадает moment
+func (archive ArchiveType) Next(moment time.Time) time.Time {
+	period := archive.Truncate(moment)
+	switch archive {
+	case ArchiveHourly:
+		return period.Add(time.Hour)
+	case ArchiveMonthly:
+		return period.AddDate(0, 1, 0)
+	}
+	return period.AddDate(0, 0, 1)
+}
+
+// Первый период архива, который начинается не раньше from
+func (archive ArchiveType) First(from time.Time) time.Time {
+	period := archive.Truncate(from)
+	if period.Before(from) {
+		return archive.Next(period)
+	}
+	return period
+}
+
+/**
+Архив теплосчётчика: записи одного типа за запрошенный период в порядке возрастания времени.
+*/
+type Archive struct {
+	Serial         string          // Серийный заводской номер теплосчётчика
+	Type           ArchiveType     // Тип архива
+	UnitQ          UnitQEnum       // Единицы измерения тепловой энергии
+	TimeRequest    time.Time       // Время запроса
+	Records        []ArchiveRecord // Записи архива
+	CoefficientGJ  float64         // переводной коэффициент ГДж в ГКал, см. DataDevice
+	CoefficientMWh float64         // переводной коэффициент МВт в ГКал, см. DataDevice
+	CoefficientKWh float64         // переводной коэффициент КВт в ГКал, см. DataDevice
+}
+
+/**
+Запись архива за один период (час, сутки, месяц).
+*/
+type ArchiveRecord struct {
+	Start   time.Time       // Начало периода по часам прибора
+	End     time.Time       // Окончание периода, не входит в период
+	TimeOn  uint32          // Время работы при включенном питании на конец периода, в секундах
+	TimeOff uint32          // Время отсутствия питания на конец периода, в секундах
+	Systems []ArchiveSystem // Системы теплосчётчика, нумерация с 0
+}
+
+/**
+Данные одной системы теплосчётчика в записи архива.
+Интеграторы и времена - накопленные значения на конец периода, как в DataDevice,
+потребление за период - разность с предыдущей записью. Температуры и давления - средние за период.
+Времена в ошибках заполняются, если прибор их ведёт, иначе 0.
+*/
+type ArchiveSystem struct {
+	SigmaQ float64 // Результирующее значение тепловой энергии, см. SystemDevice
+	Q1     float64
+	Q2     float64
+	Q3     float64
+	// Тепловая энергия по тарифам на конец периода, Tariffs[0] - тариф 1. Заполняется, если прибор ведёт тарифы
+	Tariffs []float64
+	V1      float64 // Объём, м3
+	V2      float64
+	M1      float64 // Масса, т
+	M2      float64
+
+	T1 float32 // Средняя температура подачи, в градусах Цельсия
+	T2 float32 // Средняя температура обратки, в градусах Цельсия
+	P1 float32 // Среднее давление подачи, в МПа
+	P2 float32 // Среднее давление обратки, в МПа
+
+	M3      float64 // Масса подпитки, т, см. SystemDevice
+	TMakeup float32 // Средняя температура подпитки, в градусах Цельсия
+	PMakeup float32 // Среднее давление подпитки, в МПа
+
+	TimeRunSys uint32 // Время работы без ошибок, в секундах
+	TimeError  uint32 // Время работы с любыми ошибками, в секундах
+	TimeGMin   uint32 // Время, когда расход был меньше минимального, в секундах
+	TimeGMax   uint32 // Время, когда расход был больше максимального, в секундах
+	TimeDT     uint32 // Время, когда разность температур была меньше минимальной, в секундах
+	TimeFault  uint32 // Время технической неисправности, в секундах
+
+	Status bool // Статус системы, активна или нет. Неактивные системы не выводятся
+}
+
+// Добавляет в запись архива системы до номера indexArray включительно, см. DataDevice::AddNewSystem
+func (record *ArchiveRecord) AddNewSystem(indexArray int) {
+	if len(record.Systems) <= indexArray {
+		record.Systems = append(record.Systems, make([]ArchiveSystem, indexArray+1-len(record.Systems))...)
+	}
+}
+
+// Изменение единиц измерения энергии во всех записях, коэффициенты как у DataDevice::ChangeUnitQ
+func (archive *Archive) ChangeUnitQ(u UnitQEnum) {
+	if archive.UnitQ == u {
+		return // нужные единицы измерения уже выставлены
+	}
+	coefficients := DataDevice{
+		CoefficientGJ:  archive.CoefficientGJ,
+		CoefficientMWh: archive.CoefficientMWh,
+		CoefficientKWh: archive.CoefficientKWh,
+	}
+	coefficients.UnitQ = archive.UnitQ
+	k := coefficients.getCoefficient()
+	coefficients.UnitQ = u
+	k /= coefficients.getCoefficient()
+
+	for i := range archive.Records {
+		for j := range archive.Records[i].Systems {
+			system := &archive.Records[i].Systems[j]
+			system.SigmaQ *= k
+			system.Q1 *= k
+			system.Q2 *= k
+			system.Q3 *= k
+			for t := range system.Tariffs {
+				system.Tariffs[t] *= k
+			}
+		}
+	}
+	archive.UnitQ = u
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+/**
+Результат синхронизации часов прибора с часами компьютера.
+Расхождение - время прибора минус время компьютера: положительное - часы прибора спешат.
+*/
+type ClockSync struct {
+	Serial      string        // Серийный заводской номер теплосчётчика
+	TimeRequest time.Time     // Время компьютера, когда было прочитано время прибора
+	Before      time.Time     // Время на приборе до коррекции
+	Drift       time.Duration // Расхождение до коррекции
+	After       time.Time     // Время на приборе после коррекции. Не заполняется, если время не записывалось
+	DriftAfter  time.Duration // Расхождение после коррекции
+	Corrected   bool          // Время на приборе записано
+	DryRun      bool          // Пробный запуск: расхождение вычислено, время не записывалось
+}
@@ -0,0 +1,11 @@
+package models
+
+/**
+Прибор, драйвер которого определён по ответам прибора (-type=auto)
+*/
+type Detection struct {
+	Driver   string // Имя драйвера, которым опрашивается прибор
+	Protocol string // Протокол, по которому ответил прибор
+	Model    string // Модель прибора
+	Firmware string // Версия ПО прибора. Пустая строка - прибор версию не сообщил
+}
@@ -26,6 +26,8 @@ type DataDevice struct {
 	CoefficientGJ  float64        // переводной коэффициент ГДж в ГКал. См. dataDevice::getCoefficientGJ
 	CoefficientMWh float64        // переводной коэффициент МВт в ГКал. См. dataDevice::getCoefficientMWh
 	CoefficientKWh float64        // переводной коэффициент КВт в ГКал. См. dataDevice::getCoefficientKWh
+	Detected       *Detection     // Определённый по ответам прибора драйвер, nil - драйвер задан явно
+	Status         *PollStatus    `json:",omitempty"` // Состояние опроса для вывода, см. poll.NewStatus. nil - не заполнено
 }
 
 /**
@@ -92,6 +94,17 @@ type SystemDevice struct {
 	P2 float32 // Давление 2, в МПа
 	P3 float32 // Давление 3, в МПа
 
+	/*
+		Трубопровод подпитки (третий трубопровод): масса M3 (т), расходы GM3 (т/ч) и GV3 (м3/ч),
+		температура и давление подпитки. T3 и P3 заняты трубопроводом холодной воды, поэтому температура
+		и давление подпитки заведены отдельно. Если в системе нет подпитки, значения равны 0.
+	*/
+	M3      float64
+	GM3     float32
+	GV3     float32
+	TMakeup float32 // Температура подпитки, в градусах Цельсия
+	PMakeup float32 // Давление подпитки, в МПа
+
 	Status bool // Статус системы, активна или нет. Если нет, то не будет отображаться в результах опроса
 }
 
@@ -104,11 +117,28 @@ func (dataDevice *DataDevice) AddNewSystem(indexArray int) {
 	if len(dataDevice.Systems) == 0 {
 		dataDevice.Systems = make([]SystemDevice, indexArray+1, indexArray+1)
 	} else if len(dataDevice.Systems)-1 < indexArray {
-		newSystems := make([]SystemDevice, (indexArray+1)-(len(dataDevice.Systems)-1))
+		newSystems := make([]SystemDevice, indexArray+1-len(dataDevice.Systems))
 		dataDevice.Systems = append(dataDevice.Systems, newSystems...)
 	}
 }
 
+// Копия данных теплосчётчика. Драйвер возвращает данные, которые он изменит при следующем опросе,
+// поэтому сохранять нужно копию.
+func (dataDevice *DataDevice) Clone() *DataDevice {
+	clone := *dataDevice
+	clone.Systems = append([]SystemDevice(nil), dataDevice.Systems...)
+	if dataDevice.Detected != nil {
+		detected := *dataDevice.Detected
+		clone.Detected = &detected
+	}
+	if dataDevice.Status != nil {
+		status := *dataDevice.Status
+		status.Valid = append([]string(nil), dataDevice.Status.Valid...)
+		clone.Status = &status
+	}
+	return &clone
+}
+
 // Изменение единиц измерения энергии
 func (dataDevice *DataDevice) ChangeUnitQ(u UnitQEnum) {
 	if dataDevice.UnitQ == u {
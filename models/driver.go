@@ -1,8 +1,10 @@
 package models
 
 import (
+	"context"
 	logService "qBox/services/log"
 	netService "qBox/services/net"
+	"time"
 )
 
 type IDeviceDriver interface {
@@ -10,16 +12,56 @@ type IDeviceDriver interface {
 		Инициализация драйвера.
 		Ядро программы при инициализации драйвера вызовет этот метод и передаст следующие параметры:
 
+		ctx - ограничивает время опроса и отменяется при завершении программы. Передаётся в netService.Network::RunIO
 		counterNumber - номер теплосчётчика. Может принимать 255 значений, 0x00 - 0xFF, в зависимости от модели счётчика.
 		network - сервис netService.Network
 		logger - сервис logService.LoggerService
 
 		Эти параметры следует сохранить - возможно, они понадобятся для реализации метода Read()
+
+		Init начинает новый сеанс опроса и переводит драйвер в исходное состояние: данные, серийный номер,
+		количество систем и коэффициенты предыдущего сеанса не сохраняются. Реализация начинается с обнуления
+		структуры драйвера, например *tem = Tem104{}.
+		Экземпляр драйвера обслуживает один сеанс опроса одного теплосчётчика. Экземпляры создаются фабрикой,
+		зарегистрированной в drivers.Register, и не должны использоваться из нескольких горутин одновременно.
 	*/
-	Init(counterNumber byte, network *netService.Network, logger *logService.LoggerService) error
+	Init(ctx context.Context, counterNumber byte, network *netService.Network, logger *logService.LoggerService) error
 
 	/**
-	Чтение текущих данных теплосчётчика
+	Чтение текущих данных теплосчётчика.
+	ctx передаётся в netService.Network::RunIO. При его истечении возвращается ошибка и прочитанная часть данных.
+	Возвращаемые данные принадлежат драйверу и изменяются следующим вызовом Init, см. DataDevice::Clone
 	*/
-	Read() (*DataDevice, error)
+	Read(ctx context.Context) (*DataDevice, error)
+}
+
+/**
+Драйвер прибора M-Bus. Такой прибор можно выбрать по вторичному адресу (EN 13757-2): ядро выбирает прибор
+до вызова Init и передаёт counterNumber 0xFD. По адресу 0xFD драйвер не отправляет SND_NKE,
+так как эта команда снимает выбор прибора.
+*/
+type IMBusDriver interface {
+	IDeviceDriver
+	MBus()
+}
+
+/**
+Драйвер, читающий архивы теплосчётчика (часовой, суточный, месячный).
+ReadArchive вызывается после Init вместо Read и возвращает записи архива, период которых начинается
+в интервале [from, to), в порядке возрастания времени, см. Archive. Архив принадлежит вызывающему коду.
+Если тип архива прибором не поддерживается, возвращается ошибка. При ошибке чтения возвращается архив с уже прочитанными записями.
+*/
+type IArchiveDriver interface {
+	IDeviceDriver
+	ReadArchive(ctx context.Context, archive ArchiveType, from time.Time, to time.Time) (*Archive, error)
+}
+
+/**
+Драйвер, устанавливающий время на приборе.
+SetTime вызывается после Init и записывает moment - время по часам компьютера - с точностью, которую позволяет
+протокол. Текущее время прибора драйвер возвращает в DataDevice::Time, см. Read.
+*/
+type IClockDriver interface {
+	IDeviceDriver
+	SetTime(ctx context.Context, moment time.Time) error
 }
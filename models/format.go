@@ -4,4 +4,6 @@ import "io"
 
 type Formatter interface {
 	Render(writer io.Writer, device *DataDevice)
+	RenderArchive(writer io.Writer, archive *Archive) // Вывод архива, см. IArchiveDriver
+	RenderClock(writer io.Writer, clock *ClockSync)    // Вывод результата синхронизации часов, см. IClockDriver
 }
@@ -0,0 +1,203 @@
+package models
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+Вывод в CSV для импорта в электронные таблицы: строка заголовка и по строке на каждую активную систему.
+Набор и порядок колонок не зависят от драйвера и данных прибора. Разделитель колонок и десятичный
+разделитель настраиваются, для русского Excel - ';' и ','. Без заголовка (NoHeader) вывод нескольких
+опросов можно объединять в один файл.
+*/
+type CsvFormat struct {
+	Delimiter rune // Разделитель колонок, по умолчанию ';'
+	Decimal   rune // Десятичный разделитель, по умолчанию ','
+	NoHeader  bool // Не выводить строку заголовка
+}
+
+// Время в колонках CSV, по местному времени
+const csvTimeLayout = "2006-01-02 15:04:05"
+
+var csvDeviceHeader = []string{
+	"serial", "timeRequest", "timeDevice", "unitQ", "system", "timeRunSys",
+	"SigmaQ", "Q1", "Q2", "Q3", "V1", "V2", "M1", "M2", "GM1", "GM2", "GV1", "GV2",
+	"T1", "T2", "T3", "P1", "P2", "P3", "M3", "GM3", "GV3", "TMakeup", "PMakeup",
+	"status", "step", "errorClass",
+}
+
+var csvArchiveHeader = []string{
+	"serial", "archive", "start", "end", "unitQ", "system",
+	"SigmaQ", "Q1", "Q2", "Q3", "V1", "V2", "M1", "M2", "T1", "T2", "P1", "P2", "M3", "TMakeup", "PMakeup",
+	"timeOn", "timeOff", "timeRunSys", "timeError", "timeGMin", "timeGMax", "timeDT", "timeFault", "tariffs",
+}
+
+var csvClockHeader = []string{"serial", "timeRequest", "timeBefore", "drift", "timeAfter", "driftAfter", "corrected", "dryRun"}
+
+// Строка заголовка для данных теплосчётчика. Выводится отдельно, например, один раз для пакетного опроса
+func (format CsvFormat) RenderHeader(writer io.Writer) {
+	if format.NoHeader {
+		return
+	}
+	csvWriter := format.writer(writer)
+	_ = csvWriter.Write(csvDeviceHeader)
+	csvWriter.Flush()
+}
+
+// Формат без строки заголовка
+func (format CsvFormat) WithoutHeader() *CsvFormat {
+	format.NoHeader = true
+	return &format
+}
+
+/**
+Колонки status, step и errorClass - состояние опроса, см. PollStatus. Если данные не прочитаны,
+либо при частичном результате нет ни одной активной системы, выводится одна строка с состоянием опроса
+и пустыми показаниями.
+*/
+func (format CsvFormat) Render(writer io.Writer, device *DataDevice) {
+	format.RenderHeader(writer)
+	csvWriter := format.writer(writer)
+	var status []string
+	if device.Status != nil {
+		status = []string{string(device.Status.State), string(device.Status.Step), string(device.Status.Class)}
+	} else {
+		status = []string{"", "", ""}
+	}
+	rows := 0
+	unitQ := unitQText(device.UnitQ)
+	for i, system := range device.Systems {
+		if system.Status == false || device.Status != nil && device.Status.State == StateFailed {
+			continue
+		}
+		rows++
+		_ = csvWriter.Write(append([]string{
+			device.Serial,
+			device.TimeRequest.Format(csvTimeLayout),
+			device.Time.Format(csvTimeLayout),
+			unitQ,
+			strconv.Itoa(i + 1),
+			strconv.FormatUint(uint64(system.TimeRunSys), 10),
+			format.float64(system.SigmaQ), format.float64(system.Q1), format.float64(system.Q2), format.float64(system.Q3),
+			format.float64(system.V1), format.float64(system.V2), format.float64(system.M1), format.float64(system.M2),
+			format.float32(system.GM1), format.float32(system.GM2), format.float32(system.GV1), format.float32(system.GV2),
+			format.float32(system.T1), format.float32(system.T2), format.float32(system.T3),
+			format.float32(system.P1), format.float32(system.P2), format.float32(system.P3),
+			format.float64(system.M3), format.float32(system.GM3), format.float32(system.GV3),
+			format.float32(system.TMakeup), format.float32(system.PMakeup),
+		}, status...))
+	}
+	if rows == 0 && device.Status != nil {
+		row := make([]string, len(csvDeviceHeader)-len(status), len(csvDeviceHeader))
+		row[0] = device.Serial
+		row[1] = device.TimeRequest.Format(csvTimeLayout)
+		_ = csvWriter.Write(append(row, status...))
+	}
+	csvWriter.Flush()
+}
+
+/**
+Архив: по строке на каждую активную систему каждой записи.
+Количество тарифов зависит от прибора, поэтому энергия по тарифам выводится одной колонкой tariffs через пробел.
+*/
+func (format CsvFormat) RenderArchive(writer io.Writer, archive *Archive) {
+	csvWriter := format.writer(writer)
+	if !format.NoHeader {
+		_ = csvWriter.Write(csvArchiveHeader)
+	}
+	unitQ := unitQText(archive.UnitQ)
+	for _, record := range archive.Records {
+		for i, system := range record.Systems {
+			if system.Status == false {
+				continue
+			}
+			_ = csvWriter.Write([]string{
+				archive.Serial,
+				string(archive.Type),
+				record.Start.Format(csvTimeLayout),
+				record.End.Format(csvTimeLayout),
+				unitQ,
+				strconv.Itoa(i + 1),
+				format.float64(system.SigmaQ), format.float64(system.Q1), format.float64(system.Q2), format.float64(system.Q3),
+				format.float64(system.V1), format.float64(system.V2), format.float64(system.M1), format.float64(system.M2),
+				format.float32(system.T1), format.float32(system.T2), format.float32(system.P1), format.float32(system.P2),
+				format.float64(system.M3), format.float32(system.TMakeup), format.float32(system.PMakeup),
+				strconv.FormatUint(uint64(record.TimeOn), 10),
+				strconv.FormatUint(uint64(record.TimeOff), 10),
+				strconv.FormatUint(uint64(system.TimeRunSys), 10),
+				strconv.FormatUint(uint64(system.TimeError), 10),
+				strconv.FormatUint(uint64(system.TimeGMin), 10),
+				strconv.FormatUint(uint64(system.TimeGMax), 10),
+				strconv.FormatUint(uint64(system.TimeDT), 10),
+				strconv.FormatUint(uint64(system.TimeFault), 10),
+				format.tariffs(system.Tariffs),
+			})
+		}
+	}
+	csvWriter.Flush()
+}
+
+// Расхождения часов в секундах. Время после коррекции не заполняется, если время не записывалось
+func (format CsvFormat) RenderClock(writer io.Writer, clock *ClockSync) {
+	csvWriter := format.writer(writer)
+	if !format.NoHeader {
+		_ = csvWriter.Write(csvClockHeader)
+	}
+	after, driftAfter := "", ""
+	if clock.Corrected {
+		after = clock.After.Format(csvTimeLayout)
+		driftAfter = fmt.Sprint(int64(clock.DriftAfter.Round(time.Second) / time.Second))
+	}
+	_ = csvWriter.Write([]string{
+		clock.Serial,
+		clock.TimeRequest.Format(csvTimeLayout),
+		clock.Before.Format(csvTimeLayout),
+		fmt.Sprint(int64(clock.Drift.Round(time.Second) / time.Second)),
+		after,
+		driftAfter,
+		strconv.FormatBool(clock.Corrected),
+		strconv.FormatBool(clock.DryRun),
+	})
+	csvWriter.Flush()
+}
+
+func (format CsvFormat) writer(writer io.Writer) *csv.Writer {
+	csvWriter := csv.NewWriter(writer)
+	csvWriter.Comma = ';'
+	if format.Delimiter != 0 {
+		csvWriter.Comma = format.Delimiter
+	}
+	return csvWriter
+}
+
+func (format CsvFormat) float64(value float64) string {
+	return format.decimal(strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+func (format CsvFormat) tariffs(values []float64) string {
+	texts := make([]string, len(values))
+	for i, value := range values {
+		texts[i] = format.float64(value)
+	}
+	return strings.Join(texts, " ")
+}
+
+func (format CsvFormat) float32(value float32) string {
+	return format.decimal(strconv.FormatFloat(float64(value), 'f', -1, 32))
+}
+
+func (format CsvFormat) decimal(value string) string {
+	decimal := ','
+	if format.Decimal != 0 {
+		decimal = format.Decimal
+	}
+	if decimal == '.' {
+		return value
+	}
+	return strings.Replace(value, ".", string(decimal), 1)
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCsvFormat(t *testing.T) {
+	moment := time.Date(2024, 3, 15, 12, 34, 56, 0, time.UTC)
+	device := &DataDevice{
+		Serial:      "104001",
+		UnitQ:       Gcal,
+		TimeRequest: moment,
+		Time:        moment.Add(-time.Minute),
+		Systems: []SystemDevice{
+			{Status: true, TimeRunSys: 3600, SigmaQ: 1234.5, V1: 45678.25, T1: 72.5, T2: 48.25},
+			{Status: false, SigmaQ: 1},
+			{Status: true, Q1: 0.125, P1: 0.6},
+		},
+	}
+
+	var output bytes.Buffer
+	CsvFormat{}.Render(&output, device)
+	lines := strings.Split(strings.TrimRight(output.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("ожидались заголовок и две строки систем:\n%s", output.String())
+	}
+	if lines[0] != strings.Join(csvDeviceHeader, ";") {
+		t.Errorf("заголовок: %s", lines[0])
+	}
+	expected := "104001;2024-03-15 12:34:56;2024-03-15 12:33:56;ГКал;1;3600;1234,5;0;0;0;45678,25;0;0;0;0;0;0;0;72,5;48,25;0;0;0;0;0;0;0;0;0;;;"
+	if lines[1] != expected {
+		t.Errorf("строка системы 1:\n%s\nожидалась\n%s", lines[1], expected)
+	}
+	if !strings.HasPrefix(lines[2], "104001;2024-03-15 12:34:56;2024-03-15 12:33:56;ГКал;3;0;0;0,125;") {
+		t.Errorf("строка системы 3: %s", lines[2])
+	}
+	if columns := strings.Count(lines[2], ";") + 1; columns != len(csvDeviceHeader) {
+		t.Errorf("колонок %d, ожидалось %d", columns, len(csvDeviceHeader))
+	}
+
+	output.Reset()
+	CsvFormat{Delimiter: ',', Decimal: '.', NoHeader: true}.Render(&output, device)
+	lines = strings.Split(strings.TrimRight(output.String(), "\n"), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "104001,2024-03-15 12:34:56,2024-03-15 12:33:56,ГКал,1,3600,1234.5,") {
+		t.Errorf("вывод без заголовка:\n%s", output.String())
+	}
+
+	// Данные не прочитаны: одна строка с состоянием опроса
+	output.Reset()
+	CsvFormat{NoHeader: true}.Render(&output, &DataDevice{TimeRequest: moment,
+		Status: &PollStatus{State: StateFailed, Step: StepRead, Class: ErrorTimeout, Error: "прибор не ответил на запрос"}})
+	expected = ";2024-03-15 12:34:56" + strings.Repeat(";", len(csvDeviceHeader)-5) + ";failed;read;timeout\n"
+	if output.String() != expected {
+		t.Errorf("вывод ошибки:\n%s\nожидался\n%s", output.String(), expected)
+	}
+}
+
+func TestCsvFormatClock(t *testing.T) {
+	moment := time.Date(2024, 3, 15, 12, 34, 56, 0, time.UTC)
+	var output bytes.Buffer
+	CsvFormat{NoHeader: true}.RenderClock(&output, &ClockSync{
+		Serial:      "104001",
+		TimeRequest: moment,
+		Before:      moment.Add(-90 * time.Second),
+		Drift:       -90 * time.Second,
+		DryRun:      true,
+	})
+	expected := "104001;2024-03-15 12:34:56;2024-03-15 12:33:26;-90;;;false;true\n"
+	if output.String() != expected {
+		t.Errorf("вывод:\n%s\nожидался\n%s", output.String(), expected)
+	}
+}
@@ -0,0 +1,247 @@
+package models
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+Вывод в InfluxDB line protocol: по строке на каждую активную систему. Метки - serial, system, driver
+и метки задания пакетного опроса, поля - все показания системы (имена как в json2), время строки -
+время опроса DataDevice::TimeRequest либо, с TimeDevice, время на приборе, в наносекундах.
+При частичном результате выводятся только достоверные поля, см. PollStatus::Valid, если данные
+не прочитаны - ничего. Строки можно записывать в InfluxDB и совместимые базы, см. services/influx.
+*/
+type InfluxFormat struct {
+	Measurement string            // Имя измерения, по умолчанию qbox. Архивы - Measurement_archive, часы - _clock
+	Driver      string            // Имя драйвера, метка driver. При определении драйвера (auto) заменяется определённым драйвером
+	Tags        map[string]string // Дополнительные метки, например, метки задания пакетного опроса
+	TimeDevice  bool              // Время строки - время на приборе, иначе время опроса
+}
+
+// Поле строки: имя поля, имя в DataDevice::FilledFields и значение
+type influxField struct {
+	name  string
+	valid string
+	value string
+}
+
+func (format InfluxFormat) Render(writer io.Writer, device *DataDevice) {
+	valid := map[string]bool{}
+	if device.Status != nil {
+		if device.Status.State == StateFailed {
+			return
+		}
+		for _, name := range device.Status.Valid {
+			valid[name] = true
+		}
+	}
+	partial := device.Status != nil && device.Status.State == StatePartial
+
+	timestamp := device.TimeRequest
+	if format.TimeDevice {
+		timestamp = device.Time
+	}
+	driver := format.Driver
+	if device.Detected != nil {
+		driver = device.Detected.Driver
+	}
+	unitQ := UnitQCode(device.UnitQ)
+	for i, system := range device.Systems {
+		if system.Status == false {
+			continue
+		}
+		var fields []influxField
+		for _, field := range []influxField{
+			{"timeRunSys", "timeRunSys", influxInteger(uint64(system.TimeRunSys))},
+			{"sigmaQ", "SigmaQ", influxFloat(system.SigmaQ, 64)},
+			{"q1", "Q1", influxFloat(system.Q1, 64)},
+			{"q2", "Q2", influxFloat(system.Q2, 64)},
+			{"q3", "Q3", influxFloat(system.Q3, 64)},
+			{"v1", "V1", influxFloat(system.V1, 64)},
+			{"v2", "V2", influxFloat(system.V2, 64)},
+			{"m1", "M1", influxFloat(system.M1, 64)},
+			{"m2", "M2", influxFloat(system.M2, 64)},
+			{"m3", "M3", influxFloat(system.M3, 64)},
+			{"gm1", "GM1", influxFloat(float64(system.GM1), 32)},
+			{"gm2", "GM2", influxFloat(float64(system.GM2), 32)},
+			{"gm3", "GM3", influxFloat(float64(system.GM3), 32)},
+			{"gv1", "GV1", influxFloat(float64(system.GV1), 32)},
+			{"gv2", "GV2", influxFloat(float64(system.GV2), 32)},
+			{"gv3", "GV3", influxFloat(float64(system.GV3), 32)},
+			{"t1", "T1", influxFloat(float64(system.T1), 32)},
+			{"t2", "T2", influxFloat(float64(system.T2), 32)},
+			{"t3", "T3", influxFloat(float64(system.T3), 32)},
+			{"tMakeup", "TMakeup", influxFloat(float64(system.TMakeup), 32)},
+			{"p1", "P1", influxFloat(float64(system.P1), 32)},
+			{"p2", "P2", influxFloat(float64(system.P2), 32)},
+			{"p3", "P3", influxFloat(float64(system.P3), 32)},
+			{"pMakeup", "PMakeup", influxFloat(float64(system.PMakeup), 32)},
+		} {
+			if partial && !valid[fmt.Sprintf("system%d.%s", i+1, field.valid)] {
+				continue
+			}
+			fields = append(fields, field)
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		fields = append(fields, influxField{name: "unitQ", value: influxString(unitQ)})
+		format.line(writer, format.measurement(""), map[string]string{
+			"serial": device.Serial,
+			"system": strconv.Itoa(i + 1),
+			"driver": driver,
+		}, fields, timestamp)
+	}
+}
+
+// Записи архива - по строке на систему со временем начала периода
+func (format InfluxFormat) RenderArchive(writer io.Writer, archive *Archive) {
+	unitQ := UnitQCode(archive.UnitQ)
+	for _, record := range archive.Records {
+		for i, system := range record.Systems {
+			if system.Status == false {
+				continue
+			}
+			fields := []influxField{
+				{name: "sigmaQ", value: influxFloat(system.SigmaQ, 64)},
+				{name: "q1", value: influxFloat(system.Q1, 64)},
+				{name: "q2", value: influxFloat(system.Q2, 64)},
+				{name: "q3", value: influxFloat(system.Q3, 64)},
+				{name: "v1", value: influxFloat(system.V1, 64)},
+				{name: "v2", value: influxFloat(system.V2, 64)},
+				{name: "m1", value: influxFloat(system.M1, 64)},
+				{name: "m2", value: influxFloat(system.M2, 64)},
+				{name: "m3", value: influxFloat(system.M3, 64)},
+				{name: "t1", value: influxFloat(float64(system.T1), 32)},
+				{name: "t2", value: influxFloat(float64(system.T2), 32)},
+				{name: "tMakeup", value: influxFloat(float64(system.TMakeup), 32)},
+				{name: "p1", value: influxFloat(float64(system.P1), 32)},
+				{name: "p2", value: influxFloat(float64(system.P2), 32)},
+				{name: "pMakeup", value: influxFloat(float64(system.PMakeup), 32)},
+				{name: "timeOn", value: influxInteger(uint64(record.TimeOn))},
+				{name: "timeOff", value: influxInteger(uint64(record.TimeOff))},
+				{name: "timeRunSys", value: influxInteger(uint64(system.TimeRunSys))},
+				{name: "timeError", value: influxInteger(uint64(system.TimeError))},
+				{name: "timeGMin", value: influxInteger(uint64(system.TimeGMin))},
+				{name: "timeGMax", value: influxInteger(uint64(system.TimeGMax))},
+				{name: "timeDT", value: influxInteger(uint64(system.TimeDT))},
+				{name: "timeFault", value: influxInteger(uint64(system.TimeFault))},
+			}
+			for t, q := range system.Tariffs {
+				fields = append(fields, influxField{name: fmt.Sprintf("tariff%d", t+1), value: influxFloat(q, 64)})
+			}
+			fields = append(fields, influxField{name: "unitQ", value: influxString(unitQ)})
+			format.line(writer, format.measurement("_archive"), map[string]string{
+				"serial":  archive.Serial,
+				"system":  strconv.Itoa(i + 1),
+				"driver":  format.Driver,
+				"archive": string(archive.Type),
+			}, fields, record.Start)
+		}
+	}
+}
+
+// Расхождения часов в секундах, время строки - время опроса
+func (format InfluxFormat) RenderClock(writer io.Writer, clock *ClockSync) {
+	fields := []influxField{
+		{name: "drift", value: influxFloat(clock.Drift.Seconds(), 64)},
+		{name: "corrected", value: strconv.FormatBool(clock.Corrected)},
+		{name: "dryRun", value: strconv.FormatBool(clock.DryRun)},
+	}
+	if clock.Corrected {
+		fields = append(fields, influxField{name: "driftAfter", value: influxFloat(clock.DriftAfter.Seconds(), 64)})
+	}
+	format.line(writer, format.measurement("_clock"), map[string]string{
+		"serial": clock.Serial,
+		"driver": format.Driver,
+	}, fields, clock.TimeRequest)
+}
+
+func (format InfluxFormat) measurement(suffix string) string {
+	if format.Measurement == "" {
+		return "qbox" + suffix
+	}
+	return format.Measurement + suffix
+}
+
+/**
+Строка line protocol. Метки выводятся по алфавиту, как рекомендует InfluxDB, пустые метки не выводятся.
+Метки строки заменяют одноимённые метки Tags. Поля с NaN и бесконечностью не выводятся:
+line protocol их не поддерживает. Время не заполненное драйвером не выводится, и его назначает база.
+*/
+func (format InfluxFormat) line(writer io.Writer, measurement string, tags map[string]string, fields []influxField, timestamp time.Time) {
+	var line strings.Builder
+	line.WriteString(influxMeasurementEscaper.Replace(measurement))
+
+	merged := make(map[string]string, len(format.Tags)+len(tags))
+	for key, value := range format.Tags {
+		merged[key] = value
+	}
+	for key, value := range tags {
+		merged[key] = value
+	}
+	keys := make([]string, 0, len(merged))
+	for key, value := range merged {
+		if key != "" && value != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		line.WriteByte(',')
+		line.WriteString(influxTagEscaper.Replace(key))
+		line.WriteByte('=')
+		line.WriteString(influxTagEscaper.Replace(merged[key]))
+	}
+
+	separator := byte(' ')
+	written := false
+	for _, field := range fields {
+		if field.value == "" {
+			continue
+		}
+		line.WriteByte(separator)
+		line.WriteString(influxTagEscaper.Replace(field.name))
+		line.WriteByte('=')
+		line.WriteString(field.value)
+		separator = ','
+		written = true
+	}
+	if !written {
+		return
+	}
+	if !timestamp.IsZero() {
+		line.WriteByte(' ')
+		line.WriteString(strconv.FormatInt(timestamp.UnixNano(), 10))
+	}
+	line.WriteByte('\n')
+	_, _ = io.WriteString(writer, line.String())
+}
+
+var influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+
+var influxTagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+
+var influxStringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Пустая строка - значение не выводится
+func influxFloat(value float64, bitSize int) string {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return ""
+	}
+	return strconv.FormatFloat(value, 'f', -1, bitSize)
+}
+
+func influxInteger(value uint64) string {
+	return strconv.FormatUint(value, 10) + "i"
+}
+
+func influxString(value string) string {
+	return `"` + influxStringEscaper.Replace(value) + `"`
+}
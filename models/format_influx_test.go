@@ -0,0 +1,75 @@
+package models
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInfluxFormat(t *testing.T) {
+	moment := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	device := &DataDevice{
+		Serial:      "104001",
+		UnitQ:       Gcal,
+		TimeRequest: moment,
+		Time:        moment.Add(-3 * time.Second),
+		Systems: []SystemDevice{
+			{Status: true, TimeRunSys: 3600, SigmaQ: 1234.5, T1: 72.5, P1: float32(math.NaN())},
+			{Status: false, SigmaQ: 1},
+		},
+	}
+	format := InfluxFormat{Driver: "tem104", Tags: map[string]string{"house": "17 А", "driver": "заменяется"}}
+
+	var output bytes.Buffer
+	format.Render(&output, device)
+	expected := `qbox,driver=tem104,house=17\ А,serial=104001,system=1 timeRunSys=3600i,sigmaQ=1234.5,q1=0,q2=0,q3=0,` +
+		`v1=0,v2=0,m1=0,m2=0,m3=0,gm1=0,gm2=0,gm3=0,gv1=0,gv2=0,gv3=0,t1=72.5,t2=0,t3=0,tMakeup=0,p2=0,p3=0,pMakeup=0,` +
+		`unitQ="Gcal" 1792317600000000000` + "\n"
+	if output.String() != expected {
+		t.Errorf("вывод:\n%s\nожидался\n%s", output.String(), expected)
+	}
+
+	// Время на приборе, частичный результат - только достоверные поля
+	output.Reset()
+	format.TimeDevice = true
+	format.Tags = nil
+	device.Status = &PollStatus{State: StatePartial, Valid: []string{"serial", "system1.SigmaQ"}}
+	format.Render(&output, device)
+	expected = `qbox,driver=tem104,serial=104001,system=1 sigmaQ=1234.5,unitQ="Gcal" 1792317597000000000` + "\n"
+	if output.String() != expected {
+		t.Errorf("частичный результат:\n%s\nожидался\n%s", output.String(), expected)
+	}
+
+	// Нулевые показания активной системы частичного результата прочитаны и выводятся
+	output.Reset()
+	device.Status = &PollStatus{State: StatePartial, Valid: device.FilledFields()}
+	format.Render(&output, device)
+	if !strings.Contains(output.String(), ",q1=0,") || !strings.Contains(output.String(), ",t2=0,") {
+		t.Errorf("нулевые показания частичного результата:\n%s", output.String())
+	}
+
+	// Данные не прочитаны
+	output.Reset()
+	device.Status = &PollStatus{State: StateFailed}
+	format.Render(&output, device)
+	if output.Len() != 0 {
+		t.Errorf("вывод без данных: %s", output.String())
+	}
+}
+
+func TestInfluxFormatArchive(t *testing.T) {
+	moment := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	var output bytes.Buffer
+	InfluxFormat{Driver: "tem104m"}.RenderArchive(&output, &Archive{
+		Serial:  "104001",
+		Type:    ArchiveDaily,
+		UnitQ:   GJ,
+		Records: []ArchiveRecord{{Start: moment, Systems: []ArchiveSystem{{Status: true, SigmaQ: 1.5, Tariffs: []float64{1, 0.5}}}}},
+	})
+	if !strings.HasPrefix(output.String(), "qbox_archive,archive=daily,driver=tem104m,serial=104001,system=1 sigmaQ=1.5,") ||
+		!strings.HasSuffix(output.String(), `,tariff1=1,tariff2=0.5,unitQ="GJ" 1792281600000000000`+"\n") {
+		t.Errorf("вывод архива: %s", output.String())
+	}
+}
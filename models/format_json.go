@@ -11,6 +11,18 @@ type JsonFormat struct {
 }
 
 func (format JsonFormat) Render(writer io.Writer, device *DataDevice) {
+	var status *pollStatusJson
+	if device.Status != nil {
+		status = (*pollStatusJson)(device.Status)
+		if device.Status.State == StateFailed {
+			// Данные не прочитаны, выводится только состояние опроса
+			bytesResponse, _ := json.Marshal(struct {
+				Status *pollStatusJson `json:"status"`
+			}{status})
+			fmt.Fprintln(writer, string(bytesResponse))
+			return
+		}
+	}
 	deviceForJson := dataDeviceJson{
 		Serial:        device.Serial,
 		UnitQ:         device.UnitQ,
@@ -18,6 +30,11 @@ func (format JsonFormat) Render(writer io.Writer, device *DataDevice) {
 		Time:          JSONTime(device.Time),
 		TimeOn:        device.TimeOn,
 		TimeRunCommon: device.TimeRunCommon,
+		Status:        status,
+	}
+	if device.Detected != nil {
+		detected := detectionJson(*device.Detected)
+		deviceForJson.Detected = &detected
 	}
 
 	for _, system := range device.Systems {
@@ -34,6 +51,61 @@ func (format JsonFormat) Render(writer io.Writer, device *DataDevice) {
 	fmt.Fprintln(writer, string(bytesResponse))
 }
 
+func (format JsonFormat) RenderArchive(writer io.Writer, archive *Archive) {
+	archiveForJson := archiveJson{
+		Serial:      archive.Serial,
+		UnitQ:       archive.UnitQ,
+		Type:        archive.Type,
+		TimeRequest: JSONTime(archive.TimeRequest),
+		Records:     make([]archiveRecordJson, 0, len(archive.Records)),
+	}
+
+	for _, record := range archive.Records {
+		recordForJson := archiveRecordJson{
+			Start:   JSONTime(record.Start),
+			End:     JSONTime(record.End),
+			TimeOn:  record.TimeOn,
+			TimeOff: record.TimeOff,
+		}
+		for _, system := range record.Systems {
+			if system.Status == false {
+				continue
+			}
+			recordForJson.Systems = append(recordForJson.Systems, archiveSystemJson(system))
+		}
+		archiveForJson.Records = append(archiveForJson.Records, recordForJson)
+	}
+
+	bytesResponse, err := json.Marshal(archiveForJson)
+	if err != nil {
+		fmt.Fprintln(writer, "{}")
+	}
+	fmt.Fprintln(writer, string(bytesResponse))
+}
+
+func (format JsonFormat) RenderClock(writer io.Writer, clock *ClockSync) {
+	clockForJson := clockSyncJson{
+		Serial:      clock.Serial,
+		TimeRequest: JSONTime(clock.TimeRequest),
+		Before:      JSONTime(clock.Before),
+		Drift:       int64(clock.Drift.Round(time.Second) / time.Second),
+		Corrected:   clock.Corrected,
+		DryRun:      clock.DryRun,
+	}
+	if clock.Corrected {
+		after := JSONTime(clock.After)
+		driftAfter := int64(clock.DriftAfter.Round(time.Second) / time.Second)
+		clockForJson.After = &after
+		clockForJson.DriftAfter = &driftAfter
+	}
+
+	bytesResponse, err := json.Marshal(clockForJson)
+	if err != nil {
+		fmt.Fprintln(writer, "{}")
+	}
+	fmt.Fprintln(writer, string(bytesResponse))
+}
+
 /**
 Чтобы не засорять код файла device.go подробностями по JSON,
 решено сделать дубликат структур с настройками под JSON формат.
@@ -46,6 +118,15 @@ type dataDeviceJson struct {
 	TimeOn        uint32             `json:"timeOn"`
 	TimeRunCommon uint32             `json:"timeRunCommon"`
 	Systems       []systemDeviceJson `json:"system"`
+	Detected      *detectionJson     `json:"detected,omitempty"`
+	Status        *pollStatusJson    `json:"status,omitempty"`
+}
+
+type detectionJson struct {
+	Driver   string `json:"driver"`
+	Protocol string `json:"protocol"`
+	Model    string `json:"model"`
+	Firmware string `json:"firmware,omitempty"`
 }
 
 type systemDeviceJson struct {
@@ -68,7 +149,66 @@ type systemDeviceJson struct {
 	P1         float32
 	P2         float32
 	P3         float32
-	Status     bool `json:"-"`
+	M3         float64 `json:",omitempty"` // поля подпитки выводятся, только если заполнены драйвером
+	GM3        float32 `json:",omitempty"`
+	GV3        float32 `json:",omitempty"`
+	TMakeup    float32 `json:",omitempty"`
+	PMakeup    float32 `json:",omitempty"`
+	Status     bool    `json:"-"`
+}
+
+type archiveJson struct {
+	Serial      string              `json:"serial"`
+	UnitQ       UnitQEnum           `json:"unitQ"`
+	Type        ArchiveType         `json:"archive"`
+	TimeRequest JSONTime            `json:"timeRequest"`
+	Records     []archiveRecordJson `json:"records"`
+}
+
+type archiveRecordJson struct {
+	Start   JSONTime            `json:"start"`
+	End     JSONTime            `json:"end"`
+	TimeOn  uint32              `json:"timeOn"`
+	TimeOff uint32              `json:"timeOff"`
+	Systems []archiveSystemJson `json:"system"`
+}
+
+type archiveSystemJson struct {
+	SigmaQ     float64
+	Q1         float64
+	Q2         float64
+	Q3         float64
+	Tariffs    []float64 `json:",omitempty"` // энергия по тарифам выводится, только если прибор ведёт тарифы
+	V1         float64
+	V2         float64
+	M1         float64
+	M2         float64
+	T1         float32
+	T2         float32
+	P1         float32
+	P2         float32
+	M3         float64 `json:",omitempty"`
+	TMakeup    float32 `json:",omitempty"`
+	PMakeup    float32 `json:",omitempty"`
+	TimeRunSys uint32  `json:"timeRunSys"`
+	TimeError  uint32  `json:"timeError"`
+	TimeGMin   uint32  `json:"timeGMin"`
+	TimeGMax   uint32  `json:"timeGMax"`
+	TimeDT     uint32  `json:"timeDT"`
+	TimeFault  uint32  `json:"timeFault"`
+	Status     bool    `json:"-"`
+}
+
+// Расхождения часов в секундах
+type clockSyncJson struct {
+	Serial      string    `json:"serial"`
+	TimeRequest JSONTime  `json:"timeRequest"`
+	Before      JSONTime  `json:"timeBefore"`
+	Drift       int64     `json:"drift"`
+	After       *JSONTime `json:"timeAfter,omitempty"`
+	DriftAfter  *int64    `json:"driftAfter,omitempty"`
+	Corrected   bool      `json:"corrected"`
+	DryRun      bool      `json:"dryRun"`
 }
 
 type JSONTime time.Time
@@ -0,0 +1,365 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Версия формата json2, поле schema. Описание формата - schema/qbox-data-v2.schema.json
+const Json2Schema = "qbox/data/v2"
+
+/**
+Формат json2: версионированный JSON, не требующий знания устройства qBox. Каждое показание выводится
+со своими единицами измерения, время - в RFC 3339 с часовым поясом, системы - с номером системы прибора.
+В документ входят драйвер, номер прибора, строка подключения и ошибка опроса, поэтому Render допускает
+device == nil: при ошибке до чтения данных выводятся только сведения об опросе и ошибка.
+Состояние опроса DataDevice::Status выводится в разделе status, при состоянии failed показания не выводятся.
+*/
+type Json2Format struct {
+	Driver   string            // Имя драйвера. При определении драйвера (auto) заменяется определённым драйвером
+	Number   byte              // Номер прибора
+	Address  string            // Вторичный адрес M-Bus
+	Endpoint string            // Строка подключения
+	Tags     map[string]string // Метки задания пакетного опроса
+	Err      error             // Ошибка опроса, выводится в разделе error
+}
+
+// Показание с единицами измерения. Значение null - прибор вернул не число (NaN, бесконечность)
+type quantityJson2 struct {
+	Value json.RawMessage `json:"value"`
+	Unit  string          `json:"unit"`
+}
+
+type errorJson2 struct {
+	Message string `json:"message"`
+}
+
+// Состояние опроса, см. PollStatus. Текст ошибки выводится в разделе error
+type statusJson2 struct {
+	State PollState  `json:"state"`
+	Step  PollStep   `json:"step,omitempty"`
+	Class ErrorClass `json:"class,omitempty"`
+	Valid []string   `json:"valid,omitempty"`
+}
+
+type dataDeviceJson2 struct {
+	Schema        string              `json:"schema"`
+	Driver        string              `json:"driver"`
+	Number        byte                `json:"number"`
+	Address       string              `json:"address,omitempty"`
+	Endpoint      string              `json:"endpoint"`
+	Tags          map[string]string   `json:"tags,omitempty"`
+	Serial        string              `json:"serial,omitempty"`
+	TimeRequest   *time.Time          `json:"timeRequest,omitempty"`
+	TimeDevice    *time.Time          `json:"timeDevice,omitempty"`
+	TimeOn        *quantityJson2      `json:"timeOn,omitempty"`
+	TimeRunCommon *quantityJson2      `json:"timeRunCommon,omitempty"`
+	Detected      *detectionJson      `json:"detected,omitempty"`
+	Systems       []systemDeviceJson2 `json:"systems"`
+	Status        *statusJson2        `json:"status,omitempty"`
+	Error         *errorJson2         `json:"error,omitempty"`
+}
+
+type systemDeviceJson2 struct {
+	System     int           `json:"system"` // Номер системы прибора, с 1
+	TimeRunSys quantityJson2 `json:"timeRunSys"`
+	SigmaQ     quantityJson2 `json:"sigmaQ"`
+	Q1         quantityJson2 `json:"q1"`
+	Q2         quantityJson2 `json:"q2"`
+	Q3         quantityJson2 `json:"q3"`
+	V1         quantityJson2 `json:"v1"`
+	V2         quantityJson2 `json:"v2"`
+	M1         quantityJson2 `json:"m1"`
+	M2         quantityJson2 `json:"m2"`
+	M3         quantityJson2 `json:"m3"`
+	GM1        quantityJson2 `json:"gm1"`
+	GM2        quantityJson2 `json:"gm2"`
+	GM3        quantityJson2 `json:"gm3"`
+	GV1        quantityJson2 `json:"gv1"`
+	GV2        quantityJson2 `json:"gv2"`
+	GV3        quantityJson2 `json:"gv3"`
+	T1         quantityJson2 `json:"t1"`
+	T2         quantityJson2 `json:"t2"`
+	T3         quantityJson2 `json:"t3"`
+	TMakeup    quantityJson2 `json:"tMakeup"`
+	P1         quantityJson2 `json:"p1"`
+	P2         quantityJson2 `json:"p2"`
+	P3         quantityJson2 `json:"p3"`
+	PMakeup    quantityJson2 `json:"pMakeup"`
+}
+
+func (format Json2Format) Render(writer io.Writer, device *DataDevice) {
+	document := dataDeviceJson2{
+		Schema:   Json2Schema,
+		Driver:   format.Driver,
+		Number:   format.Number,
+		Address:  format.Address,
+		Endpoint: format.Endpoint,
+		Tags:     format.Tags,
+		Systems:  []systemDeviceJson2{},
+		Error:    format.error(),
+	}
+	if device != nil && device.Status != nil {
+		status := device.Status
+		document.Status = &statusJson2{State: status.State, Step: status.Step, Class: status.Class, Valid: status.Valid}
+		if document.Error == nil && status.Error != "" {
+			document.Error = &errorJson2{Message: status.Error}
+		}
+		if status.State == StateFailed {
+			// Данные не прочитаны: выводятся сведения об опросе, время опроса и ошибка
+			document.TimeRequest = timeJson2(device.TimeRequest)
+			device = nil
+		}
+	}
+	if device != nil {
+		document.Serial = device.Serial
+		document.TimeRequest = timeJson2(device.TimeRequest)
+		document.TimeDevice = timeJson2(device.Time)
+		document.TimeOn = seconds(device.TimeOn)
+		document.TimeRunCommon = seconds(device.TimeRunCommon)
+		if device.Detected != nil {
+			detected := detectionJson(*device.Detected)
+			document.Detected = &detected
+			document.Driver = device.Detected.Driver
+		}
+
+		unitQ := UnitQCode(device.UnitQ)
+		for i, system := range device.Systems {
+			if system.Status == false {
+				continue
+			}
+			document.Systems = append(document.Systems, systemDeviceJson2{
+				System:     i + 1,
+				TimeRunSys: *seconds(system.TimeRunSys),
+				SigmaQ:     quantity(system.SigmaQ, 64, unitQ),
+				Q1:         quantity(system.Q1, 64, unitQ),
+				Q2:         quantity(system.Q2, 64, unitQ),
+				Q3:         quantity(system.Q3, 64, unitQ),
+				V1:         quantity(system.V1, 64, "m3"),
+				V2:         quantity(system.V2, 64, "m3"),
+				M1:         quantity(system.M1, 64, "t"),
+				M2:         quantity(system.M2, 64, "t"),
+				M3:         quantity(system.M3, 64, "t"),
+				GM1:        quantity(float64(system.GM1), 32, "t/h"),
+				GM2:        quantity(float64(system.GM2), 32, "t/h"),
+				GM3:        quantity(float64(system.GM3), 32, "t/h"),
+				GV1:        quantity(float64(system.GV1), 32, "m3/h"),
+				GV2:        quantity(float64(system.GV2), 32, "m3/h"),
+				GV3:        quantity(float64(system.GV3), 32, "m3/h"),
+				T1:         quantity(float64(system.T1), 32, "Cel"),
+				T2:         quantity(float64(system.T2), 32, "Cel"),
+				T3:         quantity(float64(system.T3), 32, "Cel"),
+				TMakeup:    quantity(float64(system.TMakeup), 32, "Cel"),
+				P1:         quantity(float64(system.P1), 32, "MPa"),
+				P2:         quantity(float64(system.P2), 32, "MPa"),
+				P3:         quantity(float64(system.P3), 32, "MPa"),
+				PMakeup:    quantity(float64(system.PMakeup), 32, "MPa"),
+			})
+		}
+	}
+	format.print(writer, document)
+}
+
+type archiveJson2 struct {
+	Schema      string               `json:"schema"`
+	Driver      string               `json:"driver"`
+	Number      byte                 `json:"number"`
+	Address     string               `json:"address,omitempty"`
+	Endpoint    string               `json:"endpoint"`
+	Serial      string               `json:"serial"`
+	Archive     ArchiveType          `json:"archive"`
+	TimeRequest *time.Time           `json:"timeRequest,omitempty"`
+	Records     []archiveRecordJson2 `json:"records"`
+	Error       *errorJson2          `json:"error,omitempty"`
+}
+
+type archiveRecordJson2 struct {
+	Start   *time.Time           `json:"start"`
+	End     *time.Time           `json:"end"`
+	TimeOn  quantityJson2        `json:"timeOn"`
+	TimeOff quantityJson2        `json:"timeOff"`
+	Systems []archiveSystemJson2 `json:"systems"`
+}
+
+type archiveSystemJson2 struct {
+	System     int             `json:"system"`
+	SigmaQ     quantityJson2   `json:"sigmaQ"`
+	Q1         quantityJson2   `json:"q1"`
+	Q2         quantityJson2   `json:"q2"`
+	Q3         quantityJson2   `json:"q3"`
+	Tariffs    []quantityJson2 `json:"tariffs,omitempty"`
+	V1         quantityJson2   `json:"v1"`
+	V2         quantityJson2   `json:"v2"`
+	M1         quantityJson2   `json:"m1"`
+	M2         quantityJson2   `json:"m2"`
+	M3         quantityJson2   `json:"m3"`
+	T1         quantityJson2   `json:"t1"`
+	T2         quantityJson2   `json:"t2"`
+	TMakeup    quantityJson2   `json:"tMakeup"`
+	P1         quantityJson2   `json:"p1"`
+	P2         quantityJson2   `json:"p2"`
+	PMakeup    quantityJson2   `json:"pMakeup"`
+	TimeRunSys quantityJson2   `json:"timeRunSys"`
+	TimeError  quantityJson2   `json:"timeError"`
+	TimeGMin   quantityJson2   `json:"timeGMin"`
+	TimeGMax   quantityJson2   `json:"timeGMax"`
+	TimeDT     quantityJson2   `json:"timeDT"`
+	TimeFault  quantityJson2   `json:"timeFault"`
+}
+
+func (format Json2Format) RenderArchive(writer io.Writer, archive *Archive) {
+	document := archiveJson2{
+		Schema:      Json2Schema,
+		Driver:      format.Driver,
+		Number:      format.Number,
+		Address:     format.Address,
+		Endpoint:    format.Endpoint,
+		Serial:      archive.Serial,
+		Archive:     archive.Type,
+		TimeRequest: timeJson2(archive.TimeRequest),
+		Records:     make([]archiveRecordJson2, 0, len(archive.Records)),
+		Error:       format.error(),
+	}
+	unitQ := UnitQCode(archive.UnitQ)
+	for _, record := range archive.Records {
+		recordJson := archiveRecordJson2{
+			Start:   timeJson2(record.Start),
+			End:     timeJson2(record.End),
+			TimeOn:  *seconds(record.TimeOn),
+			TimeOff: *seconds(record.TimeOff),
+			Systems: []archiveSystemJson2{},
+		}
+		for i, system := range record.Systems {
+			if system.Status == false {
+				continue
+			}
+			var tariffs []quantityJson2
+			for _, q := range system.Tariffs {
+				tariffs = append(tariffs, quantity(q, 64, unitQ))
+			}
+			recordJson.Systems = append(recordJson.Systems, archiveSystemJson2{
+				System:     i + 1,
+				SigmaQ:     quantity(system.SigmaQ, 64, unitQ),
+				Q1:         quantity(system.Q1, 64, unitQ),
+				Q2:         quantity(system.Q2, 64, unitQ),
+				Q3:         quantity(system.Q3, 64, unitQ),
+				Tariffs:    tariffs,
+				V1:         quantity(system.V1, 64, "m3"),
+				V2:         quantity(system.V2, 64, "m3"),
+				M1:         quantity(system.M1, 64, "t"),
+				M2:         quantity(system.M2, 64, "t"),
+				M3:         quantity(system.M3, 64, "t"),
+				T1:         quantity(float64(system.T1), 32, "Cel"),
+				T2:         quantity(float64(system.T2), 32, "Cel"),
+				TMakeup:    quantity(float64(system.TMakeup), 32, "Cel"),
+				P1:         quantity(float64(system.P1), 32, "MPa"),
+				P2:         quantity(float64(system.P2), 32, "MPa"),
+				PMakeup:    quantity(float64(system.PMakeup), 32, "MPa"),
+				TimeRunSys: *seconds(system.TimeRunSys),
+				TimeError:  *seconds(system.TimeError),
+				TimeGMin:   *seconds(system.TimeGMin),
+				TimeGMax:   *seconds(system.TimeGMax),
+				TimeDT:     *seconds(system.TimeDT),
+				TimeFault:  *seconds(system.TimeFault),
+			})
+		}
+		document.Records = append(document.Records, recordJson)
+	}
+	format.print(writer, document)
+}
+
+type clockSyncJson2 struct {
+	Schema      string         `json:"schema"`
+	Driver      string         `json:"driver"`
+	Number      byte           `json:"number"`
+	Address     string         `json:"address,omitempty"`
+	Endpoint    string         `json:"endpoint"`
+	Serial      string         `json:"serial"`
+	TimeRequest *time.Time     `json:"timeRequest,omitempty"`
+	TimeBefore  *time.Time     `json:"timeBefore,omitempty"`
+	Drift       quantityJson2  `json:"drift"`
+	TimeAfter   *time.Time     `json:"timeAfter,omitempty"`
+	DriftAfter  *quantityJson2 `json:"driftAfter,omitempty"`
+	Corrected   bool           `json:"corrected"`
+	DryRun      bool           `json:"dryRun"`
+	Error       *errorJson2    `json:"error,omitempty"`
+}
+
+// Расхождение - время прибора минус время компьютера, в секундах
+func (format Json2Format) RenderClock(writer io.Writer, clock *ClockSync) {
+	document := clockSyncJson2{
+		Schema:      Json2Schema,
+		Driver:      format.Driver,
+		Number:      format.Number,
+		Address:     format.Address,
+		Endpoint:    format.Endpoint,
+		Serial:      clock.Serial,
+		TimeRequest: timeJson2(clock.TimeRequest),
+		TimeBefore:  timeJson2(clock.Before),
+		Drift:       quantity(clock.Drift.Seconds(), 64, "s"),
+		Corrected:   clock.Corrected,
+		DryRun:      clock.DryRun,
+		Error:       format.error(),
+	}
+	if clock.Corrected {
+		driftAfter := quantity(clock.DriftAfter.Seconds(), 64, "s")
+		document.TimeAfter = timeJson2(clock.After)
+		document.DriftAfter = &driftAfter
+	}
+	format.print(writer, document)
+}
+
+func (format Json2Format) error() *errorJson2 {
+	if format.Err == nil {
+		return nil
+	}
+	return &errorJson2{Message: format.Err.Error()}
+}
+
+func (format Json2Format) print(writer io.Writer, document interface{}) {
+	bytesResponse, err := json.Marshal(document)
+	if err != nil {
+		fmt.Fprintln(writer, "{}")
+		return
+	}
+	fmt.Fprintln(writer, string(bytesResponse))
+}
+
+// Время в RFC 3339 с часовым поясом. Не заполненное драйвером время не выводится
+func timeJson2(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.Round(0)
+	return &t
+}
+
+func quantity(value float64, bitSize int, unit string) quantityJson2 {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return quantityJson2{Value: json.RawMessage("null"), Unit: unit}
+	}
+	return quantityJson2{Value: json.RawMessage(strconv.FormatFloat(value, 'f', -1, bitSize)), Unit: unit}
+}
+
+func seconds(value uint32) *quantityJson2 {
+	return &quantityJson2{Value: json.RawMessage(strconv.FormatUint(uint64(value), 10)), Unit: "s"}
+}
+
+// Обозначение единиц энергии UCUM, как в формате json2
+func UnitQCode(unitQ UnitQEnum) string {
+	switch unitQ {
+	case MWh:
+		return "MWh"
+	case KWh:
+		return "kWh"
+	case GJ:
+		return "GJ"
+	case Gcal:
+		return "Gcal"
+	}
+	return ""
+}
@@ -0,0 +1,258 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJson2Format(t *testing.T) {
+	zone := time.FixedZone("MSK", 3*3600)
+	moment := time.Date(2024, 3, 15, 12, 34, 56, 0, zone)
+	device := &DataDevice{
+		Serial:      "104001",
+		UnitQ:       Gcal,
+		TimeRequest: moment,
+		Time:        moment.Add(-time.Minute),
+		TimeOn:      3600,
+		Systems: []SystemDevice{
+			{Status: false},
+			{Status: true, SigmaQ: 1234.5, GM1: 1.226, T1: 72.5, P1: float32(math.NaN())},
+		},
+	}
+	format := Json2Format{Driver: "tem104", Number: 1, Endpoint: "192.168.12.1:4001"}
+
+	var output bytes.Buffer
+	format.Render(&output, device)
+	var document map[string]interface{}
+	if err := json.Unmarshal(output.Bytes(), &document); err != nil {
+		t.Fatalf("%v: %s", err, output.String())
+	}
+	if document["schema"] != Json2Schema || document["driver"] != "tem104" || document["endpoint"] != "192.168.12.1:4001" {
+		t.Errorf("сведения об опросе: %s", output.String())
+	}
+	if document["timeDevice"] != "2024-03-15T12:33:56+03:00" {
+		t.Errorf("время прибора: %v", document["timeDevice"])
+	}
+	if _, ok := document["error"]; ok {
+		t.Errorf("раздел error без ошибки: %s", output.String())
+	}
+	systems := document["systems"].([]interface{})
+	if len(systems) != 1 {
+		t.Fatalf("ожидалась одна активная система: %s", output.String())
+	}
+	system := systems[0].(map[string]interface{})
+	for key, expected := range map[string]interface{}{
+		"system": 2.0,
+		"sigmaQ": map[string]interface{}{"value": 1234.5, "unit": "Gcal"},
+		"gm1":    map[string]interface{}{"value": 1.226, "unit": "t/h"},
+		"t1":     map[string]interface{}{"value": 72.5, "unit": "Cel"},
+		"p1":     map[string]interface{}{"value": nil, "unit": "MPa"},
+	} {
+		if !reflect.DeepEqual(system[key], expected) {
+			t.Errorf("%s: %v, ожидалось %v", key, system[key], expected)
+		}
+	}
+
+	// Ошибка до чтения данных
+	output.Reset()
+	format.Err = errors.New("не удалось установить соединение")
+	format.Render(&output, nil)
+	expected := `{"schema":"qbox/data/v2","driver":"tem104","number":1,"endpoint":"192.168.12.1:4001","systems":[],` +
+		`"error":{"message":"не удалось установить соединение"}}` + "\n"
+	if output.String() != expected {
+		t.Errorf("вывод ошибки:\n%s\nожидался\n%s", output.String(), expected)
+	}
+
+	// Частичный результат: показания выводятся с состоянием опроса
+	output.Reset()
+	format.Err = nil
+	device.Status = &PollStatus{State: StatePartial, Step: StepRead, Class: ErrorFrame,
+		Error: "получен некорректный ответ", Valid: device.FilledFields()}
+	format.Render(&output, device)
+	document = nil
+	if err := json.Unmarshal(output.Bytes(), &document); err != nil {
+		t.Fatalf("%v: %s", err, output.String())
+	}
+	status := document["status"].(map[string]interface{})
+	if status["state"] != "partial" || status["class"] != "frame" || len(document["systems"].([]interface{})) != 1 {
+		t.Errorf("частичный результат: %s", output.String())
+	}
+	if document["error"].(map[string]interface{})["message"] != "получен некорректный ответ" {
+		t.Errorf("ошибка частичного результата: %s", output.String())
+	}
+}
+
+// Вывод json2 соответствует опубликованной JSON Schema
+func TestJson2Schema(t *testing.T) {
+	text, err := ioutil.ReadFile(filepath.Join("..", "schema", "qbox-data-v2.schema.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]interface{}
+	if err = json.Unmarshal(text, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	moment := time.Date(2024, 3, 15, 12, 34, 56, 0, time.UTC)
+	format := Json2Format{Driver: "mbus", Number: 5, Address: "12345678", Endpoint: "serial:/dev/ttyUSB0",
+		Tags: map[string]string{"house": "17"}}
+	systems := []SystemDevice{{Status: true, SigmaQ: 1, M3: 2, TMakeup: 3}}
+	var output bytes.Buffer
+	format.Render(&output, &DataDevice{Serial: "1", UnitQ: GJ, TimeRequest: moment, Time: moment, Systems: systems,
+		Detected: &Detection{Driver: "mbus", Protocol: "M-Bus", Model: "KAM MULTICAL"}})
+	format.RenderArchive(&output, &Archive{Serial: "1", Type: ArchiveDaily, UnitQ: MWh, TimeRequest: moment,
+		Records: []ArchiveRecord{{Start: moment, End: moment.Add(24 * time.Hour), Systems: []ArchiveSystem{{Status: true, Tariffs: []float64{1, 2}}}}}})
+	format.RenderClock(&output, &ClockSync{Serial: "1", TimeRequest: moment, Before: moment, Drift: time.Minute,
+		After: moment, Corrected: true})
+	format.Render(&output, &DataDevice{Serial: "1", TimeRequest: moment, Systems: systems,
+		Status: &PollStatus{State: StatePartial, Step: StepRead, Class: ErrorTimeout, Error: "прибор не ответил на запрос",
+			Valid: []string{"serial", "system1.SigmaQ"}}})
+	format.Render(&output, &DataDevice{TimeRequest: moment,
+		Status: &PollStatus{State: StateFailed, Step: StepConnect, Class: ErrorConnect, Error: "соединение не установлено"}})
+	format.Err = errors.New("истекло время опроса")
+	format.Render(&output, nil)
+
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		var document interface{}
+		if err = json.Unmarshal([]byte(line), &document); err != nil {
+			t.Fatal(err)
+		}
+		if err = validate(schema, schema, document); err != nil {
+			t.Errorf("%v:\n%s", err, line)
+		}
+	}
+}
+
+/**
+Проверка документа по JSON Schema. Поддерживаются только ключевые слова, которые используются
+в schema/qbox-data-v2.schema.json
+*/
+func validate(root map[string]interface{}, schema map[string]interface{}, value interface{}) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		definition, ok := root["$defs"].(map[string]interface{})[strings.TrimPrefix(ref, "#/$defs/")]
+		if !ok {
+			return fmt.Errorf("нет определения %s", ref)
+		}
+		return validate(root, definition.(map[string]interface{}), value)
+	}
+	if variants, ok := schema["oneOf"].([]interface{}); ok {
+		matched := 0
+		var errs []string
+		for _, variant := range variants {
+			if err := validate(root, variant.(map[string]interface{}), value); err != nil {
+				errs = append(errs, err.Error())
+			} else {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return fmt.Errorf("oneOf: совпало вариантов %d: %s", matched, strings.Join(errs, "; "))
+		}
+		return nil
+	}
+	if constant, ok := schema["const"]; ok && !reflect.DeepEqual(constant, value) {
+		return fmt.Errorf("ожидается %v, получено %v", constant, value)
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, item := range enum {
+			found = found || reflect.DeepEqual(item, value)
+		}
+		if !found {
+			return fmt.Errorf("значение %v не из списка %v", value, enum)
+		}
+	}
+	if schemaType, ok := schema["type"]; ok {
+		types, ok := schemaType.([]interface{})
+		if !ok {
+			types = []interface{}{schemaType}
+		}
+		found := false
+		for _, item := range types {
+			found = found || jsonType(value, item.(string))
+		}
+		if !found {
+			return fmt.Errorf("значение %v не типа %v", value, schemaType)
+		}
+	}
+	if schema["format"] == "date-time" {
+		if _, err := time.Parse(time.RFC3339, value.(string)); err != nil {
+			return err
+		}
+	}
+	if number, ok := value.(float64); ok {
+		if minimum, ok := schema["minimum"].(float64); ok && number < minimum {
+			return fmt.Errorf("%v меньше %v", number, minimum)
+		}
+		if maximum, ok := schema["maximum"].(float64); ok && number > maximum {
+			return fmt.Errorf("%v больше %v", number, maximum)
+		}
+	}
+	if items, ok := value.([]interface{}); ok && schema["items"] != nil {
+		for i, item := range items {
+			if err := validate(root, schema["items"].(map[string]interface{}), item); err != nil {
+				return fmt.Errorf("[%d]: %w", i, err)
+			}
+		}
+	}
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	required, _ := schema["required"].([]interface{})
+	for _, key := range required {
+		if _, ok := object[key.(string)]; !ok {
+			return fmt.Errorf("нет обязательного поля %s", key)
+		}
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	for key, item := range object {
+		property, ok := properties[key]
+		if !ok {
+			additional, ok := schema["additionalProperties"].(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("поле %s не описано", key)
+			}
+			property = additional
+		}
+		if err := validate(root, property.(map[string]interface{}), item); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func jsonType(value interface{}, name string) bool {
+	switch name {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		number, ok := value.(float64)
+		return ok && number == math.Trunc(number)
+	case "null":
+		return value == nil
+	}
+	return false
+}
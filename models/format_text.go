@@ -3,30 +3,35 @@ package models
 import (
 	"fmt"
 	"io"
+	"strings"
+	"time"
 )
 
 type TextFormat struct {
 }
 
 func (format TextFormat) Render(writer io.Writer, device *DataDevice) {
+	if device.Status != nil {
+		renderStatusText(writer, device.Status)
+		if device.Status.State == StateFailed {
+			fmt.Fprintln(writer, "")
+			return
+		}
+	}
+	if device.Detected != nil {
+		fmt.Fprintf(writer, "Определён прибор - %s, протокол %s, драйвер %s\n",
+			device.Detected.Model, device.Detected.Protocol, device.Detected.Driver)
+		if device.Detected.Firmware != "" {
+			fmt.Fprintf(writer, "Версия ПО прибора - %s\n", device.Detected.Firmware)
+		}
+	}
 	fmt.Fprintf(writer, "Заводской номер прибора - %v\n", device.Serial)
 	fmt.Fprintf(writer, "Время опроса - %s\n", device.TimeRequest.Format("02.01.2006 15:04:05"))
 	fmt.Fprintf(writer, "Время на приборе - %s\n", device.Time.Format("02.01.2006 15:04:05"))
 	fmt.Fprintf(writer, "Время работы при включенном питании - %f ч\n", float32(device.TimeOn)/3600.00)
 	fmt.Fprintf(writer, "Время работы без ошибок - %f ч\n", float32(device.TimeRunCommon)/3600.00)
 
-	var textUnitQ string
-
-	switch device.UnitQ {
-	case MWh:
-		textUnitQ = "МВт"
-	case KWh:
-		textUnitQ = "КВт"
-	case GJ:
-		textUnitQ = "ГДж"
-	case Gcal:
-		textUnitQ = "ГКал"
-	}
+	textUnitQ := unitQText(device.UnitQ)
 	for i, system := range device.Systems {
 		if system.Status == false {
 			continue
@@ -51,8 +56,123 @@ func (format TextFormat) Render(writer io.Writer, device *DataDevice) {
 		fmt.Fprintf(writer, "P1 %f МПа\n", system.P1)
 		fmt.Fprintf(writer, "P2 %f МПа\n", system.P2)
 		fmt.Fprintf(writer, "P3 %f МПа\n", system.P3)
+		if system.M3 != 0 || system.GM3 != 0 || system.GV3 != 0 {
+			fmt.Fprintf(writer, "M3 подпитки %f тонн\n", system.M3)
+			fmt.Fprintf(writer, "G3 массовый подпитки %f тонн/ч\n", system.GM3)
+			fmt.Fprintf(writer, "G3 объёмный подпитки %f м3/ч\n", system.GV3)
+			fmt.Fprintf(writer, "T подпитки %f C\n", system.TMakeup)
+			fmt.Fprintf(writer, "P подпитки %f МПа\n", system.PMakeup)
+		}
 		fmt.Fprintf(writer, "Время работы системы (без ошибок) № %d - %f ч\n", i+1, float32(system.TimeRunSys)/3600.00)
 	}
 
 	fmt.Fprintln(writer, "")
 }
+
+func (format TextFormat) RenderArchive(writer io.Writer, archive *Archive) {
+	fmt.Fprintf(writer, "Заводской номер прибора - %v\n", archive.Serial)
+	fmt.Fprintf(writer, "Архив - %s\n", archiveTypeText(archive.Type))
+	fmt.Fprintf(writer, "Время опроса - %s\n", archive.TimeRequest.Format("02.01.2006 15:04:05"))
+	fmt.Fprintf(writer, "Записей - %d\n", len(archive.Records))
+
+	textUnitQ := unitQText(archive.UnitQ)
+	for _, record := range archive.Records {
+		fmt.Fprintln(writer, "")
+		fmt.Fprintf(writer, "Запись за период %s - %s:\n", record.Start.Format("02.01.2006 15:04"), record.End.Format("02.01.2006 15:04"))
+		fmt.Fprintf(writer, "Время работы при включенном питании - %f ч\n", float32(record.TimeOn)/3600.00)
+		fmt.Fprintf(writer, "Время отсутствия питания - %f ч\n", float32(record.TimeOff)/3600.00)
+		for i, system := range record.Systems {
+			if system.Status == false {
+				continue
+			}
+			fmt.Fprintf(writer, "Показания системы %d:\n", i+1)
+			fmt.Fprintf(writer, "Q результирующее %f %s\n", system.SigmaQ, textUnitQ)
+			fmt.Fprintf(writer, "Q1 %f %s\n", system.Q1, textUnitQ)
+			fmt.Fprintf(writer, "Q2 %f %s\n", system.Q2, textUnitQ)
+			fmt.Fprintf(writer, "Q3 %f %s\n", system.Q3, textUnitQ)
+			for t, q := range system.Tariffs {
+				fmt.Fprintf(writer, "Q тариф %d %f %s\n", t+1, q, textUnitQ)
+			}
+			fmt.Fprintf(writer, "V1 %f м3\n", system.V1)
+			fmt.Fprintf(writer, "V2 %f м3\n", system.V2)
+			fmt.Fprintf(writer, "M1 %f тонн\n", system.M1)
+			fmt.Fprintf(writer, "M2 %f тонн\n", system.M2)
+			fmt.Fprintf(writer, "T1 средняя %f C\n", system.T1)
+			fmt.Fprintf(writer, "T2 средняя %f C\n", system.T2)
+			fmt.Fprintf(writer, "P1 среднее %f МПа\n", system.P1)
+			fmt.Fprintf(writer, "P2 среднее %f МПа\n", system.P2)
+			if system.M3 != 0 {
+				fmt.Fprintf(writer, "M3 подпитки %f тонн\n", system.M3)
+				fmt.Fprintf(writer, "T подпитки средняя %f C\n", system.TMakeup)
+				fmt.Fprintf(writer, "P подпитки среднее %f МПа\n", system.PMakeup)
+			}
+			fmt.Fprintf(writer, "Время работы без ошибок - %f ч\n", float32(system.TimeRunSys)/3600.00)
+			fmt.Fprintf(writer, "Время работы с ошибками - %f ч\n", float32(system.TimeError)/3600.00)
+			fmt.Fprintf(writer, "Время G < Gmin - %f ч\n", float32(system.TimeGMin)/3600.00)
+			fmt.Fprintf(writer, "Время G > Gmax - %f ч\n", float32(system.TimeGMax)/3600.00)
+			fmt.Fprintf(writer, "Время dT < dTmin - %f ч\n", float32(system.TimeDT)/3600.00)
+			fmt.Fprintf(writer, "Время технической неисправности - %f ч\n", float32(system.TimeFault)/3600.00)
+		}
+	}
+	fmt.Fprintln(writer, "")
+}
+
+func (format TextFormat) RenderClock(writer io.Writer, clock *ClockSync) {
+	fmt.Fprintf(writer, "Заводской номер прибора - %v\n", clock.Serial)
+	fmt.Fprintf(writer, "Время опроса - %s\n", clock.TimeRequest.Format("02.01.2006 15:04:05"))
+	fmt.Fprintf(writer, "Время на приборе до коррекции - %s\n", clock.Before.Format("02.01.2006 15:04:05"))
+	fmt.Fprintf(writer, "Расхождение до коррекции - %s\n", clock.Drift.Round(time.Second).String())
+	switch {
+	case clock.Corrected:
+		fmt.Fprintf(writer, "Время на приборе после коррекции - %s\n", clock.After.Format("02.01.2006 15:04:05"))
+		fmt.Fprintf(writer, "Расхождение после коррекции - %s\n", clock.DriftAfter.Round(time.Second).String())
+	case clock.DryRun:
+		fmt.Fprintln(writer, "Пробный запуск, время на приборе не изменено")
+	default:
+		fmt.Fprintln(writer, "Время на приборе не изменено")
+	}
+
+	fmt.Fprintln(writer, "")
+}
+
+// Состояние опроса. При частичном результате - список достоверных полей
+func renderStatusText(writer io.Writer, status *PollStatus) {
+	fmt.Fprintf(writer, "Статус опроса - %s\n", statusText(status.State))
+	if status.State == StateSuccess {
+		return
+	}
+	if status.Step != "" {
+		fmt.Fprintf(writer, "Шаг опроса с ошибкой - %s\n", status.Step)
+	}
+	fmt.Fprintf(writer, "Класс ошибки - %s\n", status.Class)
+	fmt.Fprintf(writer, "Ошибка - %s\n", status.Error)
+	if status.State == StatePartial {
+		fmt.Fprintf(writer, "Достоверные данные - %s\n", strings.Join(status.Valid, ", "))
+	}
+}
+
+func unitQText(unitQ UnitQEnum) string {
+	switch unitQ {
+	case MWh:
+		return "МВт"
+	case KWh:
+		return "КВт"
+	case GJ:
+		return "ГДж"
+	case Gcal:
+		return "ГКал"
+	}
+	return ""
+}
+
+func archiveTypeText(archive ArchiveType) string {
+	switch archive {
+	case ArchiveHourly:
+		return "часовой"
+	case ArchiveDaily:
+		return "суточный"
+	case ArchiveMonthly:
+		return "месячный"
+	}
+	return string(archive)
+}
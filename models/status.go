@@ -0,0 +1,109 @@
+package models
+
+import "fmt"
+
+// Итог опроса теплосчётчика
+type PollState string
+
+const (
+	StateSuccess PollState = "success" // Данные прочитаны полностью
+	StatePartial PollState = "partial" // Опрос прерван ошибкой, часть данных прочитана, см. PollStatus::Valid
+	StateFailed  PollState = "failed"  // Данные не прочитаны
+)
+
+// Шаг опроса, на котором произошла ошибка
+type PollStep string
+
+const (
+	StepConnect PollStep = "connect" // Подключение к шлюзу или порту
+	StepDetect  PollStep = "detect"  // Определение драйвера (-type=auto)
+	StepSelect  PollStep = "select"  // Выбор прибора M-Bus по вторичному адресу
+	StepInit    PollStep = "init"    // Инициализация драйвера
+	StepRead    PollStep = "read"    // Чтение текущих данных
+	StepSetTime PollStep = "setTime" // Запись времени на прибор (-sync-time)
+)
+
+// Класс ошибки опроса
+type ErrorClass string
+
+const (
+	ErrorConnect     ErrorClass = "connect"     // Соединение не установлено или разорвано
+	ErrorTimeout     ErrorClass = "timeout"     // Прибор не ответил, либо истекло время опроса
+	ErrorFrame       ErrorClass = "frame"       // Кадр ответа не прошёл проверку драйвера: контрольная сумма, заголовок, длина
+	ErrorProtocol    ErrorClass = "protocol"    // Ответ прибора не соответствует ожиданиям драйвера
+	ErrorInterrupted ErrorClass = "interrupted" // Опрос отменён, например, по сигналу SIGINT
+	ErrorConfig      ErrorClass = "config"      // Ошибка настройки: неизвестный драйвер, недопустимые значения, возможность не поддерживается драйвером
+	ErrorDriver      ErrorClass = "driver"      // Внутренняя ошибка драйвера (panic), перехваченная сеансом опроса
+)
+
+/**
+Состояние опроса: итог, шаг и класс ошибки, и поля, которым можно доверять.
+При успешном опросе достоверны все поля, при ошибке без данных - ни одно.
+При частичном результате достоверны поля, заполненные драйвером до ошибки, см. DataDevice::FilledFields,
+остальные значения не прочитаны, а не равны нулю. Нулевое значение достоверного поля - прочитанный ноль.
+*/
+type PollStatus struct {
+	State PollState
+	Step  PollStep   // Пустой - ошибки нет либо шаг не известен
+	Class ErrorClass // Пустой - ошибки нет
+	Error string
+	Valid []string // Достоверные поля частичного результата
+}
+
+/**
+Поля, заполненные драйвером. Серийный номер и время прибора - если заданы. Показания - по системам,
+которые драйвер отметил активными (SystemDevice::Status): все поля системы, в том числе нулевые,
+так как нулевое показание (расход остановленного контура, энергия нового прибора) - прочитанное значение.
+Время включения и работы прибора - если есть активная система. Драйверы заполняют данные только по ответам,
+прошедшим проверку, но отдельные записи полей не отслеживаются: при обрыве чтения системы часть её полей
+может остаться не прочитанной.
+Имена полей - как в формате json, показания систем - с номером системы: system1.SigmaQ.
+*/
+func (dataDevice *DataDevice) FilledFields() []string {
+	var fields []string
+	if dataDevice.Serial != "" {
+		fields = append(fields, "serial")
+	}
+	if !dataDevice.Time.IsZero() {
+		fields = append(fields, "timeDevice")
+	}
+	var systems []string
+	for i, system := range dataDevice.Systems {
+		if system.Status == false {
+			continue
+		}
+		for _, name := range SystemFields {
+			systems = append(systems, fmt.Sprintf("system%d.%s", i+1, name))
+		}
+	}
+	if len(systems) > 0 {
+		fields = append(fields, "timeOn", "timeRunCommon")
+	}
+	return append(fields, systems...)
+}
+
+// Показания системы в списке достоверных полей, см. FilledFields
+var SystemFields = []string{
+	"timeRunSys", "SigmaQ", "Q1", "Q2", "Q3", "V1", "V2", "M1", "M2", "GM1", "GM2", "GV1", "GV2",
+	"T1", "T2", "T3", "P1", "P2", "P3", "M3", "GM3", "GV3", "TMakeup", "PMakeup",
+}
+
+type pollStatusJson struct {
+	State PollState  `json:"state"`
+	Step  PollStep   `json:"step,omitempty"`
+	Class ErrorClass `json:"class,omitempty"`
+	Error string     `json:"error,omitempty"`
+	Valid []string   `json:"valid,omitempty"`
+}
+
+func statusText(state PollState) string {
+	switch state {
+	case StateSuccess:
+		return "успешно"
+	case StatePartial:
+		return "частично"
+	case StateFailed:
+		return "ошибка"
+	}
+	return string(state)
+}
@@ -0,0 +1,159 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"qBox/services/config"
+	"qBox/services/log"
+	"qBox/services/net"
+	"qBox/services/poll"
+	"sync"
+	"time"
+)
+
+/**
+Пакетный опрос теплосчётчиков.
+Задания группируются по адресу шлюза (см. net.GatewayAddress). Группы опрашиваются параллельно,
+не более Workers одновременно, а задания одной группы - последовательно через одно соединение,
+т.к. линия RS-485 за шлюзом не допускает одновременных запросов.
+*/
+type Runner struct {
+	Workers int               // Количество одновременно опрашиваемых шлюзов
+	Timeout time.Duration     // Ограничение времени опроса одного теплосчётчика, 0 - без ограничения
+	Logger  log.LoggerService // Общий лог, сообщения каждого задания помечаются его номером
+}
+
+// Опрос всех заданий. handle вызывается по одному разу для каждого задания, вызовы не пересекаются.
+// При отмене ctx оставшиеся задания завершаются с ошибкой *net.InterruptedError.
+func (runner Runner) Run(ctx context.Context, jobs []Job, handle func(Result)) {
+	var mutex sync.Mutex
+	report := func(result Result) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		handle(result)
+	}
+
+	var gateways []string
+	groups := make(map[string][]Job)
+	for _, job := range jobs {
+		gateway, err := net.GatewayAddress(job.Endpoint)
+		if err != nil {
+			runner.Logger.Check("batch")
+			runner.Logger.Error("#%d %s", job.Index, err.Error())
+			report(Result{Job: job, TimeStart: time.Now(), Err: err})
+			continue
+		}
+		if _, ok := groups[gateway]; !ok {
+			gateways = append(gateways, gateway)
+		}
+		groups[gateway] = append(groups[gateway], job)
+	}
+
+	workers := runner.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	queue := make(chan []Job)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range queue {
+				runner.pollGateway(ctx, group, report)
+			}
+		}()
+	}
+	for _, gateway := range gateways {
+		queue <- groups[gateway]
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// Последовательный опрос теплосчётчиков за одним шлюзом.
+// Соединение используется повторно, пока строка подключения заданий не меняется.
+func (runner Runner) pollGateway(ctx context.Context, jobs []Job, report func(Result)) {
+	var network *net.Network
+	var endpoint string
+
+	closeNetwork := func() {
+		if network != nil && network.IsConnected() {
+			_ = network.Close()
+		}
+		network = nil
+	}
+	defer closeNetwork()
+
+	for _, job := range jobs {
+		logger := runner.Logger.WithPrefix(fmt.Sprintf("#%d ", job.Index))
+
+		if network == nil || job.Endpoint != endpoint {
+			closeNetwork()
+			transport, err := net.NewTransport(job.Endpoint)
+			if err != nil {
+				logger.Check("batch")
+				logger.Error(err.Error())
+				report(Result{Job: job, TimeStart: time.Now(), Err: err})
+				continue
+			}
+			network = net.NewNetwork(transport, logger)
+			endpoint = job.Endpoint
+		}
+		network.SetLogger(logger)
+
+		before := network.Stats()
+		result := runner.poll(ctx, job, network, logger)
+		result.IO = network.Stats().Sub(before)
+		if errors.As(result.Err, new(*net.InterruptedError)) {
+			// Ответ на прерванный запрос может прийти позже и быть принят за ответ следующему теплосчётчику
+			closeNetwork()
+		}
+		report(result)
+	}
+}
+
+func (runner Runner) poll(ctx context.Context, job Job, network *net.Network, logger log.LoggerService) Result {
+	result := Result{Job: job, TimeStart: time.Now()}
+
+	if runner.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, runner.Timeout)
+		defer cancel()
+	}
+
+	unitQ, err := config.UnitQ(job.Unit)
+	if err == nil && job.Number > 0xFF {
+		err = errors.New("номер теплосчётчика может принимать значения от 0 до 255")
+	}
+	if err != nil {
+		err = &poll.ConfigError{Err: err}
+	}
+	if err == nil {
+		logger.Check("batch")
+		logger.Info("Опрос теплосчётчика %d, %s", job.Number, job.Endpoint)
+		session := poll.Session{
+			Driver:        string(job.Driver),
+			CounterNumber: byte(job.Number),
+			Address:       job.Address,
+			Network:       network,
+			Logger:        logger,
+		}
+		result.Data, err = session.Poll(ctx)
+	}
+	if err != nil {
+		logger.Check("batch")
+		logger.Error(err.Error())
+	} else {
+		result.Data.ChangeUnitQ(unitQ)
+	}
+	if result.Data != nil {
+		result.Data.Status = poll.NewStatus(result.Data, err)
+	}
+
+	result.Err = err
+	result.Duration = time.Since(result.TimeStart)
+	return result
+}
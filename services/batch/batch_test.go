@@ -0,0 +1,173 @@
+package batch
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	stdnet "net"
+	"qBox/models"
+	"qBox/services/log"
+	"qBox/services/net"
+	"qBox/services/poll"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ozzolog "github.com/go-ozzo/ozzo-log"
+)
+
+/**
+Шлюз с приборами ТЭМ-104 на шине: на чтение памяти отвечает нулями, в памяти таймера 2К по адресу 7Ch -
+заводской номер прибора. Подключения подсчитываются: задания за одним шлюзом должны опрашиваться
+через одно соединение.
+*/
+type temGateway struct {
+	serials  map[byte]uint32 // Заводские номера по номеру прибора
+	accepted int32
+}
+
+func (gateway *temGateway) serve(connection stdnet.Conn) {
+	defer connection.Close()
+	var buffer []byte
+	frame := make([]byte, 256)
+	for {
+		n, err := connection.Read(frame)
+		if err != nil {
+			return
+		}
+		buffer = append(buffer, frame[:n]...)
+		for len(buffer) >= 6 && len(buffer) >= 7+int(buffer[5]) {
+			length := 7 + int(buffer[5])
+			if response := gateway.handle(buffer[:length]); response != nil {
+				_, _ = connection.Write(response)
+			}
+			buffer = buffer[length:]
+		}
+	}
+}
+
+func (gateway *temGateway) handle(request []byte) []byte {
+	serial, ok := gateway.serials[request[1]]
+	if !ok || request[0] != 0x55 {
+		return nil
+	}
+	payload := request[6 : len(request)-1]
+	var address, size int
+	switch len(payload) {
+	case 2:
+		address, size = int(payload[0]), int(payload[1])
+	case 3:
+		address, size = int(payload[0])<<8|int(payload[1]), int(payload[2])
+	default:
+		return nil
+	}
+	data := make([]byte, size)
+	if request[3] == 0x0F && request[4] == 0x01 && address <= 0x7C && address+size >= 0x80 {
+		binary.BigEndian.PutUint32(data[0x7C-address:], serial)
+	}
+	response := append([]byte{0xAA, request[1], request[2], request[3], request[4], byte(size)}, data...)
+	var sum byte
+	for _, b := range response {
+		sum += b
+	}
+	return append(response, ^sum)
+}
+
+// Запуск шлюза, возвращает строку подключения
+func startGateway(t *testing.T, serials map[byte]uint32) (string, *temGateway) {
+	listener, err := stdnet.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gateway := &temGateway{serials: serials}
+	go func() {
+		for {
+			connection, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&gateway.accepted, 1)
+			go gateway.serve(connection)
+		}
+	}()
+	t.Cleanup(func() { _ = listener.Close() })
+	return listener.Addr().String(), gateway
+}
+
+func TestRun(t *testing.T) {
+	endpointA, gatewayA := startGateway(t, map[byte]uint32{1: 104001, 2: 104002})
+	endpointB, gatewayB := startGateway(t, map[byte]uint32{1: 104003})
+
+	jobs := []Job{
+		{Index: 1, Endpoint: endpointA, Driver: "tem104", Number: 1},
+		{Index: 2, Endpoint: endpointB, Driver: "tem104", Number: 1},
+		{Index: 3, Endpoint: endpointA, Driver: "2", Number: 2},
+		{Index: 4, Endpoint: "192.168.12.1:порт", Driver: "tem104", Number: 1},
+		{Index: 5, Endpoint: endpointA, Driver: "нет такого", Number: 1},
+		{Index: 6, Endpoint: endpointA, Driver: "tem104", Number: 300},
+	}
+	runner := Runner{Workers: 2, Timeout: 10 * time.Second, Logger: log.NewLoggerService(ozzolog.NewLogger())}
+
+	var active int32
+	results := make(map[int]Result)
+	var mutex sync.Mutex
+	runner.Run(context.Background(), jobs, func(result Result) {
+		if atomic.AddInt32(&active, 1) != 1 {
+			t.Error("вызовы handle пересекаются")
+		}
+		defer atomic.AddInt32(&active, -1)
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		if _, ok := results[result.Job.Index]; ok {
+			t.Errorf("задание %d получено повторно", result.Job.Index)
+		}
+		results[result.Job.Index] = result
+	})
+
+	if len(results) != len(jobs) {
+		t.Fatalf("результатов %d, заданий %d", len(results), len(jobs))
+	}
+	for index, serial := range map[int]string{1: "104001", 2: "104003", 3: "104002"} {
+		result := results[index]
+		if result.Err != nil || result.Data == nil || result.Data.Serial != serial {
+			t.Errorf("задание %d: %v, данные %+v", index, result.Err, result.Data)
+		}
+	}
+	if !errors.As(results[4].Err, new(*net.EndpointError)) {
+		t.Errorf("задание 4: ожидалась ошибка строки подключения, получено %v", results[4].Err)
+	}
+	for _, index := range []int{5, 6} {
+		if results[index].Data != nil || poll.Classify(results[index].Err) != models.ErrorConfig {
+			t.Errorf("задание %d: ожидалась ошибка настройки без данных, получено %v", index, results[index].Err)
+		}
+	}
+	if accepted := atomic.LoadInt32(&gatewayA.accepted); accepted != 1 {
+		t.Errorf("подключений к шлюзу A %d, ожидалось одно на все задания шлюза", accepted)
+	}
+	if accepted := atomic.LoadInt32(&gatewayB.accepted); accepted != 1 {
+		t.Errorf("подключений к шлюзу B %d", accepted)
+	}
+}
+
+func TestRunInterrupted(t *testing.T) {
+	endpoint, _ := startGateway(t, map[byte]uint32{1: 104001})
+	jobs := []Job{
+		{Index: 1, Endpoint: endpoint, Driver: "tem104", Number: 1},
+		{Index: 2, Endpoint: endpoint, Driver: "tem104", Number: 1},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	count := 0
+	Runner{Logger: log.NewLoggerService(ozzolog.NewLogger())}.Run(ctx, jobs, func(result Result) {
+		count++
+		if !errors.As(result.Err, new(*net.InterruptedError)) {
+			t.Errorf("задание %d: ожидалось прерывание, получено %v", result.Job.Index, result.Err)
+		}
+	})
+	if count != len(jobs) {
+		t.Errorf("результатов %d, заданий %d", count, len(jobs))
+	}
+}
@@ -0,0 +1,76 @@
+package batch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+/**
+Задание на опрос одного теплосчётчика
+*/
+type Job struct {
+	Index    int               `json:"-"`        // Порядковый номер задания в файле, начиная с 1
+	Endpoint string            `json:"endpoint"` // Строка подключения, как и у аргумента командной строки
+	Driver   DriverRef         `json:"driver"`   // Имя или номер драйвера, как у флага type
+	Number   uint              `json:"number"`   // Номер теплосчётчика
+	Address  string            `json:"address"`  // Вторичный адрес прибора M-Bus, как у флага address
+	Unit     uint              `json:"unit"`     // Единицы измерения энергии, как у флага unitQ
+	Tags     map[string]string `json:"tags"`     // Произвольные метки, без изменений переносятся в результат
+}
+
+// Имя или номер драйвера. В файле заданий может быть задан строкой ("tem104") или числом (2)
+type DriverRef string
+
+func (ref *DriverRef) UnmarshalJSON(b []byte) error {
+	var number json.Number
+	if json.Unmarshal(b, &number) == nil {
+		*ref = DriverRef(number.String())
+		return nil
+	}
+	var name string
+	err := json.Unmarshal(b, &name)
+	if err != nil {
+		return errors.New("драйвер задаётся именем или номером")
+	}
+	*ref = DriverRef(name)
+	return nil
+}
+
+// Чтение заданий из JSON файла. См. ReadJobs
+func LoadJobs(path string, defaults Job) ([]Job, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadJobs(file, defaults)
+}
+
+// Чтение заданий. Ожидается JSON массив объектов с полями endpoint, driver, number, address, unit, tags.
+// Поля, не заданные в задании, берутся из defaults.
+// Ошибка возвращается только для некорректного JSON, проверка самих заданий выполняется при опросе,
+// чтобы по каждому заданию был получен результат.
+func ReadJobs(reader io.Reader, defaults Job) ([]Job, error) {
+	var items []json.RawMessage
+	err := json.NewDecoder(reader).Decode(&items)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный файл заданий: %w", err)
+	}
+
+	jobs := make([]Job, 0, len(items))
+	for i, item := range items {
+		job := defaults
+		job.Tags = nil
+		err = json.Unmarshal(item, &job)
+		if err != nil {
+			return nil, fmt.Errorf("некорректное задание %d: %w", i+1, err)
+		}
+		job.Index = i + 1
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
@@ -0,0 +1,45 @@
+package batch
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadJobs(t *testing.T) {
+	defaults := Job{Endpoint: "192.168.12.1:4001", Driver: "tem104", Unit: 2, Tags: map[string]string{"default": "1"}}
+	jobs, err := ReadJobs(strings.NewReader(`[
+		{"number": 1, "tags": {"house": "Ленина 17"}},
+		{"endpoint": "192.168.12.2:4001", "driver": 9, "number": 2},
+		{"driver": "skm2", "number": 3, "unit": 0}
+	]`), defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Job{
+		{Index: 1, Endpoint: "192.168.12.1:4001", Driver: "tem104", Number: 1, Unit: 2,
+			Tags: map[string]string{"house": "Ленина 17"}},
+		{Index: 2, Endpoint: "192.168.12.2:4001", Driver: "9", Number: 2, Unit: 2},
+		{Index: 3, Endpoint: "192.168.12.1:4001", Driver: "skm2", Number: 3},
+	}
+	if !reflect.DeepEqual(jobs, expected) {
+		t.Errorf("задания %+v, ожидалось %+v", jobs, expected)
+	}
+	if defaults.Tags["default"] != "1" || len(defaults.Tags) != 1 {
+		t.Errorf("метки по умолчанию изменены: %v", defaults.Tags)
+	}
+}
+
+func TestReadJobsInvalid(t *testing.T) {
+	for document, message := range map[string]string{
+		`{"number": 1}`:                      "некорректный файл заданий",
+		`[{"number": 1}, {"number": "два"}]`: "некорректное задание 2",
+		`[{"driver": true}]`:                 "драйвер задаётся именем или номером",
+		`[{"number": 1}`:                     "некорректный файл заданий",
+	} {
+		_, err := ReadJobs(strings.NewReader(document), Job{})
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("%s: ошибка %v, ожидалось %q", document, err, message)
+		}
+	}
+}
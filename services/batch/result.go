@@ -0,0 +1,163 @@
+package batch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"qBox/drivers"
+	"qBox/models"
+	"qBox/services/net"
+	"qBox/services/poll"
+	"sort"
+	"time"
+)
+
+/**
+Результат опроса по заданию
+*/
+type Result struct {
+	Job       Job
+	Data      *models.DataDevice // Данные теплосчётчика. При ошибке могут быть прочитаны частично или отсутствовать
+	Err       error
+	TimeStart time.Time
+	Duration  time.Duration
+	IO        net.Stats // Счётчики обмена за время опроса задания
+}
+
+// Вывод результата одной записью. Для формата json - объект в одну строку (JSON Lines),
+// в котором данные теплосчётчика представлены так же, как при опросе одного теплосчётчика.
+// Для формата json2 - документ json2 со сведениями о задании.
+// Для формата csv выводятся только строки систем, заголовок выводится один раз до начала опроса.
+// Для формата influx - строки систем с драйвером и метками задания.
+// Данные выводятся с состоянием опроса, в том числе при ошибке без данных, см. poll.NewStatus.
+func (result Result) Render(writer io.Writer, formatter models.Formatter) {
+	switch format := formatter.(type) {
+	case *models.JsonFormat, models.JsonFormat:
+		result.renderJson(writer, formatter)
+	case *models.Json2Format, models.Json2Format:
+		result.Json2Format().Render(writer, result.data())
+	case *models.InfluxFormat:
+		result.renderInflux(writer, *format)
+	case models.InfluxFormat:
+		result.renderInflux(writer, format)
+	case *models.CsvFormat, models.CsvFormat:
+		// Только строки данных, чтобы результаты заданий складывались в одну таблицу
+		formatter.Render(writer, result.data())
+	default:
+		result.renderText(writer, formatter)
+	}
+}
+
+type resultJson struct {
+	Job       int               `json:"job"`
+	Endpoint  string            `json:"endpoint"`
+	Driver    DriverRef         `json:"driver"`
+	Number    uint              `json:"number"`
+	Address   string            `json:"address,omitempty"`
+	Tags      map[string]string `json:"tags,omitempty"`
+	TimeStart models.JSONTime   `json:"timeStart"`
+	Duration  float64           `json:"duration"` // секунды
+	Error     string            `json:"error,omitempty"`
+	Data      json.RawMessage   `json:"data,omitempty"`
+}
+
+func (result Result) renderJson(writer io.Writer, formatter models.Formatter) {
+	record := resultJson{
+		Job:       result.Job.Index,
+		Endpoint:  result.Job.Endpoint,
+		Driver:    result.Job.Driver,
+		Number:    result.Job.Number,
+		Address:   result.Job.Address,
+		Tags:      result.Job.Tags,
+		TimeStart: models.JSONTime(result.TimeStart),
+		Duration:  result.Duration.Seconds(),
+	}
+	if result.Err != nil {
+		record.Error = result.Err.Error()
+	}
+	var buffer bytes.Buffer
+	formatter.Render(&buffer, result.data())
+	record.Data = bytes.TrimSpace(buffer.Bytes())
+
+	bytesResponse, err := json.Marshal(record)
+	if err != nil {
+		fmt.Fprintln(writer, "{}")
+		return
+	}
+	fmt.Fprintln(writer, string(bytesResponse))
+}
+
+// Формат json2 задания: сведения об опросе берутся из задания, ошибка выводится в разделе error
+func (result Result) Json2Format() models.Json2Format {
+	return models.Json2Format{
+		Driver:   result.driverName(),
+		Number:   byte(result.Job.Number),
+		Address:  result.Job.Address,
+		Endpoint: result.Job.Endpoint,
+		Tags:     result.Job.Tags,
+		Err:      result.Err,
+	}
+}
+
+// Строки influx задания: метки задания дополняют метки формата
+func (result Result) renderInflux(writer io.Writer, format models.InfluxFormat) {
+	format.Driver = result.driverName()
+	if len(result.Job.Tags) > 0 {
+		tags := make(map[string]string, len(format.Tags)+len(result.Job.Tags))
+		for key, value := range format.Tags {
+			tags[key] = value
+		}
+		for key, value := range result.Job.Tags {
+			tags[key] = value
+		}
+		format.Tags = tags
+	}
+	format.Render(writer, result.data())
+}
+
+// Имя драйвера задания, заданного именем или номером
+func (result Result) driverName() string {
+	if driver, err := drivers.Lookup(string(result.Job.Driver)); err == nil {
+		return driver.Name
+	}
+	return string(result.Job.Driver)
+}
+
+func (result Result) renderText(writer io.Writer, formatter models.Formatter) {
+	if result.Job.Address != "" {
+		fmt.Fprintf(writer, "Задание %d: %s, драйвер %s, вторичный адрес %s\n",
+			result.Job.Index, result.Job.Endpoint, result.Job.Driver, result.Job.Address)
+	} else {
+		fmt.Fprintf(writer, "Задание %d: %s, драйвер %s, номер %d\n",
+			result.Job.Index, result.Job.Endpoint, result.Job.Driver, result.Job.Number)
+	}
+
+	if len(result.Job.Tags) > 0 {
+		keys := make([]string, 0, len(result.Job.Tags))
+		for key := range result.Job.Tags {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fmt.Fprint(writer, "Метки:")
+		for _, key := range keys {
+			fmt.Fprintf(writer, " %s=%s", key, result.Job.Tags[key])
+		}
+		fmt.Fprintln(writer, "")
+	}
+
+	formatter.Render(writer, result.data())
+}
+
+// Данные для вывода с состоянием опроса. При ошибке без данных - только время и состояние опроса
+func (result Result) data() *models.DataDevice {
+	if result.Data != nil && result.Data.Status != nil {
+		return result.Data
+	}
+	data := &models.DataDevice{TimeRequest: result.TimeStart}
+	if result.Data != nil {
+		data = result.Data.Clone()
+	}
+	data.Status = poll.NewStatus(result.Data, result.Err)
+	return data
+}
@@ -4,47 +4,85 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"net/url"
 	"os"
 	"qBox/drivers"
-	"qBox/drivers/skm2"
-	"qBox/drivers/skm2m"
-	"qBox/drivers/tem104k"
-	"qBox/drivers/tem104m"
+	_ "qBox/drivers/mbusgeneric"
+	_ "qBox/drivers/skm2"
+	_ "qBox/drivers/skm2m"
+	_ "qBox/drivers/tem104k"
+	_ "qBox/drivers/tem104m"
 	"qBox/models"
+	"qBox/services/influx"
+	"qBox/services/mqtt"
+	"qBox/services/raw"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 )
 
-// Карта зарегистрированных драйверов.
-// Примечание: Добавляя новые драйвера, необходимо добавить описание в HELP для флага type
-var driversMap = [15]models.IDeviceDriver{
-	new(skm2.SKM),
-	new(drivers.SKU02B),
-	new(drivers.Tem104),
-	new(drivers.TEM05OLD),
-	new(drivers.SKU02),
-	new(drivers.TM3),
-	new(drivers.TEM104M1),
-	new(drivers.Tem104s1),
-	new(drivers.TESMART01),
-	new(drivers.SKU02K),
-	new(drivers.SKU02B7B),
-	new(tem104m.TEM104M),
-	new(tem104k.Tem104K),
-	new(drivers.TEM104M2),
-	new(skm2m.SKM),
-}
-
 const VersionCoreApp = "0.0.5"
 
 type Config struct {
 	log           bool
 	dev           bool
-	hostPort      string
-	deviceType    int
+	endpoint      string
+	deviceType    string
 	format        string
 	counterNumber uint
 	unitQInt      uint
+	timeout       time.Duration
+	batchFile     string
+	workers       uint
+	mbusModels    string
+	address       string
+	command       string
+	scanMode      string
+	scanTimeout   time.Duration
+	archive       string
+	from          string
+	to            string
+	syncTime      bool
+	syncMax       time.Duration
+	dryRun        bool
+	capture       string
+	replay        string
+	explicit      map[string]bool // Флаги, заданные в командной строке
+	checksum      string
+	rawTimeout    time.Duration
+	frames        []string
+	csvDelimiter  string
+	csvDecimal    string
+	csvHeader     bool
+	listen        string
+	interval      time.Duration
+	influxMeasure string
+	influxTime    string
+	influxURL     string
+	influxToken   string
+	mqttURL       string
+	mqttTopic     string
+	mqttPayload   string
+	mqttQoS       uint
+	mqttRetain    bool
+	mqttClientID  string
+	mqttWill      string
+	mqttWillText  string
+	mqttCA        string
+	mqttCert      string
+	mqttKey       string
 }
 
+// Подкоманды утилиты: первый аргумент командной строки перед флагами
+const (
+	CommandPoll = ""     // Опрос теплосчётчика, по умолчанию
+	CommandScan = "scan" // Поиск приборов M-Bus на шине
+	CommandRaw  = "raw"  // Отправка произвольных запросов прибору
+
+	CommandExporter = "exporter" // Экспортёр метрик Prometheus
+)
+
 func (cS Config) IsOnLog() bool {
 	return cS.log
 }
@@ -53,35 +91,327 @@ func (cS Config) IsDevEnv() bool {
 	return cS.dev
 }
 
-func (cS Config) GetHostPort() string {
-	return cS.hostPort
+// Строка подключения к теплосчётчику: host:port, [ipv6]:port?timeout=5s или serial:/dev/ttyUSB0?baud=9600
+func (cS Config) GetEndpoint() string {
+	return cS.endpoint
+}
+
+// Общее время опроса теплосчётчика: подключение, инициализация драйвера и чтение данных. 0 - без ограничения
+func (cS Config) GetTimeout() time.Duration {
+	return cS.timeout
+}
+
+// Подкоманда, см. CommandPoll, CommandScan, CommandRaw, CommandExporter
+func (cS Config) GetCommand() string {
+	return cS.command
+}
+
+// Вторичный адрес прибора M-Bus. Пустая строка - прибор выбирается по номеру
+func (cS Config) GetAddress() string {
+	return cS.address
+}
+
+// Режим поиска приборов: all, primary или secondary
+func (cS Config) GetScanMode() (string, error) {
+	switch cS.scanMode {
+	case "all", "primary", "secondary":
+		return cS.scanMode, nil
+	}
+	return "", fmt.Errorf("неверный режим поиска %q, возможно: all, primary, secondary", cS.scanMode)
+}
+
+// Время ожидания ответа прибора на один запрос при поиске
+func (cS Config) GetScanTimeout() time.Duration {
+	return cS.scanTimeout
+}
+
+// Тип читаемого архива. Пустое значение - читаются текущие данные
+func (cS Config) GetArchive() (models.ArchiveType, error) {
+	if cS.archive == "" {
+		return "", nil
+	}
+	return models.ParseArchiveType(cS.archive)
+}
+
+// Период чтения архива [from, to). Если окончание не задано, архив читается по текущее время
+func (cS Config) GetArchivePeriod() (time.Time, time.Time, error) {
+	if cS.from == "" {
+		return time.Time{}, time.Time{}, errors.New("не задано начало периода архива, флаг from")
+	}
+	from, err := parseDate(cS.from)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	to := time.Now()
+	if cS.to != "" {
+		to, err = parseDate(cS.to)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+	return from, to, nil
+}
+
+// Дата флагов from и to по местному времени: 2006-01-02 или 2006-01-02T15:04
+func parseDate(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", "2006-01-02T15:04", "2006-01-02 15:04"} {
+		date, err := time.ParseInLocation(layout, value, time.Local)
+		if err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("неверная дата %q, ожидается ГГГГ-ММ-ДД или ГГГГ-ММ-ДДTчч:мм", value)
+}
+
+// Режим синхронизации часов прибора вместо опроса
+func (cS Config) IsSyncTime() bool {
+	return cS.syncTime
+}
+
+// Наибольшая допустимая коррекция часов прибора. 0 - без ограничения
+func (cS Config) GetSyncMax() time.Duration {
+	return cS.syncMax
+}
+
+// Пробный запуск синхронизации: расхождение вычисляется, время не записывается
+func (cS Config) IsDryRun() bool {
+	return cS.dryRun
+}
+
+// Контрольная сумма, добавляемая к запросам подкоманды raw
+func (cS Config) GetChecksum() (raw.Checksum, error) {
+	return raw.ParseChecksum(cS.checksum)
+}
+
+// Время ожидания ответа на запрос подкоманды raw
+func (cS Config) GetRawTimeout() time.Duration {
+	return cS.rawTimeout
+}
+
+// Запросы подкоманды raw в шестнадцатеричном виде, заданные после строки подключения.
+// Пустой список - запросы читаются построчно со стандартного ввода
+func (cS Config) GetFrames() []string {
+	return cS.frames
+}
+
+// Адрес HTTP сервера экспортёра метрик, например :9715
+func (cS Config) GetListen() string {
+	return cS.listen
+}
+
+// Период опроса теплосчётчиков экспортёром метрик
+func (cS Config) GetInterval() time.Duration {
+	return cS.interval
+}
+
+// Файл, в который записывается обмен с прибором. Пустая строка - обмен не записывается
+func (cS Config) GetCaptureFile() string {
+	return cS.capture
+}
+
+// Файл записи обмена, воспроизводимый вместо подключения к прибору. Пустая строка - опрос прибора
+func (cS Config) GetReplayFile() string {
+	return cS.replay
+}
+
+/**
+Драйвер, номер и вторичный адрес прибора из заголовка воспроизводимой записи обмена.
+Значения, заданные в командной строке, не заменяются.
+*/
+func (cS *Config) ApplyReplayDefaults(driver string, number byte, address string) {
+	if !cS.explicit["type"] {
+		cS.deviceType = driver
+	}
+	if !cS.explicit["number"] {
+		cS.counterNumber = uint(number)
+	}
+	if !cS.explicit["address"] {
+		cS.address = address
+	}
 }
 
 func (cS Config) GetCounterNumber() byte {
 	return byte(cS.counterNumber)
 }
 
-func (cS *Config) GetDriver() (models.IDeviceDriver, error) {
-	for i, driver := range driversMap {
-		if i == cS.deviceType {
-			return driver, nil
-		}
+// Имя или номер драйвера, заданный флагом type
+func (cS Config) GetDeviceType() string {
+	return cS.deviceType
+}
+
+// Имя драйвера, заданного флагом type именем или номером
+func (cS Config) GetDriverName() (string, error) {
+	if cS.deviceType == drivers.Auto {
+		return drivers.Auto, nil
+	}
+	driver, err := drivers.Lookup(cS.deviceType)
+	if err != nil {
+		return "", fmt.Errorf("задан не верный драйвер устройства: %w. Список драйверов доступен по флагу \"-list-drivers\"", err)
 	}
-	return nil, errors.New("задан не верный драйвер устройства. Список драйверов доступен по флагу \"-help\" или \"-h\"")
+	return driver.Name, nil
+}
+
+// Файл заданий для пакетного опроса. Пустая строка - опрашивается один теплосчётчик
+func (cS Config) GetBatchFile() string {
+	return cS.batchFile
+}
+
+// Количество теплосчётчиков (шлюзов), опрашиваемых одновременно в пакетном режиме
+func (cS Config) GetWorkers() int {
+	return int(cS.workers)
+}
+
+// Файл настроек моделей приборов для драйвера mbus. Пустая строка - стандартное сопоставление записей
+func (cS Config) GetMBusModelsFile() string {
+	return cS.mbusModels
 }
 
 func (cS Config) GetFormatter() models.Formatter {
 	switch cS.format {
 	case "json":
 		return new(models.JsonFormat)
+	case "json2":
+		format := cS.GetJson2Format()
+		return &format
+	case "influx":
+		driver, err := cS.GetDriverName()
+		if err != nil {
+			driver = cS.deviceType
+		}
+		return &models.InfluxFormat{Measurement: cS.influxMeasure, Driver: driver, TimeDevice: cS.influxTime == "device"}
+	case "csv":
+		delimiter, _ := csvSeparator(cS.csvDelimiter)
+		decimal, _ := csvSeparator(cS.csvDecimal)
+		return &models.CsvFormat{Delimiter: delimiter, Decimal: decimal, NoHeader: !cS.csvHeader}
 	}
 	return new(models.TextFormat)
 }
 
+// Структурированный формат результата (json, json2, csv, influx), который разбирается программами
+func (cS Config) IsStructuredFormat() bool {
+	switch cS.format {
+	case "json", "json2", "csv", "influx":
+		return true
+	}
+	return false
+}
+
+// Формат json2 со сведениями об опросе из командной строки: драйвер, номер, адрес и строка подключения
+func (cS Config) GetJson2Format() models.Json2Format {
+	driver, err := cS.GetDriverName()
+	if err != nil {
+		driver = cS.deviceType
+	}
+	return models.Json2Format{
+		Driver:   driver,
+		Number:   cS.GetCounterNumber(),
+		Address:  cS.address,
+		Endpoint: cS.endpoint,
+	}
+}
+
+/**
+Запись результатов формата influx в InfluxDB по флагу influx-url. nil - результаты выводятся на стандартный вывод.
+Флаг influx-url допускается только с форматом influx.
+*/
+func (cS Config) GetInfluxWriter() (*influx.Writer, error) {
+	if cS.influxURL == "" {
+		return nil, nil
+	}
+	if cS.format != "influx" {
+		return nil, errors.New("флаг influx-url используется только с форматом influx")
+	}
+	token := cS.influxToken
+	if token == "" {
+		token = os.Getenv("QBOX_INFLUX_TOKEN")
+	}
+	return &influx.Writer{URL: cS.influxURL, Token: token}, nil
+}
+
+/**
+Публикация данных в MQTT по флагу mqtt-url. nil - данные не публикуются. Публикуются текущие данные
+при опросе одного теплосчётчика и в пакетном режиме. Пароль, не заданный в URL, берётся из переменной
+окружения QBOX_MQTT_PASSWORD.
+*/
+func (cS Config) GetMqttPublisher() (*mqtt.Publisher, error) {
+	if cS.mqttURL == "" {
+		return nil, nil
+	}
+	if cS.command != CommandPoll || cS.archive != "" || cS.syncTime {
+		return nil, errors.New("флаг mqtt-url используется только при опросе текущих данных")
+	}
+	if cS.mqttPayload != mqtt.PayloadJson2 && cS.mqttPayload != mqtt.PayloadFlat {
+		return nil, fmt.Errorf("неверный формат сообщений MQTT %q, возможно: json2, flat", cS.mqttPayload)
+	}
+	if cS.mqttQoS > 2 {
+		return nil, fmt.Errorf("неверный QoS %d, возможно: 0, 1, 2", cS.mqttQoS)
+	}
+	if err := mqtt.ValidateTemplate(cS.mqttTopic); err != nil {
+		return nil, err
+	}
+	options := mqtt.Options{URL: cS.mqttURL, ClientID: cS.mqttClientID}
+	if address, err := url.Parse(cS.mqttURL); err == nil {
+		if _, ok := address.User.Password(); !ok {
+			options.Password = os.Getenv("QBOX_MQTT_PASSWORD")
+		}
+	}
+	if cS.mqttCA != "" || cS.mqttCert != "" || cS.mqttKey != "" {
+		if !mqtt.IsSecureURL(cS.mqttURL) {
+			return nil, errors.New("флаги mqtt-ca, mqtt-cert и mqtt-key используются только с адресом mqtts://")
+		}
+		config, err := mqtt.LoadTLSConfig(cS.mqttCA, cS.mqttCert, cS.mqttKey)
+		if err != nil {
+			return nil, err
+		}
+		options.TLS = config
+	}
+	if cS.mqttWill != "" {
+		options.Will = &mqtt.Message{
+			Topic:   cS.mqttWill,
+			Payload: []byte(cS.mqttWillText),
+			QoS:     byte(cS.mqttQoS),
+			Retain:  cS.mqttRetain,
+		}
+		if err := mqtt.ValidateTopic(cS.mqttWill); err != nil {
+			return nil, err
+		}
+	}
+	return &mqtt.Publisher{
+		Options: options,
+		Topic:   cS.mqttTopic,
+		Payload: cS.mqttPayload,
+		QoS:     byte(cS.mqttQoS),
+		Retain:  cS.mqttRetain,
+	}, nil
+}
+
+// Разделитель CSV: один символ, кроме кавычки и перевода строки. tab - символ табуляции
+func csvSeparator(value string) (rune, error) {
+	if value == "tab" || value == "\\t" {
+		return '\t', nil
+	}
+	separator := []rune(value)
+	if len(separator) != 1 || separator[0] == '"' || separator[0] == '\r' || separator[0] == '\n' {
+		return 0, fmt.Errorf("неверный разделитель CSV %q, ожидается один символ или tab", value)
+	}
+	return separator[0], nil
+}
+
 // Возвращает конфигурацию для единиц измерения энергии.
 // Если неверно заданы, то возвращается ошибка и ГКал.
 func (cS Config) GetUnitQ() (models.UnitQEnum, error) {
-	switch cS.unitQInt {
+	return UnitQ(cS.unitQInt)
+}
+
+// Значение флага unitQ, используется как значение по умолчанию для заданий пакетного опроса
+func (cS Config) GetUnitQInt() uint {
+	return cS.unitQInt
+}
+
+// Преобразует значение флага unitQ в единицы измерения энергии.
+// Если значение неверное, то возвращается ошибка и ГКал.
+func UnitQ(value uint) (models.UnitQEnum, error) {
+	switch value {
 	case 1:
 		return models.Gcal, nil
 	case 2:
@@ -104,7 +434,37 @@ func InitConfig() Config {
 	flag.Usage = func() {
 		_, _ = fmt.Fprintln(os.Stdout, "Утилита qBox предоставляет возможность опрашивать теплосчётчики, используя различные драйверы.")
 		_, _ = fmt.Fprintf(os.Stdout, "Использование: %s -type=[драйвер] [другие настройки] ipAddress:port\n", os.Args[0])
-		_, _ = fmt.Fprintf(os.Stdout, "Например: %s -type=2 192.168.12.1\n", os.Args[0])
+		_, _ = fmt.Fprintf(os.Stdout, "Например: %s -type=tem104 192.168.12.1:4001\n", os.Args[0])
+		_, _ = fmt.Fprintln(os.Stdout, "")
+		_, _ = fmt.Fprintln(os.Stdout, "Вместо IP адреса можно указать имя узла или IPv6 адрес в квадратных скобках, а также таймаут подключения:")
+		_, _ = fmt.Fprintf(os.Stdout, "  %s -type=tem104 meter-17.corp.lan:4001?timeout=5s\n", os.Args[0])
+		_, _ = fmt.Fprintf(os.Stdout, "  %s -type=tem104 [2001:db8::1]:4001\n", os.Args[0])
+		_, _ = fmt.Fprintln(os.Stdout, "Вместо ipAddress:port можно указать последовательный порт (RS-232/RS-485):")
+		_, _ = fmt.Fprintf(os.Stdout, "  %s -type=tem104 serial:/dev/ttyUSB0?baud=9600&parity=none&stop=1&data=8&turnaround=20ms\n", os.Args[0])
+		_, _ = fmt.Fprintf(os.Stdout, "  %s -type=tem104 serial:COM3?baud=19200\n", os.Args[0])
+		_, _ = fmt.Fprintln(os.Stdout, "  baud - скорость обмена (по умолчанию 9600), data - бит данных (8),")
+		_, _ = fmt.Fprintln(os.Stdout, "  parity - чётность none, even, odd (none), stop - стоп-бит (1),")
+		_, _ = fmt.Fprintln(os.Stdout, "  turnaround - задержка переключения линии RS-485 после отправки запроса (0)")
+		_, _ = fmt.Fprintln(os.Stdout, "")
+		_, _ = fmt.Fprintln(os.Stdout, "Чтение архива за период:")
+		_, _ = fmt.Fprintf(os.Stdout, "  %s -type=tem104m -archive=daily -from=2026-09-01 -to=2026-10-01 192.168.12.1:4001\n", os.Args[0])
+		_, _ = fmt.Fprintln(os.Stdout, "")
+		_, _ = fmt.Fprintln(os.Stdout, "Синхронизация часов прибора с часами компьютера:")
+		_, _ = fmt.Fprintf(os.Stdout, "  %s -type=tem104m -sync-time [-sync-max=10m] [-dry-run] 192.168.12.1:4001\n", os.Args[0])
+		_, _ = fmt.Fprintln(os.Stdout, "")
+		_, _ = fmt.Fprintln(os.Stdout, "Запись обмена с прибором в файл и воспроизведение записи без прибора:")
+		_, _ = fmt.Fprintf(os.Stdout, "  %s -type=tem104 -capture=session.qcap 192.168.12.1:4001\n", os.Args[0])
+		_, _ = fmt.Fprintf(os.Stdout, "  %s -replay=session.qcap\n", os.Args[0])
+		_, _ = fmt.Fprintln(os.Stdout, "")
+		_, _ = fmt.Fprintln(os.Stdout, "Поиск приборов M-Bus на шине одного шлюза или порта:")
+		_, _ = fmt.Fprintf(os.Stdout, "  %s scan [-scan=all|primary|secondary] [-scan-timeout=1s] ipAddress:port\n", os.Args[0])
+		_, _ = fmt.Fprintln(os.Stdout, "")
+		_, _ = fmt.Fprintln(os.Stdout, "Отправка произвольных запросов прибору и вывод ответов (запросы без аргументов читаются со стандартного ввода):")
+		_, _ = fmt.Fprintf(os.Stdout, "  %s raw [-checksum=none|tem|mbus|modbus] [-raw-timeout=3s] ipAddress:port [запрос ...]\n", os.Args[0])
+		_, _ = fmt.Fprintf(os.Stdout, "  %s raw -checksum=mbus 192.168.12.1:4001 \"105B01\"\n", os.Args[0])
+		_, _ = fmt.Fprintln(os.Stdout, "")
+		_, _ = fmt.Fprintln(os.Stdout, "Экспортёр метрик Prometheus: опрос теплосчётчиков из файла заданий (см. флаг batch) по расписанию:")
+		_, _ = fmt.Fprintf(os.Stdout, "  %s exporter [-listen=:9715] [-interval=5m] jobs.json\n", os.Args[0])
 		_, _ = fmt.Fprintln(os.Stdout, "")
 		_, _ = fmt.Fprintln(os.Stdout, "Список доступных настроек:")
 		_, _ = fmt.Fprintln(os.Stdout, "")
@@ -127,27 +487,12 @@ func InitConfig() Config {
 			"Выключенный флаг - режим производства, отладачная информация в логах скрыта.\n\t"+
 			"Принимает значения 1, 0.")
 
-	flag.IntVar(
+	flag.StringVar(
 		&configService.deviceType,
 		"type",
-		9999,
-		"Обязательный атрибут. Тип теплосчётчика, в зависимости от выбранного типа используется тот или иной драйвер\n\t"+
-			"Доступные типы(драйвера):"+
-			"\n\t   0 - СКМ-2"+
-			"\n\t   1 - SKU-02-B (5b)."+
-			"\n\t   2 - ТЭМ-104"+
-			"\n\t   3 - ТЭМ-05"+
-			"\n\t   4 - SKU-02"+
-			"\n\t   5 - ИСТОК TM3"+
-			"\n\t   6 - TEM-104M-1"+
-			"\n\t   7 - TEM-104-1"+
-			"\n\t   8 - TEM-104-1 ТЭСМАРТ (РФ)"+
-			"\n\t   9 - SKU-02-K"+
-			"\n\t   10 - SKU-02-B (7b)"+
-			"\n\t   11 - TEM-104M"+
-			"\n\t   12 - TEM-104k"+
-			"\n\t   13 - TEM-104M2"+
-			"\n\t   14 - SKM2M.")
+		"",
+		"Обязательный атрибут. Тип теплосчётчика, в зависимости от выбранного типа используется тот или иной драйвер.\n\t"+
+			"Задаётся именем драйвера либо его номером. Доступные типы(драйвера):"+driversHelp())
 
 	flag.UintVar(
 		&configService.counterNumber,
@@ -159,7 +504,124 @@ func InitConfig() Config {
 		&configService.format,
 		"format",
 		"text",
-		"Формат вывода результата. По умолчанию текстовый вид \"text\". Также доступен формат \"json\"")
+		"Формат вывода результата. По умолчанию текстовый вид \"text\". Также доступны форматы \"json\",\n\t"+
+			"\"json2\" - JSON с единицами измерения и временем RFC 3339, описан в schema/qbox-data-v2.schema.json,\n\t"+
+			"\"csv\" - по строке на каждую активную систему, см. флаги csv-delimiter, csv-decimal, csv-header,\n\t"+
+			"и \"influx\" - InfluxDB line protocol, по строке на каждую активную систему, см. флаги influx-*")
+
+	flag.StringVar(
+		&configService.influxMeasure,
+		"influx-measurement",
+		"qbox",
+		"Имя измерения формата influx. Архивы записываются в измерение с суффиксом _archive,\n\t"+
+			"синхронизация часов - _clock")
+
+	flag.StringVar(
+		&configService.influxTime,
+		"influx-time",
+		"request",
+		"Время строк формата influx: request - время опроса по часам компьютера, device - время на приборе")
+
+	flag.StringVar(
+		&configService.influxURL,
+		"influx-url",
+		"",
+		"Запись результатов формата influx по HTTP вместо стандартного вывода, например\n\t"+
+			"http://localhost:8086/write?db=heat или http://localhost:8086/api/v2/write?org=home&bucket=heat.\n\t"+
+			"Результаты, в том числе пакетного опроса, записываются одним запросом после опроса")
+
+	flag.StringVar(
+		&configService.influxToken,
+		"influx-token",
+		"",
+		"Токен InfluxDB 2.x для флага influx-url. По умолчанию - переменная окружения QBOX_INFLUX_TOKEN")
+
+	flag.StringVar(
+		&configService.mqttURL,
+		"mqtt-url",
+		"",
+		"Публикация успешно прочитанных данных в брокер MQTT 3.1.1, например mqtt://localhost:1883\n\t"+
+			"или mqtts://user@broker:8883. Пароль можно задать переменной окружения QBOX_MQTT_PASSWORD")
+
+	flag.StringVar(
+		&configService.mqttTopic,
+		"mqtt-topic",
+		"heat/{serial}/{system}",
+		"Шаблон темы MQTT. Подстановки: {serial}, {system}, {driver}, {number}, {address} и метки задания\n\t"+
+			"пакетного опроса по имени. С {system} публикуется сообщение на каждую систему, без - на прибор")
+
+	flag.StringVar(
+		&configService.mqttPayload,
+		"mqtt-payload",
+		"json2",
+		"Формат сообщений MQTT: json2 - документ формата json2, flat - плоский JSON объект ключ-значение")
+
+	flag.UintVar(
+		&configService.mqttQoS,
+		"mqtt-qos",
+		1,
+		"QoS сообщений MQTT: 0, 1 или 2")
+
+	flag.BoolVar(
+		&configService.mqttRetain,
+		"mqtt-retain",
+		false,
+		"Брокер хранит последнее значение каждой темы и отдаёт его новым подписчикам")
+
+	flag.StringVar(
+		&configService.mqttClientID,
+		"mqtt-client-id",
+		"",
+		"Идентификатор клиента MQTT. По умолчанию qbox-<номер процесса>")
+
+	flag.StringVar(
+		&configService.mqttWill,
+		"mqtt-will-topic",
+		"",
+		"Тема Last-Will: брокер публикует в неё сообщение mqtt-will-message, если опрос не удался\n\t"+
+			"или соединение с qBox прервано")
+
+	flag.StringVar(
+		&configService.mqttWillText,
+		"mqtt-will-message",
+		"failed",
+		"Сообщение Last-Will")
+
+	flag.StringVar(
+		&configService.mqttCA,
+		"mqtt-ca",
+		"",
+		"Корневой сертификат брокера MQTT в формате PEM. По умолчанию - системные сертификаты")
+
+	flag.StringVar(
+		&configService.mqttCert,
+		"mqtt-cert",
+		"",
+		"Сертификат клиента MQTT в формате PEM, вместе с флагом mqtt-key")
+
+	flag.StringVar(
+		&configService.mqttKey,
+		"mqtt-key",
+		"",
+		"Закрытый ключ сертификата клиента MQTT в формате PEM")
+
+	flag.StringVar(
+		&configService.csvDelimiter,
+		"csv-delimiter",
+		";",
+		"Разделитель колонок формата csv: один символ или tab. По умолчанию \";\" для русского Excel")
+
+	flag.StringVar(
+		&configService.csvDecimal,
+		"csv-decimal",
+		",",
+		"Десятичный разделитель чисел формата csv. По умолчанию \",\" для русского Excel")
+
+	flag.BoolVar(
+		&configService.csvHeader,
+		"csv-header",
+		true,
+		"Строка заголовка формата csv. С -csv-header=false вывод нескольких опросов можно объединять в один файл")
 
 	flag.UintVar( // Значения такие же как models.unitQ
 		&configService.unitQInt,
@@ -171,17 +633,226 @@ func InitConfig() Config {
 			"\n\t   3 - КВт"+
 			"\n\t   0 - МВт")
 
+	flag.DurationVar(
+		&configService.timeout,
+		"timeout",
+		0,
+		"Общее время опроса теплосчётчика, например 90s или 2m. По истечении опрос прерывается.\n\t"+
+			"Ограничивает весь сеанс: подключение, повторные попытки, переподключения и чтение данных.\n\t"+
+			"По умолчанию 0 - без ограничения.")
+
+	flag.StringVar(
+		&configService.batchFile,
+		"batch",
+		"",
+		"Пакетный режим. Путь к JSON файлу со списком заданий на опрос, например:\n\t"+
+			"[{\"endpoint\": \"192.168.12.1:4001\", \"driver\": \"tem104\", \"number\": 1, \"unit\": 1, \"tags\": {\"house\": \"17\"}}]\n\t"+
+			"Не заданные в задании driver, number и unit берутся из флагов type, number и unitQ.\n\t"+
+			"Результат каждого задания выводится отдельной записью, для формата json - одной строкой.\n\t"+
+			"Теплосчётчики с одинаковым адресом шлюза (порта) опрашиваются последовательно через одно соединение.\n\t"+
+			"Флаг timeout в пакетном режиме ограничивает время опроса каждого теплосчётчика.")
+
+	flag.UintVar(
+		&configService.workers,
+		"workers",
+		4,
+		"Количество шлюзов (портов), опрашиваемых одновременно в пакетном режиме")
+
+	flag.StringVar(
+		&configService.mbusModels,
+		"mbus-models",
+		"",
+		"Путь к JSON файлу настроек моделей приборов для драйвера mbus. Задаёт, в какие поля данных\n\t"+
+			"попадают записи прибора, для моделей, у которых стандартное сопоставление по VIF не подходит, например:\n\t"+
+			"[{\"manufacturer\": \"KAM\", \"records\": [{\"header\": \"0C06\", \"field\": \"Q1\", \"system\": 0}]}]")
+
+	flag.StringVar(
+		&configService.address,
+		"address",
+		"",
+		"Вторичный адрес прибора M-Bus: ID[.MAN[.VER[.MED]]], например 12345678 или 12345678.KAM.1B.04.\n\t"+
+			"Прибор выбирается по вторичному адресу вместо номера (флаг number). Только для драйверов M-Bus\n\t"+
+			"с возможностью secondary, см. флаг list-drivers")
+
+	flag.StringVar(
+		&configService.scanMode,
+		"scan",
+		"all",
+		"Режим поиска для подкоманды scan: primary - по первичным адресам 0-250,\n\t"+
+			"secondary - по вторичным адресам, all - оба способа")
+
+	flag.DurationVar(
+		&configService.scanTimeout,
+		"scan-timeout",
+		time.Second,
+		"Время ожидания ответа прибора на каждый запрос при поиске подкомандой scan\n\t"+
+			"и при определении драйвера (-type=auto)")
+
+	flag.StringVar(
+		&configService.archive,
+		"archive",
+		"",
+		"Чтение архива вместо текущих данных: hourly - часовой, daily - суточный, monthly - месячный.\n\t"+
+			"Период задаётся флагами from и to. Только для драйверов с возможностью archive, см. флаг list-drivers.\n\t"+
+			"Каждая запись архива выводится отдельно. В пакетном режиме не используется")
+
+	flag.StringVar(
+		&configService.from,
+		"from",
+		"",
+		"Начало периода архива по местному времени: ГГГГ-ММ-ДД или ГГГГ-ММ-ДДTчч:мм, например 2026-09-01")
+
+	flag.StringVar(
+		&configService.to,
+		"to",
+		"",
+		"Окончание периода архива, не включается в период. По умолчанию - текущее время")
+
+	flag.BoolVar(
+		&configService.syncTime,
+		"sync-time",
+		false,
+		"Синхронизация часов прибора вместо опроса: вычисляется расхождение с часами компьютера,\n\t"+
+			"и на прибор записывается время компьютера. Только для драйверов с возможностью clock, см. флаг list-drivers.\n\t"+
+			"Время на приборе до и после коррекции выводится и записывается в лог. В пакетном режиме не используется")
+
+	flag.DurationVar(
+		&configService.syncMax,
+		"sync-max",
+		10*time.Minute,
+		"Наибольшая коррекция часов прибора, например 10m. Если расхождение больше, время не записывается.\n\t"+
+			"0 - без ограничения")
+
+	flag.BoolVar(
+		&configService.dryRun,
+		"dry-run",
+		false,
+		"Пробный запуск синхронизации часов: расхождение вычисляется и выводится, время на приборе не изменяется")
+
+	flag.StringVar(
+		&configService.capture,
+		"capture",
+		"",
+		"Запись обмена с прибором в файл, например session.qcap: подключения, запросы, ответы, таймауты\n\t"+
+			"и переподключения с временем. Запись воспроизводится флагом replay. В пакетном режиме не используется")
+
+	flag.StringVar(
+		&configService.replay,
+		"replay",
+		"",
+		"Воспроизведение записи обмена (флаг capture) вместо подключения к прибору: драйвер получает записанные ответы.\n\t"+
+			"Драйвер, номер и адрес прибора по умолчанию берутся из записи. Адрес подключения не задаётся")
+
+	flag.StringVar(
+		&configService.checksum,
+		"checksum",
+		"none",
+		"Контрольная сумма, добавляемая к запросам подкоманды raw и проверяемая в ответах:\n\t"+
+			"none - запрос отправляется как задан, ответ читается до таймаута,\n\t"+
+			"tem - инвертированная сумма байтов (ТЭМ), mbus - сумма байтов и 16h (кадр задаётся без CS и 16h),\n\t"+
+			"modbus - CRC16 modbus RTU. С контрольной суммой ответ читается до конца кадра")
+
+	flag.DurationVar(
+		&configService.rawTimeout,
+		"raw-timeout",
+		3*time.Second,
+		"Время ожидания ответа на каждый запрос подкоманды raw")
+
+	flag.StringVar(
+		&configService.listen,
+		"listen",
+		":9715",
+		"Адрес HTTP сервера подкоманды exporter, например :9715 или 127.0.0.1:9715. Метрики - по пути /metrics")
+
+	flag.DurationVar(
+		&configService.interval,
+		"interval",
+		5*time.Minute,
+		"Период опроса теплосчётчиков подкомандой exporter. Запрос метрик отдаёт данные последнего опроса")
+
 	var versionFlag *bool
 	versionFlag = flag.Bool("version", false, "Версия "+VersionCoreApp)
 
-	flag.Parse()
+	var listDriversFlag *bool
+	listDriversFlag = flag.Bool("list-drivers", false, "Список драйверов: имя, номер, теплосчётчик, протокол, производитель и возможности")
+
+	arguments := os.Args[1:]
+	if len(arguments) > 0 && (arguments[0] == CommandScan || arguments[0] == CommandRaw || arguments[0] == CommandExporter) {
+		configService.command = arguments[0]
+		arguments = arguments[1:]
+	}
+	_ = flag.CommandLine.Parse(arguments)
+	configService.explicit = map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		configService.explicit[f.Name] = true
+	})
+
+	if configService.influxTime != "request" && configService.influxTime != "device" {
+		_, _ = fmt.Fprintf(os.Stderr, "неверное время строк формата influx %q, возможно: request, device\n", configService.influxTime)
+		os.Exit(2)
+	}
+
+	for _, separator := range []string{configService.csvDelimiter, configService.csvDecimal} {
+		if _, err := csvSeparator(separator); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(2)
+		}
+	}
 
 	if *versionFlag {
 		fmt.Println(flag.Lookup("version").Usage)
 		os.Exit(0)
 	}
 
-	configService.hostPort = flag.Arg(0)
+	if *listDriversFlag {
+		printDrivers()
+		os.Exit(0)
+	}
+
+	configService.endpoint = flag.Arg(0)
+	if configService.command == CommandExporter && flag.NArg() > 0 {
+		// Список теплосчётчиков экспортёра - файл заданий пакетного опроса
+		configService.batchFile, configService.endpoint = flag.Arg(0), ""
+	}
+	if configService.command == CommandRaw && flag.NArg() > 1 {
+		configService.frames = flag.Args()[1:]
+	}
 
 	return *configService
 }
+
+// Описание драйверов для флага type
+func driversHelp() string {
+	var help strings.Builder
+	for _, driver := range drivers.List() {
+		help.WriteString("\n\t   " + driver.Name)
+		if number := driver.Number(); number >= 0 {
+			help.WriteString(" (" + strconv.Itoa(number) + ")")
+		}
+		help.WriteString(" - " + driver.Title)
+	}
+	help.WriteString("\n\t   " + drivers.Auto + " - определить по ответам прибора (ТЭМ, M-Bus, modbus, SKU-02)")
+	return help.String()
+}
+
+func printDrivers() {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "Имя\tНомер\tТеплосчётчик\tПротокол\tПроизводитель\tВозможности")
+	for _, driver := range drivers.List() {
+		number := "-"
+		if driver.Number() >= 0 {
+			number = strconv.Itoa(driver.Number())
+		}
+		manufacturer := driver.Manufacturer
+		if manufacturer == "" {
+			manufacturer = "-"
+		}
+		capabilities := make([]string, 0, len(driver.Capabilities()))
+		for _, capability := range driver.Capabilities() {
+			capabilities = append(capabilities, string(capability))
+		}
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			driver.Name, number, driver.Title, driver.Protocol, manufacturer, strings.Join(capabilities, ", "))
+	}
+	_ = writer.Flush()
+}
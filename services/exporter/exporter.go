@@ -0,0 +1,143 @@
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"qBox/drivers"
+	"qBox/models"
+	"qBox/services/batch"
+	"qBox/services/net"
+	"qBox/services/poll"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/**
+Экспортёр метрик Prometheus. Теплосчётчики из списка заданий опрашиваются по расписанию пакетным опросом
+(batch.Runner), а запрос /metrics отдаёт последние успешно прочитанные данные каждого задания, не обращаясь
+к приборам. Время чтения данных выводится метрикой qbox_data_timestamp_seconds, поэтому устаревшие данные
+можно отличить от свежих. Ошибки опроса не удаляют прочитанные ранее данные, а учитываются счётчиками.
+
+	exporter := &exporter.Exporter{Jobs: jobs, Runner: batch.Runner{Workers: 4, Logger: logger}, Interval: 5 * time.Minute}
+	go exporter.Run(ctx)
+	http.Handle("/metrics", exporter)
+*/
+type Exporter struct {
+	Jobs     []batch.Job   // Список заданий на опрос
+	Runner   batch.Runner  // Пакетный опрос
+	Interval time.Duration // Период опроса от начала предыдущего опроса. Если опрос дольше, следующий начинается сразу
+
+	mutex   sync.Mutex
+	targets map[int]*target // Состояние заданий по номеру задания
+}
+
+// Состояние опроса одного задания
+type target struct {
+	job      batch.Job
+	driver   string             // Имя драйвера. При определении драйвера (auto) - определённый драйвер
+	data     *models.DataDevice // Последние успешно прочитанные данные, nil - данных нет
+	up       bool               // Последний опрос успешен
+	polls    uint64
+	errors   map[models.ErrorClass]uint64
+	lastPoll time.Time
+	duration time.Duration // Длительность последнего опроса
+	io       net.Stats     // Счётчики обмена всех опросов
+}
+
+// Опрос по расписанию до отмены ctx. Первый опрос начинается сразу
+func (exporter *Exporter) Run(ctx context.Context) {
+	for {
+		start := time.Now()
+		exporter.Poll(ctx)
+
+		wait := exporter.Interval - time.Since(start)
+		if wait < 0 {
+			wait = 0
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// Однократный опрос всех заданий с обновлением данных метрик
+func (exporter *Exporter) Poll(ctx context.Context) {
+	logger := exporter.Runner.Logger
+	logger.Check("exporter")
+	logger.Info("Опрос заданий: %d", len(exporter.Jobs))
+	start := time.Now()
+	exporter.Runner.Run(ctx, exporter.Jobs, exporter.update)
+	logger.Check("exporter")
+	logger.Info("Опрос завершён за %s", time.Since(start).Round(time.Millisecond).String())
+}
+
+// Учёт результата задания. Данные с ошибкой, в том числе частично прочитанные, не заменяют прочитанные ранее
+func (exporter *Exporter) update(result batch.Result) {
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+
+	state := exporter.target(result.Job)
+	state.polls++
+	state.lastPoll = result.TimeStart
+	state.duration = result.Duration
+	state.io = state.io.Add(result.IO)
+	state.up = result.Err == nil
+	if result.Err != nil {
+		state.errors[poll.Classify(result.Err)]++
+		return
+	}
+	state.data = result.Data
+	if result.Data.Detected != nil {
+		state.driver = result.Data.Detected.Driver
+	}
+}
+
+func (exporter *Exporter) target(job batch.Job) *target {
+	if exporter.targets == nil {
+		exporter.targets = make(map[int]*target)
+	}
+	state, ok := exporter.targets[job.Index]
+	if !ok {
+		state = &target{job: job, driver: string(job.Driver), errors: make(map[models.ErrorClass]uint64)}
+		if driver, err := drivers.Lookup(state.driver); err == nil {
+			state.driver = driver.Name
+		}
+		exporter.targets[job.Index] = state
+	}
+	return state
+}
+
+// Метрики в текстовом формате Prometheus
+func (exporter *Exporter) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	exporter.mutex.Lock()
+	targets := make([]target, 0, len(exporter.Jobs))
+	for _, job := range exporter.Jobs {
+		if state, ok := exporter.targets[job.Index]; ok {
+			snapshot := *state
+			snapshot.errors = make(map[models.ErrorClass]uint64, len(state.errors))
+			for class, count := range state.errors {
+				snapshot.errors[class] = count
+			}
+			targets = append(targets, snapshot)
+		}
+	}
+	exporter.mutex.Unlock()
+
+	writer.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writeMetrics(writer, targets)
+}
+
+// Метки задания: строка подключения, драйвер, номер и вторичный адрес прибора
+func (state target) labels() []label {
+	return []label{
+		{"endpoint", state.job.Endpoint},
+		{"driver", state.driver},
+		{"number", strconv.FormatUint(uint64(state.job.Number), 10)},
+		{"address", state.job.Address},
+	}
+}
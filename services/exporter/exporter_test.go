@@ -0,0 +1,94 @@
+package exporter
+
+import (
+	"errors"
+	"math"
+	"net/http/httptest"
+	"qBox/models"
+	"qBox/services/batch"
+	"qBox/services/net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	moment := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	jobs := []batch.Job{
+		{Index: 1, Endpoint: "192.168.12.1:4001", Driver: "2", Number: 1},
+		{Index: 2, Endpoint: "192.168.12.1:4001", Driver: "tem104", Number: 2},
+	}
+	exporter := &Exporter{Jobs: jobs}
+
+	data := &models.DataDevice{
+		Serial:      "104001",
+		UnitQ:       models.Gcal,
+		TimeRequest: moment,
+		Time:        moment.Add(-3 * time.Second),
+		Systems: []models.SystemDevice{
+			{Status: true, SigmaQ: 1234.5, V1: 45678.25, T1: 72.5, P2: float32(math.NaN()), GM1: 1.25},
+			{Status: false, SigmaQ: 1},
+		},
+	}
+	exporter.update(batch.Result{Job: jobs[0], Data: data, TimeStart: moment, Duration: 1500 * time.Millisecond,
+		IO: net.Stats{Requests: 3, Retries: 1}})
+	exporter.update(batch.Result{Job: jobs[1], Err: &net.NoResponseError{}, TimeStart: moment, Duration: 4 * time.Second,
+		IO: net.Stats{Requests: 1, Timeouts: 4, Failures: 1}})
+	// Ошибка следующего опроса не удаляет прочитанные данные
+	exporter.update(batch.Result{Job: jobs[0], Err: errors.New("ошибка разбора ответа"),
+		TimeStart: moment.Add(5 * time.Minute), Duration: time.Second, IO: net.Stats{Requests: 2, Failures: 1}})
+
+	recorder := httptest.NewRecorder()
+	exporter.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	output := recorder.Body.String()
+	if !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type: %s", recorder.Header().Get("Content-Type"))
+	}
+
+	target1 := `{endpoint="192.168.12.1:4001",driver="tem104",number="1",address=""}`
+	target2 := `{endpoint="192.168.12.1:4001",driver="tem104",number="2",address=""}`
+	device := `{serial="104001",driver="tem104",endpoint="192.168.12.1:4001"`
+	for _, line := range []string{
+		"# TYPE qbox_up gauge",
+		"qbox_up" + target1 + " 0",
+		"qbox_polls_total" + target1 + " 2",
+		"qbox_poll_duration_seconds" + target2 + " 4",
+		"qbox_last_poll_timestamp_seconds" + target1 + " 1792317900",
+		"qbox_poll_errors_total" + strings.TrimSuffix(target1, "}") + `,class="protocol"} 1`,
+		"qbox_poll_errors_total" + strings.TrimSuffix(target2, "}") + `,class="timeout"} 1`,
+		"qbox_io_requests_total" + target1 + " 5",
+		"qbox_io_retries_total" + target1 + " 1",
+		"qbox_io_timeouts_total" + target2 + " 4",
+		"qbox_io_failures_total" + target1 + " 1",
+		"qbox_data_timestamp_seconds" + device + "} 1792317600",
+		"qbox_device_time_seconds" + device + "} 1792317597",
+		"# TYPE qbox_sigma_q_total counter",
+		"qbox_sigma_q_total" + device + `,system="1",unit="Gcal"} 1234.5`,
+		"qbox_v1_cubic_meters_total" + device + `,system="1"} 45678.25`,
+		"qbox_t1_celsius" + device + `,system="1"} 72.5`,
+		"qbox_p2_megapascals" + device + `,system="1"} NaN`,
+		"qbox_gm1_tonnes_per_hour" + device + `,system="1"} 1.25`,
+	} {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("нет строки %s", line)
+		}
+	}
+	if strings.Contains(output, `system="2"`) {
+		t.Error("выведена неактивная система")
+	}
+	if strings.Count(output, "# TYPE qbox_t1_celsius ") != 1 {
+		t.Error("метрика должна выводиться одной группой")
+	}
+	if t.Failed() {
+		t.Log(output)
+	}
+}
+
+func TestLabelEscape(t *testing.T) {
+	var output strings.Builder
+	writeFamily(&output, "qbox_up", "gauge", "test", []sample{{[]label{{"endpoint", "serial:C:\\COM3?\"a\"\n"}}, "1"}})
+	expected := "# HELP qbox_up test\n# TYPE qbox_up gauge\nqbox_up{endpoint=\"serial:C:\\\\COM3?\\\"a\\\"\\n\"} 1\n"
+	if output.String() != expected {
+		t.Errorf("вывод:\n%s\nожидался\n%s", output.String(), expected)
+	}
+}
@@ -0,0 +1,219 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"qBox/models"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type label struct {
+	name  string
+	value string
+}
+
+type sample struct {
+	labels []label
+	value  string
+}
+
+// Показание системы прибора. Показания энергии выводятся в единицах задания (unit), см. метку unit
+type systemMetric struct {
+	name   string
+	kind   string // gauge или counter
+	help   string
+	energy bool
+	value  func(system *models.SystemDevice) string
+}
+
+/**
+Показания систем. Мгновенные значения - gauge, накопленные прибором значения (интеграторы) - counter:
+они не убывают, пока прибор не сброшен, и Prometheus вычисляет по ним расход функцией increase.
+*/
+var systemMetrics = []systemMetric{
+	{"qbox_sigma_q_total", "counter", "Тепловая энергия, результирующее значение Q системы", true,
+		func(system *models.SystemDevice) string { return float64Value(system.SigmaQ) }},
+	{"qbox_q1_total", "counter", "Тепловая энергия Q1", true,
+		func(system *models.SystemDevice) string { return float64Value(system.Q1) }},
+	{"qbox_q2_total", "counter", "Тепловая энергия Q2", true,
+		func(system *models.SystemDevice) string { return float64Value(system.Q2) }},
+	{"qbox_q3_total", "counter", "Тепловая энергия Q3", true,
+		func(system *models.SystemDevice) string { return float64Value(system.Q3) }},
+	{"qbox_v1_cubic_meters_total", "counter", "Объём V1, м3", false,
+		func(system *models.SystemDevice) string { return float64Value(system.V1) }},
+	{"qbox_v2_cubic_meters_total", "counter", "Объём V2, м3", false,
+		func(system *models.SystemDevice) string { return float64Value(system.V2) }},
+	{"qbox_m1_tonnes_total", "counter", "Масса M1, т", false,
+		func(system *models.SystemDevice) string { return float64Value(system.M1) }},
+	{"qbox_m2_tonnes_total", "counter", "Масса M2, т", false,
+		func(system *models.SystemDevice) string { return float64Value(system.M2) }},
+	{"qbox_m3_tonnes_total", "counter", "Масса подпитки M3, т", false,
+		func(system *models.SystemDevice) string { return float64Value(system.M3) }},
+	{"qbox_t1_celsius", "gauge", "Температура T1, C", false,
+		func(system *models.SystemDevice) string { return float32Value(system.T1) }},
+	{"qbox_t2_celsius", "gauge", "Температура T2, C", false,
+		func(system *models.SystemDevice) string { return float32Value(system.T2) }},
+	{"qbox_t3_celsius", "gauge", "Температура T3, C", false,
+		func(system *models.SystemDevice) string { return float32Value(system.T3) }},
+	{"qbox_p1_megapascals", "gauge", "Давление P1, МПа", false,
+		func(system *models.SystemDevice) string { return float32Value(system.P1) }},
+	{"qbox_p2_megapascals", "gauge", "Давление P2, МПа", false,
+		func(system *models.SystemDevice) string { return float32Value(system.P2) }},
+	{"qbox_p3_megapascals", "gauge", "Давление P3, МПа", false,
+		func(system *models.SystemDevice) string { return float32Value(system.P3) }},
+	{"qbox_gv1_cubic_meters_per_hour", "gauge", "Объёмный расход G1, м3/ч", false,
+		func(system *models.SystemDevice) string { return float32Value(system.GV1) }},
+	{"qbox_gv2_cubic_meters_per_hour", "gauge", "Объёмный расход G2, м3/ч", false,
+		func(system *models.SystemDevice) string { return float32Value(system.GV2) }},
+	{"qbox_gv3_cubic_meters_per_hour", "gauge", "Объёмный расход подпитки G3, м3/ч", false,
+		func(system *models.SystemDevice) string { return float32Value(system.GV3) }},
+	{"qbox_gm1_tonnes_per_hour", "gauge", "Массовый расход G1, т/ч", false,
+		func(system *models.SystemDevice) string { return float32Value(system.GM1) }},
+	{"qbox_gm2_tonnes_per_hour", "gauge", "Массовый расход G2, т/ч", false,
+		func(system *models.SystemDevice) string { return float32Value(system.GM2) }},
+	{"qbox_gm3_tonnes_per_hour", "gauge", "Массовый расход подпитки G3, т/ч", false,
+		func(system *models.SystemDevice) string { return float32Value(system.GM3) }},
+}
+
+// Вывод метрик заданий. Метрики с одним именем выводятся вместе, как требует формат
+func writeMetrics(writer io.Writer, targets []target) {
+	perTarget := func(name string, kind string, help string, value func(state target) string) {
+		var samples []sample
+		for _, state := range targets {
+			samples = append(samples, sample{state.labels(), value(state)})
+		}
+		writeFamily(writer, name, kind, help, samples)
+	}
+
+	perTarget("qbox_up", "gauge", "Последний опрос задания успешен: 1 - да, 0 - нет",
+		func(state target) string { return boolValue(state.up) })
+	perTarget("qbox_polls_total", "counter", "Опросы задания",
+		func(state target) string { return uint64Value(state.polls) })
+	perTarget("qbox_poll_duration_seconds", "gauge", "Длительность последнего опроса задания",
+		func(state target) string { return float64Value(state.duration.Seconds()) })
+	perTarget("qbox_last_poll_timestamp_seconds", "gauge", "Время начала последнего опроса задания, Unix",
+		func(state target) string { return unixValue(state.lastPoll.UnixNano()) })
+
+	var errorSamples []sample
+	for _, state := range targets {
+		classes := make([]string, 0, len(state.errors))
+		for class := range state.errors {
+			classes = append(classes, string(class))
+		}
+		sort.Strings(classes)
+		for _, class := range classes {
+			labels := append(state.labels(), label{"class", class})
+			errorSamples = append(errorSamples, sample{labels, uint64Value(state.errors[models.ErrorClass(class)])})
+		}
+	}
+	writeFamily(writer, "qbox_poll_errors_total", "counter",
+		"Опросы задания с ошибкой по классу ошибки: connect, timeout, frame, protocol, interrupted, config, driver", errorSamples)
+
+	perTarget("qbox_io_requests_total", "counter", "Запросы к прибору",
+		func(state target) string { return uint64Value(state.io.Requests) })
+	perTarget("qbox_io_retries_total", "counter", "Повторные запросы: ответ не прошёл проверку",
+		func(state target) string { return uint64Value(state.io.Retries) })
+	perTarget("qbox_io_timeouts_total", "counter", "Таймауты чтения без ответа прибора",
+		func(state target) string { return uint64Value(state.io.Timeouts) })
+	perTarget("qbox_io_reconnects_total", "counter", "Переподключения к шлюзу во время обмена",
+		func(state target) string { return uint64Value(state.io.Reconnects) })
+	perTarget("qbox_io_failures_total", "counter", "Запросы, завершившиеся ошибкой после всех повторов",
+		func(state target) string { return uint64Value(state.io.Failures) })
+
+	// Данные приборов: последние успешно прочитанные
+	perDevice := func(name string, kind string, help string, value func(data *models.DataDevice) string) {
+		var samples []sample
+		for _, state := range targets {
+			if state.data != nil {
+				samples = append(samples, sample{state.deviceLabels(), value(state.data)})
+			}
+		}
+		writeFamily(writer, name, kind, help, samples)
+	}
+	perDevice("qbox_data_timestamp_seconds", "gauge", "Время чтения выводимых данных прибора, Unix",
+		func(data *models.DataDevice) string { return unixValue(data.TimeRequest.UnixNano()) })
+	perDevice("qbox_device_time_seconds", "gauge", "Время на приборе при чтении данных, Unix",
+		func(data *models.DataDevice) string { return unixValue(data.Time.UnixNano()) })
+
+	for _, metric := range systemMetrics {
+		var samples []sample
+		for _, state := range targets {
+			if state.data == nil {
+				continue
+			}
+			for i := range state.data.Systems {
+				system := &state.data.Systems[i]
+				if system.Status == false {
+					continue
+				}
+				labels := append(state.deviceLabels(), label{"system", strconv.Itoa(i + 1)})
+				if metric.energy {
+					labels = append(labels, label{"unit", models.UnitQCode(state.data.UnitQ)})
+				}
+				samples = append(samples, sample{labels, metric.value(system)})
+			}
+		}
+		writeFamily(writer, metric.name, metric.kind, metric.help, samples)
+	}
+}
+
+// Метки данных прибора: заводской номер, драйвер и строка подключения
+func (state target) deviceLabels() []label {
+	return []label{{"serial", state.data.Serial}, {"driver", state.driver}, {"endpoint", state.job.Endpoint}}
+}
+
+func writeFamily(writer io.Writer, name string, kind string, help string, samples []sample) {
+	if len(samples) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(writer, "# HELP %s %s\n", name, help)
+	_, _ = fmt.Fprintf(writer, "# TYPE %s %s\n", name, kind)
+	for _, item := range samples {
+		var line strings.Builder
+		line.WriteString(name)
+		line.WriteByte('{')
+		for i, label := range item.labels {
+			if i > 0 {
+				line.WriteByte(',')
+			}
+			line.WriteString(label.name)
+			line.WriteString(`="`)
+			line.WriteString(labelEscaper.Replace(label.value))
+			line.WriteByte('"')
+		}
+		line.WriteString("} ")
+		line.WriteString(item.value)
+		line.WriteByte('\n')
+		_, _ = io.WriteString(writer, line.String())
+	}
+}
+
+// Экранирование значений меток: обратная косая черта, кавычка и перевод строки
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Значения NaN и бесконечность выводятся как NaN, +Inf, -Inf
+func float64Value(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func float32Value(value float32) string {
+	return strconv.FormatFloat(float64(value), 'g', -1, 32)
+}
+
+func uint64Value(value uint64) string {
+	return strconv.FormatUint(value, 10)
+}
+
+func boolValue(value bool) string {
+	if value {
+		return "1"
+	}
+	return "0"
+}
+
+// Время Unix в секундах с миллисекундами
+func unixValue(nanoseconds int64) string {
+	return strconv.FormatFloat(float64(nanoseconds/1e6)/1e3, 'f', -1, 64)
+}
@@ -0,0 +1,92 @@
+package influx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+/**
+Запись строк InfluxDB line protocol (models.InfluxFormat) в InfluxDB или совместимую базу по HTTP.
+Строки накапливаются в буфере и отправляются одним запросом Flush, например, после пакетного опроса.
+URL - полный адрес записи с параметрами базы, время строк - в наносекундах:
+
+	http://localhost:8086/write?db=heat                                  InfluxDB 1.x, VictoriaMetrics
+	http://localhost:8086/api/v2/write?org=home&bucket=heat&precision=ns  InfluxDB 2.x
+*/
+type Writer struct {
+	URL     string        // Адрес записи
+	Token   string        // Токен InfluxDB 2.x, заголовок Authorization: Token. Пустой - без авторизации
+	Timeout time.Duration // Время ожидания ответа базы, 0 - 30 секунд
+	Client  *http.Client  // HTTP клиент, nil - http.DefaultClient
+
+	buffer bytes.Buffer
+}
+
+func (writer *Writer) Write(p []byte) (int, error) {
+	return writer.buffer.Write(p)
+}
+
+// Количество строк, ожидающих отправки
+func (writer *Writer) Lines() int {
+	return bytes.Count(writer.buffer.Bytes(), []byte{'\n'})
+}
+
+/**
+Отправка накопленных строк. Пустой буфер не отправляется. Буфер очищается и при ошибке, чтобы
+при повторных вызовах строки не записывались дважды. Ответ базы с кодом не 2xx возвращается как *WriteError.
+*/
+func (writer *Writer) Flush(ctx context.Context) error {
+	if writer.buffer.Len() == 0 {
+		return nil
+	}
+	body := append([]byte(nil), writer.buffer.Bytes()...)
+	writer.buffer.Reset()
+
+	timeout := writer.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, writer.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if writer.Token != "" {
+		request.Header.Set("Authorization", "Token "+writer.Token)
+	}
+	client := writer.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 4096))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return &WriteError{Status: response.StatusCode, Message: strings.TrimSpace(string(message))}
+	}
+	return nil
+}
+
+/**
+База отклонила запись: неверные строки, нет базы или нет доступа
+*/
+type WriteError struct {
+	Status  int    // Код ответа HTTP
+	Message string // Текст ответа базы
+}
+
+func (e *WriteError) Error() string {
+	return fmt.Sprintf("запись в InfluxDB отклонена, код %d: %s", e.Status, e.Message)
+}
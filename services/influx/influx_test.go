@@ -0,0 +1,55 @@
+package influx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	var body, authorization, query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		body, authorization, query = string(data), r.Header.Get("Authorization"), r.URL.RawQuery
+		if r.URL.Query().Get("db") == "missing" {
+			http.Error(w, `{"error":"database not found: \"missing\""}`, http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	writer := &Writer{URL: server.URL + "/write?db=heat", Token: "secret"}
+	if err := writer.Flush(context.Background()); err != nil || body != "" {
+		t.Errorf("пустой буфер не отправляется: %v, %q", err, body)
+	}
+	_, _ = fmt.Fprint(writer, "qbox,serial=1,system=1 t1=72.5\n")
+	_, _ = fmt.Fprint(writer, "qbox,serial=1,system=2 t1=70\n")
+	if writer.Lines() != 2 {
+		t.Errorf("строк %d, ожидалось 2", writer.Lines())
+	}
+	if err := writer.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if body != "qbox,serial=1,system=1 t1=72.5\nqbox,serial=1,system=2 t1=70\n" || authorization != "Token secret" ||
+		query != "db=heat" {
+		t.Errorf("запрос: %q, %q, %q", body, authorization, query)
+	}
+	if writer.Lines() != 0 {
+		t.Error("буфер не очищен")
+	}
+
+	writer = &Writer{URL: server.URL + "/write?db=missing"}
+	_, _ = fmt.Fprint(writer, "qbox t1=1\n")
+	var writeError *WriteError
+	if err := writer.Flush(context.Background()); !errors.As(err, &writeError) || writeError.Status != http.StatusNotFound {
+		t.Errorf("ожидалась ошибка записи, получено %v", err)
+	}
+	if authorization != "" {
+		t.Errorf("заголовок авторизации без токена: %q", authorization)
+	}
+}
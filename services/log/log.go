@@ -1,12 +1,24 @@
 package log
 
 import (
-	"github.com/go-ozzo/ozzo-log"
 	"fmt"
+	"github.com/go-ozzo/ozzo-log"
+	"io"
+	"os"
 )
 
 type LoggerService struct {
-	logger *log.Logger
+	logger  *log.Logger
+	prefix  string    // добавляется к категории сообщений, см. WithPrefix
+	console io.Writer // вывод сообщений Fatal, см. SetConsole
+}
+
+// Логгер поверх уже настроенного ozzo-log. Используется, когда qBox подключён как библиотека
+// и логом управляет вызывающая программа. Закрывать такой логгер не требуется.
+func NewLoggerService(logger *log.Logger) LoggerService {
+	l := LoggerService{logger: logger}
+	l.Check("app")
+	return l
 }
 
 func (l *LoggerService) Open(devEnv bool, silentMode bool) error {
@@ -34,6 +46,12 @@ func (l *LoggerService) Open(devEnv bool, silentMode bool) error {
 	return nil
 }
 
+// Вывод сообщений Fatal. По умолчанию - стандартный вывод. При структурированном формате результата сообщения
+// выводятся в stderr, чтобы не смешиваться с документом на стандартном выводе. Задаётся до копирования логгера.
+func (l *LoggerService) SetConsole(writer io.Writer) {
+	l.console = writer
+}
+
 func (l *LoggerService) Close() {
 	l.Check("app")
 	l.Info("Закончено")
@@ -42,7 +60,16 @@ func (l *LoggerService) Close() {
 }
 
 func (l *LoggerService) Check(category string) {
-	l.logger = l.logger.GetLogger(category)
+	l.logger = l.logger.GetLogger(l.prefix + category)
+}
+
+// Копия логгера, к категориям сообщений которой добавляется prefix.
+// Используется при параллельном опросе, чтобы в общем логе различать сообщения разных теплосчётчиков.
+// Копию закрывать не требуется.
+func (l LoggerService) WithPrefix(prefix string) LoggerService {
+	l.prefix = prefix
+	l.Check("app")
+	return l
 }
 
 func (l LoggerService) Info(format string, a ...interface{}) {
@@ -56,11 +83,18 @@ func (l LoggerService) Info(format string, a ...interface{}) {
 func (l LoggerService) Fatal(format string, a ...interface{}) {
 	if len(a) > 0 {
 		l.logger.Emergency(format, a...)
-		fmt.Printf(format+"\n", a)
+		_, _ = fmt.Fprintf(l.consoleWriter(), format+"\n", a...)
 	} else {
 		l.logger.Emergency(format)
-		fmt.Println(format)
+		_, _ = fmt.Fprintln(l.consoleWriter(), format)
+	}
+}
+
+func (l LoggerService) consoleWriter() io.Writer {
+	if l.console == nil {
+		return os.Stdout
 	}
+	return l.console
 }
 
 func (l LoggerService) Error(format string, a ...interface{}) {
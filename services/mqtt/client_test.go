@@ -0,0 +1,349 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	stdnet "net"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Сеанс клиента тестового брокера
+type brokerSession struct {
+	clientID string
+	username string
+	password string
+	graceful bool // Клиент отключился пакетом DISCONNECT
+}
+
+/**
+Локальный брокер MQTT 3.1.1 для тестов: подтверждает сообщения по QoS, передаёт их в канал messages
+и публикует Last-Will при разрыве соединения без DISCONNECT. Код refuse отклоняет подключения.
+*/
+type testBroker struct {
+	listener stdnet.Listener
+	refuse   byte
+	messages chan Message
+	sessions chan brokerSession
+}
+
+func startBroker(t *testing.T, listener stdnet.Listener) *testBroker {
+	broker := &testBroker{listener: listener, messages: make(chan Message, 16), sessions: make(chan brokerSession, 4)}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go broker.serve(conn)
+		}
+	}()
+	return broker
+}
+
+func (broker *testBroker) serve(conn stdnet.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	send := func(p packet) {
+		encoded, _ := p.encode()
+		_, _ = conn.Write(encoded)
+	}
+
+	connect, err := readPacket(reader)
+	if err != nil || connect.kind() != packetConnect {
+		return
+	}
+	var session brokerSession
+	body := connect.body
+	next := func() []byte {
+		length := int(binary.BigEndian.Uint16(body))
+		value := body[2 : 2+length]
+		body = body[2+length:]
+		return value
+	}
+	next() // Имя протокола
+	flags := body[1]
+	body = body[4:]
+	session.clientID = string(next())
+	var will *Message
+	if flags&0x04 != 0 {
+		will = &Message{Topic: string(next()), QoS: flags >> 3 & 0x03, Retain: flags&0x20 != 0}
+		will.Payload = next()
+	}
+	if flags&0x80 != 0 {
+		session.username = string(next())
+	}
+	if flags&0x40 != 0 {
+		session.password = string(next())
+	}
+	send(packet{packetConnack << 4, []byte{0, broker.refuse}})
+	if broker.refuse != 0 {
+		return
+	}
+
+	for {
+		received, err := readPacket(reader)
+		if err != nil {
+			break
+		}
+		switch received.kind() {
+		case packetPublish:
+			message := Message{QoS: received.header >> 1 & 0x03, Retain: received.header&0x01 != 0}
+			length := int(binary.BigEndian.Uint16(received.body))
+			message.Topic = string(received.body[2 : 2+length])
+			payload := received.body[2+length:]
+			var id uint16
+			if message.QoS > 0 {
+				id = binary.BigEndian.Uint16(payload)
+				payload = payload[2:]
+			}
+			message.Payload = payload
+			broker.messages <- message
+			switch message.QoS {
+			case 1:
+				send(ackPacket(packetPuback, id))
+			case 2:
+				send(ackPacket(packetPubrec, id))
+			}
+		case packetPubrel:
+			id, _ := received.id()
+			send(ackPacket(packetPubcomp, id))
+		case packetPingreq:
+			send(packet{header: packetPingresp << 4})
+		case packetDisconnect:
+			session.graceful = true
+		}
+		if session.graceful {
+			break
+		}
+	}
+	if !session.graceful && will != nil {
+		broker.messages <- *will
+	}
+	broker.sessions <- session
+}
+
+func (broker *testBroker) url(scheme string) string {
+	return scheme + "://" + broker.listener.Addr().String()
+}
+
+func (broker *testBroker) message(t *testing.T) Message {
+	t.Helper()
+	select {
+	case message := <-broker.messages:
+		return message
+	case <-time.After(2 * time.Second):
+		t.Fatal("брокер не получил сообщение")
+	}
+	return Message{}
+}
+
+func (broker *testBroker) session(t *testing.T) brokerSession {
+	t.Helper()
+	select {
+	case session := <-broker.sessions:
+		return session
+	case <-time.After(2 * time.Second):
+		t.Fatal("соединение с брокером не закрыто")
+	}
+	return brokerSession{}
+}
+
+func listen(t *testing.T) stdnet.Listener {
+	listener, err := stdnet.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return listener
+}
+
+func TestPublish(t *testing.T) {
+	broker := startBroker(t, listen(t))
+	ctx := context.Background()
+	client, err := Connect(ctx, Options{
+		URL:      "mqtt://heat:secret@" + broker.listener.Addr().String(),
+		ClientID: "qbox-test",
+		Will:     &Message{Topic: "heat/status", Payload: []byte("failed")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for qos := byte(0); qos <= 2; qos++ {
+		sent := Message{Topic: "heat/104001/1", Payload: []byte{'0' + qos}, QoS: qos, Retain: qos == 1}
+		if err = client.Publish(ctx, sent); err != nil {
+			t.Fatalf("QoS %d: %v", qos, err)
+		}
+		received := broker.message(t)
+		if received.Topic != sent.Topic || string(received.Payload) != string(sent.Payload) ||
+			received.QoS != qos || received.Retain != sent.Retain {
+			t.Errorf("получено %+v, отправлено %+v", received, sent)
+		}
+	}
+	if err = client.Publish(ctx, Message{Topic: "heat/+/1"}); err == nil {
+		t.Error("тема с + не должна публиковаться")
+	}
+
+	if err = client.Disconnect(); err != nil {
+		t.Fatal(err)
+	}
+	session := broker.session(t)
+	expected := brokerSession{clientID: "qbox-test", username: "heat", password: "secret", graceful: true}
+	if session != expected {
+		t.Errorf("сеанс %+v, ожидался %+v", session, expected)
+	}
+	select {
+	case message := <-broker.messages:
+		t.Errorf("Last-Will при штатном отключении: %+v", message)
+	default:
+	}
+}
+
+func TestWill(t *testing.T) {
+	broker := startBroker(t, listen(t))
+	publisher := &Publisher{Options: Options{
+		URL:  broker.url("tcp"),
+		Will: &Message{Topic: "heat/qbox/status", Payload: []byte("failed"), QoS: 1, Retain: true},
+	}}
+	if err := publisher.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := publisher.Close(true); err != nil {
+		t.Fatal(err)
+	}
+	will := broker.message(t)
+	if will.Topic != "heat/qbox/status" || string(will.Payload) != "failed" || will.QoS != 1 || !will.Retain {
+		t.Errorf("Last-Will %+v", will)
+	}
+	if session := broker.session(t); session.graceful {
+		t.Error("соединение должно быть разорвано без DISCONNECT")
+	}
+}
+
+func TestConnectRefused(t *testing.T) {
+	broker := startBroker(t, listen(t))
+	broker.refuse = 5
+	_, err := Connect(context.Background(), Options{URL: broker.url("mqtt")})
+	var connectError *ConnectError
+	if !errors.As(err, &connectError) || connectError.Code != 5 {
+		t.Errorf("ожидался отказ с кодом 5, получено %v", err)
+	}
+
+	for _, address := range []string{"http://localhost", "mqtt://", "localhost:1883"} {
+		if _, err = Connect(context.Background(), Options{URL: address}); err == nil {
+			t.Errorf("адрес %s не должен приниматься", address)
+		}
+	}
+}
+
+func TestTLSClientCertificate(t *testing.T) {
+	directory := t.TempDir()
+	caCertificate, caKey := createCertificate(t, directory, "ca", nil, nil)
+	createCertificate(t, directory, "server", caCertificate, caKey)
+	createCertificate(t, directory, "client", caCertificate, caKey)
+	serverCertificate, err := tls.LoadX509KeyPair(filepath.Join(directory, "server.pem"), filepath.Join(directory, "server.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(caCertificate)
+	listener := tls.NewListener(listen(t), &tls.Config{
+		Certificates: []tls.Certificate{serverCertificate},
+		ClientCAs:    roots,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	broker := startBroker(t, listener)
+	address := "mqtts://localhost:" + strconv.Itoa(listener.Addr().(*stdnet.TCPAddr).Port)
+
+	config, err := LoadTLSConfig(filepath.Join(directory, "ca.pem"), filepath.Join(directory, "client.pem"),
+		filepath.Join(directory, "client.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := Connect(context.Background(), Options{URL: address, TLS: config})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = client.Publish(context.Background(), Message{Topic: "heat/tls", Payload: []byte("1"), QoS: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if message := broker.message(t); message.Topic != "heat/tls" {
+		t.Errorf("получено %+v", message)
+	}
+	_ = client.Disconnect()
+
+	// Без сертификата клиента брокер разрывает соединение
+	config, err = LoadTLSConfig(filepath.Join(directory, "ca.pem"), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client, err = Connect(context.Background(), Options{URL: address, TLS: config}); err == nil {
+		err = client.Publish(context.Background(), Message{Topic: "heat/tls", QoS: 1})
+		_ = client.Close()
+	}
+	if err == nil {
+		t.Error("подключение без сертификата клиента должно завершаться ошибкой")
+	}
+}
+
+/**
+Сертификат с ключом в файлах name.pem и name.key. Без parent - самоподписанный корневой сертификат,
+иначе - сертификат localhost, подписанный parent
+*/
+func createCertificate(t *testing.T, directory string, name string, parent *x509.Certificate,
+	parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificatePem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err = ioutil.WriteFile(filepath.Join(directory, name+".pem"), certificatePem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(directory, name+".key"), keyPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certificate, key
+}
@@ -0,0 +1,150 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Типы пакетов MQTT 3.1.1, старшие 4 бита первого байта пакета
+const (
+	packetConnect    byte = 1
+	packetConnack    byte = 2
+	packetPublish    byte = 3
+	packetPuback     byte = 4
+	packetPubrec     byte = 5
+	packetPubrel     byte = 6
+	packetPubcomp    byte = 7
+	packetPingreq    byte = 12
+	packetPingresp   byte = 13
+	packetDisconnect byte = 14
+)
+
+// Наибольшая длина пакета, которую можно закодировать в заголовке: 4 байта переменной длины
+const maxRemainingLength = 268435455
+
+// Пакет: первый байт (тип и флаги) и содержимое после заголовка
+type packet struct {
+	header byte
+	body   []byte
+}
+
+func (p packet) kind() byte {
+	return p.header >> 4
+}
+
+// Пакет в байтах: первый байт, длина содержимого в переменном формате и содержимое
+func (p packet) encode() ([]byte, error) {
+	length := len(p.body)
+	if length > maxRemainingLength {
+		return nil, fmt.Errorf("пакет MQTT слишком большой: %d байт", length)
+	}
+	encoded := make([]byte, 0, length+5)
+	encoded = append(encoded, p.header)
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		encoded = append(encoded, digit)
+		if length == 0 {
+			break
+		}
+	}
+	return append(encoded, p.body...), nil
+}
+
+func readPacket(reader *bufio.Reader) (packet, error) {
+	header, err := reader.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		digit, err := reader.ReadByte()
+		if err != nil {
+			return packet{}, err
+		}
+		if i == 4 {
+			return packet{}, errors.New("неверная длина пакета MQTT")
+		}
+		length += int(digit&0x7F) * multiplier
+		multiplier *= 128
+		if digit&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	if _, err = io.ReadFull(reader, body); err != nil {
+		return packet{}, err
+	}
+	return packet{header, body}, nil
+}
+
+// Строка или двоичные данные с длиной в 2 байтах
+func appendString(buffer []byte, value []byte) []byte {
+	buffer = append(buffer, byte(len(value)>>8), byte(len(value)))
+	return append(buffer, value...)
+}
+
+func connectPacket(clientID string, username string, password string, keepAlive uint16, will *Message) packet {
+	flags := byte(0x02) // Чистый сеанс: брокер не хранит подписки и сообщения клиента
+	if will != nil {
+		flags |= 0x04 | will.QoS<<3
+		if will.Retain {
+			flags |= 0x20
+		}
+	}
+	if username != "" {
+		flags |= 0x80
+	}
+	if password != "" {
+		flags |= 0x40
+	}
+
+	body := appendString(nil, []byte("MQTT"))
+	body = append(body, 4, flags, byte(keepAlive>>8), byte(keepAlive))
+	body = appendString(body, []byte(clientID))
+	if will != nil {
+		body = appendString(body, []byte(will.Topic))
+		body = appendString(body, will.Payload)
+	}
+	if username != "" {
+		body = appendString(body, []byte(username))
+	}
+	if password != "" {
+		body = appendString(body, []byte(password))
+	}
+	return packet{packetConnect << 4, body}
+}
+
+func publishPacket(message Message, id uint16) packet {
+	header := packetPublish<<4 | message.QoS<<1
+	if message.Retain {
+		header |= 0x01
+	}
+	body := appendString(nil, []byte(message.Topic))
+	if message.QoS > 0 {
+		body = append(body, byte(id>>8), byte(id))
+	}
+	return packet{header, append(body, message.Payload...)}
+}
+
+// Пакеты подтверждения PUBACK, PUBREC, PUBREL, PUBCOMP: только идентификатор сообщения
+func ackPacket(kind byte, id uint16) packet {
+	header := kind << 4
+	if kind == packetPubrel {
+		header |= 0x02
+	}
+	return packet{header, []byte{byte(id >> 8), byte(id)}}
+}
+
+func (p packet) id() (uint16, error) {
+	if len(p.body) < 2 {
+		return 0, fmt.Errorf("неверный пакет MQTT типа %d", p.kind())
+	}
+	return binary.BigEndian.Uint16(p.body), nil
+}
@@ -0,0 +1,283 @@
+package mqtt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"qBox/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Форматы сообщений публикации
+const (
+	PayloadJson2 = "json2" // Документ json2, см. models.Json2Format
+	PayloadFlat  = "flat"  // Плоский JSON объект: ключ - значение без единиц измерения
+)
+
+/**
+Публикация данных теплосчётчиков. Тема задаётся шаблоном с подстановками {serial}, {system}, {driver},
+{number}, {address} и метками задания пакетного опроса по имени, например heat/{house}/{serial}.
+Шаблон с {system} публикует сообщение на каждую активную систему, без {system} - одно сообщение на прибор.
+Символы / + # в подставляемых значениях заменяются на _, пустое значение - на _.
+
+	publisher := &mqtt.Publisher{Options: mqtt.Options{URL: "mqtt://localhost"}, Topic: "heat/{serial}/{system}", QoS: 1}
+	err := publisher.Connect(ctx)
+	count, err := publisher.Publish(ctx, data, models.Json2Format{Driver: "tem104"})
+	err = publisher.Close(pollErr != nil)
+*/
+type Publisher struct {
+	Options Options
+	Topic   string // Шаблон темы
+	Payload string // Формат сообщений: PayloadJson2 или PayloadFlat, пустой - json2
+	QoS     byte
+	Retain  bool // Брокер хранит последнее значение темы
+
+	client *Client
+}
+
+// Подключение к брокеру с Last-Will из Options::Will
+func (publisher *Publisher) Connect(ctx context.Context) error {
+	client, err := Connect(ctx, publisher.Options)
+	if err != nil {
+		return err
+	}
+	publisher.client = client
+	return nil
+}
+
+/**
+Публикация успешно прочитанных данных прибора. source - сведения об опросе: драйвер, номер, адрес,
+строка подключения и метки задания, как у формата json2. Возвращает количество опубликованных сообщений.
+*/
+func (publisher *Publisher) Publish(ctx context.Context, device *models.DataDevice, source models.Json2Format) (int, error) {
+	messages, err := publisher.Messages(device, source)
+	if err != nil {
+		return 0, err
+	}
+	for i, message := range messages {
+		if err = publisher.client.Publish(ctx, message); err != nil {
+			return i, err
+		}
+	}
+	return len(messages), nil
+}
+
+/**
+Завершение публикации. При failed соединение разрывается без отключения, и брокер публикует Last-Will:
+подписчики узнают, что опрос не удался. Иначе Last-Will отменяется штатным отключением.
+*/
+func (publisher *Publisher) Close(failed bool) error {
+	if publisher.client == nil {
+		return nil
+	}
+	client := publisher.client
+	publisher.client = nil
+	if failed {
+		return client.Close()
+	}
+	return client.Disconnect()
+}
+
+// Сообщения данных прибора без отправки
+func (publisher *Publisher) Messages(device *models.DataDevice, source models.Json2Format) ([]Message, error) {
+	if device.Detected != nil {
+		source.Driver = device.Detected.Driver
+	}
+	values := map[string]string{
+		"serial":  device.Serial,
+		"driver":  source.Driver,
+		"number":  strconv.Itoa(int(source.Number)),
+		"address": source.Address,
+	}
+	for name, value := range source.Tags {
+		if _, reserved := values[name]; !reserved && name != "system" {
+			values[name] = value
+		}
+	}
+
+	var messages []Message
+	if !strings.Contains(publisher.Topic, "{system}") {
+		payload, err := publisher.payload(device, source, 0)
+		if err != nil {
+			return nil, err
+		}
+		return []Message{publisher.message(ExpandTopic(publisher.Topic, values), payload)}, nil
+	}
+	for i, system := range device.Systems {
+		if system.Status == false {
+			continue
+		}
+		values["system"] = strconv.Itoa(i + 1)
+		payload, err := publisher.payload(device, source, i+1)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, publisher.message(ExpandTopic(publisher.Topic, values), payload))
+	}
+	return messages, nil
+}
+
+func (publisher *Publisher) message(topic string, payload []byte) Message {
+	return Message{Topic: topic, Payload: payload, QoS: publisher.QoS, Retain: publisher.Retain}
+}
+
+// Сообщение прибора (system = 0) или одной системы прибора
+func (publisher *Publisher) payload(device *models.DataDevice, source models.Json2Format, system int) ([]byte, error) {
+	switch publisher.Payload {
+	case PayloadJson2, "":
+		if system > 0 {
+			device = device.Clone()
+			for i := range device.Systems {
+				device.Systems[i].Status = device.Systems[i].Status && i+1 == system
+			}
+		}
+		var buffer bytes.Buffer
+		source.Render(&buffer, device)
+		return bytes.TrimSpace(buffer.Bytes()), nil
+	case PayloadFlat:
+		return flatPayload(device, source, system), nil
+	}
+	return nil, fmt.Errorf("неверный формат сообщений MQTT %q, возможно: json2, flat", publisher.Payload)
+}
+
+/**
+Плоский JSON объект. Сообщение системы содержит номер системы и её показания, сообщение прибора -
+показания всех активных систем с префиксом system<номер>., например system1.sigmaQ.
+Энергия - в единицах unitQ, значения не числа (NaN) выводятся как null.
+*/
+func flatPayload(device *models.DataDevice, source models.Json2Format, system int) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	add := func(key string, value string) {
+		if buffer.Len() > 1 {
+			buffer.WriteByte(',')
+		}
+		encoded, _ := json.Marshal(key)
+		buffer.Write(encoded)
+		buffer.WriteByte(':')
+		buffer.WriteString(value)
+	}
+	text := func(value string) string {
+		encoded, _ := json.Marshal(value)
+		return string(encoded)
+	}
+
+	add("serial", text(device.Serial))
+	add("driver", text(source.Driver))
+	add("number", strconv.Itoa(int(source.Number)))
+	add("timeRequest", text(device.TimeRequest.Format(time.RFC3339)))
+	if !device.Time.IsZero() {
+		add("timeDevice", text(device.Time.Format(time.RFC3339)))
+	}
+	add("unitQ", text(models.UnitQCode(device.UnitQ)))
+	for i := range device.Systems {
+		if device.Systems[i].Status == false || system > 0 && i+1 != system {
+			continue
+		}
+		prefix := fmt.Sprintf("system%d.", i+1)
+		if system > 0 {
+			add("system", strconv.Itoa(system))
+			prefix = ""
+		}
+		for _, field := range systemFields(&device.Systems[i]) {
+			add(prefix+field.name, field.value)
+		}
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes()
+}
+
+type flatField struct {
+	name  string
+	value string
+}
+
+// Показания системы, имена как в формате json2
+func systemFields(system *models.SystemDevice) []flatField {
+	return []flatField{
+		{"timeRunSys", strconv.FormatUint(uint64(system.TimeRunSys), 10)},
+		{"sigmaQ", number(system.SigmaQ, 64)},
+		{"q1", number(system.Q1, 64)},
+		{"q2", number(system.Q2, 64)},
+		{"q3", number(system.Q3, 64)},
+		{"v1", number(system.V1, 64)},
+		{"v2", number(system.V2, 64)},
+		{"m1", number(system.M1, 64)},
+		{"m2", number(system.M2, 64)},
+		{"m3", number(system.M3, 64)},
+		{"gm1", number(float64(system.GM1), 32)},
+		{"gm2", number(float64(system.GM2), 32)},
+		{"gm3", number(float64(system.GM3), 32)},
+		{"gv1", number(float64(system.GV1), 32)},
+		{"gv2", number(float64(system.GV2), 32)},
+		{"gv3", number(float64(system.GV3), 32)},
+		{"t1", number(float64(system.T1), 32)},
+		{"t2", number(float64(system.T2), 32)},
+		{"t3", number(float64(system.T3), 32)},
+		{"tMakeup", number(float64(system.TMakeup), 32)},
+		{"p1", number(float64(system.P1), 32)},
+		{"p2", number(float64(system.P2), 32)},
+		{"p3", number(float64(system.P3), 32)},
+		{"pMakeup", number(float64(system.PMakeup), 32)},
+	}
+}
+
+func number(value float64, bitSize int) string {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return "null"
+	}
+	return strconv.FormatFloat(value, 'f', -1, bitSize)
+}
+
+/**
+Тема по шаблону. Подстановки без значения заменяются на _, символы / + # значений - на _,
+чтобы значение не меняло уровень темы и не превращало её в шаблон подписки.
+*/
+func ExpandTopic(template string, values map[string]string) string {
+	var topic strings.Builder
+	for {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			break
+		}
+		value := topicEscaper.Replace(values[template[start+1:start+end]])
+		if value == "" {
+			value = "_"
+		}
+		topic.WriteString(template[:start])
+		topic.WriteString(value)
+		template = template[start+end+1:]
+	}
+	topic.WriteString(template)
+	return topic.String()
+}
+
+var topicEscaper = strings.NewReplacer("/", "_", "+", "_", "#", "_", "\x00", "")
+
+/**
+Проверка шаблона темы: скобки подстановок парные, имена не пустые, вне подстановок
+нет символов + и #.
+*/
+func ValidateTemplate(template string) error {
+	rest := template
+	for {
+		start := strings.IndexAny(rest, "{}")
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if rest[start] == '}' || end < 0 || strings.ContainsAny(rest[start+1:start+end], "{/") || end == 1 {
+			return fmt.Errorf("неверный шаблон темы MQTT %q: ожидаются подстановки вида {serial}", template)
+		}
+		rest = rest[start+end+1:]
+	}
+	return ValidateTopic(ExpandTopic(template, nil))
+}
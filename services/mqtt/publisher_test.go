@@ -0,0 +1,82 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"math"
+	"qBox/models"
+	"testing"
+	"time"
+)
+
+func TestMessages(t *testing.T) {
+	moment := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	device := &models.DataDevice{
+		Serial:      "104001",
+		UnitQ:       models.Gcal,
+		TimeRequest: moment,
+		Systems: []models.SystemDevice{
+			{Status: false, SigmaQ: 1},
+			{Status: true, TimeRunSys: 3600, SigmaQ: 1234.5, T1: 72.5, P1: float32(math.NaN())},
+		},
+	}
+	source := models.Json2Format{Driver: "tem104", Number: 1, Tags: map[string]string{"house": "Ленина 17/2", "serial": "x"}}
+
+	publisher := &Publisher{Topic: "heat/{house}/{serial}/{system}", Payload: PayloadFlat, QoS: 1, Retain: true}
+	messages, err := publisher.Messages(device, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("сообщений %d, ожидалось по одному на активную систему", len(messages))
+	}
+	expected := `{"serial":"104001","driver":"tem104","number":1,"timeRequest":"2026-10-18T10:00:00Z","unitQ":"Gcal",` +
+		`"system":2,"timeRunSys":3600,"sigmaQ":1234.5,"q1":0,"q2":0,"q3":0,"v1":0,"v2":0,"m1":0,"m2":0,"m3":0,` +
+		`"gm1":0,"gm2":0,"gm3":0,"gv1":0,"gv2":0,"gv3":0,"t1":72.5,"t2":0,"t3":0,"tMakeup":0,"p1":null,"p2":0,"p3":0,"pMakeup":0}`
+	message := messages[0]
+	if message.Topic != "heat/Ленина 17_2/104001/2" || string(message.Payload) != expected || message.QoS != 1 || !message.Retain {
+		t.Errorf("сообщение %s %s", message.Topic, message.Payload)
+	}
+
+	// Сообщение на прибор в формате json2
+	publisher = &Publisher{Topic: "heat/{driver}/{address}", Payload: PayloadJson2}
+	messages, err = publisher.Messages(device, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var document struct {
+		Schema  string `json:"schema"`
+		Serial  string `json:"serial"`
+		Systems []struct {
+			System int `json:"system"`
+		} `json:"systems"`
+	}
+	if len(messages) != 1 || messages[0].Topic != "heat/tem104/_" {
+		t.Fatalf("сообщения %+v", messages)
+	}
+	if err = json.Unmarshal(messages[0].Payload, &document); err != nil {
+		t.Fatal(err)
+	}
+	if document.Schema != models.Json2Schema || document.Serial != "104001" || len(document.Systems) != 1 ||
+		document.Systems[0].System != 2 {
+		t.Errorf("документ %s", messages[0].Payload)
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	for template, valid := range map[string]bool{
+		"heat/{serial}/{system}": true,
+		"heat/{house}/{serial}":  true,
+		"heat/total":             true,
+		"heat/{serial":           false,
+		"heat/serial}":           false,
+		"heat/{}":                false,
+		"heat/{a/b}":             false,
+		"heat/+/{serial}":        false,
+		"heat/#":                 false,
+		"":                       false,
+	} {
+		if err := ValidateTemplate(template); (err == nil) != valid {
+			t.Errorf("шаблон %q: %v", template, err)
+		}
+	}
+}
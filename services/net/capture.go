@@ -0,0 +1,213 @@
+package net
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Формат файла записи обмена, см. CaptureHeader
+const CaptureFormat = "qcap/1"
+
+// Событие записи обмена
+type CaptureEventKind string
+
+const (
+	CaptureOpen    CaptureEventKind = "open"    // Установка соединения, при ошибке заполняется Error
+	CaptureClose   CaptureEventKind = "close"   // Закрытие соединения. Переподключение - close и следующий open
+	CaptureWrite   CaptureEventKind = "write"   // Запрос прибору
+	CaptureRead    CaptureEventKind = "read"    // Данные, полученные одним чтением
+	CaptureTimeout CaptureEventKind = "timeout" // Чтение завершилось по таймауту без данных
+	CaptureEOF     CaptureEventKind = "eof"     // Соединение закрыто другой стороной
+	CaptureError   CaptureEventKind = "error"   // Прочие ошибки чтения
+)
+
+/**
+Заголовок файла записи обмена (первая строка файла). Далее - по одному событию CaptureEvent в строке (JSON Lines):
+
+	{"format":"qcap/1","version":"0.0.5","start":"2026-10-18T10:00:00.1+03:00","endpoint":"192.168.12.1:4001","driver":"tem104","number":1}
+	{"time":"2026-10-18T10:00:00.15+03:00","event":"open"}
+	{"time":"2026-10-18T10:00:00.16+03:00","event":"write","data":"5501FE0F010300008018"}
+	{"time":"2026-10-18T10:00:00.41+03:00","event":"read","data":"AA01FE0F0180...","duration":"250ms"}
+	{"time":"2026-10-18T10:00:05.42+03:00","event":"timeout","duration":"5s"}
+*/
+type CaptureHeader struct {
+	Format   string    `json:"format"`
+	Version  string    `json:"version"` // Версия qBox
+	Start    time.Time `json:"start"`
+	Endpoint string    `json:"endpoint"`
+	Driver   string    `json:"driver"`
+	Number   byte      `json:"number"`
+	Address  string    `json:"address,omitempty"` // Вторичный адрес M-Bus
+	Args     []string  `json:"args,omitempty"`    // Аргументы командной строки
+}
+
+type CaptureEvent struct {
+	Time     time.Time        `json:"time"`
+	Kind     CaptureEventKind `json:"event"`
+	Data     string           `json:"data,omitempty"`     // Байты в шестнадцатеричном виде
+	Duration string           `json:"duration,omitempty"` // Время ожидания чтения
+	Error    string           `json:"error,omitempty"`
+}
+
+// Байты события
+func (event CaptureEvent) Bytes() ([]byte, error) {
+	return hex.DecodeString(event.Data)
+}
+
+/**
+Транспорт, записывающий весь обмен с прибором в файл записи обмена (см. CaptureHeader):
+соединения, запросы, ответы, таймауты и ошибки с временем. Каждое событие записывается сразу,
+поэтому запись сохраняется и при аварийном завершении программы.
+*/
+type CaptureTransport struct {
+	transport Transport
+	encoder   *json.Encoder
+	err       error // Первая ошибка записи файла
+	mutex     sync.Mutex
+}
+
+func NewCaptureTransport(transport Transport, writer io.Writer, header CaptureHeader) (*CaptureTransport, error) {
+	header.Format = CaptureFormat
+	if header.Start.IsZero() {
+		header.Start = time.Now()
+	}
+	capture := &CaptureTransport{transport: transport, encoder: json.NewEncoder(writer)}
+	if err := capture.encoder.Encode(header); err != nil {
+		return nil, fmt.Errorf("запись обмена: %w", err)
+	}
+	return capture, nil
+}
+
+func (capture *CaptureTransport) Open(ctx context.Context) error {
+	err := capture.transport.Open(ctx)
+	capture.record(CaptureEvent{Kind: CaptureOpen, Error: errorText(err)})
+	return err
+}
+
+func (capture *CaptureTransport) Close() error {
+	err := capture.transport.Close()
+	capture.record(CaptureEvent{Kind: CaptureClose, Error: errorText(err)})
+	return err
+}
+
+func (capture *CaptureTransport) Read(b []byte) (int, error) {
+	start := time.Now()
+	n, err := capture.transport.Read(b)
+	duration := time.Since(start).Round(time.Millisecond).String()
+	if n > 0 {
+		capture.record(CaptureEvent{Kind: CaptureRead, Data: fmt.Sprintf("%X", b[:n]), Duration: duration})
+	}
+	switch {
+	case err == nil:
+	case isTimeout(err):
+		capture.record(CaptureEvent{Kind: CaptureTimeout, Duration: duration})
+	case errors.Is(err, io.EOF):
+		capture.record(CaptureEvent{Kind: CaptureEOF})
+	default:
+		capture.record(CaptureEvent{Kind: CaptureError, Error: err.Error()})
+	}
+	return n, err
+}
+
+func (capture *CaptureTransport) Write(b []byte) (int, error) {
+	n, err := capture.transport.Write(b)
+	capture.record(CaptureEvent{Kind: CaptureWrite, Data: fmt.Sprintf("%X", b), Error: errorText(err)})
+	return n, err
+}
+
+func (capture *CaptureTransport) SetReadDeadline(t time.Time) error {
+	return capture.transport.SetReadDeadline(t)
+}
+
+func (capture *CaptureTransport) SetWriteDeadline(t time.Time) error {
+	return capture.transport.SetWriteDeadline(t)
+}
+
+func (capture *CaptureTransport) String() string {
+	return capture.transport.String()
+}
+
+// Первая ошибка записи файла. Обмен с прибором при ошибке записи не прерывается
+func (capture *CaptureTransport) Err() error {
+	capture.mutex.Lock()
+	defer capture.mutex.Unlock()
+	return capture.err
+}
+
+func (capture *CaptureTransport) record(event CaptureEvent) {
+	capture.mutex.Lock()
+	defer capture.mutex.Unlock()
+	if capture.err != nil {
+		return
+	}
+	event.Time = time.Now()
+	if err := capture.encoder.Encode(event); err != nil {
+		capture.err = fmt.Errorf("запись обмена: %w", err)
+	}
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// Чтение файла записи обмена, см. CaptureHeader
+func LoadCapture(path string) (CaptureHeader, []CaptureEvent, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return CaptureHeader{}, nil, err
+	}
+	defer file.Close()
+	header, events, err := ReadCapture(file)
+	if err != nil {
+		return header, events, fmt.Errorf("%s: %w", path, err)
+	}
+	return header, events, nil
+}
+
+func ReadCapture(reader io.Reader) (CaptureHeader, []CaptureEvent, error) {
+	var header CaptureHeader
+	var events []CaptureEvent
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if header.Format == "" {
+			if err := json.Unmarshal([]byte(text), &header); err != nil || header.Format != CaptureFormat {
+				return header, nil, fmt.Errorf("строка %d: ожидается заголовок записи обмена %s", line, CaptureFormat)
+			}
+			continue
+		}
+		var event CaptureEvent
+		if err := json.Unmarshal([]byte(text), &event); err != nil {
+			return header, events, fmt.Errorf("строка %d: %w", line, err)
+		}
+		if _, err := event.Bytes(); err != nil {
+			return header, events, fmt.Errorf("строка %d: данные события: %w", line, err)
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return header, events, err
+	}
+	if header.Format == "" {
+		return header, nil, errors.New("файл записи обмена пуст")
+	}
+	return header, events, nil
+}
@@ -0,0 +1,125 @@
+package net
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	ozzolog "github.com/go-ozzo/ozzo-log"
+	"qBox/services/log"
+)
+
+// Транспорт с заданной последовательностью результатов чтения: данные, таймаут (nil) или ошибка
+type scriptedTransport struct {
+	reads []interface{}
+}
+
+func (transport *scriptedTransport) Open(context.Context) error { return nil }
+func (transport *scriptedTransport) Close() error               { return nil }
+func (transport *scriptedTransport) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+func (transport *scriptedTransport) Read(b []byte) (int, error) {
+	if len(transport.reads) == 0 {
+		return 0, os.ErrDeadlineExceeded
+	}
+	result := transport.reads[0]
+	transport.reads = transport.reads[1:]
+	switch value := result.(type) {
+	case []byte:
+		return copy(b, value), nil
+	case error:
+		return 0, value
+	}
+	return 0, os.ErrDeadlineExceeded
+}
+func (transport *scriptedTransport) SetReadDeadline(time.Time) error  { return nil }
+func (transport *scriptedTransport) SetWriteDeadline(time.Time) error { return nil }
+func (transport *scriptedTransport) String() string                   { return "scripted" }
+
+func TestCaptureReplay(t *testing.T) {
+	// Таймаут, обрыв соединения с переподключением, ответ двумя частями
+	scripted := &scriptedTransport{reads: []interface{}{nil, io.EOF, []byte{0xAA, 0x01}, []byte{0x02, 0x03}}}
+	request := Request{
+		Bytes:              []byte{0x55, 0x01},
+		ControlFunction:    func(response []byte) bool { return len(response) == 4 },
+		Attempts:           1,
+		Reconnect:          true,
+		SecondsReadTimeout: 1,
+	}
+	logger := log.NewLoggerService(ozzolog.NewLogger())
+
+	var file bytes.Buffer
+	capture, err := NewCaptureTransport(scripted, &file, CaptureHeader{Driver: "tem104", Number: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	network := NewNetwork(capture, logger)
+	expected, err := network.RunIO(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := network.Stats(); stats != (Stats{Requests: 1, Timeouts: 1, Reconnects: 1}) {
+		t.Errorf("счётчики обмена: %+v", stats)
+	}
+	_ = network.Close()
+
+	header, events, err := ReadCapture(&file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Format != CaptureFormat || header.Driver != "tem104" || header.Number != 1 || header.Start.IsZero() {
+		t.Errorf("заголовок: %+v", header)
+	}
+	var kinds []CaptureEventKind
+	for _, event := range events {
+		kinds = append(kinds, event.Kind)
+	}
+	expectedKinds := []CaptureEventKind{CaptureOpen, CaptureWrite, CaptureTimeout, CaptureWrite, CaptureEOF,
+		CaptureClose, CaptureOpen, CaptureWrite, CaptureRead, CaptureRead, CaptureClose}
+	if len(kinds) != len(expectedKinds) {
+		t.Fatalf("события %v, ожидались %v", kinds, expectedKinds)
+	}
+	for i := range kinds {
+		if kinds[i] != expectedKinds[i] {
+			t.Fatalf("события %v, ожидались %v", kinds, expectedKinds)
+		}
+	}
+
+	replay := NewReplayTransport(events, nil)
+	network = NewNetwork(replay, logger)
+	actual, err := network.RunIO(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = network.Close()
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("воспроизведён ответ %X, записан %X", actual, expected)
+	}
+	if replay.Remaining() != 0 {
+		t.Errorf("не воспроизведено событий: %d", replay.Remaining())
+	}
+
+	// Запрос после окончания записи - ошибка
+	if _, err = network.RunIO(context.Background(), request); err == nil {
+		t.Error("ожидалась ошибка запроса после окончания записи")
+	}
+	if stats := network.Stats(); stats.Requests != 2 || stats.Failures != 1 {
+		t.Errorf("счётчики обмена воспроизведения: %+v", stats)
+	}
+}
+
+func TestReadCaptureErrors(t *testing.T) {
+	for _, text := range []string{
+		"",
+		`{"time":"2026-10-18T10:00:00Z","event":"open"}`,
+		"{\"format\":\"qcap/1\"}\n{\"event\":\"read\",\"data\":\"5G\"}",
+	} {
+		if _, _, err := ReadCapture(bytes.NewBufferString(text)); err == nil {
+			t.Errorf("%q: ожидалась ошибка", text)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Обмен через транспорт, соединение которого не установлено или закрыто
+var ErrNotConnected = errors.New("соединение не установлено")
+
+/**
+Ошибка в строке подключения к теплосчётчику
+*/
+type EndpointError struct {
+	Endpoint string
+	Err      error
+}
+
+func (e *EndpointError) Error() string {
+	return fmt.Sprintf("некорректная строка подключения \"%s\": %s", e.Endpoint, e.Err.Error())
+}
+
+func (e *EndpointError) Unwrap() error {
+	return e.Err
+}
+
+/**
+Ошибка определения IP адреса по имени узла (DNS)
+*/
+type ResolveError struct {
+	Host string
+	Err  error
+}
+
+func (e *ResolveError) Error() string {
+	return fmt.Sprintf("не удалось определить адрес узла %s: %s", e.Host, e.Err.Error())
+}
+
+func (e *ResolveError) Unwrap() error {
+	return e.Err
+}
+
+/**
+Ошибка установки соединения с определённым адресом
+*/
+type DialError struct {
+	Address string
+	Err     error
+}
+
+func (e *DialError) Error() string {
+	return fmt.Sprintf("не удалось установить соединение с %s: %s", e.Address, e.Err.Error())
+}
+
+func (e *DialError) Unwrap() error {
+	return e.Err
+}
+
+// Соединение не установлено по истечении таймаута подключения
+func (e *DialError) Timeout() bool {
+	return isTimeout(e.Err)
+}
+
+/**
+Обмен данными прерван: истёк общий срок опроса или опрос отменён (например, по сигналу SIGINT)
+*/
+type InterruptedError struct {
+	Err error // context.DeadlineExceeded или context.Canceled
+}
+
+func (e *InterruptedError) Error() string {
+	if errors.Is(e.Err, context.DeadlineExceeded) {
+		return "обмен данными прерван: истекло время опроса"
+	}
+	return "обмен данными прерван: опрос отменён"
+}
+
+func (e *InterruptedError) Unwrap() error {
+	return e.Err
+}
+
+/**
+Прибор не ответил на запрос: все попытки обмена завершились без данных
+*/
+type NoResponseError struct {
+}
+
+func (e *NoResponseError) Error() string {
+	return "прибор не ответил на запрос"
+}
+
+/**
+Ответ прибора не прошёл проверку Request.ControlFunction во всех попытках обмена:
+не совпала контрольная сумма, заголовок или длина ответа
+*/
+type ResponseError struct {
+	Response []byte // Последний полученный ответ
+}
+
+func (e *ResponseError) Error() string {
+	return "получен некорректный ответ"
+}
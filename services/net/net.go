@@ -2,6 +2,7 @@ package net
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io"
 	"net"
@@ -15,25 +16,72 @@ const connected = 0x01
 const disconnected = 0x02
 
 /**
-Сервис для работы с TCP соединением
+Сервис для работы с соединением. Само соединение устанавливается через Transport (TCP, последовательный порт)
 */
 type Network struct {
-	host             string
-	port             int
-	connection       *net.TCPConn
+	transport        Transport
 	logger           log.LoggerService
 	connectionStatus byte
+	stats            Stats
 }
 
-func NewNetwork(ip string, port int, logger log.LoggerService) *Network {
-	return &Network{host: ip, port: port, logger: logger, connectionStatus: disconnected}
+/**
+Счётчики обмена RunIO с момента создания Network. Используются для наблюдения за качеством связи,
+например, экспортёром метрик. Разность счётчиков до и после опроса - см. Stats::Sub.
+*/
+type Stats struct {
+	Requests   uint64 // Запросы RunIO
+	Retries    uint64 // Повторные попытки: ответ не прошёл проверку
+	Timeouts   uint64 // Таймауты чтения без ответа и сбросы соединения шлюзом, после которых запрос отправлялся заново
+	Reconnects uint64 // Переподключения по EOF
+	Failures   uint64 // Запросы, завершившиеся ошибкой
+}
+
+// Разность счётчиков: обмен после снимка before
+func (stats Stats) Sub(before Stats) Stats {
+	return Stats{
+		Requests:   stats.Requests - before.Requests,
+		Retries:    stats.Retries - before.Retries,
+		Timeouts:   stats.Timeouts - before.Timeouts,
+		Reconnects: stats.Reconnects - before.Reconnects,
+		Failures:   stats.Failures - before.Failures,
+	}
+}
+
+// Сумма счётчиков, например, нескольких опросов
+func (stats Stats) Add(other Stats) Stats {
+	return Stats{
+		Requests:   stats.Requests + other.Requests,
+		Retries:    stats.Retries + other.Retries,
+		Timeouts:   stats.Timeouts + other.Timeouts,
+		Reconnects: stats.Reconnects + other.Reconnects,
+		Failures:   stats.Failures + other.Failures,
+	}
+}
+
+func NewNetwork(transport Transport, logger log.LoggerService) *Network {
+	return &Network{transport: transport, logger: logger, connectionStatus: disconnected}
+}
+
+// Замена логгера. Используется, когда одно соединение последовательно обслуживает несколько теплосчётчиков
+func (network *Network) SetLogger(logger log.LoggerService) {
+	network.logger = logger
+}
+
+// Счётчики обмена. Network не используется параллельно, поэтому счётчики читаются между запросами
+func (network *Network) Stats() Stats {
+	return network.stats
 }
 
 func (network *Network) IsConnected() bool {
 	return network.connectionStatus == connected
 }
 
-func (network *Network) Connect() error {
+/**
+Установка соединения. Ошибки определения адреса и подключения возвращаются как *ResolveError и *DialError,
+чтобы вызывающий код мог отличить их от прочих ошибок.
+*/
+func (network *Network) Connect(ctx context.Context) error {
 	var err error
 
 	network.logger.Check("netService")
@@ -44,76 +92,91 @@ func (network *Network) Connect() error {
 		return err
 	}
 
-	ip := net.ParseIP(network.host)
-	addr := &net.TCPAddr{
-		IP:   ip,
-		Port: network.port,
-	}
-
 	network.logger.Info("Установка соединения...")
-	network.logger.Info("Host: %v Port: %d", network.host, network.port)
-	network.connection, err = net.DialTCP("tcp", nil, addr)
+	network.logger.Info("%s", network.transport.String())
+	err = network.transport.Open(ctx)
 	if err == nil {
 		network.connectionStatus = connected
 		network.logger.Info("Соединение установлено.")
 	} else {
-		network.logger.Fatal(err.Error())
+		network.logger.Error("%s", err.Error())
 	}
 	return err
 }
 
+// Закрытие соединения. Транспорт освобождает соединение и при ошибке закрытия, поэтому статус сбрасывается в любом случае
 func (network *Network) Close() error {
 	network.logger.Check("netService")
 
 	network.logger.Info("Соединение закрывается.")
-	err := network.connection.Close()
+	err := network.transport.Close()
+	network.connectionStatus = disconnected
 	if err == nil {
-		network.connectionStatus = disconnected
 		network.logger.Info("Соединение закрыто.")
 	} else {
-		network.logger.Fatal(err.Error())
+		network.logger.Error("%s", err.Error())
 	}
 	return err
 }
 
-func (network *Network) Reconnect() {
+// Переподключение. Возвращает ошибку закрытия или установки соединения
+func (network *Network) Reconnect(ctx context.Context) error {
 
 	network.logger.Check("netService")
 
 	err := network.Close()
 	if err != nil {
-		return
+		return err
 	}
 	network.connectionStatus = disconnected
-	err = network.Connect()
+	err = network.Connect(ctx)
 	if err != nil {
-		return
+		return err
 	}
 	network.connectionStatus = connected
+	return nil
+}
+
+/**
+Обмен данными: отправка запроса и чтение ответа с повторными попытками и переподключениями.
+Все попытки ограничены ctx: по его истечении или отмене обмен прерывается после завершения текущей операции
+чтения/записи, а таймауты чтения/записи не превышают срок ctx. В этом случае возвращается *InterruptedError.
+*/
+func (network *Network) RunIO(ctx context.Context, request Request) ([]byte, error) {
+	network.stats.Requests++
+	response, err := network.runIO(ctx, request)
+	if err != nil {
+		network.stats.Failures++
+	}
+	return response, err
 }
 
-func (network *Network) RunIO(request Request) ([]byte, error) {
+func (network *Network) runIO(ctx context.Context, request Request) ([]byte, error) {
 
 	var err error
 	var response []byte
 
 	network.logger.Check("netService")
 
+	if err = ctx.Err(); err != nil {
+		return nil, &InterruptedError{Err: err}
+	}
+
 	if !network.IsConnected() {
-		err = network.Connect()
+		err = network.Connect(ctx)
 		if err != nil {
 			return nil, err
 		}
 	}
 
 	write := func() error {
-		err = network.connection.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		err = network.transport.SetWriteDeadline(deadline(ctx, 10*time.Second))
 		if err != nil {
 			network.logger.Debug("%s", err.Error())
 			return err
 		}
 		network.logger.Info("Отправка данных...")
-		_, err = network.connection.Write(request.Bytes)
+		_, err = network.transport.Write(request.Bytes)
 		network.logger.Debug("Отправка %d байт: %X", len(request.Bytes), request.Bytes)
 		if err != nil {
 			network.logger.Debug("%s", err.Error())
@@ -130,11 +193,19 @@ func (network *Network) RunIO(request Request) ([]byte, error) {
 	errorsCount := 0
 	for {
 
-		tempResponse, err := network.doRead(request.SecondsReadTimeout)
+		tempResponse, err := network.doRead(ctx, request.SecondsReadTimeout)
+
+		if err != nil && ctx.Err() != nil {
+			network.logger.Debug("Обмен данными прерван: %s", ctx.Err().Error())
+			return response, &InterruptedError{Err: ctx.Err()}
+		}
 
 		if err == io.EOF && request.Reconnect {
 			network.logger.Debug("Получен EOF")
-			network.Reconnect()
+			network.stats.Reconnects++
+			if err = network.Reconnect(ctx); err != nil {
+				return response, err
+			}
 			err = write()
 			if err != nil {
 				return response, err
@@ -147,7 +218,7 @@ func (network *Network) RunIO(request Request) ([]byte, error) {
 			// Каждый раз при обнаруженной ошибки запускаем write/read заново, если счётчик ошибок не достиг
 			// предельного значения
 
-			if strings.Contains(err.Error(), "i/o timeout") {
+			if isTimeout(err) {
 				if len(response) > 0 {
 					// Данные уже пришли, дальше ждать нет причин. Иногда встречаются счётчики
 					// с кратковременной памятью, как у Дори :), поэтому дальнейший таймаут вызывает проблемы
@@ -157,11 +228,13 @@ func (network *Network) RunIO(request Request) ([]byte, error) {
 					// данные не приходили, сработал таймаут
 					// В данном случае увеличиваем счётчик ошибок, а далее пробуем послать запрос и получить ответ.
 					errorsCount++
+					network.stats.Timeouts++
 				}
 			} else if strings.Contains(err.Error(), "An existing connection was forcibly closed by the remote host") {
 				// RTU сбрасывает соединения на чтение. Точные причины этого состояния не найдены.
 				// В данном случае увеличиваем счётчик ошибок, а далее пробуем послать запрос и получить ответ.
 				errorsCount++
+				network.stats.Timeouts++
 			}
 
 			network.logger.Debug("%s", err.Error())
@@ -204,7 +277,8 @@ func (network *Network) RunIO(request Request) ([]byte, error) {
 
 	if !request.ControlFunction(response) && request.Attempts != 0 {
 		network.logger.Debug("Проверка ответа завершилась неудачей. Производится повторная попытка.")
-		return network.RunIO(Request{
+		network.stats.Retries++
+		return network.runIO(ctx, Request{
 			request.Bytes,
 			request.ControlFunction,
 			request.Attempts - 1,
@@ -214,16 +288,75 @@ func (network *Network) RunIO(request Request) ([]byte, error) {
 
 	if !request.ControlFunction(response) {
 		network.logger.Debug("Проверка ответа завершилась неудачей. Повторные попытки все исчерпаны.")
-		return response, errors.New("получен некорректный ответ")
+		if len(response) == 0 {
+			return response, &NoResponseError{}
+		}
+		return response, &ResponseError{Response: response}
 	}
 
 	network.logger.Debug("Результат - %X", response)
 	return response, err
 }
 
-func (network *Network) doRead(secondsTimeout uint8) ([]byte, error) {
+/**
+Однократный обмен без повторных попыток: отправка запроса и чтение ответа в течение timeout.
+Чтение завершается, когда complete возвращает true, либо по истечении timeout. Отсутствие ответа
+ошибкой не считается - возвращается пустой ответ. Используется для поиска приборов на шине,
+где молчание прибора - ожидаемый результат, и для отправки произвольных запросов.
+*/
+func (network *Network) Exchange(ctx context.Context, request []byte, timeout time.Duration, complete func(response []byte) bool) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &InterruptedError{Err: err}
+	}
+	if !network.IsConnected() {
+		err := network.Connect(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := network.transport.SetWriteDeadline(deadline(ctx, 10*time.Second))
+	if err != nil {
+		return nil, err
+	}
+	_, err = network.transport.Write(request)
+	network.logger.Debug("Отправка %d байт: %X", len(request), request)
+	if err != nil {
+		return nil, err
+	}
+
+	var response []byte
+	err = network.transport.SetReadDeadline(deadline(ctx, timeout))
+	if err != nil {
+		return nil, err
+	}
+	buffer := make([]byte, 1200)
+	for {
+		n, err := network.transport.Read(buffer)
+		response = append(response, buffer[:n]...)
+		if len(request) <= len(response) && bytes.Equal(request, response[:len(request)]) {
+			response = response[len(request):] // эхо
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return response, &InterruptedError{Err: ctx.Err()}
+			}
+			if isTimeout(err) {
+				break
+			}
+			return response, err
+		}
+		if complete != nil && complete(response) {
+			break
+		}
+	}
+	network.logger.Debug("Получено %d байт: %X", len(response), response)
+	return response, nil
+}
+
+func (network *Network) doRead(ctx context.Context, secondsTimeout uint8) ([]byte, error) {
 	var err error
-	err = network.setReadTimeout(secondsTimeout)
+	err = network.transport.SetReadDeadline(deadline(ctx, time.Duration(secondsTimeout)*time.Second))
 	if err != nil {
 		network.logger.Debug("%s", err.Error())
 		return nil, err
@@ -237,7 +370,7 @@ func (network *Network) doRead(secondsTimeout uint8) ([]byte, error) {
 	В связи с этим подобран буфер и таймаут для выполнения чтения данных
 	*/
 	buffer := make([]byte, 1200)
-	n, err := network.connection.Read(buffer)
+	n, err := network.transport.Read(buffer)
 	if err != nil {
 		return nil, err
 	}
@@ -246,8 +379,13 @@ func (network *Network) doRead(secondsTimeout uint8) ([]byte, error) {
 	return buffer[:n], nil
 }
 
-func (network *Network) setReadTimeout(seconds uint8) error {
-	return network.connection.SetReadDeadline(time.Now().Add(time.Duration(seconds) * time.Second))
+// Срок операции чтения/записи: через timeout, но не позже срока ctx
+func deadline(ctx context.Context, timeout time.Duration) time.Time {
+	t := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(t) {
+		return ctxDeadline
+	}
+	return t
 }
 
 func SplitHostPort(endpoint string) (host string, port int, err error) {
@@ -0,0 +1,131 @@
+package net
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"qBox/services/log"
+	"time"
+)
+
+/**
+Транспорт, воспроизводящий файл записи обмена (см. CaptureTransport) без линии связи.
+Драйверу передаются записанные ответы, таймауты, EOF и ошибки в том же порядке, ожидания таймаутов нет.
+Запрос, который отличается от записанного, записывается в лог, обмен продолжается по записи - так
+воспроизводятся и сеансы, в которых запрос зависит от текущего времени (установка часов, архивы).
+Непрочитанные драйвером ответы перед следующим запросом пропускаются, как на линии связи.
+*/
+type ReplayTransport struct {
+	events  []CaptureEvent
+	next    int
+	pending []byte
+	logger  *log.LoggerService // nil - расхождения не записываются в лог
+}
+
+func NewReplayTransport(events []CaptureEvent, logger *log.LoggerService) *ReplayTransport {
+	return &ReplayTransport{events: events, logger: logger}
+}
+
+func (replay *ReplayTransport) Open(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	replay.pending = nil
+	for ; replay.next < len(replay.events); replay.next++ {
+		event := replay.events[replay.next]
+		if event.Kind == CaptureOpen {
+			replay.next++
+			if event.Error != "" {
+				return errors.New(event.Error)
+			}
+			return nil
+		}
+	}
+	return errors.New("в записи обмена больше нет подключений")
+}
+
+func (replay *ReplayTransport) Close() error {
+	replay.pending = nil
+	replay.skipResponses()
+	if replay.next < len(replay.events) && replay.events[replay.next].Kind == CaptureClose {
+		replay.next++
+	}
+	return nil
+}
+
+func (replay *ReplayTransport) Read(b []byte) (int, error) {
+	if len(replay.pending) == 0 {
+		if replay.next >= len(replay.events) {
+			return 0, os.ErrDeadlineExceeded
+		}
+		event := replay.events[replay.next]
+		switch event.Kind {
+		case CaptureRead:
+			replay.pending, _ = event.Bytes()
+		case CaptureTimeout:
+			replay.next++
+			return 0, os.ErrDeadlineExceeded
+		case CaptureEOF:
+			replay.next++
+			return 0, io.EOF
+		case CaptureError:
+			replay.next++
+			return 0, errors.New(event.Error)
+		default:
+			// Драйвер ждёт ответ, которого в записи нет
+			return 0, os.ErrDeadlineExceeded
+		}
+		replay.next++
+	}
+	n := copy(b, replay.pending)
+	replay.pending = replay.pending[n:]
+	return n, nil
+}
+
+func (replay *ReplayTransport) Write(b []byte) (int, error) {
+	replay.pending = nil
+	replay.skipResponses()
+	if replay.next >= len(replay.events) || replay.events[replay.next].Kind != CaptureWrite {
+		return 0, errors.New("запрос после окончания записи обмена")
+	}
+	event := replay.events[replay.next]
+	replay.next++
+	if expected, _ := event.Bytes(); !bytes.Equal(expected, b) && replay.logger != nil {
+		replay.logger.Notice("Запрос отличается от записи обмена: %X, записан %X", b, expected)
+	}
+	if event.Error != "" {
+		return 0, errors.New(event.Error)
+	}
+	return len(b), nil
+}
+
+// Пропуск ответов, которые драйвер не прочитал
+func (replay *ReplayTransport) skipResponses() {
+	for replay.next < len(replay.events) {
+		switch replay.events[replay.next].Kind {
+		case CaptureRead, CaptureTimeout, CaptureEOF, CaptureError:
+			replay.next++
+		default:
+			return
+		}
+	}
+}
+
+func (replay *ReplayTransport) SetReadDeadline(time.Time) error {
+	return nil
+}
+
+func (replay *ReplayTransport) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+func (replay *ReplayTransport) String() string {
+	return "Воспроизведение записи обмена"
+}
+
+// Событий записи, которые ещё не воспроизведены
+func (replay *ReplayTransport) Remaining() int {
+	return len(replay.events) - replay.next
+}
@@ -0,0 +1,212 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ParityEnum byte // Контроль чётности последовательного порта
+const (
+	ParityNone ParityEnum = 'N' // Без контроля чётности
+	ParityEven ParityEnum = 'E' // Чётный
+	ParityOdd  ParityEnum = 'O' // Нечётный
+)
+
+/**
+Настройки последовательного порта.
+*/
+type SerialConfig struct {
+	Device   string     // Путь к устройству. Например /dev/ttyUSB0 или COM3
+	BaudRate int        // Скорость обмена, бит/с
+	DataBits int        // Бит данных, 5..8
+	Parity   ParityEnum // Контроль чётности
+	StopBits int        // Стоп-бит, 1 или 2
+
+	/*
+		Задержка после отправки запроса, перед чтением ответа.
+		На полудуплексной линии RS-485 адаптеру требуется время на переключение с передачи на приём,
+		а некоторым теплосчётчикам - время на подготовку ответа.
+	*/
+	TurnaroundDelay time.Duration
+}
+
+// Настройки по умолчанию: 9600 8N1, их используют большинство теплосчётчиков
+func DefaultSerialConfig() SerialConfig {
+	return SerialConfig{BaudRate: 9600, DataBits: 8, Parity: ParityNone, StopBits: 1}
+}
+
+// Разбор строки вида /dev/ttyUSB0?baud=9600&parity=none&stop=1&data=8&turnaround=20ms
+// Отсутствующие параметры берутся из DefaultSerialConfig.
+func ParseSerialConfig(s string) (SerialConfig, error) {
+	config := DefaultSerialConfig()
+
+	device, query, err := splitEndpointQuery(s)
+	if err != nil {
+		return config, fmt.Errorf("некорректные параметры последовательного порта: %w", err)
+	}
+	if device == "" {
+		return config, errors.New("не задано устройство последовательного порта")
+	}
+	config.Device = device
+
+	for key := range query {
+		value := query.Get(key)
+		switch key {
+		case "baud":
+			config.BaudRate, err = strconv.Atoi(value)
+		case "data":
+			config.DataBits, err = strconv.Atoi(value)
+		case "stop":
+			config.StopBits, err = strconv.Atoi(value)
+		case "parity":
+			config.Parity, err = parseParity(value)
+		case "turnaround":
+			config.TurnaroundDelay, err = time.ParseDuration(value)
+		default:
+			err = errors.New("неизвестный параметр")
+		}
+		if err != nil {
+			return config, fmt.Errorf("параметр последовательного порта \"%s\": %w", key, err)
+		}
+	}
+
+	return config, config.validate()
+}
+
+func parseParity(value string) (ParityEnum, error) {
+	switch strings.ToLower(value) {
+	case "n", "none":
+		return ParityNone, nil
+	case "e", "even":
+		return ParityEven, nil
+	case "o", "odd":
+		return ParityOdd, nil
+	}
+	return ParityNone, errors.New("допустимые значения none, even, odd")
+}
+
+func (config SerialConfig) validate() error {
+	if config.BaudRate <= 0 {
+		return errors.New("скорость обмена должна быть больше 0")
+	}
+	if config.DataBits < 5 || config.DataBits > 8 {
+		return errors.New("количество бит данных должно быть от 5 до 8")
+	}
+	if config.StopBits != 1 && config.StopBits != 2 {
+		return errors.New("количество стоп-бит должно быть 1 или 2")
+	}
+	if config.TurnaroundDelay < 0 {
+		return errors.New("задержка переключения линии не может быть отрицательной")
+	}
+	return nil
+}
+
+func (config SerialConfig) String() string {
+	return fmt.Sprintf("%s %d %d%c%d", config.Device, config.BaudRate, config.DataBits, config.Parity, config.StopBits)
+}
+
+/**
+Порт, открытый средствами конкретной ОС. См. serial_linux.go, serial_windows.go
+*/
+type serialPort interface {
+	Read(b []byte) (int, error)
+	Write(b []byte) (int, error)
+	Close() error
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+	Drain() error // ожидание фактической отправки всех байт из буфера порта
+	Flush() error // очистка буфера приёма
+}
+
+/**
+Транспорт поверх последовательного порта (RS-232/RS-485).
+*/
+type SerialTransport struct {
+	config SerialConfig
+	port   serialPort
+}
+
+func NewSerialTransport(config SerialConfig) *SerialTransport {
+	return &SerialTransport{config: config}
+}
+
+// Порт открывается без ожидания, поэтому ctx проверяется только перед открытием
+func (transport *SerialTransport) Open(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	port, err := openSerialPort(transport.config)
+	if err != nil {
+		return err
+	}
+	transport.port = port
+	return nil
+}
+
+func (transport *SerialTransport) Close() error {
+	if transport.port == nil {
+		return errors.New("порт не был открыт")
+	}
+	err := transport.port.Close()
+	transport.port = nil
+	return err
+}
+
+func (transport *SerialTransport) Read(b []byte) (int, error) {
+	if transport.port == nil {
+		return 0, ErrNotConnected
+	}
+	return transport.port.Read(b)
+}
+
+/**
+Перед отправкой запроса очищается буфер приёма, чтобы запоздавший ответ на предыдущий запрос
+не был принят за ответ на текущий. После отправки дожидаемся фактической передачи всех байт
+и выдерживаем задержку переключения линии.
+*/
+func (transport *SerialTransport) Write(b []byte) (int, error) {
+	if transport.port == nil {
+		return 0, ErrNotConnected
+	}
+	err := transport.port.Flush()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := transport.port.Write(b)
+	if err != nil {
+		return n, err
+	}
+
+	err = transport.port.Drain()
+	if err != nil {
+		return n, err
+	}
+
+	if transport.config.TurnaroundDelay > 0 {
+		time.Sleep(transport.config.TurnaroundDelay)
+	}
+	return n, nil
+}
+
+func (transport *SerialTransport) SetReadDeadline(t time.Time) error {
+	if transport.port == nil {
+		return ErrNotConnected
+	}
+	return transport.port.SetReadDeadline(t)
+}
+
+func (transport *SerialTransport) SetWriteDeadline(t time.Time) error {
+	if transport.port == nil {
+		return ErrNotConnected
+	}
+	return transport.port.SetWriteDeadline(t)
+}
+
+func (transport *SerialTransport) String() string {
+	return "Serial: " + transport.config.String()
+}
@@ -0,0 +1,98 @@
+//go:build linux
+// +build linux
+
+package net
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+var baudRates = map[int]uint32{
+	1200:   unix.B1200,
+	2400:   unix.B2400,
+	4800:   unix.B4800,
+	9600:   unix.B9600,
+	19200:  unix.B19200,
+	38400:  unix.B38400,
+	57600:  unix.B57600,
+	115200: unix.B115200,
+	230400: unix.B230400,
+}
+
+var dataBits = map[int]uint32{
+	5: unix.CS5,
+	6: unix.CS6,
+	7: unix.CS7,
+	8: unix.CS8,
+}
+
+/**
+Последовательный порт Linux. Дескриптор открывается в неблокирующем режиме, поэтому os.File
+регистрирует его в планировщике ввода-вывода Go и таймауты чтения/записи работают так же, как у net.Conn.
+*/
+type linuxSerialPort struct {
+	*os.File
+	fd int
+}
+
+func openSerialPort(config SerialConfig) (serialPort, error) {
+	baud, ok := baudRates[config.BaudRate]
+	if !ok {
+		return nil, fmt.Errorf("скорость обмена %d не поддерживается", config.BaudRate)
+	}
+
+	fd, err := unix.Open(config.Device, unix.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть порт %s: %w", config.Device, err)
+	}
+
+	termios, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("порт %s не является последовательным: %w", config.Device, err)
+	}
+
+	// Режим "raw": без обработки управляющих символов, эха и программного управления потоком.
+	termios.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON | unix.IXOFF | unix.IXANY
+	termios.Oflag &^= unix.OPOST
+	termios.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+
+	termios.Cflag &^= unix.CBAUD | unix.CSIZE | unix.PARENB | unix.PARODD | unix.CSTOPB | unix.CRTSCTS
+	termios.Cflag |= baud | dataBits[config.DataBits] | unix.CREAD | unix.CLOCAL
+	termios.Ispeed = baud
+	termios.Ospeed = baud
+
+	switch config.Parity {
+	case ParityEven:
+		termios.Cflag |= unix.PARENB
+	case ParityOdd:
+		termios.Cflag |= unix.PARENB | unix.PARODD
+	}
+	if config.StopBits == 2 {
+		termios.Cflag |= unix.CSTOPB
+	}
+
+	// VMIN=1 обязателен: при VMIN=0 read() вернёт 0 байт вместо EAGAIN, что будет воспринято как EOF.
+	termios.Cc[unix.VMIN] = 1
+	termios.Cc[unix.VTIME] = 0
+
+	err = unix.IoctlSetTermios(fd, unix.TCSETS, termios)
+	if err != nil {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("не удалось настроить порт %s: %w", config.Device, err)
+	}
+
+	return &linuxSerialPort{File: os.NewFile(uintptr(fd), config.Device), fd: fd}, nil
+}
+
+func (port *linuxSerialPort) Drain() error {
+	// Аналог tcdrain(): ioctl TCSBRK с ненулевым аргументом ожидает отправки буфера без посылки break.
+	return unix.IoctlSetInt(port.fd, unix.TCSBRK, 1)
+}
+
+func (port *linuxSerialPort) Flush() error {
+	return unix.IoctlSetInt(port.fd, unix.TCFLSH, unix.TCIFLUSH)
+}
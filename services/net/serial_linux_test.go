@@ -0,0 +1,95 @@
+//go:build linux
+// +build linux
+
+package net
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	ozzolog "github.com/go-ozzo/ozzo-log"
+	"golang.org/x/sys/unix"
+	"qBox/services/log"
+)
+
+// Псевдотерминал: ведущая сторона играет роль теплосчётчика, ведомая открывается как последовательный порт
+func openPty(t *testing.T) (*os.File, string) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("псевдотерминал недоступен: %v", err)
+	}
+	t.Cleanup(func() { _ = master.Close() })
+
+	fd := int(master.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		t.Fatal(err)
+	}
+	number, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return master, fmt.Sprintf("/dev/pts/%d", number)
+}
+
+// Запрос уходит через SerialTransport без искажений, ответ возвращается через RunIO
+func TestSerialRoundTrip(t *testing.T) {
+	master, device := openPty(t)
+
+	config, err := ParseSerialConfig(device + "?baud=2400&parity=even&turnaround=5ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	network := NewNetwork(NewSerialTransport(config), log.NewLoggerService(ozzolog.NewLogger()))
+	if err := network.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = network.Close() }()
+
+	// Байты, которые терминал в каноническом режиме изменил бы: перевод строки, возврат каретки, XON/XOFF, ^C
+	request := []byte{0x55, 0x0A, 0x0D, 0x11, 0x13, 0x03, 0xFF}
+	response := []byte{0xAA, 0x0D, 0x0A, 0x7F, 0x00, 0x1C}
+	received := make(chan []byte, 1)
+	go func() {
+		buffer := make([]byte, len(request))
+		if _, err := io.ReadFull(master, buffer); err != nil {
+			received <- nil
+			return
+		}
+		received <- buffer
+		_, _ = master.Write(response)
+	}()
+
+	answer, err := network.RunIO(context.Background(), Request{
+		Bytes:              request,
+		ControlFunction:    func(answer []byte) bool { return len(answer) == len(response) },
+		Attempts:           1,
+		SecondsReadTimeout: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := <-received; !bytes.Equal(got, request) {
+		t.Errorf("устройство получило % X, ожидалось % X", got, request)
+	}
+	if !bytes.Equal(answer, response) {
+		t.Errorf("получен ответ % X, ожидалось % X", answer, response)
+	}
+}
+
+func TestSerialNotTerminal(t *testing.T) {
+	file, err := os.CreateTemp(t.TempDir(), "port")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = file.Close()
+
+	transport := NewSerialTransport(SerialConfig{Device: file.Name(), BaudRate: 9600, DataBits: 8, Parity: ParityNone, StopBits: 1})
+	if err := transport.Open(context.Background()); err == nil {
+		_ = transport.Close()
+		t.Error("ожидалась ошибка открытия обычного файла как порта")
+	}
+}
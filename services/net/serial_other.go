@@ -0,0 +1,10 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package net
+
+import "errors"
+
+func openSerialPort(config SerialConfig) (serialPort, error) {
+	return nil, errors.New("работа с последовательным портом на этой ОС не поддерживается")
+}
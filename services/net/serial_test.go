@@ -0,0 +1,53 @@
+package net
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSerialConfig(t *testing.T) {
+	tests := []struct {
+		input string
+		want  SerialConfig
+	}{
+		{"/dev/ttyUSB0", SerialConfig{Device: "/dev/ttyUSB0", BaudRate: 9600, DataBits: 8, Parity: ParityNone, StopBits: 1}},
+		{"COM3?baud=19200", SerialConfig{Device: "COM3", BaudRate: 19200, DataBits: 8, Parity: ParityNone, StopBits: 1}},
+		{"/dev/ttyS1?parity=even&stop=2", SerialConfig{Device: "/dev/ttyS1", BaudRate: 9600, DataBits: 8, Parity: ParityEven, StopBits: 2}},
+		{"/dev/ttyS1?parity=O&data=7", SerialConfig{Device: "/dev/ttyS1", BaudRate: 9600, DataBits: 7, Parity: ParityOdd, StopBits: 1}},
+		{"/dev/ttyS1?parity=none", SerialConfig{Device: "/dev/ttyS1", BaudRate: 9600, DataBits: 8, Parity: ParityNone, StopBits: 1}},
+		{"/dev/ttyUSB0?baud=2400&parity=even&stop=1&data=8&turnaround=20ms", SerialConfig{
+			Device: "/dev/ttyUSB0", BaudRate: 2400, DataBits: 8, Parity: ParityEven, StopBits: 1, TurnaroundDelay: 20 * time.Millisecond,
+		}},
+	}
+	for _, test := range tests {
+		config, err := ParseSerialConfig(test.input)
+		if err != nil {
+			t.Errorf("%s: %v", test.input, err)
+			continue
+		}
+		if config != test.want {
+			t.Errorf("%s: получено %+v, ожидалось %+v", test.input, config, test.want)
+		}
+	}
+}
+
+func TestParseSerialConfigInvalid(t *testing.T) {
+	inputs := []string{
+		"",
+		"?baud=9600",
+		"/dev/ttyUSB0?baud=fast",
+		"/dev/ttyUSB0?baud=0",
+		"/dev/ttyUSB0?parity=mark",
+		"/dev/ttyUSB0?stop=3",
+		"/dev/ttyUSB0?data=9",
+		"/dev/ttyUSB0?turnaround=20",
+		"/dev/ttyUSB0?turnaround=-5ms",
+		"/dev/ttyUSB0?speed=9600",
+		"/dev/ttyUSB0?baud=%zz",
+	}
+	for _, input := range inputs {
+		if config, err := ParseSerialConfig(input); err == nil {
+			t.Errorf("%s: ожидалась ошибка, получено %+v", input, config)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+//go:build windows
+// +build windows
+
+package net
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	kernel32         = windows.NewLazySystemDLL("kernel32.dll")
+	procGetCommState = kernel32.NewProc("GetCommState")
+	procSetCommState = kernel32.NewProc("SetCommState")
+	procPurgeComm    = kernel32.NewProc("PurgeComm")
+)
+
+const (
+	dcbBinary         = 0x0001 // fBinary
+	dcbParity         = 0x0002 // fParity
+	dcbDtrControl     = 0x0010 // fDtrControl = DTR_CONTROL_ENABLE
+	dcbRtsControl     = 0x1000 // fRtsControl = RTS_CONTROL_ENABLE
+	purgeRxClear      = 0x0008 // PURGE_RXCLEAR
+	maxReadTimeout    = 0xFFFFFFFE
+	readWithoutLimits = 0xFFFFFFFF // MAXDWORD
+)
+
+// Структура DCB из WinAPI. Битовые поля fBinary..fAbortOnError упакованы в flags.
+type dcb struct {
+	DCBlength  uint32
+	BaudRate   uint32
+	flags      uint32
+	wReserved  uint16
+	XonLim     uint16
+	XoffLim    uint16
+	ByteSize   byte
+	Parity     byte
+	StopBits   byte
+	XonChar    byte
+	XoffChar   byte
+	ErrorChar  byte
+	EofChar    byte
+	EvtChar    byte
+	wReserved1 uint16
+}
+
+/**
+Последовательный порт Windows. Таймауты чтения реализованы через COMMTIMEOUTS:
+ReadFile возвращает управление, как только пришёл хотя бы один байт, либо по истечении таймаута.
+*/
+type windowsSerialPort struct {
+	handle        windows.Handle
+	mutex         sync.Mutex
+	readDeadline  time.Time
+	writeDeadline time.Time
+}
+
+func openSerialPort(config SerialConfig) (serialPort, error) {
+	device := config.Device
+	if !strings.HasPrefix(device, `\\.\`) {
+		device = `\\.\` + device // для COM10 и выше
+	}
+
+	path, err := windows.UTF16PtrFromString(device)
+	if err != nil {
+		return nil, err
+	}
+
+	handle, err := windows.CreateFile(path, windows.GENERIC_READ|windows.GENERIC_WRITE, 0, nil, windows.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть порт %s: %w", config.Device, err)
+	}
+
+	state := dcb{}
+	state.DCBlength = uint32(unsafe.Sizeof(state))
+	r, _, err := procGetCommState.Call(uintptr(handle), uintptr(unsafe.Pointer(&state)))
+	if r == 0 {
+		_ = windows.CloseHandle(handle)
+		return nil, fmt.Errorf("порт %s не является последовательным: %w", config.Device, err)
+	}
+
+	state.BaudRate = uint32(config.BaudRate)
+	state.ByteSize = byte(config.DataBits)
+	state.flags = dcbBinary | dcbDtrControl | dcbRtsControl
+	switch config.Parity {
+	case ParityOdd:
+		state.Parity = 1
+		state.flags |= dcbParity
+	case ParityEven:
+		state.Parity = 2
+		state.flags |= dcbParity
+	default:
+		state.Parity = 0
+	}
+	if config.StopBits == 2 {
+		state.StopBits = 2
+	} else {
+		state.StopBits = 0
+	}
+
+	r, _, err = procSetCommState.Call(uintptr(handle), uintptr(unsafe.Pointer(&state)))
+	if r == 0 {
+		_ = windows.CloseHandle(handle)
+		return nil, fmt.Errorf("не удалось настроить порт %s: %w", config.Device, err)
+	}
+
+	return &windowsSerialPort{handle: handle}, nil
+}
+
+func (port *windowsSerialPort) Read(b []byte) (int, error) {
+	port.mutex.Lock()
+	deadline := port.readDeadline
+	port.mutex.Unlock()
+
+	for {
+		timeout := uint32(maxReadTimeout)
+		if !deadline.IsZero() {
+			left := time.Until(deadline)
+			if left <= 0 {
+				return 0, os.ErrDeadlineExceeded
+			}
+			if left < time.Duration(maxReadTimeout)*time.Millisecond {
+				timeout = uint32(left / time.Millisecond)
+			}
+			if timeout == 0 {
+				timeout = 1
+			}
+		}
+
+		err := windows.SetCommTimeouts(port.handle, &windows.CommTimeouts{
+			ReadIntervalTimeout:        readWithoutLimits,
+			ReadTotalTimeoutMultiplier: readWithoutLimits,
+			ReadTotalTimeoutConstant:   timeout,
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		var n uint32
+		err = windows.ReadFile(port.handle, b, &n, nil)
+		if err != nil {
+			return int(n), err
+		}
+		if n > 0 {
+			return int(n), nil
+		}
+	}
+}
+
+func (port *windowsSerialPort) Write(b []byte) (int, error) {
+	var n uint32
+	err := windows.WriteFile(port.handle, b, &n, nil)
+	return int(n), err
+}
+
+func (port *windowsSerialPort) Close() error {
+	return windows.CloseHandle(port.handle)
+}
+
+func (port *windowsSerialPort) SetReadDeadline(t time.Time) error {
+	port.mutex.Lock()
+	port.readDeadline = t
+	port.mutex.Unlock()
+	return nil
+}
+
+// Запись в порт синхронная, таймаут записи сохраняется для единообразия с другими транспортами.
+func (port *windowsSerialPort) SetWriteDeadline(t time.Time) error {
+	port.mutex.Lock()
+	port.writeDeadline = t
+	port.mutex.Unlock()
+	return nil
+}
+
+func (port *windowsSerialPort) Drain() error {
+	return windows.FlushFileBuffers(port.handle)
+}
+
+func (port *windowsSerialPort) Flush() error {
+	r, _, err := procPurgeComm.Call(uintptr(port.handle), purgeRxClear)
+	if r == 0 {
+		return err
+	}
+	return nil
+}
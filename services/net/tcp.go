@@ -0,0 +1,151 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+/**
+Транспорт поверх TCP соединения. Используется для теплосчётчиков, подключённых через TCP шлюзы.
+Узел может быть задан IP адресом (IPv4 или IPv6) либо именем, которое определяется через DNS при каждом
+подключении - шлюзы с динамическим DNS меняют адрес между сеансами.
+*/
+type TcpTransport struct {
+	host           string
+	port           int
+	connectTimeout time.Duration // 0 - таймаут подключения определяется ОС
+	connection     net.Conn
+}
+
+func NewTcpTransport(host string, port int) *TcpTransport {
+	return &TcpTransport{host: host, port: port}
+}
+
+// Разбор строки вида host:port?timeout=10s
+func parseTcpTransport(endpoint string) (*TcpTransport, error) {
+	address, query, err := splitEndpointQuery(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	host, port, err := SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if host == "" {
+		return nil, errors.New("не задан узел")
+	}
+
+	transport := NewTcpTransport(host, port)
+	for key := range query {
+		switch key {
+		case "timeout":
+			transport.connectTimeout, err = time.ParseDuration(query.Get(key))
+			if err == nil && transport.connectTimeout < 0 {
+				err = errors.New("таймаут не может быть отрицательным")
+			}
+		default:
+			err = errors.New("неизвестный параметр")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("параметр \"%s\": %w", key, err)
+		}
+	}
+	return transport, nil
+}
+
+// Задаёт таймаут установки соединения
+func (transport *TcpTransport) SetConnectTimeout(timeout time.Duration) {
+	transport.connectTimeout = timeout
+}
+
+/**
+Определяет адреса узла и поочерёдно пытается установить соединение с каждым из них.
+Возвращает *ResolveError, если адреса узла определить не удалось, и *DialError, если ни с одним из адресов
+соединение не установлено.
+*/
+func (transport *TcpTransport) Open(ctx context.Context) error {
+	if transport.connectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, transport.connectTimeout)
+		defer cancel()
+	}
+
+	addresses, err := transport.resolve(ctx)
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	var dialError error
+	for _, address := range addresses {
+		hostPort := net.JoinHostPort(address.String(), strconv.Itoa(transport.port))
+		connection, err := dialer.DialContext(ctx, "tcp", hostPort)
+		if err == nil {
+			transport.connection = connection
+			return nil
+		}
+		dialError = &DialError{Address: hostPort, Err: err}
+	}
+	return dialError
+}
+
+func (transport *TcpTransport) resolve(ctx context.Context) ([]net.IPAddr, error) {
+	if ip := net.ParseIP(transport.host); ip != nil {
+		return []net.IPAddr{{IP: ip}}, nil
+	}
+
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, transport.host)
+	if err != nil {
+		return nil, &ResolveError{Host: transport.host, Err: err}
+	}
+	if len(addresses) == 0 {
+		return nil, &ResolveError{Host: transport.host, Err: errors.New("адреса не найдены")}
+	}
+	return addresses, nil
+}
+
+func (transport *TcpTransport) Close() error {
+	if transport.connection == nil {
+		return errors.New("соединение не было установлено")
+	}
+	err := transport.connection.Close()
+	transport.connection = nil
+	return err
+}
+
+func (transport *TcpTransport) Read(b []byte) (int, error) {
+	if transport.connection == nil {
+		return 0, ErrNotConnected
+	}
+	return transport.connection.Read(b)
+}
+
+func (transport *TcpTransport) Write(b []byte) (int, error) {
+	if transport.connection == nil {
+		return 0, ErrNotConnected
+	}
+	return transport.connection.Write(b)
+}
+
+func (transport *TcpTransport) SetReadDeadline(t time.Time) error {
+	if transport.connection == nil {
+		return ErrNotConnected
+	}
+	return transport.connection.SetReadDeadline(t)
+}
+
+func (transport *TcpTransport) SetWriteDeadline(t time.Time) error {
+	if transport.connection == nil {
+		return ErrNotConnected
+	}
+	return transport.connection.SetWriteDeadline(t)
+}
+
+func (transport *TcpTransport) String() string {
+	return "Host: " + transport.host + " Port: " + strconv.Itoa(transport.port)
+}
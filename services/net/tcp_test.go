@@ -0,0 +1,93 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	ozzolog "github.com/go-ozzo/ozzo-log"
+	"qBox/services/log"
+)
+
+func TestTcpTransportNotConnected(t *testing.T) {
+	transport := NewTcpTransport("127.0.0.1", 1)
+	if _, err := transport.Write([]byte{0x55}); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("запись без соединения: %v", err)
+	}
+	if _, err := transport.Read(make([]byte, 1)); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("чтение без соединения: %v", err)
+	}
+	if err := transport.SetReadDeadline(time.Now()); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("таймаут чтения без соединения: %v", err)
+	}
+	if err := transport.SetWriteDeadline(time.Now()); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("таймаут записи без соединения: %v", err)
+	}
+}
+
+// Шлюз разрывает соединение и больше не принимает подключения: ошибка переподключения возвращается из RunIO
+func TestReconnectFailure(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		connection, err := listener.Accept()
+		_ = listener.Close()
+		if err == nil {
+			_ = connection.Close()
+		}
+	}()
+
+	address := listener.Addr().(*net.TCPAddr)
+	network := NewNetwork(NewTcpTransport("127.0.0.1", address.Port), log.NewLoggerService(ozzolog.NewLogger()))
+	_, err = network.RunIO(context.Background(), Request{
+		Bytes:              []byte{0x55, 0x01},
+		ControlFunction:    func(response []byte) bool { return len(response) > 0 },
+		Attempts:           1,
+		Reconnect:          true,
+		SecondsReadTimeout: 2,
+	})
+	if !errors.As(err, new(*DialError)) {
+		t.Errorf("ожидалась ошибка подключения, получено %v", err)
+	}
+}
+
+// Ошибка закрытия не оставляет Network в состоянии "подключено", следующее подключение возможно
+func TestCloseError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = listener.Close() }()
+	go func() {
+		for {
+			connection, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer func() { _ = connection.Close() }()
+		}
+	}()
+
+	address := listener.Addr().(*net.TCPAddr)
+	transport := NewTcpTransport("127.0.0.1", address.Port)
+	network := NewNetwork(transport, log.NewLoggerService(ozzolog.NewLogger()))
+	if err := network.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = transport.connection.Close()
+	if err := network.Close(); err == nil {
+		t.Error("ожидалась ошибка повторного закрытия соединения")
+	}
+	if network.IsConnected() {
+		t.Error("после ошибки закрытия соединение считается установленным")
+	}
+	if err := network.Connect(context.Background()); err != nil {
+		t.Errorf("повторное подключение: %v", err)
+	}
+	_ = network.Close()
+}
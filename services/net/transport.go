@@ -0,0 +1,92 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+Транспорт, по которому ведётся обмен данными с теплосчётчиком.
+Network работает только через этот интерфейс и не знает, что находится на другой стороне:
+TCP шлюз (iRZ, Moxa и т.д.) или RS-232/RS-485 адаптер, подключённый к компьютеру.
+*/
+type Transport interface {
+	Open(ctx context.Context) error     // Открытие соединения (порта). Должно прерываться при отмене ctx
+	Close() error                       // Закрытие соединения (порта)
+	Read(b []byte) (int, error)         // Чтение данных. По истечении таймаута должна возвращаться ошибка с Timeout() == true
+	Write(b []byte) (int, error)        // Запись данных
+	SetReadDeadline(t time.Time) error  // Таймаут чтения
+	SetWriteDeadline(t time.Time) error // Таймаут записи
+	String() string                     // Описание транспорта для лога
+}
+
+const serialPrefix = "serial:"
+
+// Создаёт транспорт по строке подключения.
+// Поддерживаются строки вида:
+//  - host:port?timeout=10s - TCP соединение. host - IP адрес, имя узла или IPv6 адрес в квадратных скобках,
+//    timeout - необязательный таймаут установки соединения;
+//  - serial:/dev/ttyUSB0?baud=9600&parity=none&stop=1&data=8&turnaround=20ms - последовательный порт.
+// В случае ошибки возвращается *EndpointError.
+func NewTransport(endpoint string) (Transport, error) {
+	if endpoint == "" {
+		return nil, &EndpointError{Endpoint: endpoint, Err: errors.New("строка подключения не задана")}
+	}
+
+	if strings.HasPrefix(endpoint, serialPrefix) {
+		config, err := ParseSerialConfig(strings.TrimPrefix(endpoint, serialPrefix))
+		if err != nil {
+			return nil, &EndpointError{Endpoint: endpoint, Err: err}
+		}
+		return NewSerialTransport(config), nil
+	}
+
+	transport, err := parseTcpTransport(endpoint)
+	if err != nil {
+		return nil, &EndpointError{Endpoint: endpoint, Err: err}
+	}
+	return transport, nil
+}
+
+// Адрес шлюза для строки подключения: host:port для TCP, serial:устройство для последовательного порта.
+// Параметры строки подключения (таймаут, скорость и т.д.) не учитываются: теплосчётчики за одним шлюзом
+// находятся на одной линии связи и не могут опрашиваться одновременно.
+func GatewayAddress(endpoint string) (string, error) {
+	transport, err := NewTransport(endpoint)
+	if err != nil {
+		return "", err
+	}
+	switch transport := transport.(type) {
+	case *TcpTransport:
+		return net.JoinHostPort(strings.ToLower(transport.host), strconv.Itoa(transport.port)), nil
+	case *SerialTransport:
+		return serialPrefix + transport.config.Device, nil
+	}
+	return endpoint, nil
+}
+
+// Отделяет параметры (всё, что после "?") от адреса в строке подключения
+func splitEndpointQuery(endpoint string) (string, url.Values, error) {
+	address, rawQuery := endpoint, ""
+	if i := strings.Index(endpoint, "?"); i >= 0 {
+		address, rawQuery = endpoint[:i], endpoint[i+1:]
+	}
+	query, err := url.ParseQuery(rawQuery)
+	return address, query, err
+}
+
+// Проверка, что ошибка является таймаутом чтения/записи.
+// Справедливо как для net.Conn, так и для os.File (os.ErrDeadlineExceeded).
+func isTimeout(err error) bool {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	var netError net.Error
+	return errors.As(err, &netError) && netError.Timeout()
+}
@@ -0,0 +1,83 @@
+package net
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewTransportTcp(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		host     string
+		port     int
+		timeout  time.Duration
+		gateway  string
+	}{
+		{"192.168.1.10:4001", "192.168.1.10", 4001, 0, "192.168.1.10:4001"},
+		{"gateway.local:502", "gateway.local", 502, 0, "gateway.local:502"},
+		{"Gateway.Local:502", "Gateway.Local", 502, 0, "gateway.local:502"},
+		{"[fe80::1]:4001", "fe80::1", 4001, 0, "[fe80::1]:4001"},
+		{"[2001:db8::10]:10001?timeout=5s", "2001:db8::10", 10001, 5 * time.Second, "[2001:db8::10]:10001"},
+		{"10.0.0.1:4001?timeout=1500ms", "10.0.0.1", 4001, 1500 * time.Millisecond, "10.0.0.1:4001"},
+		{"10.0.0.1:4001?timeout=0s", "10.0.0.1", 4001, 0, "10.0.0.1:4001"},
+	}
+	for _, test := range tests {
+		transport, err := NewTransport(test.endpoint)
+		if err != nil {
+			t.Errorf("%s: %v", test.endpoint, err)
+			continue
+		}
+		tcp, ok := transport.(*TcpTransport)
+		if !ok {
+			t.Errorf("%s: ожидался TCP транспорт, получен %T", test.endpoint, transport)
+			continue
+		}
+		if tcp.host != test.host || tcp.port != test.port || tcp.connectTimeout != test.timeout {
+			t.Errorf("%s: получено %s:%d таймаут %s", test.endpoint, tcp.host, tcp.port, tcp.connectTimeout)
+		}
+		if gateway, _ := GatewayAddress(test.endpoint); gateway != test.gateway {
+			t.Errorf("%s: адрес шлюза %s, ожидался %s", test.endpoint, gateway, test.gateway)
+		}
+	}
+}
+
+func TestNewTransportSerial(t *testing.T) {
+	transport, err := NewTransport("serial:/dev/ttyUSB0?baud=19200&turnaround=10ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, ok := transport.(*SerialTransport)
+	if !ok {
+		t.Fatalf("ожидался транспорт последовательного порта, получен %T", transport)
+	}
+	if serial.config.Device != "/dev/ttyUSB0" || serial.config.BaudRate != 19200 || serial.config.TurnaroundDelay != 10*time.Millisecond {
+		t.Errorf("получено %+v", serial.config)
+	}
+	if gateway, _ := GatewayAddress("serial:/dev/ttyUSB0?baud=9600"); gateway != "serial:/dev/ttyUSB0" {
+		t.Errorf("адрес шлюза %s", gateway)
+	}
+}
+
+func TestNewTransportInvalid(t *testing.T) {
+	endpoints := []string{
+		"",
+		"192.168.1.10",
+		":4001",
+		"gateway.local:port",
+		"fe80::1:4001",
+		"[fe80::1]",
+		"10.0.0.1:4001?timeout=5",
+		"10.0.0.1:4001?timeout=-1s",
+		"10.0.0.1:4001?retries=3",
+		"10.0.0.1:4001?timeout=%zz",
+		"serial:",
+		"serial:/dev/ttyUSB0?baud=x",
+	}
+	for _, endpoint := range endpoints {
+		transport, err := NewTransport(endpoint)
+		if !errors.As(err, new(*EndpointError)) {
+			t.Errorf("%s: ожидалась *EndpointError, получено %v (%v)", endpoint, err, transport)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package poll
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"qBox/drivers"
+	"qBox/drivers/mbus"
+	"qBox/models"
+	"qBox/services/log"
+	"qBox/services/net"
+	"time"
+)
+
+/**
+Сеанс опроса одного теплосчётчика: создание нового экземпляра драйвера, инициализация и чтение текущих данных.
+Используется утилитой в обычном и пакетном режимах, а также может использоваться как библиотека
+в собственных программах сбора данных:
+
+	transport, err := net.NewTransport("192.168.12.1:4001")
+	network := net.NewNetwork(transport, logger)
+	defer network.Close()
+	data, err := poll.Session{Driver: "tem104", CounterNumber: 1, Network: network, Logger: logger}.Poll(ctx)
+
+Сеансы можно выполнять повторно и параллельно, но сеансы с одним Network - только последовательно.
+*/
+type Session struct {
+	Driver        string            // Имя или номер драйвера, см. drivers.Lookup, либо drivers.Auto
+	CounterNumber byte              // Номер теплосчётчика
+	Address       string            // Вторичный адрес прибора M-Bus, см. mbus.ParseSecondaryAddress. Если задан, CounterNumber не используется
+	Network       *net.Network      // Соединение. Если соединение не установлено, оно устанавливается при первом запросе
+	Logger        log.LoggerService // Лог. Драйвер получает собственную копию
+	ProbeTimeout  time.Duration     // Время ожидания ответа на запросы определения драйвера (drivers.Auto). 0 - 1 секунда
+}
+
+// Опрос теплосчётчика. Возвращает копию данных, которая принадлежит вызывающему коду.
+// При ошибке чтения возвращается ошибка и прочитанная часть данных, если драйвер её вернул.
+// Ошибки шагов сеанса возвращаются как *StepError, состояние опроса - см. NewStatus.
+func (session Session) Poll(ctx context.Context) (data *models.DataDevice, err error) {
+	logger := session.Logger
+
+	defer func() {
+		// Ошибка в драйвере (например, разбор неожиданного ответа) не должна завершать программу сбора данных
+		if r := recover(); r != nil {
+			logger.Error("Ошибка драйвера: %v", r)
+			data, err = nil, &PanicError{Value: r}
+		}
+	}()
+
+	driver, detection, err := session.newDriver(ctx, &logger)
+	if err != nil {
+		return nil, err
+	}
+	err = session.init(ctx, driver, &logger)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Чтение текущих данных")
+	data, err = driver.Read(ctx)
+	if data != nil {
+		data = data.Clone()
+		data.Detected = detection
+	}
+	return data, stepError(models.StepRead, err)
+}
+
+// Чтение архива теплосчётчика за период [from, to), см. models.IArchiveDriver.
+// При ошибке чтения возвращается ошибка и архив с прочитанными записями, если драйвер его вернул.
+func (session Session) ReadArchive(ctx context.Context, archiveType models.ArchiveType, from time.Time, to time.Time) (archive *models.Archive, err error) {
+	logger := session.Logger
+
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("Ошибка драйвера: %v", r)
+			archive, err = nil, &PanicError{Value: r}
+		}
+	}()
+
+	if !from.Before(to) {
+		return nil, configError(fmt.Errorf("начало периода архива %s должно быть раньше окончания %s",
+			from.Format("02.01.2006 15:04"), to.Format("02.01.2006 15:04")))
+	}
+	driver, _, err := session.newDriver(ctx, &logger)
+	if err != nil {
+		return nil, err
+	}
+	archiveDriver, ok := driver.(models.IArchiveDriver)
+	if !ok {
+		return nil, configError(fmt.Errorf("драйвер %s не поддерживает чтение архивов", session.Driver))
+	}
+	err = session.init(ctx, driver, &logger)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Чтение архива %s с %s по %s", archiveType, from.Format("02.01.2006 15:04"), to.Format("02.01.2006 15:04"))
+	return archiveDriver.ReadArchive(ctx, archiveType, from, to)
+}
+
+/**
+Синхронизация часов прибора с часами компьютера, см. models.IClockDriver.
+Время прибора читается опросом текущих данных, расхождение вычисляется относительно DataDevice::TimeRequest.
+Если расхождение больше maxCorrection (0 - без ограничения), время не записывается и возвращается ошибка.
+При dryRun время не записывается. После записи время прибора читается повторно новым экземпляром драйвера
+с повторной инициализацией: драйвер не должен сохранять время или данные первого чтения.
+Время до и после коррекции записывается в лог.
+*/
+func (session Session) SyncTime(ctx context.Context, maxCorrection time.Duration, dryRun bool) (clock *models.ClockSync, err error) {
+	logger := session.Logger
+
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("Ошибка драйвера: %v", r)
+			clock, err = nil, &PanicError{Value: r}
+		}
+	}()
+
+	driver, _, err := session.newDriver(ctx, &logger)
+	if err != nil {
+		return nil, err
+	}
+	clockDriver, ok := driver.(models.IClockDriver)
+	if !ok {
+		return nil, configError(fmt.Errorf("драйвер %s не поддерживает установку времени", session.Driver))
+	}
+
+	before, err := session.readTime(ctx, driver, &logger)
+	if err != nil {
+		return nil, err
+	}
+	clock = &models.ClockSync{
+		Serial:      before.Serial,
+		TimeRequest: before.TimeRequest,
+		Before:      before.Time,
+		Drift:       before.Time.Sub(before.TimeRequest),
+		DryRun:      dryRun,
+	}
+	logger.Check("app")
+	logger.Info("Время на приборе %s, на компьютере %s, расхождение %s",
+		clock.Before.Format("02.01.2006 15:04:05"), clock.TimeRequest.Format("02.01.2006 15:04:05"), clock.Drift.String())
+
+	drift := clock.Drift
+	if drift < 0 {
+		drift = -drift
+	}
+	if maxCorrection > 0 && drift > maxCorrection {
+		return clock, fmt.Errorf("расхождение часов %s больше допустимой коррекции %s, время на приборе не изменено",
+			clock.Drift.String(), maxCorrection.String())
+	}
+	if dryRun {
+		logger.Info("Пробный запуск, время на приборе не изменено")
+		return clock, nil
+	}
+
+	logger.Check("driver")
+	err = clockDriver.SetTime(ctx, time.Now())
+	if err != nil {
+		return clock, stepError(models.StepSetTime, err)
+	}
+	clock.Corrected = true
+
+	driver, _, err = session.newDriver(ctx, &logger)
+	if err != nil {
+		return clock, err
+	}
+	after, err := session.readTime(ctx, driver, &logger)
+	if err != nil {
+		return clock, err
+	}
+	clock.After = after.Time
+	clock.DriftAfter = after.Time.Sub(after.TimeRequest)
+	logger.Check("app")
+	logger.Info("Время на приборе после коррекции %s, расхождение %s",
+		clock.After.Format("02.01.2006 15:04:05"), clock.DriftAfter.String())
+	return clock, nil
+}
+
+// Время на приборе: инициализация драйвера и чтение текущих данных
+func (session Session) readTime(ctx context.Context, driver models.IDeviceDriver, logger *log.LoggerService) (*models.DataDevice, error) {
+	err := session.init(ctx, driver, logger)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("Чтение времени на приборе")
+	data, err := driver.Read(ctx)
+	if err != nil {
+		return nil, stepError(models.StepRead, err)
+	}
+	if data.Time.IsZero() {
+		return nil, stepError(models.StepRead, errors.New("прибор не вернул время"))
+	}
+	if data.TimeRequest.IsZero() {
+		data.TimeRequest = time.Now()
+	}
+	return data.Clone(), nil
+}
+
+/**
+Определение драйвера по ответам прибора, см. drivers.Detector.
+Прибор с вторичным адресом Address проверяется только по протоколу M-Bus.
+*/
+func (session Session) Detect(ctx context.Context) (*models.Detection, error) {
+	logger := session.Logger
+	logger.Check("detect")
+	timeout := session.ProbeTimeout
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	detector := drivers.Detector{Network: session.Network, Logger: &logger, Timeout: timeout}
+	if session.Address != "" {
+		address, err := mbus.ParseSecondaryAddress(session.Address)
+		if err != nil {
+			return nil, configError(err)
+		}
+		return detector.DetectSecondary(ctx, address)
+	}
+	return detector.Detect(ctx, session.CounterNumber)
+}
+
+// Новый экземпляр драйвера. Если драйвер определяется по ответам прибора, в Driver записывается имя определённого драйвера
+func (session *Session) newDriver(ctx context.Context, logger *log.LoggerService) (models.IDeviceDriver, *models.Detection, error) {
+	if session.Driver != drivers.Auto {
+		driver, err := drivers.New(session.Driver)
+		return driver, nil, configError(err)
+	}
+	detection, err := session.Detect(ctx)
+	if err != nil {
+		return nil, nil, stepError(models.StepDetect, err)
+	}
+	logger.Info("Определён прибор %s, драйвер %s, версия ПО %q", detection.Model, detection.Driver, detection.Firmware)
+	session.Driver = detection.Driver
+	driver, err := drivers.New(detection.Driver)
+	return driver, detection, err
+}
+
+// Инициализация нового экземпляра драйвера, при необходимости с выбором прибора по вторичному адресу
+func (session Session) init(ctx context.Context, driver models.IDeviceDriver, logger *log.LoggerService) error {
+	counterNumber := session.CounterNumber
+	if session.Address != "" {
+		var err error
+		counterNumber, err = session.selectMeter(ctx, driver, logger)
+		if err != nil {
+			return stepError(models.StepSelect, err)
+		}
+	}
+
+	logger.Check("driver")
+	logger.Info("Инициализация драйвера")
+	return stepError(models.StepInit, driver.Init(ctx, counterNumber, session.Network, logger))
+}
+
+// Выбор прибора M-Bus по вторичному адресу. Возвращает адрес, по которому драйвер обращается к выбранному прибору
+func (session Session) selectMeter(ctx context.Context, driver models.IDeviceDriver, logger *log.LoggerService) (byte, error) {
+	if _, ok := driver.(models.IMBusDriver); !ok {
+		return 0, configError(fmt.Errorf("драйвер %s не поддерживает вторичную адресацию", session.Driver))
+	}
+	address, err := mbus.ParseSecondaryAddress(session.Address)
+	if err != nil {
+		return 0, configError(err)
+	}
+	logger.Check("driver")
+	err = mbus.Select(ctx, session.Network, address, logger)
+	if err != nil {
+		return 0, err
+	}
+	return mbus.AddressNetwork, nil
+}
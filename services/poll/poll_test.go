@@ -0,0 +1,82 @@
+package poll
+
+import (
+	"context"
+	"errors"
+	"qBox/drivers"
+	"qBox/models"
+	"qBox/services/log"
+	"qBox/services/net"
+	"testing"
+	"time"
+
+	ozzolog "github.com/go-ozzo/ozzo-log"
+)
+
+/**
+Часы прибора для проверки синхронизации: время прибора спешит на drift, пока не записано время.
+Драйвер хранит время первого чтения, поэтому повторное чтение тем же экземпляром вернуло бы время до коррекции.
+*/
+type stubClock struct {
+	drift     time.Duration
+	corrected bool
+	setErr    error
+	instances int
+}
+
+var clock = &stubClock{}
+
+type stubClockDriver struct {
+	data *models.DataDevice
+}
+
+func init() {
+	drivers.Register("stub-clock", func() models.IDeviceDriver {
+		clock.instances++
+		return &stubClockDriver{}
+	}, drivers.Metadata{Title: "Заглушка часов"})
+}
+
+func (driver *stubClockDriver) Init(context.Context, byte, *net.Network, *log.LoggerService) error {
+	return nil
+}
+
+func (driver *stubClockDriver) Read(context.Context) (*models.DataDevice, error) {
+	if driver.data == nil {
+		now := time.Now()
+		driver.data = &models.DataDevice{Serial: "1", TimeRequest: now, Time: now}
+		if !clock.corrected {
+			driver.data.Time = now.Add(clock.drift)
+		}
+	}
+	return driver.data, nil
+}
+
+func (driver *stubClockDriver) SetTime(context.Context, time.Time) error {
+	if clock.setErr != nil {
+		return clock.setErr
+	}
+	clock.corrected = true
+	return nil
+}
+
+// Время после коррекции читается новым экземпляром драйвера, ошибка записи относится к шагу setTime
+func TestSyncTime(t *testing.T) {
+	session := Session{Driver: "stub-clock", Logger: log.NewLoggerService(ozzolog.NewLogger())}
+
+	*clock = stubClock{drift: time.Hour}
+	result, err := session.SyncTime(context.Background(), 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Corrected || result.Drift < 59*time.Minute || result.DriftAfter > time.Minute || clock.instances != 2 {
+		t.Errorf("синхронизация %+v, экземпляров драйвера %d", result, clock.instances)
+	}
+
+	*clock = stubClock{drift: time.Hour, setErr: errors.New("запись запрещена")}
+	_, err = session.SyncTime(context.Background(), 0, false)
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) || stepErr.Step != models.StepSetTime {
+		t.Errorf("ошибка записи времени: %v", err)
+	}
+}
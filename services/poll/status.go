@@ -0,0 +1,157 @@
+package poll
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	stdnet "net"
+	"os"
+	"qBox/models"
+	"qBox/services/net"
+)
+
+/**
+Ошибка шага сеанса опроса. Текст ошибки не изменяется, шаг используется для PollStatus
+*/
+type StepError struct {
+	Step models.PollStep
+	Err  error
+}
+
+func (e *StepError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+func stepError(step models.PollStep, err error) error {
+	if err == nil {
+		return nil
+	}
+	var inner *StepError
+	if errors.As(err, &inner) {
+		return err
+	}
+	return &StepError{Step: step, Err: err}
+}
+
+/**
+Ошибка настройки опроса: неизвестный драйвер, недопустимые номер теплосчётчика, единицы или вторичный адрес,
+возможность, которую драйвер не поддерживает. Ошибка возникает до обмена с прибором, см. ErrorConfig
+*/
+type ConfigError struct {
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+func configError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ConfigError{Err: err}
+}
+
+// Паника в драйвере, перехваченная сеансом опроса, см. ErrorDriver
+type PanicError struct {
+	Value interface{}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("ошибка драйвера: %v", e.Value)
+}
+
+/**
+Класс ошибки опроса по типу ошибки Network и транспорта. Ошибки, которые вернул драйвер
+при разборе ответов, относятся к ErrorProtocol, ошибки настройки - к ErrorConfig, паника драйвера - к ErrorDriver.
+*/
+func Classify(err error) models.ErrorClass {
+	var interrupted *net.InterruptedError
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &interrupted):
+		if errors.Is(interrupted.Err, context.DeadlineExceeded) {
+			return models.ErrorTimeout
+		}
+		return models.ErrorInterrupted
+	case errors.As(err, new(*ConfigError)):
+		return models.ErrorConfig
+	case errors.As(err, new(*PanicError)):
+		return models.ErrorDriver
+	case isConnectError(err):
+		return models.ErrorConnect
+	case errors.As(err, new(*net.NoResponseError)), errors.Is(err, os.ErrDeadlineExceeded):
+		return models.ErrorTimeout
+	case errors.As(err, new(*net.ResponseError)):
+		return models.ErrorFrame
+	case errors.Is(err, io.EOF), errors.Is(err, net.ErrNotConnected), errors.As(err, new(*stdnet.OpError)),
+		errors.As(err, new(*os.PathError)):
+		// Соединение разорвано во время обмена, ошибка последовательного порта
+		return models.ErrorConnect
+	}
+	return models.ErrorProtocol
+}
+
+// Ошибка строки подключения, определения адреса или установки соединения
+func isConnectError(err error) bool {
+	return errors.As(err, new(*net.EndpointError)) || errors.As(err, new(*net.ResolveError)) ||
+		errors.As(err, new(*net.DialError))
+}
+
+/**
+Состояние опроса по результату Session::Poll. Результат с ошибкой и заполненными полями - частичный.
+*/
+func NewStatus(data *models.DataDevice, err error) *models.PollStatus {
+	if err == nil {
+		return &models.PollStatus{State: models.StateSuccess}
+	}
+	status := &models.PollStatus{State: models.StateFailed, Class: Classify(err), Error: err.Error()}
+	var step *StepError
+	if errors.As(err, &step) {
+		status.Step = step.Step
+	}
+	if isConnectError(err) {
+		status.Step = models.StepConnect
+	}
+	if data != nil {
+		status.Valid = data.FilledFields()
+		if len(status.Valid) > 0 {
+			status.State = models.StatePartial
+		}
+	}
+	return status
+}
+
+/**
+Код завершения утилиты по классу ошибки. 1 - ошибки настройки и прочие ошибки, 2 - неверные флаги
+(завершение пакетом flag), 8 - паника драйвера
+*/
+func ExitCode(class models.ErrorClass) int {
+	switch class {
+	case "":
+		return 0
+	case models.ErrorConnect:
+		return 3
+	case models.ErrorTimeout:
+		return 4
+	case models.ErrorFrame:
+		return 5
+	case models.ErrorProtocol:
+		return 6
+	case models.ErrorInterrupted:
+		return 7
+	case models.ErrorDriver:
+		return 8
+	}
+	return 1
+}
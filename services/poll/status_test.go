@@ -0,0 +1,61 @@
+package poll
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"qBox/models"
+	"qBox/services/net"
+	"reflect"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	for _, test := range []struct {
+		err   error
+		class models.ErrorClass
+	}{
+		{nil, ""},
+		{&net.EndpointError{Endpoint: "gateway", Err: errors.New("нет порта")}, models.ErrorConnect},
+		{&net.DialError{Address: "192.168.12.1:4001", Err: errors.New("connection refused")}, models.ErrorConnect},
+		{&StepError{Step: models.StepRead, Err: io.EOF}, models.ErrorConnect},
+		{&StepError{Step: models.StepInit, Err: &net.NoResponseError{}}, models.ErrorTimeout},
+		{&net.InterruptedError{Err: context.DeadlineExceeded}, models.ErrorTimeout},
+		{&net.InterruptedError{Err: context.Canceled}, models.ErrorInterrupted},
+		{fmt.Errorf("чтение страницы: %w", &net.ResponseError{Response: []byte{0x55}}), models.ErrorFrame},
+		{errors.New("ошибка разбора ответа"), models.ErrorProtocol},
+		{&ConfigError{Err: errors.New("неизвестная единица измерения энергии")}, models.ErrorConfig},
+		{&StepError{Step: models.StepSelect, Err: &ConfigError{Err: errors.New("некорректный адрес")}}, models.ErrorConfig},
+		{&PanicError{Value: "index out of range"}, models.ErrorDriver},
+	} {
+		if class := Classify(test.err); class != test.class {
+			t.Errorf("%v: %q, ожидался %q", test.err, class, test.class)
+		}
+	}
+}
+
+func TestNewStatus(t *testing.T) {
+	status := NewStatus(&models.DataDevice{Serial: "104001"}, nil)
+	if status.State != models.StateSuccess || status.Class != "" || ExitCode(status.Class) != 0 {
+		t.Errorf("успешный опрос: %+v", status)
+	}
+
+	status = NewStatus(nil, &net.DialError{Address: "192.168.12.1:4001", Err: errors.New("connection refused")})
+	if status.State != models.StateFailed || status.Step != models.StepConnect || ExitCode(status.Class) != 3 {
+		t.Errorf("ошибка соединения: %+v", status)
+	}
+
+	// Нулевые показания активной системы достоверны, показания неактивной системы - нет
+	data := &models.DataDevice{Serial: "104001", Systems: []models.SystemDevice{{Status: true, SigmaQ: 12.5}, {SigmaQ: 1}}}
+	status = NewStatus(data, stepError(models.StepRead, &net.NoResponseError{}))
+	valid := []string{"serial", "timeOn", "timeRunCommon"}
+	for _, name := range models.SystemFields {
+		valid = append(valid, "system1."+name)
+	}
+	expected := &models.PollStatus{State: models.StatePartial, Step: models.StepRead, Class: models.ErrorTimeout,
+		Error: "прибор не ответил на запрос", Valid: valid}
+	if !reflect.DeepEqual(status, expected) {
+		t.Errorf("частичный результат: %+v, ожидался %+v", status, expected)
+	}
+}
@@ -0,0 +1,149 @@
+package raw
+
+import (
+	"errors"
+	"fmt"
+	"github.com/npat-efault/crc16"
+)
+
+/**
+Контрольная сумма, добавляемая к запросу и проверяемая в ответе
+*/
+type Checksum string
+
+const (
+	ChecksumNone   Checksum = "none"   // Запрос отправляется как задан, ответ читается до таймаута
+	ChecksumTem    Checksum = "tem"    // ТЭМ: инвертированная сумма байтов кадра
+	ChecksumMBus   Checksum = "mbus"   // M-Bus: сумма байтов после заголовка кадра и стоп-байт 16h
+	ChecksumModbus Checksum = "modbus" // modbus RTU: CRC16, младший байт первым
+)
+
+func ParseChecksum(value string) (Checksum, error) {
+	switch checksum := Checksum(value); checksum {
+	case "":
+		return ChecksumNone, nil
+	case ChecksumNone, ChecksumTem, ChecksumMBus, ChecksumModbus:
+		return checksum, nil
+	}
+	return "", fmt.Errorf("неверная контрольная сумма %q, возможно: none, tem, mbus, modbus", value)
+}
+
+/**
+Запрос с контрольной суммой. Для M-Bus кадр задаётся без CS и 16h: короткий 10h C A или длинный 68h L L 68h C A CI данные
+*/
+func (checksum Checksum) Append(frame []byte) ([]byte, error) {
+	request := append([]byte{}, frame...)
+	switch checksum {
+	case ChecksumTem:
+		return append(request, temSum(frame)), nil
+	case ChecksumMBus:
+		body, err := mbusBody(frame)
+		if err != nil {
+			return nil, err
+		}
+		return append(request, sum(body), 0x16), nil
+	case ChecksumModbus:
+		crc := crc16.Checksum(crc16.Modbus, frame)
+		return append(request, byte(crc), byte(crc>>8)), nil
+	}
+	return request, nil
+}
+
+/**
+Проверка контрольной суммы ответа. Ответ без контрольной суммы (none, подтверждение M-Bus E5h) считается верным
+*/
+func (checksum Checksum) Check(response []byte) bool {
+	switch checksum {
+	case ChecksumTem:
+		return len(response) > 1 && temSum(response[:len(response)-1]) == response[len(response)-1]
+	case ChecksumMBus:
+		if len(response) == 1 {
+			return response[0] == 0xE5
+		}
+		if len(response) < 2 || response[len(response)-1] != 0x16 {
+			return false
+		}
+		body, err := mbusBody(response[:len(response)-2])
+		return err == nil && sum(body) == response[len(response)-2]
+	case ChecksumModbus:
+		if len(response) < 4 {
+			return false
+		}
+		crc := crc16.Checksum(crc16.Modbus, response[:len(response)-2])
+		return response[len(response)-2] == byte(crc) && response[len(response)-1] == byte(crc>>8)
+	}
+	return true
+}
+
+/**
+Ответ получен полностью: длина определяется по заголовку кадра протокола.
+Без контрольной суммы длина ответа неизвестна, и ответ читается до таймаута
+*/
+func (checksum Checksum) Complete(response []byte) bool {
+	switch checksum {
+	case ChecksumTem:
+		// AAh номер ^номер группа команда длина данные CS
+		return len(response) >= 6 && len(response) >= 6+int(response[5])+1
+	case ChecksumMBus:
+		if len(response) == 0 {
+			return false
+		}
+		switch response[0] {
+		case 0xE5:
+			return true
+		case 0x10:
+			return len(response) >= 5
+		case 0x68:
+			return len(response) >= 4 && len(response) >= int(response[1])+6
+		}
+	case ChecksumModbus:
+		if len(response) < 2 {
+			return false
+		}
+		function := response[1]
+		switch {
+		case function&0x80 != 0:
+			return len(response) >= 5 // Код ошибки
+		case function >= 0x01 && function <= 0x04:
+			return len(response) >= 3 && len(response) >= 5+int(response[2])
+		case function == 0x05 || function == 0x06 || function == 0x0F || function == 0x10:
+			return len(response) >= 8
+		}
+	}
+	return false
+}
+
+func (checksum Checksum) String() string {
+	switch checksum {
+	case ChecksumTem:
+		return "ТЭМ"
+	case ChecksumMBus:
+		return "M-Bus"
+	case ChecksumModbus:
+		return "modbus"
+	}
+	return "нет"
+}
+
+// Байты кадра M-Bus, по которым вычисляется контрольная сумма
+func mbusBody(frame []byte) ([]byte, error) {
+	switch {
+	case len(frame) >= 3 && frame[0] == 0x10:
+		return frame[1:], nil
+	case len(frame) >= 4 && frame[0] == 0x68 && frame[3] == 0x68:
+		return frame[4:], nil
+	}
+	return nil, errors.New("кадр M-Bus должен начинаться с 10h C A или 68h L L 68h")
+}
+
+func sum(bytes []byte) byte {
+	var result byte
+	for _, b := range bytes {
+		result += b
+	}
+	return result
+}
+
+func temSum(bytes []byte) byte {
+	return ^sum(bytes)
+}
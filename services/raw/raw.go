@@ -0,0 +1,169 @@
+package raw
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"qBox/models"
+	"qBox/services/net"
+	"strings"
+	"time"
+)
+
+/**
+Отправка произвольных запросов прибору и вывод ответов, подкоманда raw.
+Обмен выполняется через Network.Exchange: эхо запроса убирается, ответ читается до таймаута
+либо до конца кадра, если задана контрольная сумма протокола.
+*/
+type Console struct {
+	Network  *net.Network
+	Checksum Checksum
+	Timeout  time.Duration // Время ожидания ответа на запрос
+}
+
+/**
+Результат одного обмена
+*/
+type Exchange struct {
+	Request  []byte
+	Response []byte
+	Checksum Checksum
+	Duration time.Duration
+	Err      error
+}
+
+// Отправка кадра с контрольной суммой и чтение ответа
+func (console Console) Send(ctx context.Context, frame []byte) Exchange {
+	exchange := Exchange{Checksum: console.Checksum}
+	exchange.Request, exchange.Err = console.Checksum.Append(frame)
+	if exchange.Err != nil {
+		exchange.Request = frame
+		return exchange
+	}
+	var complete func(response []byte) bool
+	if console.Checksum != ChecksumNone {
+		complete = console.Checksum.Complete
+	}
+	start := time.Now()
+	exchange.Response, exchange.Err = console.Network.Exchange(ctx, exchange.Request, console.Timeout, complete)
+	exchange.Duration = time.Since(start)
+	return exchange
+}
+
+/**
+Кадр в шестнадцатеричном виде. Байты можно разделять пробелами, запятыми и двоеточиями
+и записывать с префиксом 0x: "10 5B 01", "105B01", "0x10,0x5B,0x01"
+*/
+func ParseFrame(text string) ([]byte, error) {
+	var digits strings.Builder
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == ',' || r == ':'
+	})
+	for _, field := range fields {
+		if strings.HasPrefix(field, "0x") || strings.HasPrefix(field, "0X") {
+			field = field[2:]
+			if len(field) == 1 {
+				field = "0" + field
+			}
+		}
+		digits.WriteString(field)
+	}
+	if digits.Len() == 0 {
+		return nil, fmt.Errorf("пустой кадр %q", text)
+	}
+	frame, err := hex.DecodeString(digits.String())
+	if err != nil {
+		return nil, fmt.Errorf("неверный кадр %q: ожидаются байты в шестнадцатеричном виде", text)
+	}
+	return frame, nil
+}
+
+// Вывод результата обмена. Для формата json - объект в одну строку (JSON Lines)
+func (exchange Exchange) Render(writer io.Writer, formatter models.Formatter) {
+	switch formatter.(type) {
+	case *models.JsonFormat, models.JsonFormat, *models.Json2Format, models.Json2Format:
+		exchange.renderJson(writer)
+	default:
+		exchange.renderText(writer)
+	}
+}
+
+type exchangeJson struct {
+	Request  string  `json:"request"`
+	Response string  `json:"response"`
+	Duration float64 `json:"duration"`           // секунды
+	Checksum *bool   `json:"checksum,omitempty"` // Контрольная сумма ответа верна. Нет - контрольная сумма не проверялась
+	Error    string  `json:"error,omitempty"`
+}
+
+func (exchange Exchange) renderJson(writer io.Writer) {
+	record := exchangeJson{
+		Request:  fmt.Sprintf("%X", exchange.Request),
+		Response: fmt.Sprintf("%X", exchange.Response),
+		Duration: exchange.Duration.Seconds(),
+	}
+	if exchange.Checksum != ChecksumNone && len(exchange.Response) > 0 {
+		valid := exchange.Checksum.Check(exchange.Response)
+		record.Checksum = &valid
+	}
+	if exchange.Err != nil {
+		record.Error = exchange.Err.Error()
+	}
+	bytesResponse, _ := json.Marshal(record)
+	_, _ = fmt.Fprintln(writer, string(bytesResponse))
+}
+
+func (exchange Exchange) renderText(writer io.Writer) {
+	_, _ = fmt.Fprintf(writer, "> % X\n", exchange.Request)
+	switch {
+	case len(exchange.Response) > 0:
+		_, _ = fmt.Fprintf(writer, "< %d байт за %s\n", len(exchange.Response), exchange.Duration.Round(time.Millisecond))
+		Dump(writer, exchange.Response)
+		if exchange.Checksum != ChecksumNone {
+			if exchange.Checksum.Check(exchange.Response) {
+				_, _ = fmt.Fprintf(writer, "  контрольная сумма %s верна\n", exchange.Checksum)
+			} else {
+				_, _ = fmt.Fprintf(writer, "  контрольная сумма %s НЕВЕРНА\n", exchange.Checksum)
+			}
+		}
+	case exchange.Err == nil:
+		_, _ = fmt.Fprintf(writer, "< нет ответа за %s\n", exchange.Duration.Round(time.Millisecond))
+	}
+	if exchange.Err != nil {
+		_, _ = fmt.Fprintf(writer, "! %s\n", exchange.Err.Error())
+	}
+}
+
+/**
+Вывод байтов по 16 в строке: смещение, байты и их символы ASCII
+
+	0000  68 1F 1F 68 08 01 72 78  56 34 12 2D 2C 01 04 00  h..h..rxV4.-,...
+*/
+func Dump(writer io.Writer, data []byte) {
+	for offset := 0; offset < len(data); offset += 16 {
+		end := offset + 16
+		if end > len(data) {
+			end = len(data)
+		}
+		var line strings.Builder
+		var text strings.Builder
+		for i := offset; i < offset+16; i++ {
+			if i == offset+8 {
+				line.WriteString(" ")
+			}
+			if i >= end {
+				line.WriteString("   ")
+				continue
+			}
+			line.WriteString(fmt.Sprintf(" %02X", data[i]))
+			if data[i] >= 0x20 && data[i] < 0x7F {
+				text.WriteByte(data[i])
+			} else {
+				text.WriteByte('.')
+			}
+		}
+		_, _ = fmt.Fprintf(writer, "  %04X %s  %s\n", offset, line.String(), text.String())
+	}
+}
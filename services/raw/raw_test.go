@@ -0,0 +1,99 @@
+package raw
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseFrame(t *testing.T) {
+	for _, text := range []string{"10 5B 01", "105b01", "0x10,0x5B,0x01", "10:5B:01", "0x10 0x5b 0x1"} {
+		frame, err := ParseFrame(text)
+		if err != nil {
+			t.Errorf("%q: %v", text, err)
+			continue
+		}
+		if !bytes.Equal(frame, []byte{0x10, 0x5B, 0x01}) {
+			t.Errorf("%q: %X", text, frame)
+		}
+	}
+	for _, text := range []string{"", " , ", "10 5", "zz"} {
+		if _, err := ParseFrame(text); err == nil {
+			t.Errorf("%q: ожидалась ошибка", text)
+		}
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	for _, test := range []struct {
+		checksum Checksum
+		frame    []byte
+		request  []byte
+	}{
+		{ChecksumNone, []byte{0x10, 0x40, 0x01}, []byte{0x10, 0x40, 0x01}},
+		{ChecksumTem, []byte{0x55, 0x01, 0xFE, 0x0F, 0x01, 0x03, 0x00, 0x00, 0x80}, []byte{0x55, 0x01, 0xFE, 0x0F, 0x01, 0x03, 0x00, 0x00, 0x80, 0x18}},
+		{ChecksumMBus, []byte{0x10, 0x5B, 0x01}, []byte{0x10, 0x5B, 0x01, 0x5C, 0x16}},
+		{ChecksumMBus, []byte{0x68, 0x03, 0x03, 0x68, 0x53, 0xFE, 0x50}, []byte{0x68, 0x03, 0x03, 0x68, 0x53, 0xFE, 0x50, 0xA1, 0x16}},
+		{ChecksumModbus, []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x0A}, []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x0A, 0xC5, 0xCD}},
+	} {
+		request, err := test.checksum.Append(test.frame)
+		if err != nil {
+			t.Errorf("%s %X: %v", test.checksum, test.frame, err)
+			continue
+		}
+		if !bytes.Equal(request, test.request) {
+			t.Errorf("%s %X: запрос %X, ожидался %X", test.checksum, test.frame, request, test.request)
+		}
+		if !test.checksum.Check(request) {
+			t.Errorf("%s %X: контрольная сумма не прошла проверку", test.checksum, request)
+		}
+		request[len(request)-1] ^= 0x01
+		if test.checksum != ChecksumNone && test.checksum.Check(request) {
+			t.Errorf("%s %X: неверная контрольная сумма прошла проверку", test.checksum, request)
+		}
+	}
+	if _, err := ChecksumMBus.Append([]byte{0x55, 0x01}); err == nil {
+		t.Error("mbus: ожидалась ошибка для кадра без заголовка M-Bus")
+	}
+}
+
+func TestComplete(t *testing.T) {
+	for _, test := range []struct {
+		checksum Checksum
+		response []byte
+		complete bool
+	}{
+		{ChecksumNone, []byte{0xE5}, false},
+		{ChecksumTem, []byte{0xAA, 0x01, 0xFE, 0x0F, 0x02, 0x02, 0x10}, false},
+		{ChecksumTem, []byte{0xAA, 0x01, 0xFE, 0x0F, 0x02, 0x02, 0x10, 0x10, 0x00}, true},
+		{ChecksumMBus, []byte{0xE5}, true},
+		{ChecksumMBus, []byte{0x68, 0x03, 0x03, 0x68, 0x08, 0x01, 0x72, 0x7B}, false},
+		{ChecksumMBus, []byte{0x68, 0x03, 0x03, 0x68, 0x08, 0x01, 0x72, 0x7B, 0x16}, true},
+		{ChecksumModbus, []byte{0x01, 0x03, 0x02, 0x00}, false},
+		{ChecksumModbus, []byte{0x01, 0x03, 0x02, 0x00, 0x01, 0x79, 0x84}, true},
+		{ChecksumModbus, []byte{0x01, 0x83, 0x02, 0xC0, 0xF1}, true},
+		{ChecksumModbus, []byte{0x01, 0x06, 0x00, 0x01, 0x00, 0x03, 0x98}, false},
+	} {
+		if complete := test.checksum.Complete(test.response); complete != test.complete {
+			t.Errorf("%s %X: %v, ожидалось %v", test.checksum, test.response, complete, test.complete)
+		}
+	}
+}
+
+func TestDump(t *testing.T) {
+	var output bytes.Buffer
+	Dump(&output, []byte("0123456789ABCDEF\x01\x02"))
+	lines := strings.Split(strings.TrimRight(output.String(), "\n"), "\n")
+	expected := []string{
+		"  0000  30 31 32 33 34 35 36 37  38 39 41 42 43 44 45 46  0123456789ABCDEF",
+		"  0010  01 02                                             ..",
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("вывод:\n%s", output.String())
+	}
+	for i := range lines {
+		if lines[i] != expected[i] {
+			t.Errorf("строка %d:\n%q\nожидалась\n%q", i, lines[i], expected[i])
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package simulator
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Типы приборов симулятора
+var Types = []string{"tem104", "tem104m", "skm2", "sku02", "sku02b", "tm3", "tem05"}
+
+/**
+Настройка симулятора - JSON файл, например:
+
+	{
+		"devices": [
+			{"type": "tem104", "number": 1, "serial": 104001, "clock": "-5m",
+			 "memory": {"0C01:2200": "42910000"}},
+			{"type": "tm3", "number": 2, "registers": {"0143": "0002"}},
+			{"type": "skm2", "number": 3, "telegrams": ["046D00000000"]}
+		],
+		"faults": [{"kind": "checksum", "response": 2}, {"kind": "delay", "delay": "4s", "response": 5}]
+	}
+
+Приборы подключены к одной шине и отвечают на запросы по своим номерам. Содержимое прибора берётся из профиля
+по умолчанию для типа (см. NewTem104 и др.), заданные в настройке данные записываются поверх профиля.
+*/
+type Config struct {
+	Devices []DeviceConfig `json:"devices"`
+	Faults  []Fault        `json:"faults"`
+}
+
+/**
+Настройка прибора. Данные задаются в шестнадцатеричном виде:
+memory - память ТЭМ по ключу "группа и команда чтения:адрес" ("0F01:007C"), память ТЭМ-05 по ключу "адрес";
+registers - значения регистров modbus подряд с адреса ключа, по 2 байта (big endian) на регистр;
+telegrams - записи ответов M-Bus после заголовка прикладного уровня, заменяют ответ профиля;
+blocks - данные ответа SKU-02 по ключу "команда" ("20").
+*/
+type DeviceConfig struct {
+	Type      string            `json:"type"`
+	Number    *byte             `json:"number"` // Номер прибора, первичный адрес M-Bus или адрес modbus. По умолчанию 0, как у флага number qbox
+	Serial    uint32            `json:"serial"` // Заводской номер, идентификационный номер M-Bus. 0 - номер профиля
+	Clock     Duration          `json:"clock"`  // Расхождение часов прибора с часами компьютера
+	Memory    map[string]string `json:"memory"`
+	Registers map[string]string `json:"registers"`
+	Telegrams []string          `json:"telegrams"`
+	Blocks    map[string]string `json:"blocks"`
+}
+
+func LoadConfig(path string) (Config, error) {
+	var config Config
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err = json.Unmarshal(content, &config); err != nil {
+		return config, fmt.Errorf("файл настройки симулятора %s: %w", path, err)
+	}
+	return config, nil
+}
+
+/**
+Симулятор по настройке
+*/
+func (config Config) Server() (*Server, error) {
+	if len(config.Devices) == 0 {
+		return nil, fmt.Errorf("в настройке симулятора нет приборов")
+	}
+	server := &Server{}
+	for i, deviceConfig := range config.Devices {
+		device, err := deviceConfig.Device()
+		if err != nil {
+			return nil, fmt.Errorf("прибор %d (%s): %w", i+1, deviceConfig.Type, err)
+		}
+		server.Devices = append(server.Devices, device)
+	}
+	for _, fault := range config.Faults {
+		if err := fault.validate(); err != nil {
+			return nil, err
+		}
+		server.Faults = append(server.Faults, fault)
+	}
+	return server, nil
+}
+
+/**
+Прибор по настройке: профиль типа и заданные данные
+*/
+func (config DeviceConfig) Device() (Device, error) {
+	var number byte
+	if config.Number != nil {
+		number = *config.Number
+	}
+	clock := Clock{Offset: time.Duration(config.Clock)}
+
+	known := false
+	for _, kind := range Types {
+		known = known || kind == config.Type
+	}
+	if !known {
+		return nil, fmt.Errorf("неизвестный тип прибора %q, допустимы: %s", config.Type, strings.Join(Types, ", "))
+	}
+	if err := config.unsupported(); err != nil {
+		return nil, err
+	}
+
+	switch config.Type {
+	case "tem104", "tem104m":
+		device := NewTem104(number, config.Serial)
+		if config.Type == "tem104m" {
+			device = NewTem104M(number, config.Serial)
+		}
+		device.Clock = clock
+		for key, data := range config.Memory {
+			area, address, err := parseTemAddress(key)
+			if err != nil {
+				return nil, err
+			}
+			bytes, err := parseHex(data)
+			if err != nil {
+				return nil, fmt.Errorf("memory %s: %w", key, err)
+			}
+			if device.Memory[area] == nil {
+				device.Memory[area] = Memory{}
+			}
+			device.Memory[area].Write(address, bytes)
+		}
+		return device, nil
+
+	case "skm2", "sku02b":
+		device := NewSKM2(number, config.Serial)
+		if config.Type == "sku02b" {
+			device = NewSKU02B(number, config.Serial)
+		}
+		device.Clock = clock
+		if len(config.Telegrams) > 0 {
+			device.Telegrams = nil
+		}
+		for i, telegram := range config.Telegrams {
+			records, err := parseHex(telegram)
+			if err != nil {
+				return nil, fmt.Errorf("telegrams %d: %w", i+1, err)
+			}
+			device.Telegrams = append(device.Telegrams, records)
+		}
+		return device, nil
+
+	case "sku02":
+		device := NewSKU02(config.Serial)
+		device.Clock = clock
+		for key, data := range config.Blocks {
+			command, err := strconv.ParseUint(key, 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("blocks: некорректная команда %q", key)
+			}
+			if device.Blocks[byte(command)], err = parseHex(data); err != nil {
+				return nil, fmt.Errorf("blocks %s: %w", key, err)
+			}
+		}
+		return device, nil
+
+	case "tm3":
+		device := NewTM3(number, config.Serial)
+		device.Clock = clock
+		for key, data := range config.Registers {
+			address, err := strconv.ParseUint(key, 16, 16)
+			if err != nil {
+				return nil, fmt.Errorf("registers: некорректный адрес регистра %q", key)
+			}
+			bytes, err := parseHex(data)
+			if err != nil || len(bytes)%2 != 0 {
+				return nil, fmt.Errorf("registers %s: значения задаются по 2 байта на регистр", key)
+			}
+			device.write(uint16(address), bytes)
+		}
+		return device, nil
+
+	case "tem05":
+		if config.Serial > 0xFFFF {
+			return nil, fmt.Errorf("заводской номер ТЭМ-05 не больше 65535")
+		}
+		device := NewTem05(config.Serial)
+		device.Clock = clock
+		for key, data := range config.Memory {
+			address, err := strconv.ParseUint(key, 16, 16)
+			if err != nil {
+				return nil, fmt.Errorf("memory: некорректный адрес %q", key)
+			}
+			bytes, err := parseHex(data)
+			if err != nil {
+				return nil, fmt.Errorf("memory %s: %w", key, err)
+			}
+			device.Memory.Write(uint32(address), bytes)
+		}
+		return device, nil
+	}
+	return nil, nil
+}
+
+// Данные, которые тип прибора не использует, - ошибка настройки, а не молчаливый пропуск
+func (config DeviceConfig) unsupported() error {
+	fields := map[string]bool{
+		"memory":    len(config.Memory) > 0,
+		"registers": len(config.Registers) > 0,
+		"telegrams": len(config.Telegrams) > 0,
+		"blocks":    len(config.Blocks) > 0,
+	}
+	allowed := map[string]string{
+		"tem104": "memory", "tem104m": "memory", "tem05": "memory",
+		"tm3":  "registers",
+		"skm2": "telegrams", "sku02b": "telegrams",
+		"sku02": "blocks",
+	}
+	for _, field := range []string{"memory", "registers", "telegrams", "blocks"} {
+		if fields[field] && allowed[config.Type] != field {
+			return fmt.Errorf("%s не поддерживается прибором %s", field, config.Type)
+		}
+	}
+	return nil
+}
+
+// Адрес памяти ТЭМ "0F01:007C"
+func parseTemAddress(key string) (uint16, uint32, error) {
+	parts := strings.Split(key, ":")
+	if len(parts) == 2 {
+		area, err := strconv.ParseUint(parts[0], 16, 16)
+		if err == nil {
+			var address uint64
+			address, err = strconv.ParseUint(parts[1], 16, 32)
+			if err == nil {
+				return uint16(area), uint32(address), nil
+			}
+		}
+	}
+	return 0, 0, fmt.Errorf("memory: некорректный адрес %q, ожидается группа и команда чтения и адрес, например 0F01:007C", key)
+}
+
+// Байты в шестнадцатеричном виде, пробелы допускаются
+func parseHex(value string) ([]byte, error) {
+	return hex.DecodeString(strings.ReplaceAll(value, " ", ""))
+}
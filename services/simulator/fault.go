@@ -0,0 +1,57 @@
+package simulator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Вид неисправности линии или прибора
+type FaultKind string
+
+const (
+	FaultTruncate FaultKind = "truncate" // Ответ обрывается: передаётся Length байт, по умолчанию половина ответа
+	FaultChecksum FaultKind = "checksum" // Неверная контрольная сумма ответа
+	FaultEcho     FaultKind = "echo"     // Перед ответом повторяется запрос, как у преобразователей RS-485 без подавления эха
+	FaultDelay    FaultKind = "delay"    // Ответ передаётся с задержкой Delay
+	FaultEOF      FaultKind = "eof"      // Вместо ответа закрывается соединение
+)
+
+/**
+Неисправность, которая вносится в ответ симулятора
+*/
+type Fault struct {
+	Kind     FaultKind `json:"kind"`
+	Response int       `json:"response"` // Номер ответа с запуска симулятора, с 1. 0 - все ответы
+	Length   int       `json:"length"`   // Длина оборванного ответа
+	Delay    Duration  `json:"delay"`    // Задержка ответа, например "3s"
+}
+
+func (fault Fault) validate() error {
+	switch fault.Kind {
+	case FaultTruncate, FaultChecksum, FaultEcho, FaultDelay, FaultEOF:
+	default:
+		return fmt.Errorf("неизвестная неисправность %q, допустимы: truncate, checksum, echo, delay, eof", fault.Kind)
+	}
+	if fault.Response < 0 {
+		return fmt.Errorf("неисправность %s: номер ответа не может быть отрицательным", fault.Kind)
+	}
+	return nil
+}
+
+// Длительность, в JSON задаётся строкой, как у флага timeout: "500ms", "3s"
+type Duration time.Duration
+
+func (duration *Duration) UnmarshalJSON(b []byte) error {
+	var value string
+	if err := json.Unmarshal(b, &value); err != nil {
+		return errors.New("длительность задаётся строкой, например \"3s\"")
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*duration = Duration(parsed)
+	return nil
+}
@@ -0,0 +1,183 @@
+package simulator
+
+import (
+	"fmt"
+	"qBox/drivers/mbus"
+	"time"
+)
+
+/**
+Прибор M-Bus (EN 13757-2, 13757-3): СКМ-2, SKU-02-B и другие приборы с ответом RSP_UD.
+На SND_NKE и SND_UD прибор отвечает подтверждением E5h. На REQ_UD2 передаётся очередной ответ из Telegrams:
+после последнего снова первый, SND_NKE и сброс прикладного уровня (CI = 50h) возвращают к первому ответу.
+Прибор выбирается по вторичному адресу (CI = 52h) и отвечает по адресу FDh до снятия выбора.
+Запись даты и времени DIF 04h VIF 6Dh в ответе заменяется временем прибора, установка времени - SND_UD с CI = 51h.
+*/
+type MBusDevice struct {
+	Address      byte     // Первичный адрес
+	ID           uint32   // Идентификационный номер, 8 десятичных цифр
+	Manufacturer string   // Код производителя из трёх букв
+	Version      byte     // Версия
+	Medium       byte     // Среда
+	Telegrams    [][]byte // Записи данных переменной структуры (после заголовка прикладного уровня) каждого ответа
+	Clock        Clock
+
+	next     int
+	selected bool
+	access   byte
+}
+
+func (device *MBusDevice) Handle(buffer []byte) (int, []byte) {
+	switch buffer[0] {
+	case 0x10:
+		if len(buffer) < 5 {
+			return 0, nil
+		}
+		if buffer[4] != 0x16 || mbus.Checksum(buffer[1:3]) != buffer[3] {
+			return -1, nil
+		}
+		return 5, device.short(buffer[1], buffer[2])
+	case 0x68:
+		if len(buffer) < 4 {
+			return 0, nil
+		}
+		length := int(buffer[1])
+		if buffer[3] != 0x68 || buffer[1] != buffer[2] || length < 3 {
+			return -1, nil
+		}
+		if len(buffer) < length+6 {
+			return 0, nil
+		}
+		body := buffer[4 : 4+length]
+		if mbus.Checksum(body) != buffer[4+length] || buffer[5+length] != 0x16 {
+			return length + 6, nil
+		}
+		return length + 6, device.long(body[1], body[2], body[3:])
+	}
+	return -1, nil
+}
+
+func (device *MBusDevice) addressed(address byte) bool {
+	return address == device.Address || address == mbus.AddressBroadcast || (address == mbus.AddressNetwork && device.selected)
+}
+
+// Короткий кадр: SND_NKE или REQ_UD2
+func (device *MBusDevice) short(control byte, address byte) []byte {
+	if !device.addressed(address) {
+		return nil
+	}
+	switch control &^ (mbus.ControlFCB | 0x10) {
+	case mbus.ControlSndNke:
+		device.next = 0
+		if address == mbus.AddressNetwork {
+			device.selected = false
+		}
+		return []byte{mbus.Ack}
+	case mbus.ControlReqUD2 &^ 0x10:
+		return device.respond(address)
+	}
+	return nil
+}
+
+// Длинный кадр SND_UD
+func (device *MBusDevice) long(address byte, ci byte, data []byte) []byte {
+	if ci == mbus.CISelectAddress && address == mbus.AddressNetwork {
+		device.selected = len(data) >= 8 && device.matches(data)
+		if !device.selected {
+			return nil
+		}
+		return []byte{mbus.Ack}
+	}
+	if !device.addressed(address) {
+		return nil
+	}
+	switch ci {
+	case 0x50: // сброс прикладного уровня
+		device.next = 0
+	case mbus.CIDataSend:
+		if len(data) >= 6 && data[0] == 0x04 && data[1] == 0x6D {
+			// Формат F без секунд: время устанавливается на начало минуты, см. mbus.SetTime
+			device.Clock.Set(decodeTimeF(data[2:6]))
+		}
+	}
+	return []byte{mbus.Ack}
+}
+
+// Ответ RSP_UD с очередными записями
+func (device *MBusDevice) respond(address byte) []byte {
+	var records []byte
+	if len(device.Telegrams) > 0 {
+		records = append(records, device.Telegrams[device.next%len(device.Telegrams)]...)
+		device.next = (device.next + 1) % len(device.Telegrams)
+	}
+	device.patchTime(records)
+
+	device.access++
+	header := encodeBcdID(device.ID)
+	low, high := encodeManufacturer(device.Manufacturer)
+	header = append(header, low, high, device.Version, device.Medium, device.access, 0x00, 0x00, 0x00)
+	responseAddress := device.Address
+	if address == mbus.AddressNetwork {
+		responseAddress = mbus.AddressNetwork
+	}
+	return mbus.LongFrame(mbus.ControlRspUD, responseAddress, mbus.CIResponseLong, append(header, records...))
+}
+
+// Замена текущей даты и времени (DIF 04h VIF 6Dh, номер хранения 0) временем прибора
+func (device *MBusDevice) patchTime(records []byte) {
+	data, _ := mbus.Parse(records)
+	for _, record := range data.Records {
+		if record.DIF == 0x04 && record.VIF == 0x6D && record.Storage == 0 {
+			offset := record.Offset + len(record.Header())
+			copy(records[offset:offset+4], mbus.EncodeTimeF(device.Clock.Now()))
+		}
+	}
+}
+
+// Совпадение вторичного адреса с маской команды выбора. F в цифрах номера, FFFFh и FFh - любое значение
+func (device *MBusDevice) matches(mask []byte) bool {
+	id := fmt.Sprintf("%08d", device.ID)
+	for i := 0; i < 4; i++ {
+		digits := [2]byte{mask[i] >> 4, mask[i] & 0x0F}
+		for j, digit := range digits {
+			if digit != 0x0F && id[6-2*i+j] != '0'+digit {
+				return false
+			}
+		}
+	}
+	low, high := encodeManufacturer(device.Manufacturer)
+	if !(mask[4] == 0xFF && mask[5] == 0xFF) && (mask[4] != low || mask[5] != high) {
+		return false
+	}
+	return (mask[6] == 0xFF || mask[6] == device.Version) && (mask[7] == 0xFF || mask[7] == device.Medium)
+}
+
+func (device *MBusDevice) checksumOffset(response []byte) int {
+	if len(response) > 2 {
+		return len(response) - 2
+	}
+	return len(response) - 1
+}
+
+func encodeBcdID(id uint32) []byte {
+	digits := fmt.Sprintf("%08d", id%100000000)
+	data := make([]byte, 4)
+	for i := 0; i < 4; i++ {
+		data[i] = (digits[6-2*i]-'0')<<4 | (digits[7-2*i] - '0')
+	}
+	return data
+}
+
+func encodeManufacturer(code string) (byte, byte) {
+	if len(code) != 3 {
+		return 0, 0
+	}
+	value := uint16(code[0]-64)<<10 | uint16(code[1]-64)<<5 | uint16(code[2]-64)
+	return byte(value), byte(value >> 8)
+}
+
+// Дата и время в формате F, см. mbus.EncodeTimeF
+func decodeTimeF(data []byte) time.Time {
+	year := 2000 + (int(data[2]>>5) | int(data[3]&0xF0)>>1)
+	return time.Date(year, time.Month(data[3]&0x0F), int(data[2]&0x1F), int(data[1]&0x1F), int(data[0]&0x3F), 0, 0, time.Local)
+}
@@ -0,0 +1,42 @@
+package simulator
+
+import "time"
+
+/**
+Память прибора. Байты, которые не заданы, читаются как 0
+*/
+type Memory map[uint32]byte
+
+func (memory Memory) Read(address uint32, size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = memory[address+uint32(i)]
+	}
+	return data
+}
+
+func (memory Memory) Write(address uint32, data []byte) {
+	for i, b := range data {
+		memory[address+uint32(i)] = b
+	}
+}
+
+/**
+Часы прибора: время компьютера со смещением. Смещение задаёт расхождение часов прибора
+и изменяется командами установки времени.
+*/
+type Clock struct {
+	Offset time.Duration
+}
+
+func (clock Clock) Now() time.Time {
+	return time.Now().Add(clock.Offset)
+}
+
+func (clock *Clock) Set(moment time.Time) {
+	clock.Offset = time.Until(moment)
+}
+
+func bcd(value int) byte {
+	return byte(value/10%10<<4 | value%10)
+}
@@ -0,0 +1,87 @@
+package simulator
+
+import (
+	"github.com/npat-efault/crc16"
+	"time"
+)
+
+/**
+Прибор Modbus RTU (ИСТОК-ТМ3): чтение регистров функциями 03h и 04h, запись функциями 06h и 10h.
+Регистры, которые не заданы, читаются как 0. Если задан ClockRegister, два регистра с этого адреса
+содержат время прибора (unixtime, старшее слово первым), и запись в них устанавливает часы.
+*/
+type ModbusDevice struct {
+	Number        byte
+	Registers     map[uint16]uint16
+	ClockRegister uint16 // 0 - часов нет
+	Clock         Clock
+}
+
+func (device *ModbusDevice) Handle(buffer []byte) (int, []byte) {
+	if len(buffer) < 2 {
+		return 0, nil
+	}
+	var length int
+	switch buffer[1] {
+	case 0x03, 0x04, 0x06:
+		length = 8
+	case 0x10:
+		if len(buffer) < 7 {
+			return 0, nil
+		}
+		length = 9 + int(buffer[6])
+	default:
+		return -1, nil
+	}
+	if len(buffer) < length {
+		return 0, nil
+	}
+	request := buffer[:length]
+	if crc16.Checksum(crc16.Modbus, request[:length-2]) != uint16(request[length-2])|uint16(request[length-1])<<8 {
+		return -1, nil
+	}
+	if request[0] != device.Number {
+		return length, nil
+	}
+
+	address := uint16(request[2])<<8 | uint16(request[3])
+	var response []byte
+	switch request[1] {
+	case 0x03, 0x04:
+		count := int(request[4])<<8 | int(request[5])
+		response = []byte{request[0], request[1], byte(2 * count)}
+		for i := 0; i < count; i++ {
+			value := device.read(address + uint16(i))
+			response = append(response, byte(value>>8), byte(value))
+		}
+	case 0x06:
+		device.write(address, []byte{request[4], request[5]})
+		response = append([]byte(nil), request[:6]...)
+	case 0x10:
+		device.write(address, request[7:length-2])
+		response = append([]byte(nil), request[:6]...)
+	}
+	checksum := crc16.Checksum(crc16.Modbus, response)
+	return length, append(response, byte(checksum), byte(checksum>>8))
+}
+
+func (device *ModbusDevice) read(address uint16) uint16 {
+	if device.ClockRegister != 0 && (address == device.ClockRegister || address == device.ClockRegister+1) {
+		moment := uint32(device.Clock.Now().Unix())
+		if address == device.ClockRegister {
+			return uint16(moment >> 16)
+		}
+		return uint16(moment)
+	}
+	return device.Registers[address]
+}
+
+func (device *ModbusDevice) write(address uint16, data []byte) {
+	for i := 0; i+1 < len(data); i += 2 {
+		device.Registers[address+uint16(i/2)] = uint16(data[i])<<8 | uint16(data[i+1])
+	}
+	if device.ClockRegister != 0 && address == device.ClockRegister && len(data) >= 4 {
+		moment := uint32(data[0])<<24 | uint32(data[1])<<16 | uint32(data[2])<<8 | uint32(data[3])
+		device.Clock.Set(time.Unix(int64(moment), 0))
+	}
+}
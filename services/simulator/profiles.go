@@ -0,0 +1,307 @@
+package simulator
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+/**
+Профили приборов по умолчанию: содержимое памяти, регистров и ответов, с которым драйвер прибора
+читает данные без ошибок. Значения условные, но правдоподобные: одна система теплоснабжения,
+подача около 70 °C, обратка около 45 °C, расход около 1,25 м3/ч.
+Заводской номер 0 - номер по умолчанию.
+*/
+
+// ТЭМ-104: число систем и заводской номер в памяти 2К, текущие значения в оперативной памяти с 2200h,
+// интеграторы в памяти 2К с 0200h. Все значения - big endian, см. drivers.Tem104
+func NewTem104(number byte, serial uint32) *TemDevice {
+	if serial == 0 {
+		serial = 104001
+	}
+	memory2K, ram := Memory{}, Memory{}
+	memory2K[0x0000] = 1 // число систем
+	memory2K.putUint32(0x007C, serial, binary.BigEndian)
+
+	// Интеграторы: целая часть (long) и дробная часть (float)
+	integrators := []struct {
+		long     uint32
+		fraction uint32
+		value    float64
+	}{
+		{0x258, 0x228, 1234.567}, // Q системы 1
+		{0x238, 0x208, 45678.25}, // V1
+		{0x23C, 0x20C, 45012.5},  // V2
+		{0x248, 0x218, 44567.75}, // M1
+		{0x24C, 0x21C, 43901.125},
+	}
+	for _, integrator := range integrators {
+		whole := math.Floor(integrator.value)
+		memory2K.putUint32(integrator.long, uint32(whole), binary.BigEndian)
+		memory2K.putFloat32(integrator.fraction, float32(integrator.value-whole), binary.BigEndian)
+	}
+	memory2K.putUint32(0x268, 12345678, binary.BigEndian) // время включения
+	memory2K.putUint32(0x26C, 12300000, binary.BigEndian) // время работы системы 1
+
+	for address, value := range map[uint32]float32{
+		0x00: 72.5, 0x04: 48.25, 0x08: 5.5, // T1, T2, T3
+		0x10: 0.62, 0x14: 0.45, // P1, P2
+		0x40: 1.254, 0x44: 1.248, // GV1, GV2
+		0x50: 1.226, 0x54: 1.236, // GM1, GM2
+	} {
+		ram.putFloat32(0x2200+address, value, binary.BigEndian)
+	}
+
+	return &TemDevice{
+		Number:      number,
+		Identity:    []byte("TEM-104"),
+		Version:     []byte("1.00"),
+		Memory:      map[uint16]Memory{TemMemory2K: memory2K, TemMemoryRAM: ram},
+		ClockFormat: TemClockBCD,
+	}
+}
+
+// ТЭМ-104М: заводской номер и число систем в памяти 2К с 0000h, интеграторы с 0800h,
+// расходы в оперативной памяти с 0000h. Все значения - little endian, см. tem104m.TEM104M
+func NewTem104M(number byte, serial uint32) *TemDevice {
+	if serial == 0 {
+		serial = 1040001
+	}
+	memory2K, ram := Memory{}, Memory{}
+	memory2K.putUint32(0x0000, serial, binary.LittleEndian)
+	memory2K[0x0004] = 1 // число систем
+
+	const integrators = 0x0800
+	for _, integrator := range []struct {
+		long     uint32
+		fraction uint32
+		value    float64
+	}{
+		{0x28, 0x68, 1234.567}, // Q системы 1
+		{0x08, 0x48, 45678.25}, // V1
+		{0x0C, 0x4C, 45012.5},  // V2
+		{0x18, 0x58, 44567.75}, // M1
+		{0x1C, 0x5C, 43901.125},
+	} {
+		whole := math.Floor(integrator.value)
+		memory2K.putUint32(integrators+integrator.long, uint32(whole), binary.LittleEndian)
+		memory2K.putFloat32(integrators+integrator.fraction, float32(integrator.value-whole), binary.LittleEndian)
+	}
+	memory2K.putUint32(integrators+0x98, 12345678, binary.LittleEndian)             // время включения
+	memory2K.putUint32(integrators+0xA0, 12300000, binary.LittleEndian)             // время работы системы 1
+	for address, value := range map[uint32]uint16{284: 7250, 286: 4825, 288: 550} { // T1, T2, T3 в сотых °C
+		memory2K.putUint16(integrators+address, value, binary.LittleEndian)
+	}
+	memory2K[integrators+308], memory2K[integrators+309] = 62, 45 // P1, P2 в сотых МПа
+
+	for address, value := range map[uint32]float32{0x40: 1.254, 0x44: 1.248, 0x50: 1.226, 0x54: 1.236} {
+		ram.putFloat32(address, value, binary.LittleEndian)
+	}
+
+	return &TemDevice{
+		Number:      number,
+		Identity:    []byte("TEM-104M"),
+		Version:     []byte("1.00"),
+		Memory:      map[uint16]Memory{TemMemory2K: memory2K, TemMemoryRAM: ram},
+		ClockFormat: TemClockBinary,
+	}
+}
+
+// Записи текущих данных теплосчётчика M-Bus: время, энергия, объёмы, массы, расходы, температуры, давления
+func heatMeterRecords() []byte {
+	var records []byte
+	record := func(header []byte, value []byte) {
+		records = append(append(records, header...), value...)
+	}
+	record([]byte{0x04, 0x6D}, make([]byte, 4))                    // дата и время, подставляется время прибора
+	record([]byte{0x04, 0x07}, uint32LittleEndian(123456))         // энергия, 0,01 МВт*ч
+	record([]byte{0x04, 0x13}, uint32LittleEndian(45678250))       // V1, л
+	record([]byte{0x84, 0x40, 0x13}, uint32LittleEndian(45012500)) // V2, л
+	record([]byte{0x04, 0x1B}, uint32LittleEndian(44567750))       // M1, кг
+	record([]byte{0x84, 0x40, 0x1B}, uint32LittleEndian(43901125)) // M2, кг
+	record([]byte{0x05, 0x3E}, float32LittleEndian(1.254))         // GV1, м3/ч
+	record([]byte{0x85, 0x40, 0x3E}, float32LittleEndian(1.248))   // GV2, м3/ч
+	record([]byte{0x05, 0x56}, float32LittleEndian(1.226))         // GM1, т/ч
+	record([]byte{0x85, 0x40, 0x56}, float32LittleEndian(1.236))   // GM2, т/ч
+	record([]byte{0x02, 0x59}, []byte{0x52, 0x1C})                 // T1 72,50 °C
+	record([]byte{0x02, 0x5D}, []byte{0xD9, 0x12})                 // T2 48,25 °C
+	record([]byte{0x03, 0x68}, []byte{0x38, 0x18, 0x00})           // P1 0,62 МПа в 0,0001
+	record([]byte{0x83, 0x40, 0x68}, []byte{0x94, 0x11, 0x00})     // P2 0,45 МПа в 0,0001
+	record([]byte{0x04, 0x20}, uint32LittleEndian(12345678))       // время включения, с
+	record([]byte{0x04, 0x24}, uint32LittleEndian(12300000))       // время работы без ошибок, с
+	return records
+}
+
+// СКМ-2: ответ M-Bus с записями текущих данных, см. skm2.SKM
+func NewSKM2(address byte, id uint32) *MBusDevice {
+	if id == 0 {
+		id = 20001234
+	}
+	return &MBusDevice{
+		Address:      address,
+		ID:           id,
+		Manufacturer: "SKM", // условный код производителя
+		Version:      1,
+		Medium:       0x04, // тепло, обратный трубопровод
+		Telegrams:    [][]byte{heatMeterRecords()},
+	}
+}
+
+// SKU-02-B: ответ M-Bus с записями текущих данных, см. drivers.SKU02B
+func NewSKU02B(address byte, id uint32) *MBusDevice {
+	device := NewSKM2(address, id)
+	if id == 0 {
+		device.ID = 20020001
+	}
+	device.Manufacturer = "SKU" // условный код производителя
+	return device
+}
+
+// SKU-02: блок текущих данных команды 20h, значения big endian, см. drivers.SKU02
+func NewSKU02(serial uint32) *SKU02Device {
+	if serial == 0 {
+		serial = 202001
+	}
+	current := make([]byte, 80)
+	put := func(offset int, value []byte) {
+		copy(current[offset-sku02HeaderSize:], value)
+	}
+	put(30, uint32BigEndian(1234567))  // Q1, кВт*ч
+	put(34, uint32BigEndian(1000000))  // Q2, кВт*ч
+	put(38, uint32BigEndian(4567825))  // V1, 0,01 м3
+	put(42, uint32BigEndian(4501250))  // V2, 0,01 м3
+	put(46, uint32BigEndian(12345678)) // время включения
+	put(50, uint32BigEndian(234567))   // Q, кВт*ч
+	put(70, float32BigEndian(1.254))   // GV1
+	put(74, float32BigEndian(1.248))   // GV2
+	put(86, float32BigEndian(72.5))    // T1
+	put(90, float32BigEndian(48.25))   // T2
+	put(94, float32BigEndian(5.5))     // T3
+	put(98, float32BigEndian(0.62))    // P1, МПа
+	put(102, float32BigEndian(0.45))   // P2, МПа
+	return &SKU02Device{
+		Type:    0x0002,
+		Version: 2.1,
+		UnitQ:   0x00, // МВт*ч, размерность 1
+		UnitP:   0x01, // МПа
+		UnitV:   0x00, // м3
+		Serial:  serial,
+		Blocks:  map[byte][]byte{0x20: current},
+	}
+}
+
+// ИСТОК-ТМ3: заводской номер в EF04h-EF07h, число систем 0143h, единицы измерения ED00h-ED02h,
+// данные системы 1 с 7000h, время в EF50h-EF51h, время работы в EF57h-EF58h, см. drivers.TM3
+func NewTM3(number byte, serial uint32) *ModbusDevice {
+	if serial == 0 {
+		serial = 1612001
+	}
+	registers := map[uint16]uint16{
+		0x0143: 1, // число систем
+		0xED00: 3, // давление в МПа
+		0xED01: 1, // энергия в Гкал
+		0xED02: 0, // объём в м3, масса в т
+	}
+	// Номер ГГММППП: год и месяц выпуска в EF07h, номер в партии в EF05h
+	registers[0xEF05] = uint16(serial % 1000)
+	registers[0xEF07] = uint16(serial/100000%100)<<9 | uint16(serial/1000%100)<<5 | 1
+
+	put := func(address uint16, value []byte) {
+		for i := 0; i+1 < len(value); i += 2 {
+			registers[address+uint16(i/2)] = uint16(value[i])<<8 | uint16(value[i+1])
+		}
+	}
+	const system = 0x7000
+	put(system+0, float64BigEndian(1234567000))  // Q, Гкал * 10^6
+	put(system+4, float64BigEndian(2345678000))  // Q1
+	put(system+8, float64BigEndian(44567750))    // M1, кг
+	put(system+12, float32BigEndian(1226))       // GM1, кг/ч
+	put(system+14, float32BigEndian(1.254))      // GV1
+	put(system+16, float32BigEndian(72.5))       // T1
+	put(system+18, float32BigEndian(0.62))       // P1
+	put(system+20, float64BigEndian(1111111000)) // Q2
+	put(system+24, float64BigEndian(43901125))   // M2
+	put(system+28, float32BigEndian(1236))       // GM2
+	put(system+30, float32BigEndian(1.248))      // GV2
+	put(system+32, float32BigEndian(48.25))      // T2
+	put(system+34, float32BigEndian(0.45))       // P2
+	put(system+40, float64BigEndian(2750000))    // M3, т * 10^6
+	put(system+44, float32BigEndian(0.35))       // GM3
+	put(system+46, float32BigEndian(0.351))      // GV3
+	put(system+48, float32BigEndian(8.5))        // TMakeup
+	put(system+50, float32BigEndian(0.3))        // PMakeup
+	put(system+52, float32BigEndian(5.5))        // T3
+	put(system+56, uint32BigEndian(12300000))    // время работы системы
+	put(0xEF57, uint32BigEndian(12345678))       // время включения
+
+	return &ModbusDevice{Number: number, Registers: registers, ClockRegister: 0xEF50}
+}
+
+// ТЭМ-05: блок текущих данных, см. drivers.TEM05OLD
+func NewTem05(serial uint32) *Tem05Device {
+	if serial == 0 {
+		serial = 5001
+	}
+	memory := Memory{}
+	memory[14], memory[15] = byte(serial), byte(serial>>8)
+	memory[16] = 12                            // версия ПО
+	memory[18], memory[19] = 8, 8              // код диаметра: размерность 10
+	memory[24] = 30                            // минуты наработки
+	memory.Write(25, []byte{0x11, 0x0D, 0x00}) // часы наработки 3345, little endian
+	put := func(address uint32, value uint32) {
+		memory.Write(address, []byte{byte(value), byte(value >> 8), byte(value >> 16)})
+	}
+	put(32, 1254)                                                                   // GV1, 0,001 м3/ч
+	put(38, 123456)                                                                 // Q1, 0,01 МВт*ч
+	put(41, 456782)                                                                 // V1, 0,1 м3
+	put(44, 445677)                                                                 // M1, 0,1 т
+	put(47, 1248)                                                                   // GV2
+	put(53, 100000)                                                                 // Q2
+	put(56, 450125)                                                                 // V2
+	put(59, 439011)                                                                 // M2
+	for address, value := range map[uint32]uint16{206: 7250, 208: 4825, 210: 550} { // T1, T2, T3 в сотых °C
+		memory.putUint16(address, value, binary.LittleEndian)
+	}
+	return &Tem05Device{Memory: memory}
+}
+
+func (memory Memory) putUint16(address uint32, value uint16, order binary.ByteOrder) {
+	data := make([]byte, 2)
+	order.PutUint16(data, value)
+	memory.Write(address, data)
+}
+
+func (memory Memory) putUint32(address uint32, value uint32, order binary.ByteOrder) {
+	data := make([]byte, 4)
+	order.PutUint32(data, value)
+	memory.Write(address, data)
+}
+
+func (memory Memory) putFloat32(address uint32, value float32, order binary.ByteOrder) {
+	memory.putUint32(address, math.Float32bits(value), order)
+}
+
+func uint32BigEndian(value uint32) []byte {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, value)
+	return data
+}
+
+func uint32LittleEndian(value uint32) []byte {
+	data := make([]byte, 4)
+	binary.LittleEndian.PutUint32(data, value)
+	return data
+}
+
+func float32BigEndian(value float32) []byte {
+	return uint32BigEndian(math.Float32bits(value))
+}
+
+func float32LittleEndian(value float32) []byte {
+	return uint32LittleEndian(math.Float32bits(value))
+}
+
+func float64BigEndian(value float64) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, math.Float64bits(value))
+	return data
+}
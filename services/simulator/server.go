@@ -0,0 +1,186 @@
+package simulator
+
+import (
+	"errors"
+	"io"
+	"net"
+	"qBox/services/log"
+	"sync"
+	"time"
+)
+
+/**
+Прибор на шине симулятора
+*/
+type Device interface {
+	/**
+	Разбор запроса в начале buffer.
+	n > 0 - длина разобранного запроса, response - ответ прибора, nil - прибор не отвечает (запрос другому прибору
+	или запрос с ошибкой). n == 0 - запрос получен не полностью, n < 0 - запрос не относится к протоколу прибора.
+	*/
+	Handle(buffer []byte) (n int, response []byte)
+}
+
+// Прибор, контрольная сумма ответа которого находится не в последнем байте, см. FaultChecksum
+type checksumLocator interface {
+	checksumOffset(response []byte) int
+}
+
+/**
+Симулятор шлюза: принимает TCP соединения и передаёт запросы приборам, подключённым к одной шине.
+Соединения обслуживаются параллельно, но запросы к приборам выполняются по одному, как на линии RS-485.
+*/
+type Server struct {
+	Devices []Device
+	Faults  []Fault
+	Logger  *log.LoggerService // nil - обмен не записывается в лог
+
+	mutex     sync.Mutex
+	responses int // Количество ответов с запуска, нумерация для Fault.Response
+}
+
+// Приём соединений до закрытия listener
+func (server *Server) Serve(listener net.Listener) error {
+	for {
+		connection, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		server.info("Подключение %s", connection.RemoteAddr().String())
+		go server.serveConnection(connection)
+	}
+}
+
+func (server *Server) ListenAndServe(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	server.info("Ожидание подключений на %s", listener.Addr().String())
+	return server.Serve(listener)
+}
+
+func (server *Server) serveConnection(connection net.Conn) {
+	defer connection.Close()
+	var buffer []byte
+	chunk := make([]byte, 1200)
+	for {
+		n, err := connection.Read(chunk)
+		buffer = append(buffer, chunk[:n]...)
+		for len(buffer) > 0 {
+			length, response, fault := server.handle(buffer)
+			if length == 0 {
+				break // запрос получен не полностью
+			}
+			request := buffer[:length]
+			buffer = buffer[length:]
+			if response == nil {
+				continue
+			}
+			if !server.send(connection, request, response, fault) {
+				return
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				server.info("Соединение %s: %s", connection.RemoteAddr().String(), err.Error())
+			}
+			return
+		}
+	}
+}
+
+// Разбор запроса приборами шины. Байт, который не относится ни к одному протоколу, пропускается
+func (server *Server) handle(buffer []byte) (int, []byte, *Fault) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	length, incomplete := 0, false
+	var response []byte
+	var responder Device
+	for _, device := range server.Devices {
+		n, deviceResponse := device.Handle(buffer)
+		if n == 0 {
+			incomplete = true
+		}
+		if n <= 0 || (length > 0 && n != length) {
+			continue
+		}
+		length = n
+		if response == nil && deviceResponse != nil {
+			response, responder = deviceResponse, device
+		}
+	}
+	if length == 0 {
+		if incomplete {
+			return 0, nil, nil
+		}
+		server.info("Пропущен байт %X", buffer[0])
+		return 1, nil, nil
+	}
+
+	server.info("Запрос %X", buffer[:length])
+	if response == nil {
+		return length, nil, nil
+	}
+	server.responses++
+	fault := server.fault(server.responses)
+	if fault != nil && fault.Kind == FaultChecksum {
+		response = corrupt(responder, response)
+	}
+	return length, response, fault
+}
+
+// Отправка ответа с учётом неисправности. false - соединение нужно закрыть
+func (server *Server) send(connection net.Conn, request []byte, response []byte, fault *Fault) bool {
+	if fault != nil {
+		server.info("Неисправность %s", fault.Kind)
+		switch fault.Kind {
+		case FaultEOF:
+			return false
+		case FaultDelay:
+			time.Sleep(time.Duration(fault.Delay))
+		case FaultEcho:
+			response = append(append([]byte(nil), request...), response...)
+		case FaultTruncate:
+			length := fault.Length
+			if length <= 0 || length >= len(response) {
+				length = len(response) / 2
+			}
+			response = response[:length]
+		}
+	}
+	server.info("Ответ %X", response)
+	_, err := connection.Write(response)
+	return err == nil
+}
+
+func (server *Server) fault(response int) *Fault {
+	for i := range server.Faults {
+		if server.Faults[i].Response == 0 || server.Faults[i].Response == response {
+			return &server.Faults[i]
+		}
+	}
+	return nil
+}
+
+// Ответ с неверной контрольной суммой
+func corrupt(device Device, response []byte) []byte {
+	response = append([]byte(nil), response...)
+	offset := len(response) - 1
+	if locator, ok := device.(checksumLocator); ok {
+		offset = locator.checksumOffset(response)
+	}
+	response[offset] ^= 0xFF
+	return response
+}
+
+func (server *Server) info(format string, a ...interface{}) {
+	if server.Logger != nil {
+		server.Logger.Info(format, a...)
+	}
+}
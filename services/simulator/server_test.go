@@ -0,0 +1,126 @@
+package simulator
+
+import (
+	"bytes"
+	"context"
+	"math"
+	stdnet "net"
+	"qBox/drivers"
+	_ "qBox/drivers/skm2"
+	_ "qBox/drivers/tem104m"
+	"qBox/services/log"
+	"qBox/services/net"
+	"testing"
+	"time"
+
+	ozzolog "github.com/go-ozzo/ozzo-log"
+)
+
+// Запуск симулятора на свободном порту и клиент net.Network, подключённый к нему
+func startServer(t *testing.T, server *Server) (*net.Network, *recorder) {
+	listener, err := stdnet.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(func() { _ = listener.Close() })
+
+	transport, err := net.NewTransport(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := &recorder{Transport: transport}
+	network := net.NewNetwork(received, log.NewLoggerService(ozzolog.NewLogger()))
+	t.Cleanup(func() { _ = network.Close() })
+	return network, received
+}
+
+// Транспорт, который запоминает все полученные байты: Network удаляет эхо запроса из ответа
+type recorder struct {
+	net.Transport
+	received []byte
+}
+
+func (recorder *recorder) Read(b []byte) (int, error) {
+	n, err := recorder.Transport.Read(b)
+	recorder.received = append(recorder.received, b[:n]...)
+	return n, err
+}
+
+// Профиль каждого типа прибора читается своим драйвером без ошибок
+func TestProfiles(t *testing.T) {
+	serials := map[string]string{
+		"tem104": "104001", "tem104m": "1040001", "skm2": "20001234", "sku02": "202001",
+		"sku02b": "20020001", "tm3": "1612001", "tem05": "5001",
+	}
+	for _, kind := range Types {
+		number := byte(1)
+		device, err := DeviceConfig{Type: kind, Number: &number}.Device()
+		if err != nil {
+			t.Fatalf("%s: %v", kind, err)
+		}
+		network, _ := startServer(t, &Server{Devices: []Device{device}})
+		logger := log.NewLoggerService(ozzolog.NewLogger())
+
+		driver, err := drivers.New(kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = driver.Init(context.Background(), number, network, &logger); err != nil {
+			t.Errorf("%s: инициализация: %v", kind, err)
+			continue
+		}
+		data, err := driver.Read(context.Background())
+		if err != nil {
+			t.Errorf("%s: чтение: %v", kind, err)
+			continue
+		}
+		if data.Serial != serials[kind] || len(data.Systems) == 0 || math.Abs(float64(data.Systems[0].T1)-72.5) > 0.01 {
+			t.Errorf("%s: заводской номер %s, систем %d, данные %+v", kind, data.Serial, len(data.Systems), data.Systems)
+		}
+	}
+}
+
+// Неисправности первого ответа: клиент получает ответ в том виде, в каком его передал симулятор
+func TestFaults(t *testing.T) {
+	request := []byte{0x55, 0x01, 0xFE, 0x00, 0x00, 0x00, 0xAB}
+	expected := append([]byte{0xAA, 0x01, 0xFE, 0x00, 0x00, 0x07}, "TEM-104"...)
+	expected = append(expected, temChecksum(expected))
+	complete := func(response []byte) bool { return len(response) >= len(expected) }
+
+	for _, test := range []struct {
+		fault    Fault
+		response []byte // nil - ответ не ожидается, соединение закрыто
+		received []byte // Байты, полученные транспортом
+		delay    time.Duration
+	}{
+		{fault: Fault{Kind: FaultTruncate, Length: 5}, response: expected[:5], received: expected[:5]},
+		{fault: Fault{Kind: FaultTruncate}, response: expected[:7], received: expected[:7]},
+		{fault: Fault{Kind: FaultChecksum}, response: corrupt(&TemDevice{}, expected), received: corrupt(&TemDevice{}, expected)},
+		{fault: Fault{Kind: FaultEcho}, response: expected, received: append(append([]byte(nil), request...), expected...)},
+		{fault: Fault{Kind: FaultDelay, Delay: Duration(300 * time.Millisecond)}, response: expected, received: expected, delay: 300 * time.Millisecond},
+		{fault: Fault{Kind: FaultEOF}},
+	} {
+		test.fault.Response = 1
+		network, transport := startServer(t, &Server{Devices: []Device{NewTem104(1, 0)}, Faults: []Fault{test.fault}})
+
+		start := time.Now()
+		response, err := network.Exchange(context.Background(), request, 500*time.Millisecond, complete)
+		elapsed := time.Since(start)
+		if test.response == nil {
+			if err == nil {
+				t.Errorf("%s: ответ %X, ожидалось закрытие соединения", test.fault.Kind, response)
+			}
+		} else if err != nil || !bytes.Equal(response, test.response) || !bytes.Equal(transport.received, test.received) || elapsed < test.delay {
+			t.Errorf("%s: ответ %X (%v), получено %X за %s", test.fault.Kind, response, err, transport.received, elapsed)
+		}
+
+		// Неисправность относится только к первому ответу
+		_ = network.Close()
+		transport.received = nil
+		response, err = network.Exchange(context.Background(), request, 500*time.Millisecond, complete)
+		if err != nil || !bytes.Equal(response, expected) {
+			t.Errorf("%s: второй ответ %X (%v)", test.fault.Kind, response, err)
+		}
+	}
+}
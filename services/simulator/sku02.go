@@ -0,0 +1,95 @@
+package simulator
+
+/**
+SKU-02 с собственным протоколом: запрос и ответ 68h L L 68h с заголовком из 30 байт
+(тип прибора, версия, единицы измерения, заводской номер, команда), данными и тремя контрольными суммами.
+На команду 28h прибор передаёт время (год - слово, месяц, день, часы, минуты, секунды),
+на остальные команды - данные из Blocks.
+*/
+type SKU02Device struct {
+	Type         uint16          // Тип прибора, SKU-02 - 0002h
+	Version      float32         // Версия ПО
+	Modification byte            // Модификация
+	UnitQ        byte            // Индекс единиц энергии: 0 - МВт*ч, 1 - Гкал, 2 - ГДж
+	UnitP        byte            // Индекс единиц давления
+	UnitV        byte            // Индекс единиц объёма или массы
+	Serial       uint32          // Заводской номер
+	Blocks       map[byte][]byte // Данные ответа (после заголовка) по команде
+	Clock        Clock
+}
+
+const sku02HeaderSize = 30
+
+func (device *SKU02Device) Handle(buffer []byte) (int, []byte) {
+	if buffer[0] != 0x68 {
+		return -1, nil
+	}
+	if len(buffer) < 4 {
+		return 0, nil
+	}
+	length := int(buffer[1])<<8 | int(buffer[2])
+	if buffer[3] != 0x68 || length < sku02HeaderSize+4 {
+		return -1, nil
+	}
+	if len(buffer) < length {
+		return 0, nil
+	}
+	request := buffer[:length]
+	if request[length-1] != 0x16 || !equal(sku02Checksums(request[:length-4]), request[length-4:length-1]) {
+		return -1, nil
+	}
+
+	command := request[29]
+	var data []byte
+	if command == 0x28 {
+		now := device.Clock.Now()
+		data = []byte{byte(now.Year() >> 8), byte(now.Year()), byte(now.Month()), byte(now.Day()),
+			byte(now.Hour()), byte(now.Minute()), byte(now.Second())}
+	} else {
+		var ok bool
+		if data, ok = device.Blocks[command]; !ok {
+			return length, nil
+		}
+	}
+
+	now := device.Clock.Now()
+	response := make([]byte, sku02HeaderSize, sku02HeaderSize+len(data)+4)
+	size := sku02HeaderSize + len(data) + 4
+	response[0], response[1], response[2], response[3] = 0x68, byte(size>>8), byte(size), 0x68
+	response[4], response[5] = byte(device.Type>>8), byte(device.Type)
+	copy(response[6:10], float32BigEndian(device.Version))
+	response[17], response[18], response[19], response[20] = device.Modification, device.UnitQ, device.UnitP, device.UnitV
+	response[21], response[22], response[23], response[24] = byte(device.Serial>>24), byte(device.Serial>>16), byte(device.Serial>>8), byte(device.Serial)
+	response[25], response[26], response[27], response[28] = byte(now.Year()%100), byte(now.Month()), byte(now.Day()), byte(now.Hour())
+	response[29] = command
+	response = append(response, data...)
+	response = append(response, sku02Checksums(response)...)
+	return length, append(response, 0x16)
+}
+
+func (device *SKU02Device) checksumOffset(response []byte) int {
+	return len(response) - 4
+}
+
+// Контрольные суммы SKU-02: исключающее ИЛИ, инверсия суммы и сумма с удвоенным исключающим ИЛИ
+func sku02Checksums(data []byte) []byte {
+	var xor, sum byte
+	for _, b := range data {
+		xor ^= b
+		sum += b
+	}
+	sum += xor * 2
+	return []byte{xor, sum ^ 0xFF, sum}
+}
+
+func equal(a []byte, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
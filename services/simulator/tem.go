@@ -0,0 +1,128 @@
+package simulator
+
+import (
+	"time"
+)
+
+// Области памяти приборов ТЭМ: группа и команда чтения
+const (
+	TemMemory2K    uint16 = 0x0F01 // Память таймера 2К
+	TemMemoryRTC   uint16 = 0x0F02 // Часы реального времени
+	TemMemoryFlash uint16 = 0x0F03 // Flash память архивов
+	TemMemoryRAM   uint16 = 0x0C01 // Оперативная память текущих значений
+)
+
+// Формат часов реального времени прибора ТЭМ
+type TemClockFormat byte
+
+const (
+	TemClockBCD    TemClockFormat = iota // ТЭМ-104: секунды, минуты, часы, день, месяц, год в BCD с адреса 10h
+	TemClockBinary                       // ТЭМ-104М: те же значения двоичными числами с адреса 00h, установка командой 0182h
+)
+
+/**
+Прибор с протоколом ТЭМ (ТЭМ-104, ТЭМ-104М и др.): запросы 55h N ~N группа команда длина данные КС.
+Команды чтения памяти (см. TemMemory2K и др.) передают адрес и размер блока:
+[адрес, размер] - 2 байта, [адрес старший, младший, размер] - 3 байта, [размер, адрес 4 байта] - 5 байт.
+Часы прибора подставляются в область TemMemoryRTC при каждом чтении.
+*/
+type TemDevice struct {
+	Number      byte
+	Identity    []byte            // Ответ на команду идентификации 0000h, nil - прибор команду не поддерживает
+	Version     []byte            // Ответ на команду версии ПО 0001h, nil - прибор команду не поддерживает
+	Memory      map[uint16]Memory // Области памяти по группе и команде чтения
+	ClockFormat TemClockFormat
+	Clock       Clock
+}
+
+func (device *TemDevice) Handle(buffer []byte) (int, []byte) {
+	if buffer[0] != 0x55 {
+		return -1, nil
+	}
+	if len(buffer) < 6 || len(buffer) < 6+int(buffer[5])+1 {
+		return 0, nil
+	}
+	length := 6 + int(buffer[5]) + 1
+	if temChecksum(buffer[:length-1]) != buffer[length-1] {
+		return length, nil
+	}
+	if buffer[1] != device.Number || buffer[2] != ^device.Number {
+		return length, nil
+	}
+
+	command := uint16(buffer[3])<<8 | uint16(buffer[4])
+	payload := buffer[6 : length-1]
+	var data []byte
+	switch {
+	case command == 0x0000:
+		data = device.Identity
+	case command == 0x0001:
+		data = device.Version
+	case command == 0x0182 && len(payload) >= 7:
+		device.writeClock(payload[1:])
+		data = []byte{}
+	default:
+		memory, ok := device.Memory[command]
+		if !ok && command != TemMemoryRTC {
+			return length, nil
+		}
+		var address uint32
+		var size int
+		switch len(payload) {
+		case 2:
+			address, size = uint32(payload[0]), int(payload[1])
+		case 3:
+			address, size = uint32(payload[0])<<8|uint32(payload[1]), int(payload[2])
+		case 5:
+			size = int(payload[0])
+			address = uint32(payload[1])<<24 | uint32(payload[2])<<16 | uint32(payload[3])<<8 | uint32(payload[4])
+		default:
+			return length, nil
+		}
+		if command == TemMemoryRTC {
+			memory = device.rtc(memory)
+		}
+		data = memory.Read(address, size)
+	}
+	if data == nil {
+		return length, nil
+	}
+
+	response := append([]byte{0xAA, device.Number, ^device.Number, buffer[3], buffer[4], byte(len(data))}, data...)
+	return length, append(response, temChecksum(response))
+}
+
+// Область часов с текущим временем прибора
+func (device *TemDevice) rtc(memory Memory) Memory {
+	rtc := Memory{}
+	for address, b := range memory {
+		rtc[address] = b
+	}
+	now := device.Clock.Now()
+	values := []int{now.Second(), now.Minute(), now.Hour(), now.Day(), int(now.Month()), now.Year() % 100}
+	if device.ClockFormat == TemClockBCD {
+		for i, value := range values {
+			rtc[0x10+uint32(i)] = bcd(value)
+		}
+	} else {
+		for i, value := range values {
+			rtc[uint32(i)] = byte(value)
+		}
+		rtc[6] = byte(now.Weekday())
+	}
+	return rtc
+}
+
+// Установка часов командой 0182h: секунды, минуты, часы, день, месяц, год - 2000
+func (device *TemDevice) writeClock(values []byte) {
+	device.Clock.Set(time.Date(2000+int(values[5]), time.Month(values[4]), int(values[3]),
+		int(values[2]), int(values[1]), int(values[0]), 0, time.Local))
+}
+
+func temChecksum(bytes []byte) byte {
+	var sum byte
+	for _, b := range bytes {
+		sum += b
+	}
+	return ^sum
+}
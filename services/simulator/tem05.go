@@ -0,0 +1,31 @@
+package simulator
+
+// Размер блока текущих данных ТЭМ-05, см. drivers.TEM05OLD.checkResponse
+const Tem05BlockSize = 346
+
+/**
+ТЭМ-05: на запрос 33h 81h 7Eh 32h (кнопка "Интерф. адаптер") прибор передаёт блок текущих данных Tem05BlockSize байт.
+Время прибора подставляется в блок в BCD: минуты - байт 2, часы - 4, день - 7, месяц - 8, год - 9.
+*/
+type Tem05Device struct {
+	Memory Memory
+	Clock  Clock
+}
+
+var tem05Request = []byte{0x33, 0x81, 0x7E, 0x32}
+
+func (device *Tem05Device) Handle(buffer []byte) (int, []byte) {
+	for i := 0; i < len(tem05Request); i++ {
+		if i == len(buffer) {
+			return 0, nil
+		}
+		if buffer[i] != tem05Request[i] {
+			return -1, nil
+		}
+	}
+	response := device.Memory.Read(0, Tem05BlockSize)
+	now := device.Clock.Now()
+	response[2], response[4] = bcd(now.Minute()), bcd(now.Hour())
+	response[7], response[8], response[9] = bcd(now.Day()), bcd(int(now.Month())), bcd(now.Year()%100)
+	return len(tem05Request), response
+}